@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math/rand"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/jessevdk/go-flags"
+)
+
+const (
+	// anonymizeCommand is the first argument which switches app into rewriting of feed with fake data
+	anonymizeCommand = "anonymize"
+	// anonymizedHost is host of fake urls
+	anonymizedHost = "https://example.com/"
+)
+
+// anonymizedWords are words of fake texts
+var anonymizedWords = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do", "eiusmod", "tempor",
+	"incididunt", "ut", "labore", "et", "dolore", "magna", "aliqua", "enim", "ad", "minim", "veniam", "quis", "nostrud",
+}
+
+// anonymizedElements are elements of items which values are replaced, all other elements are copied as they are
+var anonymizedElements = map[string]func(a anonymizer, value string) string{
+	"URL":                anonymizer.url,
+	"IMGURL":             anonymizer.url,
+	"IMGURL_ALTERNATIVE": anonymizer.url,
+	"VIDEO_URL":          anonymizer.url,
+	"PRODUCTNAME":        anonymizer.text,
+	"PRODUCT":            anonymizer.text,
+	"DESCRIPTION":        anonymizer.text,
+	"MANUFACTURER":       anonymizer.text,
+}
+
+// anonymizeOptions define which feed is anonymized and where it is written
+type anonymizeOptions struct {
+	feed   *url.URL
+	output string
+	seed   string
+}
+
+// anonymizer replaces values by fake data. The same value is always replaced by the same fake data for the same seed,
+// so items sharing e.g. manufacturer still share it after anonymization.
+type anonymizer struct {
+	seed string
+}
+
+// parseAnonymizeArgs parses flags of anonymize command
+func parseAnonymizeArgs(args []string) (anonymizeOptions, error) {
+	var opts struct {
+		Feed   string `short:"f" long:"feedUrl" description:"Anonymized feed: 'http(s)://' or 'file://' url" required:"true"`
+		Output string `short:"o" long:"output" description:"File which anonymized feed is written into, '-' is stdout" default:"-"`
+		Seed   string `long:"seed" description:"Secret mixed into fake data, so original values could not be guessed by anonymizing known values"`
+	}
+	parser := flags.NewParser(&opts, flags.PassDoubleDash|flags.IgnoreUnknown)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		return anonymizeOptions{}, fmt.Errorf("Unable to parse flags: %w", err)
+	}
+	feed, err := url.Parse(strings.TrimSpace(opts.Feed))
+	if err != nil {
+		return anonymizeOptions{}, fmt.Errorf("Unable to parse feed url '%s' because of %w", opts.Feed, err)
+	}
+	if !feed.IsAbs() {
+		return anonymizeOptions{}, fmt.Errorf("Feed url '%s' should be absolute", opts.Feed)
+	}
+	if opts.Output == "" {
+		return anonymizeOptions{}, fmt.Errorf("Output should not be empty, use '%s' for stdout", convertStdout)
+	}
+	return anonymizeOptions{feed: feed, output: opts.Output, seed: opts.Seed}, nil
+}
+
+// anonymizeRun writes anonymized feed into output
+func anonymizeRun(args []string) error {
+	opts, err := parseAnonymizeArgs(args)
+	if err != nil {
+		return &apperror.ConfigError{Err: err}
+	}
+	stream, err := provider.CreateStream(context.Background(), opts.feed)
+	if err != nil {
+		return fmt.Errorf("Failed to get stream: %w", err)
+	}
+	defer stream.Close()
+	var out io.Writer = os.Stdout
+	if opts.output != convertStdout {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			return fmt.Errorf("Unable to create file `%s` because of %w", opts.output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+	items, err := anonymizeFeed(stream, bw, anonymizer{seed: opts.seed})
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("Unable to write anonymized feed because of %w", err)
+	}
+	log.Printf("%d items of feed '%s' were anonymized", items, opts.feed)
+	return nil
+}
+
+// anonymizeFeed copies feed token by token and replaces values of anonymizedElements of items, so structure of feed,
+// ids, prices and elements unknown to feeddo are preserved. Comments are dropped as they could contain anything.
+// Number of items is returned.
+func anonymizeFeed(r io.Reader, w io.Writer, a anonymizer) (int, error) {
+	d := xml.NewDecoder(r)
+	enc := xml.NewEncoder(w)
+	items := 0
+	inItem := false
+	// replace is set while value of anonymized element is read
+	var replace func(a anonymizer, value string) string
+	var value strings.Builder
+	for {
+		token, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return items, fmt.Errorf("Failed to read node element after %d items: %w", items, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "SHOPITEM" {
+				inItem = true
+				items++
+			} else if inItem && replace == nil {
+				replace = anonymizedElements[t.Name.Local]
+				value.Reset()
+			}
+		case xml.EndElement:
+			if t.Name.Local == "SHOPITEM" {
+				inItem = false
+			} else if replace != nil {
+				if err := enc.EncodeToken(xml.CharData(replace(a, value.String()))); err != nil {
+					return items, fmt.Errorf("Unable to write item %d because of %w", items, err)
+				}
+				replace = nil
+			}
+		case xml.CharData:
+			if replace != nil {
+				value.Write(t)
+				continue
+			}
+			// encoder escapes tabs and new lines, so indentation is written as it is
+			if len(bytes.TrimSpace(t)) == 0 {
+				if err := enc.Flush(); err != nil {
+					return items, fmt.Errorf("Unable to write anonymized feed because of %w", err)
+				}
+				if _, err := w.Write(t); err != nil {
+					return items, fmt.Errorf("Unable to write anonymized feed because of %w", err)
+				}
+				continue
+			}
+		case xml.Comment:
+			continue
+		}
+		if err := enc.EncodeToken(token); err != nil {
+			return items, fmt.Errorf("Unable to write item %d because of %w", items, err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return items, fmt.Errorf("Unable to write anonymized feed because of %w", err)
+	}
+	return items, nil
+}
+
+// hash returns hash of value mixed with seed
+func (a anonymizer) hash(value string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(a.seed))
+	h.Write([]byte{0})
+	h.Write([]byte(value))
+	return h.Sum64()
+}
+
+// url replaces url by url of example host, extension of path is kept, so e.g. images still look like images
+func (a anonymizer) url(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	ext := ""
+	if u, err := url.Parse(value); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	return fmt.Sprintf("%s%016x%s", anonymizedHost, a.hash(value), ext)
+}
+
+// text replaces text by words of the same total length, so sizes of items stay similar
+func (a anonymizer) text(value string) string {
+	value = strings.TrimSpace(value)
+	length := utf8.RuneCountInString(value)
+	if length == 0 {
+		return ""
+	}
+	rnd := rand.New(rand.NewSource(int64(a.hash(value))))
+	var b strings.Builder
+	for b.Len() < length {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(anonymizedWords[rnd.Intn(len(anonymizedWords))])
+	}
+	return b.String()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const anonymizeItems = `<?xml version="1.0" encoding="UTF-8"?>
+<SHOP>
+	<!-- supplier: ACME s.r.o. -->
+	<SHOPITEM>
+		<ITEM_ID>1</ITEM_ID>
+		<PRODUCTNAME><![CDATA[Epson T061240]]></PRODUCTNAME>
+		<URL>http://www.czc.cz/34644/produkt</URL>
+		<IMGURL>https://iczc.cz/1/obrazek.jpg</IMGURL>
+		<PRICE_VAT>269</PRICE_VAT>
+		<MANUFACTURER>Epson</MANUFACTURER>
+		<CUSTOM_LABEL>kept</CUSTOM_LABEL>
+	</SHOPITEM>
+	<SHOPITEM>
+		<ITEM_ID>2</ITEM_ID>
+		<PRODUCTNAME></PRODUCTNAME>
+		<PRICE_VAT>100</PRICE_VAT>
+		<MANUFACTURER>Epson</MANUFACTURER>
+	</SHOPITEM>
+</SHOP>`
+
+func TestAnonymizeFeed(t *testing.T) {
+	var out bytes.Buffer
+	items, err := anonymizeFeed(strings.NewReader(anonymizeItems), &out, anonymizer{seed: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, items)
+	a := anonymizer{seed: "secret"}
+	manufacturer := a.text("Epson")
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<SHOP>
+	
+	<SHOPITEM>
+		<ITEM_ID>1</ITEM_ID>
+		<PRODUCTNAME>`+a.text("Epson T061240")+`</PRODUCTNAME>
+		<URL>`+a.url("http://www.czc.cz/34644/produkt")+`</URL>
+		<IMGURL>`+a.url("https://iczc.cz/1/obrazek.jpg")+`</IMGURL>
+		<PRICE_VAT>269</PRICE_VAT>
+		<MANUFACTURER>`+manufacturer+`</MANUFACTURER>
+		<CUSTOM_LABEL>kept</CUSTOM_LABEL>
+	</SHOPITEM>
+	<SHOPITEM>
+		<ITEM_ID>2</ITEM_ID>
+		<PRODUCTNAME></PRODUCTNAME>
+		<PRICE_VAT>100</PRICE_VAT>
+		<MANUFACTURER>`+manufacturer+`</MANUFACTURER>
+	</SHOPITEM>
+</SHOP>`, out.String())
+	assert.NotContains(t, out.String(), "Epson")
+	assert.NotContains(t, out.String(), "czc.cz")
+	assert.NotContains(t, out.String(), "ACME")
+
+	_, err = anonymizeFeed(strings.NewReader("<SHOP><SHOPITEM></SHOP>"), &out, a)
+	assert.Error(t, err)
+}
+
+func TestAnonymizer(t *testing.T) {
+	a := anonymizer{seed: "secret"}
+	assert.Equal(t, a.text("Epson T061240"), a.text(" Epson T061240 "))
+	assert.NotEqual(t, a.text("Epson T061240"), anonymizer{seed: "other"}.text("Epson T061240"))
+	assert.GreaterOrEqual(t, len(a.text("Epson T061240")), len("Epson T061240"))
+	assert.Equal(t, "", a.text("  "))
+	assert.Equal(t, "", a.url(""))
+	assert.True(t, strings.HasPrefix(a.url("https://iczc.cz/1/obrazek.jpg"), anonymizedHost))
+	assert.True(t, strings.HasSuffix(a.url("https://iczc.cz/1/obrazek.jpg"), ".jpg"))
+	assert.NotEqual(t, a.url("https://iczc.cz/1/obrazek.jpg"), a.url("https://iczc.cz/2/obrazek.jpg"))
+}
+
+func TestParseAnonymizeArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"defaults", []string{"-f", "file://testdata/one_item.xml"}, ""},
+		{"no feed", []string{}, "Unable to parse flags: the required flag `-f, --feedUrl' was not specified"},
+		{"relative feed", []string{"-f", "feed.xml"}, "Feed url 'feed.xml' should be absolute"},
+		{"empty output", []string{"-f", "file://feed.xml", "-o", ""}, "Output should not be empty, use '-' for stdout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseAnonymizeArgs(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "file://testdata/one_item.xml", opts.feed.String())
+			assert.Equal(t, convertStdout, opts.output)
+			assert.Equal(t, "", opts.seed)
+		})
+	}
+}
+
+func TestAnonymizeRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anonymize")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "feed.xml")
+
+	require.NoError(t, anonymizeRun([]string{"-f", "file://testdata/one_item.xml", "-o", output, "--seed", "secret"}))
+	anonymized, err := ioutil.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(anonymized), "<ITEM_ID>34644</ITEM_ID>")
+	assert.Contains(t, string(anonymized), "<PRICE_VAT>269</PRICE_VAT>")
+	assert.NotContains(t, string(anonymized), "czc.cz")
+	assert.Error(t, anonymizeRun([]string{"-f", "file://testdata/badFeed.xml", "-o", output}))
+}
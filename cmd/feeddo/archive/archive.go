@@ -0,0 +1,208 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+const (
+	// RawSuffix is suffix of object with raw feed
+	RawSuffix = ".xml.gz"
+	// ItemsSuffix is suffix of object with parsed items, every line is sink.Record
+	ItemsSuffix = ".ndjson.gz"
+
+	// contentType of stored objects, they are compressed by gzip
+	contentType = "application/gzip"
+)
+
+var reUnsafe = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// Archive stores raw feed and parsed items of every run into store under date partitioned keys:
+// <prefix>/dt=<date>/feed=<host and path of feed>/<time of run>.xml.gz (and .ndjson.gz), time is in UTC.
+type Archive struct {
+	store  Store
+	prefix string
+	now    func() time.Time
+}
+
+// New creates archive in store defined by url, see NewStore
+func New(rawURL string) (*Archive, error) {
+	store, prefix, err := NewStore(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return newArchive(store, prefix), nil
+}
+
+func newArchive(store Store, prefix string) *Archive {
+	return &Archive{store: store, prefix: prefix, now: time.Now}
+}
+
+// Key returns key of objects of run of feed started at provided time without suffix
+func Key(prefix string, feed *url.URL, started time.Time) string {
+	started = started.UTC()
+	name := strings.Trim(reUnsafe.ReplaceAllString(feed.Host+feed.Path, "_"), "_")
+	return path.Join(prefix, "dt="+started.Format("2006-01-02"), "feed="+name, started.Format("20060102T150405Z"))
+}
+
+// Begin creates objects for run of feed. Run should be closed to store them.
+func (a *Archive) Begin(ctx context.Context, feed *url.URL) (*Run, error) {
+	key := Key(a.prefix, feed, a.now())
+	raw, err := newObject(ctx, a.store, key+RawSuffix)
+	if err != nil {
+		return nil, err
+	}
+	items, err := newObject(ctx, a.store, key+ItemsSuffix)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return &Run{Key: key, raw: raw, items: items}, nil
+}
+
+// Run is archive of single run of feed. Errors of archiving do not affect processing of feed,
+// the first one is returned by Close.
+type Run struct {
+	// Key of run objects without suffix
+	Key   string
+	raw   *object
+	items *object
+	err   error
+}
+
+// Raw returns reader which stores everything what is read from feed
+func (r *Run) Raw(rc io.ReadCloser) io.ReadCloser {
+	return teeReadCloser{Reader: io.TeeReader(rc, r.raw), Closer: rc}
+}
+
+// Item stores item as json line
+func (r *Run) Item(item sink.Item) {
+	record, err := sink.NewRecord(item)
+	if err == nil {
+		var line []byte
+		line, err = json.Marshal(record)
+		if err == nil {
+			_, err = r.items.Write(append(line, '\n'))
+		}
+	}
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("Failed to archive item %s because of %w", item.GetID(), err)
+	}
+}
+
+// Close stores objects and returns the first error of archiving
+func (r *Run) Close() error {
+	errRaw := r.raw.Close()
+	errItems := r.items.Close()
+	switch {
+	case r.err != nil:
+		return r.err
+	case errRaw != nil:
+		return fmt.Errorf("Failed to archive raw feed because of %w", errRaw)
+	case errItems != nil:
+		return fmt.Errorf("Failed to archive items because of %w", errItems)
+	}
+	return nil
+}
+
+// Open opens archived object (or any other file) by url: path of local file, 'file://', 'http(s)://',
+// 's3://<bucket>/<key>[?region=<region>]' or 'gs://<bucket>/<key>'. Objects with suffix '.gz' are decompressed.
+func Open(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse url '%s' because of %w", rawURL, err)
+	}
+	var rc io.ReadCloser
+	switch u.Scheme {
+	case "":
+		rc, err = os.Open(rawURL)
+	case "file":
+		rc, err = os.Open(u.Host + u.Path)
+	case "http", "https":
+		rc, err = provider.CreateStream(ctx, u)
+	case "s3", "gs":
+		var store Store
+		var key string
+		store, key, err = NewStore(rawURL)
+		if err == nil {
+			rc, err = store.Open(ctx, key)
+		}
+	default:
+		err = fmt.Errorf("scheme '%s' is not supported", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open '%s' because of %w", rawURL, err)
+	}
+	if !strings.HasSuffix(u.Path, ".gz") {
+		return rc, nil
+	}
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("Unable to decompress '%s' because of %w", rawURL, err)
+	}
+	return gzipReadCloser{Reader: gz, file: rc}, nil
+}
+
+// gzipReadCloser closes decompressed file
+type gzipReadCloser struct {
+	*gzip.Reader
+	file io.Closer
+}
+
+func (g gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// object compresses data written into store. Write never fails, so feed is processed even when archiving failed,
+// the first error is returned by Close.
+type object struct {
+	w   io.WriteCloser
+	gz  *gzip.Writer
+	err error
+}
+
+func newObject(ctx context.Context, store Store, key string) (*object, error) {
+	w, err := store.Create(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create archive object '%s' because of %w", key, err)
+	}
+	return &object{w: w, gz: gzip.NewWriter(w)}, nil
+}
+
+func (o *object) Write(p []byte) (int, error) {
+	if o.err == nil {
+		_, o.err = o.gz.Write(p)
+	}
+	return len(p), nil
+}
+
+func (o *object) Close() error {
+	err := o.gz.Close()
+	if o.err == nil {
+		o.err = err
+	}
+	err = o.w.Close()
+	if o.err == nil {
+		o.err = err
+	}
+	return o.err
+}
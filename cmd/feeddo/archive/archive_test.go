@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type itemTest struct {
+	id      string
+	payload []byte
+}
+
+func (i itemTest) GetContext() string       { return "http://test.org/feed.xml" }
+func (i itemTest) GetID() string            { return i.id }
+func (i itemTest) Marshal() ([]byte, error) { return i.payload, nil }
+func (i itemTest) Topics() []string         { return []string{"shop_items"} }
+
+// failingStore creates objects which could not be written
+type failingStore struct{}
+
+func (failingStore) Create(ctx context.Context, key string) (io.WriteCloser, error) {
+	return failingWriter{}, nil
+}
+
+func (failingStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, errors.New("test error")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("test error") }
+func (failingWriter) Close() error                { return nil }
+
+func TestKey(t *testing.T) {
+	u, _ := url.Parse("https://e.mall.cz/feeds/heureka.xml?token=1")
+	started := time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, "archive/dt=2021-03-04/feed=e.mall.cz_feeds_heureka.xml/20210304T040607Z", Key("archive", u, started))
+	assert.Equal(t, "dt=2021-03-04/feed=e.mall.cz_feeds_heureka.xml/20210304T040607Z", Key("", u, started))
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	a := newArchive(fileStore{dir: dir}, "feeds")
+	a.now = func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC) }
+	u, _ := url.Parse("http://test.org/feed.xml")
+	run, err := a.Begin(context.Background(), u)
+	require.NoError(t, err)
+	raw := run.Raw(ioutil.NopCloser(strings.NewReader("<SHOP></SHOP>")))
+	data, err := ioutil.ReadAll(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "<SHOP></SHOP>", string(data))
+	run.Item(itemTest{id: "1", payload: []byte(`{"ITEM_ID":"1"}`)})
+	run.Item(itemTest{id: "2", payload: []byte(`{"ITEM_ID":"2"}`)})
+	require.NoError(t, run.Close())
+
+	base := filepath.Join(dir, "feeds", "dt=2021-03-04", "feed=test.org_feed.xml", "20210304T050607Z")
+	assert.Equal(t, "<SHOP></SHOP>", string(readGzip(t, base+RawSuffix)))
+	lines := bytes.Split(bytes.TrimSpace(readGzip(t, base+ItemsSuffix)), []byte("\n"))
+	require.Equal(t, 2, len(lines))
+	r := sink.Record{}
+	require.NoError(t, json.Unmarshal(lines[1], &r))
+	assert.Equal(t, sink.Record{Context: "http://test.org/feed.xml", ID: "2", Topics: []string{"shop_items"}, Payload: json.RawMessage(`{"ITEM_ID":"2"}`)}, r)
+}
+
+func TestRunErrors(t *testing.T) {
+	a := newArchive(failingStore{}, "")
+	u, _ := url.Parse("http://test.org/feed.xml")
+	run, err := a.Begin(context.Background(), u)
+	require.NoError(t, err)
+	// feed is read even when it could not be archived
+	data, err := ioutil.ReadAll(run.Raw(ioutil.NopCloser(strings.NewReader("<SHOP></SHOP>"))))
+	require.NoError(t, err)
+	assert.Equal(t, "<SHOP></SHOP>", string(data))
+	run.Item(itemTest{id: "1", payload: []byte(`{`)})
+	err = run.Close()
+	require.Error(t, err)
+	assert.Equal(t, "Failed to archive item 1 because of Payload of item 1 is not valid json", err.Error())
+
+	run, err = a.Begin(context.Background(), u)
+	require.NoError(t, err)
+	ioutil.ReadAll(run.Raw(ioutil.NopCloser(strings.NewReader("<SHOP></SHOP>"))))
+	err = run.Close()
+	require.Error(t, err)
+	assert.Equal(t, "Failed to archive raw feed because of test error", err.Error())
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	a := newArchive(fileStore{dir: dir}, "")
+	u, _ := url.Parse("http://test.org/feed.xml")
+	run, err := a.Begin(context.Background(), u)
+	require.NoError(t, err)
+	run.Item(itemTest{id: "1", payload: []byte(`{}`)})
+	require.NoError(t, run.Close())
+	plain := filepath.Join(dir, "plain.ndjson")
+	require.NoError(t, ioutil.WriteFile(plain, []byte("line\n"), 0644))
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+		err      string
+	}{
+		{name: "archived items", url: filepath.Join(dir, run.Key+ItemsSuffix), expected: `{"context":"http://test.org/feed.xml","id":"1","topics":["shop_items"],"payload":{}}` + "\n"},
+		{name: "file url", url: "file://" + plain, expected: "line\n"},
+		{name: "missing file", url: filepath.Join(dir, "missing"), err: "Unable to open '" + filepath.Join(dir, "missing") + "' because of open " + filepath.Join(dir, "missing") + ": no such file or directory"},
+		{name: "missing compressed file", url: "file://" + plain + ".gz", err: "Unable to open 'file://" + plain + ".gz' because of open " + plain + ".gz: no such file or directory"},
+		{name: "unsupported scheme", url: "ftp://test.org/a", err: "Unable to open 'ftp://test.org/a' because of scheme 'ftp' is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := Open(context.Background(), tt.url)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			defer rc.Close()
+			data, err := ioutil.ReadAll(rc)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(data))
+		})
+	}
+}
+
+func readGzip(t *testing.T, path string) []byte {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,140 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// Store creates and reads objects in storage. Object is stored when returned writer is closed
+// and error of storing is returned by Close.
+type Store interface {
+	Create(ctx context.Context, key string) (io.WriteCloser, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// NewStore creates store by url: 'file:///<dir>', 's3://<bucket>[/<prefix>][?region=<region>]' or 'gs://<bucket>[/<prefix>]'.
+// Prefix of keys is returned as well.
+func NewStore(rawURL string) (Store, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to parse archive url '%s' because of %w", rawURL, err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "file":
+		dir := u.Host + u.Path
+		if dir == "" {
+			return nil, "", fmt.Errorf("Archive directory was not provided")
+		}
+		return fileStore{dir: dir}, "", nil
+	case "s3":
+		if u.Host == "" {
+			return nil, "", fmt.Errorf("Archive bucket was not provided")
+		}
+		config := aws.NewConfig()
+		if region := u.Query().Get("region"); region != "" {
+			config = config.WithRegion(region)
+		}
+		sess, err := session.NewSessionWithOptions(session.Options{Config: *config, SharedConfigState: session.SharedConfigEnable})
+		if err != nil {
+			return nil, "", fmt.Errorf("Unable to create AWS session because of %w", err)
+		}
+		return s3Store{uploader: s3manager.NewUploader(sess), bucket: u.Host}, prefix, nil
+	case "gs":
+		if u.Host == "" {
+			return nil, "", fmt.Errorf("Archive bucket was not provided")
+		}
+		client, err := storage.NewClient(context.Background())
+		if err != nil {
+			return nil, "", fmt.Errorf("Unable to create GCS client because of %w", err)
+		}
+		return gcsStore{bucket: client.Bucket(u.Host)}, prefix, nil
+	}
+	return nil, "", fmt.Errorf("Archive url '%s' is not supported, use file://, s3:// or gs:// scheme", rawURL)
+}
+
+// fileStore stores objects as files in directory, keys are relative paths
+type fileStore struct {
+	dir string
+}
+
+func (s fileStore) Create(ctx context.Context, key string) (io.WriteCloser, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+func (s fileStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+}
+
+// s3Store uploads objects into S3 bucket. Object is streamed by multipart upload while it is written.
+type s3Store struct {
+	uploader *s3manager.Uploader
+	bucket   string
+}
+
+func (s s3Store) Create(ctx context.Context, key string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+			Bucket:      aws.String(s.bucket),
+			Key:         aws.String(key),
+			Body:        pr,
+			ContentType: aws.String(contentType),
+		})
+		// writes fail when upload was aborted
+		pr.CloseWithError(err)
+		done <- err
+	}()
+	return &upload{PipeWriter: pw, done: done}, nil
+}
+
+func (s s3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.uploader.S3.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// upload is written into pipe read by uploader
+type upload struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// Close finishes object and waits till it is uploaded
+func (u *upload) Close() error {
+	u.PipeWriter.Close()
+	return <-u.done
+}
+
+// gcsStore uploads objects into Google Cloud Storage bucket
+type gcsStore struct {
+	bucket *storage.BucketHandle
+}
+
+func (s gcsStore) Create(ctx context.Context, key string) (io.WriteCloser, error) {
+	w := s.bucket.Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	return w, nil
+}
+
+func (s gcsStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.bucket.Object(key).NewReader(ctx)
+}
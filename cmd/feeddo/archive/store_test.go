@@ -0,0 +1,59 @@
+package archive
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStore(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		store  interface{}
+		prefix string
+		err    string
+	}{
+		{name: "file", url: "file:///var/archive", store: fileStore{dir: "/var/archive"}},
+		{name: "s3", url: "s3://bucket/feeds/?region=eu-west-1", store: s3Store{}, prefix: "feeds"},
+		{name: "file without directory", url: "file://", err: "Archive directory was not provided"},
+		{name: "s3 without bucket", url: "s3:///feeds", err: "Archive bucket was not provided"},
+		{name: "gs without bucket", url: "gs://", err: "Archive bucket was not provided"},
+		{name: "unsupported scheme", url: "ftp://test.org", err: "Archive url 'ftp://test.org' is not supported, use file://, s3:// or gs:// scheme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, prefix, err := NewStore(tt.url)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.store, store)
+			assert.Equal(t, tt.prefix, prefix)
+			if fs, ok := tt.store.(fileStore); ok {
+				assert.Equal(t, fs, store)
+			}
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	w, err := fileStore{dir: dir}.Create(context.Background(), "a/b/c.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("test"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	data, err := ioutil.ReadFile(filepath.Join(dir, "a", "b", "c.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "test", string(data))
+}
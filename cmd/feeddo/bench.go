@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/kafka"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/grubastik/feeddo/internal/pkg/sink/drysink"
+	"github.com/jessevdk/go-flags"
+)
+
+const (
+	// benchCommand is the first argument which switches app into measuring of throughput of pipeline
+	benchCommand = "bench"
+	// stages of pipeline which are measured
+	benchDownload = "download"
+	benchParse    = "parse"
+	benchProduce  = "produce"
+)
+
+// benchOptions define which feed is measured and where profiles are written
+type benchOptions struct {
+	feed   *url.URL
+	format string
+	// cpuProfile and memProfile are files of pprof profiles, profiles are not written when they are empty
+	cpuProfile string
+	memProfile string
+}
+
+// benchStage is throughput and allocations of single stage of pipeline
+type benchStage struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+	// Bytes is size of downloaded feed, it is set for download only
+	Bytes          int64   `json:"bytes,omitempty"`
+	MBPerSecond    float64 `json:"mbPerSecond,omitempty"`
+	Items          int     `json:"items"`
+	ItemsPerSecond float64 `json:"itemsPerSecond"`
+	// Allocs and AllocBytes are number and total size of heap allocations, GCs is number of garbage collections
+	Allocs     uint64 `json:"allocs"`
+	AllocBytes uint64 `json:"allocBytes"`
+	GCs        uint32 `json:"gcs"`
+}
+
+// benchReport is result of measuring of pipeline
+type benchReport struct {
+	Feed   string       `json:"feed"`
+	Stages []benchStage `json:"stages"`
+	// ParseErrors is number of items which could not be parsed, Failed is number of items which could not be produced
+	ParseErrors int `json:"parseErrors"`
+	Failed      int `json:"failed"`
+}
+
+// parseBenchArgs parses flags of bench command
+func parseBenchArgs(args []string) (benchOptions, error) {
+	var opts struct {
+		Feed       string `short:"f" long:"feedUrl" description:"Measured feed: 'http(s)://' or 'file://' url" required:"true"`
+		Format     string `long:"format" description:"Format of report: 'text' or 'json'" default:"text"`
+		CPUProfile string `long:"cpuProfile" description:"File which CPU profile of parsing and producing is written into"`
+		MemProfile string `long:"memProfile" description:"File which heap profile is written into after producing"`
+	}
+	parser := flags.NewParser(&opts, flags.PassDoubleDash|flags.IgnoreUnknown)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		return benchOptions{}, fmt.Errorf("Unable to parse flags: %w", err)
+	}
+	feed, err := url.Parse(strings.TrimSpace(opts.Feed))
+	if err != nil {
+		return benchOptions{}, fmt.Errorf("Unable to parse feed url '%s' because of %w", opts.Feed, err)
+	}
+	if !feed.IsAbs() {
+		return benchOptions{}, fmt.Errorf("Feed url '%s' should be absolute", opts.Feed)
+	}
+	if opts.Format != reportFormatText && opts.Format != reportFormatJSON {
+		return benchOptions{}, fmt.Errorf("Unknown format of report '%s', supported formats are '%s' and '%s'", opts.Format, reportFormatText, reportFormatJSON)
+	}
+	return benchOptions{feed: feed, format: opts.Format, cpuProfile: opts.CPUProfile, memProfile: opts.MemProfile}, nil
+}
+
+// benchRun prints throughput of stages of pipeline into stdout
+func benchRun(args []string) error {
+	opts, err := parseBenchArgs(args)
+	if err != nil {
+		return &apperror.ConfigError{Err: err}
+	}
+	report, err := bench(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	return writeBenchReport(os.Stdout, report, opts.format)
+}
+
+// bench runs feed through pipeline stage by stage, so stages do not affect measurements of each other:
+// feed is downloaded into memory, parsed into products and products are produced into sink which only marshals them.
+func bench(ctx context.Context, opts benchOptions) (benchReport, error) {
+	report := benchReport{Feed: opts.feed.String()}
+	var feed bytes.Buffer
+	stage, err := measure(benchDownload, func() (int, int64, error) {
+		stream, err := provider.CreateStream(ctx, opts.feed)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Failed to get stream: %w", err)
+		}
+		defer stream.Close()
+		n, err := io.Copy(&feed, stream)
+		if err != nil {
+			return 0, n, fmt.Errorf("Unable to download feed '%s' because of %w", opts.feed, err)
+		}
+		return 0, n, nil
+	})
+	if err != nil {
+		return report, err
+	}
+	report.Stages = append(report.Stages, stage)
+
+	if opts.cpuProfile != "" {
+		f, err := os.Create(opts.cpuProfile)
+		if err != nil {
+			return report, fmt.Errorf("Unable to create file `%s` because of %w", opts.cpuProfile, err)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return report, fmt.Errorf("Unable to start CPU profile because of %w", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+	products := []product.Product{}
+	stage, err = measure(benchParse, func() (int, int64, error) {
+		var err error
+		report.ParseErrors, err = readProducts(ctx, bytes.NewReader(feed.Bytes()), opts.feed, func(p product.Product) error {
+			products = append(products, p)
+			return nil
+		})
+		return len(products), 0, err
+	})
+	if err != nil {
+		return report, err
+	}
+	report.Stages = append(report.Stages, stage)
+
+	s := drysink.New(ioutil.Discard, 0)
+	stage, err = measure(benchProduce, func() (int, int64, error) {
+		for _, p := range products {
+			ai := appItem{product: p, feed: report.Feed, topics: []string{kafka.TopicShopItems}}
+			if res := s.Send(ctx, ai); res.Err != nil {
+				report.Failed++
+			}
+		}
+		return len(products), 0, nil
+	})
+	if err != nil {
+		return report, err
+	}
+	report.Stages = append(report.Stages, stage)
+
+	if opts.memProfile != "" {
+		if err := writeHeapProfile(opts.memProfile); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// measure runs stage and computes its throughput from number of items and bytes returned by fn
+func measure(name string, fn func() (int, int64, error)) (benchStage, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	items, n, err := fn()
+	elapsed := time.Since(started)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		return benchStage{}, err
+	}
+	stage := benchStage{
+		Name:       name,
+		Seconds:    elapsed.Seconds(),
+		Bytes:      n,
+		Items:      items,
+		Allocs:     after.Mallocs - before.Mallocs,
+		AllocBytes: after.TotalAlloc - before.TotalAlloc,
+		GCs:        after.NumGC - before.NumGC,
+	}
+	if elapsed > 0 {
+		stage.MBPerSecond = float64(n) / (1 << 20) / elapsed.Seconds()
+		stage.ItemsPerSecond = float64(items) / elapsed.Seconds()
+	}
+	return stage, nil
+}
+
+// writeHeapProfile writes profile of live heap objects into file
+func writeHeapProfile(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("Unable to create file `%s` because of %w", file, err)
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("Unable to write heap profile because of %w", err)
+	}
+	return nil
+}
+
+// writeBenchReport writes report in format
+func writeBenchReport(w io.Writer, report benchReport, format string) error {
+	var err error
+	if format == reportFormatJSON {
+		err = json.NewEncoder(w).Encode(report)
+	} else {
+		_, err = io.WriteString(w, benchText(report))
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write report because of %w", err)
+	}
+	return nil
+}
+
+// benchText formats report as human readable text
+func benchText(report benchReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "feed: %s\n", report.Feed)
+	for _, s := range report.Stages {
+		if s.Name == benchDownload {
+			fmt.Fprintf(&b, "%s: %d bytes in %.3fs (%.2f MB/s)", s.Name, s.Bytes, s.Seconds, s.MBPerSecond)
+		} else {
+			fmt.Fprintf(&b, "%s: %d items in %.3fs (%.0f items/s)", s.Name, s.Items, s.Seconds, s.ItemsPerSecond)
+		}
+		fmt.Fprintf(&b, ", %d allocs (%d bytes), %d GCs\n", s.Allocs, s.AllocBytes, s.GCs)
+	}
+	fmt.Fprintf(&b, "parse errors: %d\nfailed items: %d\n", report.ParseErrors, report.Failed)
+	return b.String()
+}
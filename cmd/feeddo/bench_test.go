@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBench(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bench")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	feed, err := url.Parse("file://testdata/one_item.xml")
+	require.NoError(t, err)
+	opts := benchOptions{feed: feed, cpuProfile: filepath.Join(dir, "cpu.pprof"), memProfile: filepath.Join(dir, "mem.pprof")}
+	report, err := bench(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "file://testdata/one_item.xml", report.Feed)
+	require.Len(t, report.Stages, 3)
+	info, err := os.Stat("testdata/one_item.xml")
+	require.NoError(t, err)
+	assert.Equal(t, benchDownload, report.Stages[0].Name)
+	assert.Equal(t, info.Size(), report.Stages[0].Bytes)
+	assert.Equal(t, benchParse, report.Stages[1].Name)
+	assert.Equal(t, 1, report.Stages[1].Items)
+	assert.NotZero(t, report.Stages[1].Allocs)
+	assert.Equal(t, benchProduce, report.Stages[2].Name)
+	assert.Equal(t, 1, report.Stages[2].Items)
+	assert.Equal(t, 0, report.ParseErrors)
+	assert.Equal(t, 0, report.Failed)
+	for _, profile := range []string{opts.cpuProfile, opts.memProfile} {
+		info, err := os.Stat(profile)
+		require.NoError(t, err)
+		assert.NotZero(t, info.Size())
+	}
+
+	feed, err = url.Parse("file://testdata/absent.xml")
+	require.NoError(t, err)
+	_, err = bench(context.Background(), benchOptions{feed: feed})
+	assert.Error(t, err)
+}
+
+func TestMeasure(t *testing.T) {
+	stage, err := measure(benchParse, func() (int, int64, error) {
+		return 10, 1 << 20, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, benchParse, stage.Name)
+	assert.Equal(t, 10, stage.Items)
+	assert.Equal(t, int64(1<<20), stage.Bytes)
+	assert.Greater(t, stage.ItemsPerSecond, 0.0)
+	assert.InDelta(t, stage.ItemsPerSecond/10, stage.MBPerSecond, 0.001)
+
+	_, err = measure(benchParse, func() (int, int64, error) {
+		return 0, 0, errors.New("test error")
+	})
+	assert.EqualError(t, err, "test error")
+}
+
+func TestWriteBenchReport(t *testing.T) {
+	report := benchReport{
+		Feed: "file://testdata/feed.xml",
+		Stages: []benchStage{
+			{Name: benchDownload, Seconds: 0.5, Bytes: 1 << 20, MBPerSecond: 2, Allocs: 10, AllocBytes: 2048, GCs: 1},
+			{Name: benchParse, Seconds: 2, Items: 1000, ItemsPerSecond: 500, Allocs: 100000, AllocBytes: 1 << 20},
+		},
+		ParseErrors: 1,
+	}
+	var out bytes.Buffer
+	require.NoError(t, writeBenchReport(&out, report, reportFormatText))
+	assert.Equal(t, `feed: file://testdata/feed.xml
+download: 1048576 bytes in 0.500s (2.00 MB/s), 10 allocs (2048 bytes), 1 GCs
+parse: 1000 items in 2.000s (500 items/s), 100000 allocs (1048576 bytes), 0 GCs
+parse errors: 1
+failed items: 0
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeBenchReport(&out, benchReport{Feed: "file://testdata/feed.xml", Stages: report.Stages[1:]}, reportFormatJSON))
+	assert.Equal(t, `{"feed":"file://testdata/feed.xml","stages":[{"name":"parse","seconds":2,"items":1000,"itemsPerSecond":500,`+
+		`"allocs":100000,"allocBytes":1048576,"gcs":0}],"parseErrors":0,"failed":0}`+"\n", out.String())
+}
+
+func TestParseBenchArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"defaults", []string{"-f", "file://testdata/one_item.xml"}, ""},
+		{"no feed", []string{}, "Unable to parse flags: the required flag `-f, --feedUrl' was not specified"},
+		{"relative feed", []string{"-f", "feed.xml"}, "Feed url 'feed.xml' should be absolute"},
+		{"unknown format", []string{"-f", "file://feed.xml", "--format", "yaml"}, "Unknown format of report 'yaml', supported formats are 'text' and 'json'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseBenchArgs(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "file://testdata/one_item.xml", opts.feed.String())
+			assert.Equal(t, reportFormatText, opts.format)
+			assert.Equal(t, "", opts.cpuProfile)
+			assert.Equal(t, "", opts.memProfile)
+		})
+	}
+}
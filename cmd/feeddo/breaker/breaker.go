@@ -0,0 +1,55 @@
+package breaker
+
+import "time"
+
+// Breaker tracks consecutive failed runs of feed and decides how many runs are skipped after failure.
+// Delay after failure grows exponentially from interval of feed till max backoff. When number of consecutive failures
+// reaches threshold circuit is opened and feed is paused for cooldown, after that single trial run is made:
+// its success closes circuit and its failure pauses feed again.
+// Breaker is not safe for concurrent use, every feed has its own breaker.
+type Breaker struct {
+	maxBackoff time.Duration
+	threshold  int
+	cooldown   time.Duration
+	failures   int
+}
+
+// New creates breaker. Zero max backoff disables backoff, zero threshold disables circuit.
+func New(maxBackoff time.Duration, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{maxBackoff: maxBackoff, threshold: threshold, cooldown: cooldown}
+}
+
+// Success registers successful run and returns true when circuit was open
+func (b *Breaker) Success() bool {
+	open := b.Open()
+	b.failures = 0
+	return open
+}
+
+// Failure registers failed run and returns delay before the next run of feed processed every interval
+func (b *Breaker) Failure(interval time.Duration) time.Duration {
+	b.failures++
+	if b.Open() {
+		return maxDuration(b.cooldown, interval)
+	}
+	delay := interval
+	for i := 1; i < b.failures && delay < b.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > b.maxBackoff {
+		delay = b.maxBackoff
+	}
+	return maxDuration(delay, interval)
+}
+
+// Open returns true when feed failed too many times in a row and is paused
+func (b *Breaker) Open() bool {
+	return b.threshold > 0 && b.failures >= b.threshold
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
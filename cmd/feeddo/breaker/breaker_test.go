@@ -0,0 +1,57 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBackoff time.Duration
+		threshold  int
+		cooldown   time.Duration
+		delays     []time.Duration
+		open       []bool
+	}{
+		{
+			name:   "disabled",
+			delays: []time.Duration{time.Minute, time.Minute, time.Minute},
+			open:   []bool{false, false, false},
+		},
+		{
+			name:       "backoff",
+			maxBackoff: 5 * time.Minute,
+			delays:     []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute},
+			open:       []bool{false, false, false, false, false},
+		},
+		{
+			name:       "backoff shorter than interval",
+			maxBackoff: time.Second,
+			delays:     []time.Duration{time.Minute, time.Minute},
+			open:       []bool{false, false},
+		},
+		{
+			name:       "circuit",
+			maxBackoff: time.Hour,
+			threshold:  3,
+			cooldown:   30 * time.Minute,
+			delays:     []time.Duration{time.Minute, 2 * time.Minute, 30 * time.Minute, 30 * time.Minute},
+			open:       []bool{false, false, true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.maxBackoff, tt.threshold, tt.cooldown)
+			for i, d := range tt.delays {
+				assert.Equal(t, d, b.Failure(time.Minute), "failure %d", i+1)
+				assert.Equal(t, tt.open[i], b.Open(), "failure %d", i+1)
+			}
+			assert.Equal(t, tt.open[len(tt.open)-1], b.Success())
+			assert.False(t, b.Open())
+			assert.Equal(t, time.Minute, b.Failure(time.Minute))
+		})
+	}
+}
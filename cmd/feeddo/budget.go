@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// budget policies: items with bidding are not sent into bidding topic once run exceeded budget
+	// or they are sent with header which warns consumers that budget was exceeded
+	budgetDrop = "drop"
+	budgetWarn = "warn"
+	// message header with sum of HEUREKA_CPC of run which exceeded budget
+	headerBudgetExceeded = "feeddo-bidding-budget-exceeded"
+)
+
+// biddingBudget guards bidding topic by sum of HEUREKA_CPC of items of run of feed.
+// Items are checked as they are parsed, so items parsed before budget was exceeded are already sent.
+type biddingBudget struct {
+	feed   string
+	limit  decimal.Decimal
+	policy string
+	total  decimal.Decimal
+}
+
+// parseBudgetPolicy validates policy of bidding budget
+func parseBudgetPolicy(policy string) (string, error) {
+	switch policy {
+	case budgetDrop, budgetWarn:
+		return policy, nil
+	}
+	return "", fmt.Errorf("Budget policy '%s' is not supported, use '%s' or '%s'", policy, budgetDrop, budgetWarn)
+}
+
+// newBiddingBudget returns budget of run of feed, nil is returned when limit is not positive
+func newBiddingBudget(feed string, limit decimal.Decimal, policy string) *biddingBudget {
+	if !limit.IsPositive() {
+		return nil
+	}
+	metrics.ObserveBiddingCPC(feed, 0)
+	return &biddingBudget{feed: feed, limit: limit, policy: policy}
+}
+
+// apply adds HEUREKA_CPC of item to sum of run. Item parsed after sum exceeded budget is removed from bidding topic
+// or it gets warning header according to policy. Item removed from bidding topic is sent into itemsTopic,
+// which it could miss when items with bidding are sent only into bidding topic.
+func (b *biddingBudget) apply(ai *appItem, biddingTopic, itemsTopic string) {
+	if b == nil || !ai.product.CPC.IsPositive() {
+		return
+	}
+	b.total = b.total.Add(ai.product.CPC)
+	total, _ := b.total.Float64()
+	metrics.ObserveBiddingCPC(b.feed, total)
+	if b.total.LessThanOrEqual(b.limit) {
+		return
+	}
+	metrics.ObserveOverBudget(b.feed)
+	if b.policy == budgetWarn {
+		ai.budgetExceeded = b.total.String()
+		return
+	}
+	topics := make([]string, 0, len(ai.topics)+1)
+	hasItemsTopic := false
+	for _, t := range ai.topics {
+		hasItemsTopic = hasItemsTopic || t == itemsTopic
+	}
+	if !hasItemsTopic {
+		topics = append(topics, itemsTopic)
+	}
+	for _, t := range ai.topics {
+		if t != biddingTopic {
+			topics = append(topics, t)
+		}
+	}
+	ai.topics = topics
+}
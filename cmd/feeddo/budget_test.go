@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+	"github.com/grubastik/feeddo/internal/pkg/kafka"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBiddingBudget(t *testing.T) {
+	topics := []string{"shop_items", "shop_items_bidding"}
+	item := func(cpc string) *appItem {
+		return &appItem{product: product.Product{ID: "1", CPC: decimal.RequireFromString(cpc)}, topics: topics}
+	}
+	tests := []struct {
+		name     string
+		policy   string
+		cpcs     []string
+		topics   [][]string
+		exceeded []string
+	}{
+		{
+			"drop",
+			budgetDrop,
+			[]string{"4", "0", "6", "0.5", "1"},
+			[][]string{topics, topics, topics, {"shop_items"}, {"shop_items"}},
+			[]string{"", "", "", "", ""},
+		},
+		{
+			"warn",
+			budgetWarn,
+			[]string{"4", "6", "0.5", "0"},
+			[][]string{topics, topics, topics, topics},
+			[]string{"", "", "10.5", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBiddingBudget("http://test.org/"+tt.name, decimal.NewFromInt(10), tt.policy)
+			for i, cpc := range tt.cpcs {
+				ai := item(cpc)
+				b.apply(ai, "shop_items_bidding", "shop_items")
+				assert.Equal(t, tt.topics[i], ai.topics, "item %d", i)
+				assert.Equal(t, tt.exceeded[i], ai.budgetExceeded, "item %d", i)
+			}
+		})
+	}
+	// topics of item are not shared with other items
+	assert.Equal(t, []string{"shop_items", "shop_items_bidding"}, topics)
+
+	// budget is disabled by default
+	assert.Nil(t, newBiddingBudget("http://test.org/a.xml", decimal.Zero, budgetDrop))
+	ai := item("100")
+	(*biddingBudget)(nil).apply(ai, "shop_items_bidding", "shop_items")
+	assert.Equal(t, topics, ai.topics)
+
+	// item sent only into bidding topic is sent into items topic when budget is exceeded
+	b := newBiddingBudget("http://test.org/only", decimal.NewFromInt(10), budgetDrop)
+	ai = &appItem{product: product.Product{ID: "1", CPC: decimal.NewFromInt(11)}, topics: []string{"shop_items_bidding", "cheap"}}
+	b.apply(ai, "shop_items_bidding", "shop_items")
+	assert.Equal(t, []string{"shop_items", "cheap"}, ai.topics)
+}
+
+func TestParseArgsBiddingBudget(t *testing.T) {
+	os.Args = []string{"test", "-f", "http://test.org/a.xml", "-k", "test.org", "--biddingBudget", "1500.50", "--biddingBudgetPolicy", "warn"}
+	opts, err := parseArgs()
+	require.NoError(t, err)
+	assert.Equal(t, "1500.5", opts.biddingBudget.String())
+	assert.Equal(t, budgetWarn, opts.budgetPolicy)
+
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"not number", []string{"--biddingBudget", "much"}, "Bidding budget 'much' should be positive number"},
+		{"zero", []string{"--biddingBudget", "0"}, "Bidding budget '0' should be positive number"},
+		{"unknown policy", []string{"--biddingBudgetPolicy", "fail"}, "Budget policy 'fail' is not supported, use 'drop' or 'warn'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = append([]string{"test", "-f", "http://test.org/a.xml", "-k", "test.org"}, tt.args...)
+			_, err := parseArgs()
+			require.Error(t, err)
+			assert.Equal(t, tt.err, err.Error())
+		})
+	}
+}
+
+func TestRunOnceBiddingBudget(t *testing.T) {
+	URL, _ := url.Parse("file://testdata/one_item.xml")
+	mc := metrics.Container{URL.String(): {"feed": &AdderCustom{}}}
+	chanItem := make(chan sink.Item, 1)
+	// item has HEUREKA_CPC 1,50
+	errs := runOnce(context.Background(), options{feeds: []*url.URL{URL}, router: testRouter(t), biddingBudget: decimal.NewFromInt(1), budgetPolicy: budgetDrop}, chanItem, newMetricsObserver(mc))
+	close(chanItem)
+	require.Empty(t, errs)
+	item := <-chanItem
+	require.NotNil(t, item)
+	assert.Equal(t, []string{kafka.TopicShopItems}, item.Topics())
+}
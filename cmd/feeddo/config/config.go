@@ -0,0 +1,723 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is configuration of app loaded from YAML or TOML file.
+// Every value corresponds to command line flag, flags and environment variables take precedence over file.
+// Empty strings and missing numbers mean that value is not configured by file.
+type Config struct {
+	Feeds             []Feed      `yaml:"feeds" toml:"feeds"`
+	Scheduling        Scheduling  `yaml:"scheduling" toml:"scheduling"`
+	Parsing           Parsing     `yaml:"parsing" toml:"parsing"`
+	Sinks             []string    `yaml:"sinks" toml:"sinks"`
+	SinkFailurePolicy string      `yaml:"sinkFailurePolicy" toml:"sinkFailurePolicy"`
+	Kafka             Kafka       `yaml:"kafka" toml:"kafka"`
+	DeadLetter        DeadLetter  `yaml:"deadLetter" toml:"deadLetter"`
+	Redelivery        Redelivery  `yaml:"redelivery" toml:"redelivery"`
+	File              File        `yaml:"file" toml:"file"`
+	Postgres          Postgres    `yaml:"postgres" toml:"postgres"`
+	AMQP              AMQP        `yaml:"amqp" toml:"amqp"`
+	PubSub            PubSub      `yaml:"pubsub" toml:"pubsub"`
+	Kinesis           Kinesis     `yaml:"kinesis" toml:"kinesis"`
+	Webhook           Webhook     `yaml:"webhook" toml:"webhook"`
+	Filters           Filters     `yaml:"filters" toml:"filters"`
+	CloudEvents       CloudEvents `yaml:"cloudEvents" toml:"cloudEvents"`
+	Prices            Prices      `yaml:"prices" toml:"prices"`
+	URLs              URLs        `yaml:"urls" toml:"urls"`
+	Encryption        Encryption  `yaml:"encryption" toml:"encryption"`
+	Expiry            Expiry      `yaml:"expiry" toml:"expiry"`
+	BiddingBudget     Budget      `yaml:"biddingBudget" toml:"biddingBudget"`
+	Metrics           Metrics     `yaml:"metrics" toml:"metrics"`
+	ArchiveURL        string      `yaml:"archiveUrl" toml:"archiveUrl"`
+	Sentry            Sentry      `yaml:"sentry" toml:"sentry"`
+	Tracing           Tracing     `yaml:"tracing" toml:"tracing"`
+	DryRun            DryRun      `yaml:"dryRun" toml:"dryRun"`
+}
+
+// Feed is processed with global interval unless its own interval is set
+type Feed struct {
+	URL      string   `yaml:"url" toml:"url"`
+	Interval Duration `yaml:"interval" toml:"interval"`
+	// Alias identifies feed in metrics, state, topics and logs instead of url
+	Alias string `yaml:"alias" toml:"alias"`
+	// OverlapPolicy overrides overlap policy of scheduling for the feed
+	OverlapPolicy string `yaml:"overlapPolicy" toml:"overlapPolicy"`
+	// Priority defines which of feeds waiting for free slot is processed first
+	Priority int `yaml:"priority" toml:"priority"`
+	// ItemsPerSecond overrides throughput limit of scheduling for the feed
+	ItemsPerSecond *int `yaml:"itemsPerSecond" toml:"itemsPerSecond"`
+	// ItemElement and Charset override parsing of the feed, e.g. for supplier with different quirks
+	ItemElement string `yaml:"itemElement" toml:"itemElement"`
+	Charset     string `yaml:"charset" toml:"charset"`
+	// ItemFilter replaces item filters for the feed
+	ItemFilter string `yaml:"itemFilter" toml:"itemFilter"`
+	// TopicItems overrides items topic and routes for the feed
+	TopicItems string `yaml:"topicItems" toml:"topicItems"`
+	// Method, Body and ContentType define HTTP request of feed which is not downloaded by plain GET
+	Method      string `yaml:"method" toml:"method"`
+	Body        string `yaml:"body" toml:"body"`
+	ContentType string `yaml:"contentType" toml:"contentType"`
+}
+
+// Parsing defines how items are read from feeds, it could be overridden per feed
+type Parsing struct {
+	ItemElement string `yaml:"itemElement" toml:"itemElement"`
+	Charset     string `yaml:"charset" toml:"charset"`
+}
+
+// Scheduling defines how often feeds are processed, how failing feeds are delayed and how feeds are stopped
+type Scheduling struct {
+	Interval         *Duration `yaml:"interval" toml:"interval"`
+	ShutdownTimeout  *Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+	FailureBackoff   *Duration `yaml:"failureBackoff" toml:"failureBackoff"`
+	BreakerThreshold *int      `yaml:"breakerThreshold" toml:"breakerThreshold"`
+	BreakerCooldown  *Duration `yaml:"breakerCooldown" toml:"breakerCooldown"`
+	// OverlapPolicy defines what happens when feed is due while its previous run is not finished
+	OverlapPolicy string `yaml:"overlapPolicy" toml:"overlapPolicy"`
+	// first runs of feeds are spread over StartSpread, next runs are delayed randomly up to Jitter
+	StartSpread *Duration `yaml:"startSpread" toml:"startSpread"`
+	Jitter      *Duration `yaml:"jitter" toml:"jitter"`
+	// feeds are split between ShardCount instances, instance processes feeds of shard ShardIndex
+	ShardIndex *int `yaml:"shardIndex" toml:"shardIndex"`
+	ShardCount *int `yaml:"shardCount" toml:"shardCount"`
+	// MaxConcurrentFeeds limits number of feeds processed at the same time
+	MaxConcurrentFeeds *int `yaml:"maxConcurrentFeeds" toml:"maxConcurrentFeeds"`
+	// ItemsPerSecond limits number of items of every feed passed to sinks per second
+	ItemsPerSecond *int `yaml:"itemsPerSecond" toml:"itemsPerSecond"`
+	// TerminateAfterRuns stops every periodic feed after number of runs
+	TerminateAfterRuns *int `yaml:"terminateAfterRuns" toml:"terminateAfterRuns"`
+}
+
+// Kafka configures kafka sink
+type Kafka struct {
+	URL               string        `yaml:"url" toml:"url"`
+	Driver            string        `yaml:"driver" toml:"driver"`
+	KeyStrategy       string        `yaml:"keyStrategy" toml:"keyStrategy"`
+	Partitioner       string        `yaml:"partitioner" toml:"partitioner"`
+	PartitionField    string        `yaml:"partitionField" toml:"partitionField"`
+	Security          KafkaSecurity `yaml:"security" toml:"security"`
+	Mirrors           []string      `yaml:"mirrors" toml:"mirrors"`
+	Topics            Topics        `yaml:"topics" toml:"topics"`
+	Retry             KafkaRetry    `yaml:"retry" toml:"retry"`
+	MaxInflight       *int          `yaml:"maxInflight" toml:"maxInflight"`
+	DeliveryTimeout   *Duration     `yaml:"deliveryTimeout" toml:"deliveryTimeout"`
+	ItemBuffer        *int          `yaml:"itemBuffer" toml:"itemBuffer"`
+	MessagesPerSecond *int          `yaml:"messagesPerSecond" toml:"messagesPerSecond"`
+	BytesPerSecond    *int          `yaml:"bytesPerSecond" toml:"bytesPerSecond"`
+	BatchSize         *int          `yaml:"batchSize" toml:"batchSize"`
+	Linger            *Duration     `yaml:"linger" toml:"linger"`
+	Producers         *int          `yaml:"producers" toml:"producers"`
+	MaxProducers      *int          `yaml:"maxProducers" toml:"maxProducers"`
+}
+
+// KafkaSecurity configures connection to brokers
+type KafkaSecurity struct {
+	Protocol      string `yaml:"protocol" toml:"protocol"`
+	SASLMechanism string `yaml:"saslMechanism" toml:"saslMechanism"`
+	SASLUsername  string `yaml:"saslUsername" toml:"saslUsername"`
+	SASLPassword  string `yaml:"saslPassword" toml:"saslPassword"`
+	CACert        string `yaml:"caCert" toml:"caCert"`
+	ClientCert    string `yaml:"clientCert" toml:"clientCert"`
+	ClientKey     string `yaml:"clientKey" toml:"clientKey"`
+}
+
+// KafkaRetry configures retries of delivery on transient errors
+type KafkaRetry struct {
+	Max        *int      `yaml:"max" toml:"max"`
+	Backoff    *Duration `yaml:"backoff" toml:"backoff"`
+	MaxBackoff *Duration `yaml:"maxBackoff" toml:"maxBackoff"`
+}
+
+// Topics configures where items are delivered and whether topics are checked on start
+type Topics struct {
+	Items             string   `yaml:"items" toml:"items"`
+	Bidding           string   `yaml:"bidding" toml:"bidding"`
+	BiddingMode       string   `yaml:"biddingMode" toml:"biddingMode"`
+	Routes            []string `yaml:"routes" toml:"routes"`
+	Conditions        []string `yaml:"conditions" toml:"conditions"`
+	Runs              string   `yaml:"runs" toml:"runs"`
+	Markers           bool     `yaml:"markers" toml:"markers"`
+	Check             bool     `yaml:"check" toml:"check"`
+	Create            bool     `yaml:"create" toml:"create"`
+	Partitions        *int     `yaml:"partitions" toml:"partitions"`
+	ReplicationFactor *int     `yaml:"replicationFactor" toml:"replicationFactor"`
+}
+
+// DeadLetter configures where items which failed to be delivered are stored
+type DeadLetter struct {
+	Topic string `yaml:"topic" toml:"topic"`
+	File  string `yaml:"file" toml:"file"`
+}
+
+// Redelivery configures retry queue which delivers items failed to be delivered again
+type Redelivery struct {
+	Max        *int      `yaml:"max" toml:"max"`
+	Backoff    *Duration `yaml:"backoff" toml:"backoff"`
+	MaxBackoff *Duration `yaml:"maxBackoff" toml:"maxBackoff"`
+	QueueSize  *int      `yaml:"queueSize" toml:"queueSize"`
+	File       string    `yaml:"file" toml:"file"`
+}
+
+// File configures file sink
+type File struct {
+	Path       string `yaml:"path" toml:"path"`
+	MaxSize    *int64 `yaml:"maxSize" toml:"maxSize"`
+	MaxBackups *int   `yaml:"maxBackups" toml:"maxBackups"`
+}
+
+// Postgres configures postgres sink
+type Postgres struct {
+	DSN       string `yaml:"dsn" toml:"dsn"`
+	Table     string `yaml:"table" toml:"table"`
+	Columns   string `yaml:"columns" toml:"columns"`
+	BatchSize *int   `yaml:"batchSize" toml:"batchSize"`
+}
+
+// AMQP configures amqp sink
+type AMQP struct {
+	URL        string `yaml:"url" toml:"url"`
+	Exchange   string `yaml:"exchange" toml:"exchange"`
+	RoutingKey string `yaml:"routingKey" toml:"routingKey"`
+}
+
+// PubSub configures pubsub sink
+type PubSub struct {
+	Project string `yaml:"project" toml:"project"`
+	Topic   string `yaml:"topic" toml:"topic"`
+}
+
+// Kinesis configures kinesis sink
+type Kinesis struct {
+	Region string `yaml:"region" toml:"region"`
+	Stream string `yaml:"stream" toml:"stream"`
+}
+
+// Webhook configures webhook sink
+type Webhook struct {
+	URL         string   `yaml:"url" toml:"url"`
+	Headers     []string `yaml:"headers" toml:"headers"`
+	BatchSize   *int     `yaml:"batchSize" toml:"batchSize"`
+	Concurrency *int     `yaml:"concurrency" toml:"concurrency"`
+	Retries     *int     `yaml:"retries" toml:"retries"`
+}
+
+// Filters configures checks of items and which items are not delivered
+type Filters struct {
+	Dedup              bool      `yaml:"dedup" toml:"dedup"`
+	Diff               bool      `yaml:"diff" toml:"diff"`
+	Tombstones         bool      `yaml:"tombstones" toml:"tombstones"`
+	StateDir           string    `yaml:"stateDir" toml:"stateDir"`
+	DedupTTL           *Duration `yaml:"dedupTtl" toml:"dedupTtl"`
+	CheckReferences    bool      `yaml:"checkReferences" toml:"checkReferences"`
+	ReferenceIndexSize *int      `yaml:"referenceIndexSize" toml:"referenceIndexSize"`
+	DuplicateIndexSize *int      `yaml:"duplicateIndexSize" toml:"duplicateIndexSize"`
+	InvalidText        string    `yaml:"invalidText" toml:"invalidText"`
+	MaxPayload         *int      `yaml:"maxPayload" toml:"maxPayload"`
+	OversizedPayload   string    `yaml:"oversizedPayload" toml:"oversizedPayload"`
+	OffloadDir         string    `yaml:"offloadDir" toml:"offloadDir"`
+	// Items are conditions which items should match to be delivered
+	Items []string `yaml:"items" toml:"items"`
+	// Redact are fields of items which are dropped or hashed before serialization
+	Redact []string `yaml:"redact" toml:"redact"`
+	// Pipeline is order of stages which parsed items pass
+	Pipeline []string `yaml:"pipeline" toml:"pipeline"`
+}
+
+// Metrics configures metrics server and Pushgateway
+type Metrics struct {
+	Address        string      `yaml:"address" toml:"address"`
+	TLSCert        string      `yaml:"tlsCert" toml:"tlsCert"`
+	TLSKey         string      `yaml:"tlsKey" toml:"tlsKey"`
+	User           string      `yaml:"user" toml:"user"`
+	Password       string      `yaml:"password" toml:"password"`
+	Pprof          bool        `yaml:"pprof" toml:"pprof"`
+	ItemEndpoint   bool        `yaml:"itemEndpoint" toml:"itemEndpoint"`
+	RunHistory     *int        `yaml:"runHistory" toml:"runHistory"`
+	RunHistoryFile string      `yaml:"runHistoryFile" toml:"runHistoryFile"`
+	SummaryFile    string      `yaml:"summaryFile" toml:"summaryFile"`
+	Pushgateway    Pushgateway `yaml:"pushgateway" toml:"pushgateway"`
+}
+
+// Pushgateway configures where metrics of one time run are pushed
+type Pushgateway struct {
+	URL      string `yaml:"url" toml:"url"`
+	Job      string `yaml:"job" toml:"job"`
+	Instance string `yaml:"instance" toml:"instance"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+// CloudEvents configures envelope of items
+type CloudEvents struct {
+	Mode string `yaml:"mode" toml:"mode"`
+	Type string `yaml:"type" toml:"type"`
+}
+
+// Prices configures format of prices in payload
+type Prices struct {
+	Format   string `yaml:"format" toml:"format"`
+	Currency string `yaml:"currency" toml:"currency"`
+}
+
+// URLs configures format of urls in payload
+type URLs struct {
+	Format string `yaml:"format" toml:"format"`
+}
+
+// Encryption configures key payloads are encrypted by
+type Encryption struct {
+	Key        string `yaml:"key" toml:"key"`
+	KeyID      string `yaml:"keyId" toml:"keyId"`
+	KeyCommand string `yaml:"keyCommand" toml:"keyCommand"`
+}
+
+// Expiry configures timestamp after which items should be considered stale by consumers
+type Expiry struct {
+	TTL       *Duration `yaml:"ttl" toml:"ttl"`
+	Intervals *int      `yaml:"intervals" toml:"intervals"`
+}
+
+// Budget limits sum of HEUREKA_CPC of run of feed, Policy defines what happens with items over budget
+type Budget struct {
+	Limit  *float64 `yaml:"limit" toml:"limit"`
+	Policy string   `yaml:"policy" toml:"policy"`
+}
+
+// Sentry configures error reporting
+type Sentry struct {
+	DSN         string `yaml:"dsn" toml:"dsn"`
+	Environment string `yaml:"environment" toml:"environment"`
+}
+
+// Tracing configures export of spans
+type Tracing struct {
+	OTLPEndpoint string   `yaml:"otlpEndpoint" toml:"otlpEndpoint"`
+	Insecure     bool     `yaml:"insecure" toml:"insecure"`
+	SampleRatio  *float64 `yaml:"sampleRatio" toml:"sampleRatio"`
+}
+
+// DryRun configures validation of feeds without delivering items
+type DryRun struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	Print   *int `yaml:"print" toml:"print"`
+}
+
+// Duration is time.Duration which is decoded from string like '15m'
+type Duration time.Duration
+
+// UnmarshalText parses duration in format of time.ParseDuration
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+// KeyError points at key of configuration which has invalid value
+type KeyError struct {
+	Key string
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("Value of '%s' is not valid because of %v", e.Key, e.Err)
+}
+func (e *KeyError) Unwrap() error { return e.Err }
+
+// Load reads configuration from file. Format is chosen by extension: '.toml' for TOML, YAML otherwise.
+// References to environment variables ($VAR or ${VAR}) are expanded before decoding, so credentials could be kept out of file.
+// Unknown keys are reported as errors, so typos are not ignored silently.
+func Load(file string) (Config, error) {
+	c := Config{}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return c, fmt.Errorf("Unable to read config file because of %w", err)
+	}
+	data = []byte(os.ExpandEnv(string(data)))
+	if strings.EqualFold(filepath.Ext(file), ".toml") {
+		md, err := toml.DecodeReader(bytes.NewReader(data), &c)
+		if err != nil {
+			return c, fmt.Errorf("Unable to decode config file '%s' because of %w", file, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return c, fmt.Errorf("Unable to decode config file '%s' because of unknown key '%s'", file, undecoded[0])
+		}
+	} else {
+		if err := yaml.UnmarshalStrict(data, &c); err != nil {
+			return c, fmt.Errorf("Unable to decode config file '%s' because of %w", file, err)
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return c, fmt.Errorf("Config file '%s' is not valid: %w", file, err)
+	}
+	return c, nil
+}
+
+// Validate checks values which could be checked without other settings, error points at the first invalid key.
+// Values which depend on each other are validated together with flags.
+func (c Config) Validate() error {
+	for i, f := range c.Feeds {
+		key := fmt.Sprintf("feeds[%d]", i)
+		if _, err := url.ParseRequestURI(f.URL); err != nil {
+			return &KeyError{Key: key + ".url", Err: err}
+		}
+		if f.Interval < 0 {
+			return &KeyError{Key: key + ".interval", Err: errNegative}
+		}
+		if f.Alias != "" && !reAlias.MatchString(f.Alias) {
+			return &KeyError{Key: key + ".alias", Err: fmt.Errorf("alias should consist of letters, digits, '_', '.' and '-'")}
+		}
+		if f.ItemsPerSecond != nil && *f.ItemsPerSecond < 0 {
+			return &KeyError{Key: key + ".itemsPerSecond", Err: errNegative}
+		}
+	}
+	durations := []struct {
+		key string
+		v   *Duration
+	}{
+		{"scheduling.interval", c.Scheduling.Interval},
+		{"scheduling.shutdownTimeout", c.Scheduling.ShutdownTimeout},
+		{"scheduling.failureBackoff", c.Scheduling.FailureBackoff},
+		{"scheduling.breakerCooldown", c.Scheduling.BreakerCooldown},
+		{"scheduling.startSpread", c.Scheduling.StartSpread},
+		{"scheduling.jitter", c.Scheduling.Jitter},
+		{"kafka.retry.backoff", c.Kafka.Retry.Backoff},
+		{"kafka.retry.maxBackoff", c.Kafka.Retry.MaxBackoff},
+		{"kafka.deliveryTimeout", c.Kafka.DeliveryTimeout},
+		{"kafka.linger", c.Kafka.Linger},
+		{"redelivery.backoff", c.Redelivery.Backoff},
+		{"redelivery.maxBackoff", c.Redelivery.MaxBackoff},
+		{"expiry.ttl", c.Expiry.TTL},
+		{"filters.dedupTtl", c.Filters.DedupTTL},
+	}
+	for _, d := range durations {
+		if d.v != nil && *d.v < 0 {
+			return &KeyError{Key: d.key, Err: errNegative}
+		}
+	}
+	ints := []struct {
+		key string
+		v   *int
+	}{
+		{"scheduling.breakerThreshold", c.Scheduling.BreakerThreshold},
+		{"scheduling.maxConcurrentFeeds", c.Scheduling.MaxConcurrentFeeds},
+		{"scheduling.shardIndex", c.Scheduling.ShardIndex},
+		{"scheduling.shardCount", c.Scheduling.ShardCount},
+		{"scheduling.terminateAfterRuns", c.Scheduling.TerminateAfterRuns},
+		{"scheduling.itemsPerSecond", c.Scheduling.ItemsPerSecond},
+		{"kafka.maxInflight", c.Kafka.MaxInflight},
+		{"kafka.itemBuffer", c.Kafka.ItemBuffer},
+		{"kafka.messagesPerSecond", c.Kafka.MessagesPerSecond},
+		{"kafka.bytesPerSecond", c.Kafka.BytesPerSecond},
+		{"kafka.batchSize", c.Kafka.BatchSize},
+		{"kafka.producers", c.Kafka.Producers},
+		{"kafka.maxProducers", c.Kafka.MaxProducers},
+		{"kafka.retry.max", c.Kafka.Retry.Max},
+		{"kafka.topics.partitions", c.Kafka.Topics.Partitions},
+		{"kafka.topics.replicationFactor", c.Kafka.Topics.ReplicationFactor},
+		{"redelivery.max", c.Redelivery.Max},
+		{"redelivery.queueSize", c.Redelivery.QueueSize},
+		{"file.maxBackups", c.File.MaxBackups},
+		{"postgres.batchSize", c.Postgres.BatchSize},
+		{"webhook.batchSize", c.Webhook.BatchSize},
+		{"webhook.concurrency", c.Webhook.Concurrency},
+		{"webhook.retries", c.Webhook.Retries},
+		{"filters.referenceIndexSize", c.Filters.ReferenceIndexSize},
+		{"expiry.intervals", c.Expiry.Intervals},
+		{"filters.duplicateIndexSize", c.Filters.DuplicateIndexSize},
+		{"filters.maxPayload", c.Filters.MaxPayload},
+		{"metrics.runHistory", c.Metrics.RunHistory},
+		{"dryRun.print", c.DryRun.Print},
+	}
+	for _, i := range ints {
+		if i.v != nil && *i.v < 0 {
+			return &KeyError{Key: i.key, Err: errNegative}
+		}
+	}
+	if c.File.MaxSize != nil && *c.File.MaxSize < 0 {
+		return &KeyError{Key: "file.maxSize", Err: errNegative}
+	}
+	if c.Metrics.Pushgateway.URL != "" {
+		if _, err := url.ParseRequestURI(c.Metrics.Pushgateway.URL); err != nil {
+			return &KeyError{Key: "metrics.pushgateway.url", Err: err}
+		}
+	}
+	if b := c.BiddingBudget.Limit; b != nil && *b <= 0 {
+		return &KeyError{Key: "biddingBudget.limit", Err: fmt.Errorf("budget should be positive")}
+	}
+	if r := c.Tracing.SampleRatio; r != nil && (*r < 0 || *r > 1) {
+		return &KeyError{Key: "tracing.sampleRatio", Err: fmt.Errorf("ratio should be between 0 and 1")}
+	}
+	return nil
+}
+
+var errNegative = fmt.Errorf("value should not be negative")
+
+// reAlias matches alias of feed
+var reAlias = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// Flag is value of command line flag set by configuration
+type Flag struct {
+	// Name is long name of flag
+	Name string
+	// Values contains one value for every occurrence of flag, it is empty for boolean flags
+	Values []string
+}
+
+// Flags returns configured values as command line flags, so they are handled in the same way as flags.
+// Values which are not configured are omitted, boolean flags are returned only when they are enabled.
+func (c Config) Flags() []Flag {
+	fs := flagSet{}
+	feeds, overlaps, priorities, rates := []string{}, []string{}, []string{}, []string{}
+	elements, charsets, filters, topics := []string{}, []string{}, []string{}, []string{}
+	methods, bodies, contentTypes := []string{}, []string{}, []string{}
+	for _, f := range c.Feeds {
+		feed := f.URL
+		if f.Alias != "" {
+			feed = f.Alias + "=" + feed
+		}
+		if f.Interval != 0 {
+			feed += "@" + f.Interval.String()
+		}
+		feeds = append(feeds, feed)
+		if f.OverlapPolicy != "" {
+			overlaps = append(overlaps, f.URL+"="+f.OverlapPolicy)
+		}
+		if f.Priority != 0 {
+			priorities = append(priorities, f.URL+"="+strconv.Itoa(f.Priority))
+		}
+		if f.ItemsPerSecond != nil {
+			rates = append(rates, f.URL+"="+strconv.Itoa(*f.ItemsPerSecond))
+		}
+		if f.ItemElement != "" {
+			elements = append(elements, f.URL+"="+f.ItemElement)
+		}
+		if f.Charset != "" {
+			charsets = append(charsets, f.URL+"="+f.Charset)
+		}
+		if f.ItemFilter != "" {
+			filters = append(filters, f.URL+"="+f.ItemFilter)
+		}
+		if f.TopicItems != "" {
+			topics = append(topics, f.URL+"="+f.TopicItems)
+		}
+		if f.Method != "" {
+			methods = append(methods, f.URL+"="+f.Method)
+		}
+		if f.Body != "" {
+			bodies = append(bodies, f.URL+"="+f.Body)
+		}
+		if f.ContentType != "" {
+			contentTypes = append(contentTypes, f.URL+"="+f.ContentType)
+		}
+	}
+	fs.list("feedUrl", feeds)
+	fs.list("feedOverlapPolicy", overlaps)
+	fs.list("feedPriority", priorities)
+	fs.list("feedItemsPerSecond", rates)
+	fs.list("feedItemElement", elements)
+	fs.list("feedCharset", charsets)
+	fs.list("feedItemFilter", filters)
+	fs.list("feedTopicItems", topics)
+	fs.list("feedMethod", methods)
+	fs.list("feedBody", bodies)
+	fs.list("feedContentType", contentTypes)
+	fs.str("itemElement", c.Parsing.ItemElement)
+	fs.str("charset", c.Parsing.Charset)
+	fs.list("itemFilter", c.Filters.Items)
+	fs.str("overlapPolicy", c.Scheduling.OverlapPolicy)
+	fs.duration("interval", c.Scheduling.Interval)
+	fs.duration("shutdownTimeout", c.Scheduling.ShutdownTimeout)
+	fs.int("terminateAfterRuns", c.Scheduling.TerminateAfterRuns)
+	fs.duration("failureBackoff", c.Scheduling.FailureBackoff)
+	fs.int("breakerThreshold", c.Scheduling.BreakerThreshold)
+	fs.duration("breakerCooldown", c.Scheduling.BreakerCooldown)
+	fs.duration("startSpread", c.Scheduling.StartSpread)
+	fs.duration("jitter", c.Scheduling.Jitter)
+	fs.int("maxConcurrentFeeds", c.Scheduling.MaxConcurrentFeeds)
+	fs.int("shardIndex", c.Scheduling.ShardIndex)
+	fs.int("shardCount", c.Scheduling.ShardCount)
+	fs.int("itemsPerSecond", c.Scheduling.ItemsPerSecond)
+	fs.list("sink", c.Sinks)
+	fs.str("sinkFailurePolicy", c.SinkFailurePolicy)
+	// kafka
+	k := c.Kafka
+	fs.str("kafkaUrl", k.URL)
+	fs.str("kafkaDriver", k.Driver)
+	fs.str("kafkaKeyStrategy", k.KeyStrategy)
+	fs.str("kafkaPartitioner", k.Partitioner)
+	fs.str("partitionField", k.PartitionField)
+	fs.str("kafkaSecurityProtocol", k.Security.Protocol)
+	fs.str("kafkaSaslMechanism", k.Security.SASLMechanism)
+	fs.str("kafkaSaslUsername", k.Security.SASLUsername)
+	fs.str("kafkaSaslPassword", k.Security.SASLPassword)
+	fs.str("kafkaCaCert", k.Security.CACert)
+	fs.str("kafkaClientCert", k.Security.ClientCert)
+	fs.str("kafkaClientKey", k.Security.ClientKey)
+	fs.list("kafkaMirror", k.Mirrors)
+	fs.str("topicItems", k.Topics.Items)
+	fs.str("topicBidding", k.Topics.Bidding)
+	fs.str("biddingMode", k.Topics.BiddingMode)
+	fs.list("topicRoute", k.Topics.Routes)
+	fs.list("topicCondition", k.Topics.Conditions)
+	fs.str("topicRuns", k.Topics.Runs)
+	fs.bool("runMarkers", k.Topics.Markers)
+	fs.bool("checkTopics", k.Topics.Check)
+	fs.bool("createTopics", k.Topics.Create)
+	fs.int("topicPartitions", k.Topics.Partitions)
+	fs.int("topicReplicationFactor", k.Topics.ReplicationFactor)
+	fs.int("kafkaRetries", k.Retry.Max)
+	fs.duration("kafkaRetryBackoff", k.Retry.Backoff)
+	fs.duration("kafkaRetryMaxBackoff", k.Retry.MaxBackoff)
+	fs.int("kafkaMaxInflight", k.MaxInflight)
+	fs.duration("kafkaDeliveryTimeout", k.DeliveryTimeout)
+	fs.int("itemBuffer", k.ItemBuffer)
+	fs.int("kafkaMessagesPerSecond", k.MessagesPerSecond)
+	fs.int("kafkaBytesPerSecond", k.BytesPerSecond)
+	fs.int("kafkaBatchSize", k.BatchSize)
+	fs.duration("kafkaLinger", k.Linger)
+	fs.int("kafkaProducers", k.Producers)
+	fs.int("kafkaMaxProducers", k.MaxProducers)
+	fs.str("deadLetterTopic", c.DeadLetter.Topic)
+	fs.str("deadLetterFile", c.DeadLetter.File)
+	fs.int("redeliveries", c.Redelivery.Max)
+	fs.duration("redeliveryBackoff", c.Redelivery.Backoff)
+	fs.duration("redeliveryMaxBackoff", c.Redelivery.MaxBackoff)
+	fs.int("redeliveryQueueSize", c.Redelivery.QueueSize)
+	fs.str("redeliveryFile", c.Redelivery.File)
+	// other sinks
+	fs.str("sinkFile", c.File.Path)
+	if c.File.MaxSize != nil {
+		fs.add("sinkFileMaxSize", fmt.Sprint(*c.File.MaxSize))
+	}
+	fs.int("sinkFileMaxBackups", c.File.MaxBackups)
+	fs.str("pgDsn", c.Postgres.DSN)
+	fs.str("pgTable", c.Postgres.Table)
+	fs.str("pgColumns", c.Postgres.Columns)
+	fs.int("pgBatchSize", c.Postgres.BatchSize)
+	fs.str("amqpUrl", c.AMQP.URL)
+	fs.str("amqpExchange", c.AMQP.Exchange)
+	fs.str("amqpRoutingKey", c.AMQP.RoutingKey)
+	fs.str("pubsubProject", c.PubSub.Project)
+	fs.str("pubsubTopic", c.PubSub.Topic)
+	fs.str("kinesisRegion", c.Kinesis.Region)
+	fs.str("kinesisStream", c.Kinesis.Stream)
+	fs.str("webhookUrl", c.Webhook.URL)
+	fs.list("webhookHeader", c.Webhook.Headers)
+	fs.int("webhookBatchSize", c.Webhook.BatchSize)
+	fs.int("webhookConcurrency", c.Webhook.Concurrency)
+	fs.int("webhookRetries", c.Webhook.Retries)
+	// filters
+	fs.bool("dedup", c.Filters.Dedup)
+	fs.bool("diff", c.Filters.Diff)
+	fs.bool("tombstones", c.Filters.Tombstones)
+	fs.str("stateDir", c.Filters.StateDir)
+	fs.duration("dedupTtl", c.Filters.DedupTTL)
+	fs.bool("checkReferences", c.Filters.CheckReferences)
+	fs.int("referenceIndexSize", c.Filters.ReferenceIndexSize)
+	fs.int("duplicateIndexSize", c.Filters.DuplicateIndexSize)
+	fs.str("invalidText", c.Filters.InvalidText)
+	fs.int("maxPayload", c.Filters.MaxPayload)
+	fs.str("oversizedPayload", c.Filters.OversizedPayload)
+	fs.str("offloadDir", c.Filters.OffloadDir)
+	fs.list("redact", c.Filters.Redact)
+	fs.str("cloudEvents", c.CloudEvents.Mode)
+	fs.str("cloudEventsType", c.CloudEvents.Type)
+	fs.str("priceFormat", c.Prices.Format)
+	fs.str("priceCurrency", c.Prices.Currency)
+	fs.str("urlFormat", c.URLs.Format)
+	fs.str("encryptionKey", c.Encryption.Key)
+	fs.str("encryptionKeyId", c.Encryption.KeyID)
+	fs.str("encryptionKeyCommand", c.Encryption.KeyCommand)
+	fs.duration("itemTtl", c.Expiry.TTL)
+	fs.int("itemTtlIntervals", c.Expiry.Intervals)
+	if c.BiddingBudget.Limit != nil {
+		// budget is formatted without exponent, so it could be parsed as decimal
+		fs.add("biddingBudget", strconv.FormatFloat(*c.BiddingBudget.Limit, 'f', -1, 64))
+	}
+	fs.str("biddingBudgetPolicy", c.BiddingBudget.Policy)
+	fs.list("pipeline", c.Filters.Pipeline)
+	// metrics
+	m := c.Metrics
+	fs.str("metricsAddress", m.Address)
+	fs.str("metricsTlsCert", m.TLSCert)
+	fs.str("metricsTlsKey", m.TLSKey)
+	fs.str("metricsUser", m.User)
+	fs.str("metricsPassword", m.Password)
+	fs.bool("pprof", m.Pprof)
+	fs.bool("itemEndpoint", m.ItemEndpoint)
+	fs.int("runHistory", m.RunHistory)
+	fs.str("runHistoryFile", m.RunHistoryFile)
+	fs.str("summaryFile", m.SummaryFile)
+	fs.str("pushgatewayUrl", m.Pushgateway.URL)
+	fs.str("pushgatewayJob", m.Pushgateway.Job)
+	fs.str("pushgatewayInstance", m.Pushgateway.Instance)
+	fs.str("pushgatewayUser", m.Pushgateway.User)
+	fs.str("pushgatewayPassword", m.Pushgateway.Password)
+	// diagnostics
+	fs.str("archiveUrl", c.ArchiveURL)
+	fs.str("sentryDsn", c.Sentry.DSN)
+	fs.str("sentryEnvironment", c.Sentry.Environment)
+	fs.str("otlpEndpoint", c.Tracing.OTLPEndpoint)
+	fs.bool("otlpInsecure", c.Tracing.Insecure)
+	if c.Tracing.SampleRatio != nil {
+		fs.add("traceSampleRatio", fmt.Sprint(*c.Tracing.SampleRatio))
+	}
+	fs.bool("dryRun", c.DryRun.Enabled)
+	fs.int("dryRunPrint", c.DryRun.Print)
+	return fs
+}
+
+type flagSet []Flag
+
+func (fs *flagSet) add(name string, values ...string) {
+	*fs = append(*fs, Flag{Name: name, Values: values})
+}
+
+func (fs *flagSet) str(name, v string) {
+	if v != "" {
+		fs.add(name, v)
+	}
+}
+
+func (fs *flagSet) list(name string, vs []string) {
+	if len(vs) > 0 {
+		fs.add(name, vs...)
+	}
+}
+
+func (fs *flagSet) int(name string, v *int) {
+	if v != nil {
+		fs.add(name, fmt.Sprint(*v))
+	}
+}
+
+func (fs *flagSet) duration(name string, v *Duration) {
+	if v != nil {
+		fs.add(name, v.String())
+	}
+}
+
+func (fs *flagSet) bool(name string, v bool) {
+	if v {
+		fs.add(name)
+	}
+}
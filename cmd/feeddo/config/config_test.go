@@ -0,0 +1,116 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	require.NoError(t, os.Setenv("FEEDDO_TEST_PASSWORD", "from env"))
+	defer os.Unsetenv("FEEDDO_TEST_PASSWORD")
+	rate := 100
+	for _, file := range []string{"testdata/config.yaml", "testdata/config.toml"} {
+		t.Run(file, func(t *testing.T) {
+			c, err := Load(file)
+			require.NoError(t, err)
+			assert.Equal(t, []Feed{
+				{URL: "http://example.com/feed.xml"},
+				{URL: "http://example.com/hourly.xml", Interval: Duration(time.Hour), Alias: "hourly", OverlapPolicy: "queue", Priority: 10, ItemsPerSecond: &rate,
+					ItemElement: "ITEM", Charset: "windows-1250", ItemFilter: "PRICE_VAT>0", TopicItems: "hourly_items",
+					Method: "POST", Body: `{"date":"{date}"}`, ContentType: "application/json"},
+			}, c.Feeds)
+			assert.Equal(t, "from env", c.Kafka.Security.SASLPassword)
+			require.NotNil(t, c.Kafka.Retry.Max)
+			assert.Equal(t, 0, *c.Kafka.Retry.Max)
+
+			flags := map[string][]string{}
+			for _, f := range c.Flags() {
+				flags[f.Name] = f.Values
+			}
+			// every value of testdata is set, so every flag is returned
+			assert.Equal(t, 140, len(flags))
+			assert.Equal(t, []string{"http://example.com/feed.xml", "hourly=http://example.com/hourly.xml@1h0m0s"}, flags["feedUrl"])
+			assert.Equal(t, []string{"15m0s"}, flags["interval"])
+			assert.Equal(t, []string{"http://example.com/hourly.xml=queue"}, flags["feedOverlapPolicy"])
+			assert.Equal(t, []string{"http://example.com/hourly.xml=10"}, flags["feedPriority"])
+			assert.Equal(t, []string{"http://example.com/hourly.xml=100"}, flags["feedItemsPerSecond"])
+			assert.Equal(t, []string{"http://example.com/hourly.xml=PRICE_VAT>0"}, flags["feedItemFilter"])
+			assert.Equal(t, []string{"http://example.com/hourly.xml=POST"}, flags["feedMethod"])
+			assert.Equal(t, []string{`http://example.com/hourly.xml={"date":"{date}"}`}, flags["feedBody"])
+			assert.Equal(t, []string{"DELIVERY_DATE==0"}, flags["itemFilter"])
+			assert.Equal(t, []string{"kafka", "file"}, flags["sink"])
+			assert.Equal(t, []string{"from env"}, flags["kafkaSaslPassword"])
+			assert.Equal(t, []string{"0"}, flags["kafkaRetries"])
+			assert.Equal(t, []string{"200ms"}, flags["kafkaRetryBackoff"])
+			assert.Equal(t, []string{"500"}, flags["kafkaBatchSize"])
+			assert.Equal(t, []string{"20ms"}, flags["kafkaLinger"])
+			assert.Equal(t, []string{"Authorization: Bearer token"}, flags["webhookHeader"])
+			assert.Equal(t, []string{"0.5"}, flags["traceSampleRatio"])
+			assert.Equal(t, []string{"issues", "archive", "state"}, flags["pipeline"])
+			assert.Equal(t, []string{"binary"}, flags["cloudEvents"])
+			assert.Equal(t, []string{"object"}, flags["priceFormat"])
+			assert.Equal(t, []string{"CZK"}, flags["priceCurrency"])
+			assert.Equal(t, []string{"string"}, flags["urlFormat"])
+			assert.Equal(t, []string{"URL=drop", "EAN=hash"}, flags["redact"])
+			assert.Equal(t, []string{"replace"}, flags["invalidText"])
+			assert.Equal(t, []string{"48h0m0s"}, flags["itemTtl"])
+			assert.Equal(t, []string{"72h0m0s"}, flags["dedupTtl"])
+			assert.Equal(t, []string{"3"}, flags["itemTtlIntervals"])
+			assert.Equal(t, []string{"5000.5"}, flags["biddingBudget"])
+			assert.Equal(t, []string{"only"}, flags["biddingMode"])
+			assert.Equal(t, []string{"feeds_runs"}, flags["topicRuns"])
+			require.Contains(t, flags, "runMarkers")
+			require.Contains(t, flags, "dedup")
+			assert.Empty(t, flags["dedup"])
+		})
+	}
+}
+
+func TestFlagsNotConfigured(t *testing.T) {
+	assert.Empty(t, Config{}.Flags())
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		err     string
+	}{
+		{"missing file", "config.yaml", "", "Unable to read config file"},
+		{"unknown yaml key", "config.yaml", "kafka:\n  urls: localhost:9092\n", "line 2: field urls not found"},
+		{"unknown toml key", "config.toml", "[kafka]\nurls = \"localhost:9092\"\n", "unknown key 'kafka.urls'"},
+		{"wrong type", "config.yaml", "kafka:\n  retry:\n    max: many\n", "line 3: cannot unmarshal !!str `many` into int"},
+		{"wrong duration", "config.yaml", "scheduling:\n  interval: often\n", "invalid duration"},
+		{"wrong feed url", "config.yaml", "feeds:\n  - url: example.com\n", "Value of 'feeds[0].url' is not valid"},
+		{"negative feed interval", "config.yaml", "feeds:\n  - url: http://example.com\n    interval: -1m\n", "Value of 'feeds[0].interval' is not valid because of value should not be negative"},
+		{"wrong feed alias", "config.yaml", "feeds:\n  - url: http://example.com\n    alias: my feed\n", "Value of 'feeds[0].alias' is not valid because of alias should consist of letters, digits, '_', '.' and '-'"},
+		{"negative feed throughput", "config.yaml", "feeds:\n  - url: http://example.com\n    itemsPerSecond: -1\n", "Value of 'feeds[0].itemsPerSecond' is not valid because of value should not be negative"},
+		{"negative number", "config.toml", "[webhook]\nretries = -1\n", "Value of 'webhook.retries' is not valid because of value should not be negative"},
+		{"negative dedup ttl", "config.yaml", "filters:\n  dedupTtl: -1h\n", "Value of 'filters.dedupTtl' is not valid because of value should not be negative"},
+		{"negative size", "config.yaml", "file:\n  maxSize: -1\n", "Value of 'file.maxSize' is not valid"},
+		{"wrong pushgateway url", "config.yaml", "metrics:\n  pushgateway:\n    url: localhost\n", "Value of 'metrics.pushgateway.url' is not valid"},
+		{"zero budget", "config.yaml", "biddingBudget:\n  limit: 0\n", "Value of 'biddingBudget.limit' is not valid because of budget should be positive"},
+		{"wrong sample ratio", "config.yaml", "tracing:\n  sampleRatio: 2\n", "Value of 'tracing.sampleRatio' is not valid because of ratio should be between 0 and 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, tt.file)
+			if tt.content != "" {
+				require.NoError(t, ioutil.WriteFile(file, []byte(tt.content), 0644))
+			}
+			_, err = Load(file)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/convert"
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/parser"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/jessevdk/go-flags"
+)
+
+const (
+	// convertCommand is the first argument which switches app into conversion of feed into other format
+	convertCommand = "convert"
+	// convertStdout is output which writes converted feed into stdout
+	convertStdout = "-"
+)
+
+// convertOptions define which feed is converted and where
+type convertOptions struct {
+	feed     *url.URL
+	format   string
+	output   string
+	currency string
+}
+
+// parseConvertArgs parses flags of convert command
+func parseConvertArgs(args []string) (convertOptions, error) {
+	var opts struct {
+		Feed     string `short:"f" long:"feedUrl" description:"Converted feed: 'http(s)://' or 'file://' url" required:"true"`
+		To       string `long:"to" description:"Format of converted feed: 'ndjson', 'csv' or 'google'" required:"true"`
+		Output   string `short:"o" long:"output" description:"File which converted feed is written into, '-' is stdout" default:"-"`
+		Currency string `long:"currency" description:"Currency of prices, formats without currency ignore it" default:"CZK"`
+	}
+	parser := flags.NewParser(&opts, flags.PassDoubleDash|flags.IgnoreUnknown)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		return convertOptions{}, fmt.Errorf("Unable to parse flags: %w", err)
+	}
+	feed, err := url.Parse(strings.TrimSpace(opts.Feed))
+	if err != nil {
+		return convertOptions{}, fmt.Errorf("Unable to parse feed url '%s' because of %w", opts.Feed, err)
+	}
+	if !feed.IsAbs() {
+		return convertOptions{}, fmt.Errorf("Feed url '%s' should be absolute", opts.Feed)
+	}
+	if err := convert.Validate(opts.To); err != nil {
+		return convertOptions{}, err
+	}
+	if opts.Output == "" {
+		return convertOptions{}, fmt.Errorf("Output should not be empty, use '%s' for stdout", convertStdout)
+	}
+	return convertOptions{feed: feed, format: opts.To, output: opts.Output, currency: opts.Currency}, nil
+}
+
+// convertRun writes products of feed in other format into output
+func convertRun(args []string) error {
+	opts, err := parseConvertArgs(args)
+	if err != nil {
+		return &apperror.ConfigError{Err: err}
+	}
+	ctx := context.Background()
+	stream, err := provider.CreateStream(ctx, opts.feed)
+	if err != nil {
+		return fmt.Errorf("Failed to get stream: %w", err)
+	}
+	defer stream.Close()
+	var out io.Writer = os.Stdout
+	if opts.output != convertStdout {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			return fmt.Errorf("Unable to create file `%s` because of %w", opts.output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+	items, parseErrors, err := convertFeed(ctx, stream, bw, opts)
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("Unable to write converted feed because of %w", err)
+	}
+	log.Printf("%d items of feed '%s' were converted to %s", items, opts.feed, opts.format)
+	if parseErrors > 0 {
+		return &apperror.ParseError{Err: fmt.Errorf("%d items of feed '%s' could not be parsed", parseErrors, opts.feed)}
+	}
+	return nil
+}
+
+// convertFeed writes products as soon as they are parsed, items which could not be parsed are logged and skipped.
+// Number of written products and number of parse errors are returned.
+func convertFeed(ctx context.Context, r io.Reader, w io.Writer, opts convertOptions) (int, int, error) {
+	cw, err := convert.New(opts.format, w, convert.Options{Currency: opts.currency, Title: opts.feed.String()})
+	if err != nil {
+		return 0, 0, err
+	}
+	written := 0
+	parseErrors, err := readProducts(ctx, r, opts.feed, func(p product.Product) error {
+		if err := cw.Write(p); err != nil {
+			return err
+		}
+		written++
+		return nil
+	})
+	if err != nil {
+		return written, parseErrors, err
+	}
+	return written, parseErrors, cw.Close()
+}
+
+// readProducts calls fn for every product of feed as soon as it is parsed, items which could not be parsed are logged and skipped.
+// Reading stops on the first error of fn. Number of parse errors is returned.
+func readProducts(ctx context.Context, r io.Reader, feed *url.URL, fn func(p product.Product) error) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	// parser stops as soon as fn fails
+	defer cancel()
+	chanItems, chanErrors := parser.ProcessFeed(ctx, ioutil.NopCloser(r))
+	parseErrors := 0
+	for chanItems != nil || chanErrors != nil {
+		select {
+		case item, ok := <-chanItems:
+			if !ok {
+				chanItems = nil
+				continue
+			}
+			if err := fn(product.FromHeureka(item)); err != nil {
+				return parseErrors, err
+			}
+		case err, ok := <-chanErrors:
+			if !ok {
+				chanErrors = nil
+				continue
+			}
+			parseErrors++
+			log.Printf("Unable to read item of feed '%s' because of %v", feed, err)
+		}
+	}
+	return parseErrors, nil
+}
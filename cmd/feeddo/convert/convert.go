@@ -0,0 +1,109 @@
+// Package convert writes products of feeds in other formats, e.g. for offline transformation of feeds.
+// Products are the same as payload of items delivered by sinks, so converted feeds have the same field semantics as the pipeline.
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+)
+
+// FormatNDJSON writes products as json lines
+const FormatNDJSON = "ndjson"
+
+// Writer writes products in some format
+type Writer interface {
+	Write(p product.Product) error
+	// Close finishes document, e.g. closes root element. Underlying writer is not closed.
+	Close() error
+}
+
+// Options are settings of formats, formats ignore settings which are not relevant for them
+type Options struct {
+	// Currency of prices, e.g. 'CZK'
+	Currency string
+	// Title of converted feed
+	Title string
+}
+
+// Factory creates writer of format into w
+type Factory func(w io.Writer, o Options) (Writer, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+func init() {
+	Register(FormatNDJSON, func(w io.Writer, o Options) (Writer, error) {
+		return ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	})
+}
+
+// Register makes format available by name. It is usually called from init of file implementing format.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("Format '%s' is already registered", name))
+	}
+	factories[name] = f
+}
+
+// Formats returns sorted names of registered formats
+func Formats() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return formatsLocked()
+}
+
+// Validate checks that format with provided name is registered
+func Validate(name string) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	if _, ok := factories[name]; !ok {
+		return fmt.Errorf("Format '%s' is not supported, use one of %s", name, strings.Join(formatsLocked(), ", "))
+	}
+	return nil
+}
+
+func formatsLocked() []string {
+	names := make([]string, 0, len(factories))
+	for n := range factories {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates writer of registered format
+func New(name string, w io.Writer, o Options) (Writer, error) {
+	mu.RLock()
+	f, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Format '%s' is not registered", name)
+	}
+	return f(w, o)
+}
+
+// ndjsonWriter writes every product as json line, json is the same as payload of items delivered by sinks
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (nw ndjsonWriter) Write(p product.Product) error {
+	if err := nw.enc.Encode(p); err != nil {
+		return fmt.Errorf("Unable to write product %s because of %w", p.ID, err)
+	}
+	return nil
+}
+
+func (nw ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package convert
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProduct has every field set
+var testProduct = product.Product{
+	ID:                   "34644",
+	Name:                 "Epson T061240, azurová C13T061240",
+	Title:                "Epson T061240",
+	Description:          "Cartridge, \"azurová\" barva",
+	URL:                  "http://www.czc.cz/34644/produkt",
+	ImageURL:             "https://iczc.cz/1/obrazek",
+	AlternativeImageURLs: []string{"https://iczc.cz/2/obrazek", "https://iczc.cz/3/obrazek"},
+	PriceVAT:             decimal.New(269, 0),
+	VAT:                  "21%",
+	CPC:                  decimal.New(15, -1),
+	Manufacturer:         "Epson",
+	Category:             "Heureka.cz | Elektronika",
+	EAN:                  "8715946360188",
+	Parameters:           []product.Parameter{{Name: "Distribuce", Value: "CZ"}},
+	DeliveryDate:         "0",
+	Deliveries:           []product.Delivery{{ID: "PPL", Price: decimal.New(99, 0)}},
+	GroupID:              "T0612",
+}
+
+func TestFormats(t *testing.T) {
+	assert.Equal(t, []string{FormatCSV, FormatGoogle, FormatNDJSON}, Formats())
+	assert.NoError(t, Validate(FormatCSV))
+	assert.EqualError(t, Validate("yaml"), "Format 'yaml' is not supported, use one of csv, google, ndjson")
+	_, err := New("yaml", &bytes.Buffer{}, Options{})
+	assert.EqualError(t, err, "Format 'yaml' is not registered")
+}
+
+func TestRegisterTwice(t *testing.T) {
+	assert.Panics(t, func() {
+		Register(FormatNDJSON, func(w io.Writer, o Options) (Writer, error) { return nil, nil })
+	})
+}
+
+func TestNDJSON(t *testing.T) {
+	var out bytes.Buffer
+	w, err := New(FormatNDJSON, &out, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(product.Product{ID: "1", PriceVAT: decimal.New(100, 0)}))
+	require.NoError(t, w.Write(product.Product{ID: "2"}))
+	require.NoError(t, w.Close())
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.Contains(t, string(lines[0]), `"id":"1"`)
+	assert.Contains(t, string(lines[0]), `"priceWithVat":"100"`)
+	assert.Contains(t, string(lines[1]), `"id":"2"`)
+}
@@ -0,0 +1,94 @@
+package convert
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+)
+
+const (
+	// FormatCSV writes products as csv with header, columns are named by json fields of products
+	FormatCSV = "csv"
+	// csvListSeparator separates values of lists in single column
+	csvListSeparator = "|"
+)
+
+// csvColumns are columns of products, lists are joined by csvListSeparator, parameters are written as 'name=value'
+var csvColumns = []struct {
+	name  string
+	value func(p product.Product) string
+}{
+	{"id", func(p product.Product) string { return p.ID }},
+	{"name", func(p product.Product) string { return p.Name }},
+	{"product", func(p product.Product) string { return p.Title }},
+	{"description", func(p product.Product) string { return p.Description }},
+	{"url", func(p product.Product) string { return p.URL }},
+	{"imageUrl", func(p product.Product) string { return p.ImageURL }},
+	{"imageUrlsAlternate", func(p product.Product) string { return strings.Join(p.AlternativeImageURLs, csvListSeparator) }},
+	{"videoUrl", func(p product.Product) string { return p.VideoURL }},
+	{"priceWithVat", func(p product.Product) string { return p.PriceVAT.String() }},
+	{"vat", func(p product.Product) string { return p.VAT }},
+	{"type", func(p product.Product) string { return p.Type }},
+	{"cpc", func(p product.Product) string { return p.CPC.String() }},
+	{"manufacterer", func(p product.Product) string { return p.Manufacturer }},
+	{"category", func(p product.Product) string { return p.Category }},
+	{"ean", func(p product.Product) string { return p.EAN }},
+	{"isbn", func(p product.Product) string { return p.ISBN }},
+	{"parameters", func(p product.Product) string {
+		params := make([]string, 0, len(p.Parameters))
+		for _, param := range p.Parameters {
+			params = append(params, param.Name+"="+param.Value)
+		}
+		return strings.Join(params, csvListSeparator)
+	}},
+	{"deliveryDay", func(p product.Product) string { return p.DeliveryDate }},
+	{"groupId", func(p product.Product) string { return p.GroupID }},
+	{"accessories", func(p product.Product) string { return strings.Join(p.Accessories, csvListSeparator) }},
+	{"dues", func(p product.Product) string { return p.Dues.String() }},
+}
+
+func init() {
+	Register(FormatCSV, func(w io.Writer, o Options) (Writer, error) {
+		cw := csvWriter{w: csv.NewWriter(w)}
+		header := make([]string, 0, len(csvColumns))
+		for _, c := range csvColumns {
+			header = append(header, c.name)
+		}
+		if err := cw.write(header); err != nil {
+			return nil, fmt.Errorf("Unable to write header because of %w", err)
+		}
+		return cw, nil
+	})
+}
+
+// csvWriter writes every product as row
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw csvWriter) Write(p product.Product) error {
+	row := make([]string, 0, len(csvColumns))
+	for _, c := range csvColumns {
+		row = append(row, c.value(p))
+	}
+	if err := cw.write(row); err != nil {
+		return fmt.Errorf("Unable to write product %s because of %w", p.ID, err)
+	}
+	return nil
+}
+
+func (cw csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// write writes row, errors of underlying writer are reported when buffer is flushed
+func (cw csvWriter) write(row []string) error {
+	if err := cw.w.Write(row); err != nil {
+		return err
+	}
+	return cw.w.Error()
+}
@@ -0,0 +1,23 @@
+package convert
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSV(t *testing.T) {
+	var out bytes.Buffer
+	w, err := New(FormatCSV, &out, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testProduct))
+	require.NoError(t, w.Write(product.Product{ID: "2"}))
+	require.NoError(t, w.Close())
+	assert.Equal(t, `id,name,product,description,url,imageUrl,imageUrlsAlternate,videoUrl,priceWithVat,vat,type,cpc,manufacterer,category,ean,isbn,parameters,deliveryDay,groupId,accessories,dues
+34644,"Epson T061240, azurová C13T061240",Epson T061240,"Cartridge, ""azurová"" barva",http://www.czc.cz/34644/produkt,https://iczc.cz/1/obrazek,https://iczc.cz/2/obrazek|https://iczc.cz/3/obrazek,,269,21%,,1.5,Epson,Heureka.cz | Elektronika,8715946360188,,Distribuce=CZ,0,T0612,,0
+2,,,,,,,,0,,,0,,,,,,,,,0
+`, out.String())
+}
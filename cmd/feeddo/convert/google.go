@@ -0,0 +1,143 @@
+package convert
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+)
+
+const (
+	// FormatGoogle writes products as Google Shopping feed (RSS 2.0 with 'g' namespace)
+	FormatGoogle = "google"
+	// googleNamespace is namespace of Google Shopping elements
+	googleNamespace = "http://base.google.com/ns/1.0"
+	// availability of products which could be delivered immediately (DELIVERY_DATE 0 in heureka feed),
+	// other products with delivery date are available on backorder
+	googleInStock   = "in_stock"
+	googleBackorder = "backorder"
+)
+
+// googleItem is item of Google Shopping feed
+type googleItem struct {
+	XMLName              xml.Name         `xml:"item"`
+	ID                   string           `xml:"g:id"`
+	Title                string           `xml:"title"`
+	Description          string           `xml:"description,omitempty"`
+	Link                 string           `xml:"link,omitempty"`
+	ImageLink            string           `xml:"g:image_link,omitempty"`
+	AdditionalImageLinks []string         `xml:"g:additional_image_link"`
+	Price                string           `xml:"g:price,omitempty"`
+	Availability         string           `xml:"g:availability,omitempty"`
+	Brand                string           `xml:"g:brand,omitempty"`
+	GTIN                 string           `xml:"g:gtin,omitempty"`
+	ProductType          string           `xml:"g:product_type,omitempty"`
+	ItemGroupID          string           `xml:"g:item_group_id,omitempty"`
+	Shipping             []googleShipping `xml:"g:shipping"`
+	Details              []googleDetail   `xml:"g:product_detail"`
+}
+
+// googleShipping is delivery option of item
+type googleShipping struct {
+	Service string `xml:"g:service"`
+	Price   string `xml:"g:price"`
+}
+
+// googleDetail is parameter of item
+type googleDetail struct {
+	Name  string `xml:"g:attribute_name"`
+	Value string `xml:"g:attribute_value"`
+}
+
+func init() {
+	Register(FormatGoogle, func(w io.Writer, o Options) (Writer, error) {
+		gw := googleWriter{enc: xml.NewEncoder(w), currency: o.Currency}
+		if err := gw.start(o.Title); err != nil {
+			return nil, fmt.Errorf("Unable to write header because of %w", err)
+		}
+		return gw, nil
+	})
+}
+
+// googleWriter writes products as items of channel of RSS document
+type googleWriter struct {
+	enc      *xml.Encoder
+	currency string
+}
+
+// start writes start of document and title of channel
+func (gw googleWriter) start(title string) error {
+	tokens := []xml.Token{
+		xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)},
+		xml.StartElement{Name: xml.Name{Local: "rss"}, Attr: []xml.Attr{
+			{Name: xml.Name{Local: "version"}, Value: "2.0"},
+			{Name: xml.Name{Local: "xmlns:g"}, Value: googleNamespace},
+		}},
+		xml.StartElement{Name: xml.Name{Local: "channel"}},
+	}
+	for _, t := range tokens {
+		if err := gw.enc.EncodeToken(t); err != nil {
+			return err
+		}
+	}
+	return gw.enc.EncodeElement(title, xml.StartElement{Name: xml.Name{Local: "title"}})
+}
+
+func (gw googleWriter) Write(p product.Product) error {
+	item := googleItem{
+		ID:                   p.ID,
+		Title:                p.Name,
+		Description:          p.Description,
+		Link:                 p.URL,
+		ImageLink:            p.ImageURL,
+		AdditionalImageLinks: p.AlternativeImageURLs,
+		Brand:                p.Manufacturer,
+		GTIN:                 p.EAN,
+		ProductType:          p.Category,
+		ItemGroupID:          p.GroupID,
+	}
+	if item.Title == "" {
+		item.Title = p.Title
+	}
+	if !p.PriceVAT.IsZero() {
+		item.Price = gw.price(p.PriceVAT.StringFixed(2))
+	}
+	switch p.DeliveryDate {
+	case "":
+	case "0":
+		item.Availability = googleInStock
+	default:
+		item.Availability = googleBackorder
+	}
+	for _, d := range p.Deliveries {
+		item.Shipping = append(item.Shipping, googleShipping{Service: d.ID, Price: gw.price(d.Price.StringFixed(2))})
+	}
+	for _, param := range p.Parameters {
+		item.Details = append(item.Details, googleDetail{Name: param.Name, Value: param.Value})
+	}
+	if err := gw.enc.Encode(item); err != nil {
+		return fmt.Errorf("Unable to write product %s because of %w", p.ID, err)
+	}
+	return nil
+}
+
+// price returns amount with currency
+func (gw googleWriter) price(amount string) string {
+	if gw.currency == "" {
+		return amount
+	}
+	return amount + " " + gw.currency
+}
+
+func (gw googleWriter) Close() error {
+	for _, name := range []string{"channel", "rss"} {
+		if err := gw.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}}); err != nil {
+			return fmt.Errorf("Unable to finish document because of %w", err)
+		}
+	}
+	if err := gw.enc.Flush(); err != nil {
+		return fmt.Errorf("Unable to finish document because of %w", err)
+	}
+	return nil
+}
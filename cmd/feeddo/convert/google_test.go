@@ -0,0 +1,30 @@
+package convert
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoogle(t *testing.T) {
+	var out bytes.Buffer
+	w, err := New(FormatGoogle, &out, Options{Currency: "CZK", Title: "Feed & co"})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testProduct))
+	require.NoError(t, w.Write(product.Product{ID: "2", Title: "Title only", DeliveryDate: "3"}))
+	require.NoError(t, w.Close())
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<rss version="2.0" xmlns:g="http://base.google.com/ns/1.0"><channel><title>Feed &amp; co</title>`+
+		`<item><g:id>34644</g:id><title>Epson T061240, azurová C13T061240</title><description>Cartridge, &#34;azurová&#34; barva</description>`+
+		`<link>http://www.czc.cz/34644/produkt</link><g:image_link>https://iczc.cz/1/obrazek</g:image_link>`+
+		`<g:additional_image_link>https://iczc.cz/2/obrazek</g:additional_image_link><g:additional_image_link>https://iczc.cz/3/obrazek</g:additional_image_link>`+
+		`<g:price>269.00 CZK</g:price><g:availability>in_stock</g:availability><g:brand>Epson</g:brand><g:gtin>8715946360188</g:gtin>`+
+		`<g:product_type>Heureka.cz | Elektronika</g:product_type><g:item_group_id>T0612</g:item_group_id>`+
+		`<g:shipping><g:service>PPL</g:service><g:price>99.00 CZK</g:price></g:shipping>`+
+		`<g:product_detail><g:attribute_name>Distribuce</g:attribute_name><g:attribute_value>CZ</g:attribute_value></g:product_detail></item>`+
+		`<item><g:id>2</g:id><title>Title only</title><g:availability>backorder</g:availability></item>`+
+		`</channel></rss>`, out.String())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConvertArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"defaults", []string{"-f", "file://testdata/one_item.xml", "--to", "csv"}, ""},
+		{"no feed", []string{"--to", "csv"}, "Unable to parse flags: the required flag `-f, --feedUrl' was not specified"},
+		{"no format", []string{"-f", "file://testdata/one_item.xml"}, "Unable to parse flags: the required flag `--to' was not specified"},
+		{"relative feed", []string{"-f", "feed.xml", "--to", "csv"}, "Feed url 'feed.xml' should be absolute"},
+		{"unknown format", []string{"-f", "file://feed.xml", "--to", "yaml"}, "Format 'yaml' is not supported, use one of csv, google, ndjson"},
+		{"empty output", []string{"-f", "file://feed.xml", "--to", "csv", "-o", ""}, "Output should not be empty, use '-' for stdout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseConvertArgs(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "file://testdata/one_item.xml", opts.feed.String())
+			assert.Equal(t, "csv", opts.format)
+			assert.Equal(t, convertStdout, opts.output)
+			assert.Equal(t, "CZK", opts.currency)
+		})
+	}
+}
+
+func TestConvertFeed(t *testing.T) {
+	feed, err := url.Parse("file://testdata/feed.xml")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	written, parseErrors, err := convertFeed(context.Background(), strings.NewReader(validateItems), &out, convertOptions{feed: feed, format: "ndjson"})
+	require.NoError(t, err)
+	// items with values which could not be parsed are skipped
+	assert.Equal(t, 3, written)
+	assert.Equal(t, 2, parseErrors)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"id":"1"`)
+	assert.Contains(t, lines[2], `"name":"no id"`)
+}
+
+func TestConvertRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convert")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "feed.csv")
+
+	require.NoError(t, convertRun([]string{"-f", "file://testdata/one_item.xml", "--to", "csv", "-o", output}))
+	converted, err := ioutil.ReadFile(output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(converted)), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[1], `34644,"Epson T061240, azurová C13T061240"`))
+
+	err = convertRun([]string{"-f", "file://testdata/badFeed.xml", "--to", "google", "-o", output})
+	var parseErr *apperror.ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Error(t, convertRun([]string{"--to", "csv"}))
+}
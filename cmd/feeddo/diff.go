@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/jessevdk/go-flags"
+)
+
+const (
+	// diffCommand is the first argument which switches app into comparison of two snapshots of feed
+	diffCommand = "diff"
+	// kinds of changes of items
+	diffAdded   = "added"
+	diffRemoved = "removed"
+	diffChanged = "changed"
+)
+
+// diffOptions define which snapshots are compared and how differences are reported
+type diffOptions struct {
+	old    *url.URL
+	new    *url.URL
+	format string
+	// examples is max number of ids listed per kind of change
+	examples int
+	// changes is file which change set is written into, change set is not written when it is empty
+	changes string
+}
+
+// diffChanges counts items with the same kind of change
+type diffChanges struct {
+	Count    int      `json:"count"`
+	Examples []string `json:"examples"`
+}
+
+// diffReport is result of comparison of snapshots
+type diffReport struct {
+	Old      string `json:"old"`
+	New      string `json:"new"`
+	OldItems int    `json:"oldItems"`
+	NewItems int    `json:"newItems"`
+	// ParseErrors are numbers of items of snapshots which could not be parsed, they are not compared
+	OldParseErrors int         `json:"oldParseErrors"`
+	NewParseErrors int         `json:"newParseErrors"`
+	Added          diffChanges `json:"added"`
+	Removed        diffChanges `json:"removed"`
+	Changed        diffChanges `json:"changed"`
+	Unchanged      int         `json:"unchanged"`
+}
+
+// diffChange is record of change set
+type diffChange struct {
+	ID     string `json:"id"`
+	Change string `json:"change"`
+	// Product is new content of added or changed item
+	Product *product.Product `json:"product,omitempty"`
+}
+
+// parseDiffArgs parses flags of diff command
+func parseDiffArgs(args []string) (diffOptions, error) {
+	var opts struct {
+		Old      string `long:"old" description:"Previous snapshot of feed: 'http(s)://' or 'file://' url" required:"true"`
+		New      string `long:"new" description:"Current snapshot of feed: 'http(s)://' or 'file://' url" required:"true"`
+		Format   string `long:"format" description:"Format of report: 'text' or 'json'" default:"text"`
+		Examples int    `long:"examples" description:"Max number of ids listed per kind of change" default:"3"`
+		Changes  string `long:"changes" description:"File which change set is written into as json lines, '-' is stdout"`
+	}
+	parser := flags.NewParser(&opts, flags.PassDoubleDash|flags.IgnoreUnknown)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		return diffOptions{}, fmt.Errorf("Unable to parse flags: %w", err)
+	}
+	oldFeed, err := parseDiffURL(opts.Old)
+	if err != nil {
+		return diffOptions{}, err
+	}
+	newFeed, err := parseDiffURL(opts.New)
+	if err != nil {
+		return diffOptions{}, err
+	}
+	if opts.Format != reportFormatText && opts.Format != reportFormatJSON {
+		return diffOptions{}, fmt.Errorf("Unknown format of report '%s', supported formats are '%s' and '%s'", opts.Format, reportFormatText, reportFormatJSON)
+	}
+	if opts.Examples < 0 {
+		return diffOptions{}, fmt.Errorf("Number of examples should not be negative")
+	}
+	return diffOptions{old: oldFeed, new: newFeed, format: opts.Format, examples: opts.Examples, changes: opts.Changes}, nil
+}
+
+// parseDiffURL parses url of snapshot
+func parseDiffURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse feed url '%s' because of %w", raw, err)
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("Feed url '%s' should be absolute", raw)
+	}
+	return u, nil
+}
+
+// diffRun prints differences of snapshots into stdout and writes change set when it is requested
+func diffRun(args []string) error {
+	opts, err := parseDiffArgs(args)
+	if err != nil {
+		return &apperror.ConfigError{Err: err}
+	}
+	ctx := context.Background()
+	oldStream, err := provider.CreateStream(ctx, opts.old)
+	if err != nil {
+		return fmt.Errorf("Failed to get stream: %w", err)
+	}
+	defer oldStream.Close()
+	newStream, err := provider.CreateStream(ctx, opts.new)
+	if err != nil {
+		return fmt.Errorf("Failed to get stream: %w", err)
+	}
+	defer newStream.Close()
+	var changes *bufio.Writer
+	if opts.changes != "" {
+		var out io.Writer = os.Stdout
+		if opts.changes != convertStdout {
+			f, err := os.Create(opts.changes)
+			if err != nil {
+				return fmt.Errorf("Unable to create file `%s` because of %w", opts.changes, err)
+			}
+			defer f.Close()
+			out = f
+		}
+		changes = bufio.NewWriter(out)
+	}
+	var report diffReport
+	if changes != nil {
+		report, err = diffFeeds(ctx, oldStream, newStream, changes, opts)
+		if err == nil {
+			if err = changes.Flush(); err != nil {
+				err = fmt.Errorf("Unable to write change set because of %w", err)
+			}
+		}
+	} else {
+		// untyped nil, nil *bufio.Writer would not be recognized as missing change set
+		report, err = diffFeeds(ctx, oldStream, newStream, nil, opts)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeDiffReport(os.Stdout, report, opts.format); err != nil {
+		return err
+	}
+	if report.OldParseErrors > 0 || report.NewParseErrors > 0 {
+		return &apperror.ParseError{Err: fmt.Errorf("%d items of snapshots could not be parsed", report.OldParseErrors+report.NewParseErrors)}
+	}
+	return nil
+}
+
+// diffFeeds compares snapshots by ids and content hashes of items. Only hashes of previous snapshot are kept in memory,
+// current snapshot is streamed and added or changed items are written into changes as soon as they are read.
+// Removed items are written at the end ordered by id. Changes are not written when changes is nil.
+func diffFeeds(ctx context.Context, oldFeed, newFeed io.Reader, changes io.Writer, opts diffOptions) (diffReport, error) {
+	report := diffReport{
+		Old:     opts.old.String(),
+		New:     opts.new.String(),
+		Added:   diffChanges{Examples: []string{}},
+		Removed: diffChanges{Examples: []string{}},
+		Changed: diffChanges{Examples: []string{}},
+	}
+	var enc *json.Encoder
+	if changes != nil {
+		enc = json.NewEncoder(changes)
+	}
+	emit := func(c diffChange) error {
+		if enc == nil {
+			return nil
+		}
+		if err := enc.Encode(c); err != nil {
+			return fmt.Errorf("Unable to write change of item %s because of %w", c.ID, err)
+		}
+		return nil
+	}
+	hashes := map[string]string{}
+	var err error
+	report.OldParseErrors, err = readProducts(ctx, oldFeed, opts.old, func(p product.Product) error {
+		report.OldItems++
+		hashes[p.ID] = contentHash(p)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	seen := make(map[string]bool, len(hashes))
+	report.NewParseErrors, err = readProducts(ctx, newFeed, opts.new, func(p product.Product) error {
+		report.NewItems++
+		// the first occurrence of duplicated id is compared only
+		if seen[p.ID] {
+			return nil
+		}
+		seen[p.ID] = true
+		hash, ok := hashes[p.ID]
+		switch {
+		case !ok:
+			report.Added.add(p.ID, opts.examples)
+			return emit(diffChange{ID: p.ID, Change: diffAdded, Product: &p})
+		case hash != contentHash(p):
+			report.Changed.add(p.ID, opts.examples)
+			return emit(diffChange{ID: p.ID, Change: diffChanged, Product: &p})
+		default:
+			report.Unchanged++
+			return nil
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+	removed := []string{}
+	for id := range hashes {
+		if !seen[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+	for _, id := range removed {
+		report.Removed.add(id, opts.examples)
+		if err := emit(diffChange{ID: id, Change: diffRemoved}); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// add counts item and keeps its id as example unless there are enough examples
+func (c *diffChanges) add(id string, examples int) {
+	c.Count++
+	if len(c.Examples) < examples {
+		c.Examples = append(c.Examples, id)
+	}
+}
+
+// writeDiffReport writes report in format
+func writeDiffReport(w io.Writer, report diffReport, format string) error {
+	var err error
+	if format == reportFormatJSON {
+		err = json.NewEncoder(w).Encode(report)
+	} else {
+		_, err = io.WriteString(w, diffText(report))
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write report because of %w", err)
+	}
+	return nil
+}
+
+// diffText formats report as human readable text
+func diffText(report diffReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "old: %s\nnew: %s\n", report.Old, report.New)
+	fmt.Fprintf(&b, "old items: %d (parse errors: %d)\n", report.OldItems, report.OldParseErrors)
+	fmt.Fprintf(&b, "new items: %d (parse errors: %d)\n", report.NewItems, report.NewParseErrors)
+	for _, c := range []struct {
+		name    string
+		changes diffChanges
+	}{{diffAdded, report.Added}, {diffRemoved, report.Removed}, {diffChanged, report.Changed}} {
+		fmt.Fprintf(&b, "%s: %d\n", c.name, c.changes.Count)
+		for _, id := range c.changes.Examples {
+			fmt.Fprintf(&b, "  %s\n", id)
+		}
+	}
+	fmt.Fprintf(&b, "unchanged: %d\n", report.Unchanged)
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	diffOld = `<?xml version="1.0" encoding="UTF-8"?>
+<SHOP>
+	<SHOPITEM><ITEM_ID>1</ITEM_ID><PRICE_VAT>100</PRICE_VAT></SHOPITEM>
+	<SHOPITEM><ITEM_ID>2</ITEM_ID><PRICE_VAT>200</PRICE_VAT></SHOPITEM>
+	<SHOPITEM><ITEM_ID>3</ITEM_ID><PRICE_VAT>300</PRICE_VAT></SHOPITEM>
+	<SHOPITEM><ITEM_ID>4</ITEM_ID><PRICE_VAT>400</PRICE_VAT></SHOPITEM>
+</SHOP>`
+	diffNew = `<?xml version="1.0" encoding="UTF-8"?>
+<SHOP>
+	<SHOPITEM><ITEM_ID>1</ITEM_ID><PRICE_VAT>100</PRICE_VAT></SHOPITEM>
+	<SHOPITEM><ITEM_ID>2</ITEM_ID><PRICE_VAT>250</PRICE_VAT></SHOPITEM>
+	<SHOPITEM><ITEM_ID>5</ITEM_ID><PRICE_VAT>500</PRICE_VAT></SHOPITEM>
+	<SHOPITEM><ITEM_ID>5</ITEM_ID><PRICE_VAT>500</PRICE_VAT></SHOPITEM>
+	<SHOPITEM><ITEM_ID>6</ITEM_ID><PRICE_VAT>abc</PRICE_VAT></SHOPITEM>
+</SHOP>`
+)
+
+func TestDiffFeeds(t *testing.T) {
+	oldFeed, err := url.Parse("file://old.xml")
+	require.NoError(t, err)
+	newFeed, err := url.Parse("file://new.xml")
+	require.NoError(t, err)
+	opts := diffOptions{old: oldFeed, new: newFeed, examples: 1}
+	var changes bytes.Buffer
+	report, err := diffFeeds(context.Background(), strings.NewReader(diffOld), strings.NewReader(diffNew), &changes, opts)
+	require.NoError(t, err)
+	assert.Equal(t, diffReport{
+		Old:            "file://old.xml",
+		New:            "file://new.xml",
+		OldItems:       4,
+		NewItems:       4,
+		NewParseErrors: 1,
+		Added:          diffChanges{Count: 1, Examples: []string{"5"}},
+		Removed:        diffChanges{Count: 2, Examples: []string{"3"}},
+		Changed:        diffChanges{Count: 1, Examples: []string{"2"}},
+		Unchanged:      1,
+	}, report)
+
+	lines := strings.Split(strings.TrimSpace(changes.String()), "\n")
+	require.Len(t, lines, 4)
+	expected := []struct {
+		id, change, price string
+	}{{"2", diffChanged, "250"}, {"5", diffAdded, "500"}, {"3", diffRemoved, ""}, {"4", diffRemoved, ""}}
+	for i, e := range expected {
+		var c struct {
+			ID      string `json:"id"`
+			Change  string `json:"change"`
+			Product *struct {
+				Price string `json:"priceWithVat"`
+			} `json:"product"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &c))
+		assert.Equal(t, e.id, c.ID)
+		assert.Equal(t, e.change, c.Change)
+		if e.price == "" {
+			assert.Nil(t, c.Product)
+			continue
+		}
+		require.NotNil(t, c.Product)
+		assert.Equal(t, e.price, c.Product.Price)
+	}
+
+	report, err = diffFeeds(context.Background(), strings.NewReader(diffOld), strings.NewReader(diffOld), nil, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Unchanged)
+	assert.Equal(t, 0, report.Added.Count+report.Removed.Count+report.Changed.Count)
+}
+
+func TestWriteDiffReport(t *testing.T) {
+	report := diffReport{
+		Old:            "file://old.xml",
+		New:            "file://new.xml",
+		OldItems:       4,
+		NewItems:       4,
+		NewParseErrors: 1,
+		Added:          diffChanges{Count: 1, Examples: []string{"5"}},
+		Removed:        diffChanges{Count: 2, Examples: []string{"3", "4"}},
+		Changed:        diffChanges{Count: 0, Examples: []string{}},
+		Unchanged:      1,
+	}
+	var out bytes.Buffer
+	require.NoError(t, writeDiffReport(&out, report, reportFormatText))
+	assert.Equal(t, `old: file://old.xml
+new: file://new.xml
+old items: 4 (parse errors: 0)
+new items: 4 (parse errors: 1)
+added: 1
+  5
+removed: 2
+  3
+  4
+changed: 0
+unchanged: 1
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeDiffReport(&out, report, reportFormatJSON))
+	assert.Equal(t, `{"old":"file://old.xml","new":"file://new.xml","oldItems":4,"newItems":4,"oldParseErrors":0,"newParseErrors":1,`+
+		`"added":{"count":1,"examples":["5"]},"removed":{"count":2,"examples":["3","4"]},"changed":{"count":0,"examples":[]},"unchanged":1}`+"\n", out.String())
+}
+
+func TestParseDiffArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"defaults", []string{"--old", "file://old.xml", "--new", "file://new.xml"}, ""},
+		{"no old", []string{"--new", "file://new.xml"}, "Unable to parse flags: the required flag `--old' was not specified"},
+		{"relative new", []string{"--old", "file://old.xml", "--new", "new.xml"}, "Feed url 'new.xml' should be absolute"},
+		{"unknown format", []string{"--old", "file://old.xml", "--new", "file://new.xml", "--format", "yaml"}, "Unknown format of report 'yaml', supported formats are 'text' and 'json'"},
+		{"negative examples", []string{"--old", "file://old.xml", "--new", "file://new.xml", "--examples", "-1"}, "Number of examples should not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseDiffArgs(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "file://old.xml", opts.old.String())
+			assert.Equal(t, "file://new.xml", opts.new.String())
+			assert.Equal(t, reportFormatText, opts.format)
+			assert.Equal(t, 3, opts.examples)
+			assert.Equal(t, "", opts.changes)
+		})
+	}
+}
+
+func TestDiffRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	oldFeed := filepath.Join(dir, "old.xml")
+	require.NoError(t, ioutil.WriteFile(oldFeed, []byte(diffOld), 0644))
+	newFeed := filepath.Join(dir, "new.xml")
+	require.NoError(t, ioutil.WriteFile(newFeed, []byte(diffNew), 0644))
+	stdout := os.Stdout
+	report, err := os.Create(filepath.Join(dir, "report.txt"))
+	require.NoError(t, err)
+	defer report.Close()
+	os.Stdout = report
+	defer func() { os.Stdout = stdout }()
+
+	changes := filepath.Join(dir, "changes.ndjson")
+	assert.NoError(t, diffRun([]string{"--old", "file://" + oldFeed, "--new", "file://" + oldFeed, "--changes", changes}))
+	written, err := ioutil.ReadFile(changes)
+	require.NoError(t, err)
+	assert.Empty(t, written)
+
+	err = diffRun([]string{"--old", "file://" + oldFeed, "--new", "file://" + newFeed, "--changes", changes})
+	var parseErr *apperror.ParseError
+	require.True(t, errors.As(err, &parseErr))
+	written, err = ioutil.ReadFile(changes)
+	require.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(written), "\n"))
+	assert.Error(t, diffRun([]string{"--old", "file://" + oldFeed, "--new", "file://" + filepath.Join(dir, "absent.xml")}))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/runlog"
+	"github.com/grubastik/feeddo/internal/pkg/heureka"
+	"github.com/grubastik/feeddo/internal/pkg/parser"
+	"github.com/grubastik/feeddo/internal/pkg/payload"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+)
+
+// itemLookupTimeout limits download and parsing of feed by lookup, it is shorter than write timeout of metrics server
+const itemLookupTimeout = 4 * time.Minute
+
+// itemLookup serves /item endpoint of metrics server: feed is downloaded and parsed again till item with requested id is found.
+// Options are replaced on reload of configuration, so newly added feeds could be looked up too.
+type itemLookup struct {
+	mu   sync.RWMutex
+	opts options
+}
+
+// lookupResult is item as it would be sent by the current run of feed
+type lookupResult struct {
+	Feed string `json:"feed"`
+	// Topics are empty when item is dropped by item filter
+	Topics   []string        `json:"topics"`
+	Filtered bool            `json:"filtered"`
+	Item     json.RawMessage `json:"item"`
+}
+
+func newItemLookup(opts options) *itemLookup {
+	return &itemLookup{opts: opts}
+}
+
+func (l *itemLookup) set(opts options) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.opts = opts
+}
+
+func (l *itemLookup) get() options {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.opts
+}
+
+// ServeHTTP responds with json of item of feed. Query parameters "feed" (url or alias of configured feed) and "id" (ITEM_ID) are required.
+// Item is redacted the same way as it is before serialization, so sensitive data is not exposed by endpoint either.
+func (l *itemLookup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, id := r.URL.Query().Get("feed"), r.URL.Query().Get("id")
+	if key == "" || id == "" {
+		http.Error(w, "Query parameters 'feed' and 'id' are required", http.StatusBadRequest)
+		return
+	}
+	opts := l.get()
+	u := lookupFeed(opts, key)
+	if u == nil {
+		http.Error(w, fmt.Sprintf("Feed '%s' is not configured", key), http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), itemLookupTimeout)
+	defer cancel()
+	item, found, err := findItem(ctx, u, opts.feedRequest(u, runlog.NewID(), time.Now()), opts.feedParser(u), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("Item '%s' was not found in feed '%s'", id, key), http.StatusNotFound)
+		return
+	}
+	p := product.FromHeureka(item)
+	result := lookupResult{Feed: opts.feedKey(u), Topics: []string{}, Filtered: !opts.feedItemFilter(u).Matches(p)}
+	if !result.Filtered {
+		result.Topics = opts.router.Topics(u, p)
+	}
+	result.Item = payload.Encode(opts.payloadGuard.Redaction.Apply(p), opts.payloadGuard.Decimals, opts.payloadGuard.URLs)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// lookupFeed returns configured feed by url or alias, nil is returned for unknown feed
+func lookupFeed(opts options, key string) *url.URL {
+	for _, u := range opts.feeds {
+		if u.String() == key || opts.feedKey(u) == key {
+			return u
+		}
+	}
+	return nil
+}
+
+// findItem downloads feed by request and parses it till item with id is found. Errors of other items are returned only when item was not found.
+func findItem(ctx context.Context, u *url.URL, r provider.Request, opts parser.Options, id string) (heureka.Item, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	readCloser, _, err := provider.CreateConditionalStreamWithRequest(ctx, u, provider.Validators{}, r)
+	if err != nil {
+		return heureka.Item{}, false, fmt.Errorf("Failed to get stream: %w", err)
+	}
+	defer readCloser.Close()
+	chanItem, chanErr := parser.ProcessFeedWithOptions(ctx, readCloser, opts)
+	// parser is stopped by cancel and its channels are drained before stream is closed
+	defer drainFeed(chanItem, chanErr)
+	defer cancel()
+	var firstErr error
+	for chanItem != nil || chanErr != nil {
+		select {
+		case item, ok := <-chanItem:
+			if !ok {
+				chanItem = nil
+				continue
+			}
+			if string(item.ID) == id {
+				return item, true, nil
+			}
+		case err, ok := <-chanErr:
+			if !ok {
+				chanErr = nil
+				continue
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		return heureka.Item{}, false, fmt.Errorf("Failed to process feed '%s' because of %w", u, firstErr)
+	}
+	return heureka.Item{}, false, nil
+}
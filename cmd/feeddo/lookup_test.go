@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/parser"
+	"github.com/grubastik/feeddo/internal/pkg/payload"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/grubastik/feeddo/internal/pkg/routing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemLookup(t *testing.T) {
+	URL, _ := url.Parse("file://testdata/one_item.xml")
+	URLBad, _ := url.Parse("file://testdata/badFeed.xml")
+	guard, err := payload.NewGuard(0, payload.StrategyDrop, "")
+	require.NoError(t, err)
+	guard.Redaction, err = payload.ParseRedaction([]string{"URL"})
+	require.NoError(t, err)
+	opts := options{feeds: []*url.URL{URL, URLBad}, feedAliases: map[string]string{URL.String(): "czc"}, router: testRouter(t), payloadGuard: guard}
+	l := newItemLookup(opts)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{"missing id", "feed=czc", http.StatusBadRequest, "Query parameters 'feed' and 'id' are required\n"},
+		{"unknown feed", "feed=other&id=34644", http.StatusNotFound, "Feed 'other' is not configured\n"},
+		{"unknown item", "feed=czc&id=1", http.StatusNotFound, "Item '1' was not found in feed 'czc'\n"},
+		{"broken feed", "feed=file://testdata/badFeed.xml&id=1", http.StatusBadGateway, "Failed to process feed 'file://testdata/badFeed.xml' because of Failed to get item from stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/item?"+tt.query, nil))
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.body)
+		})
+	}
+
+	// item is looked up by alias or url of feed
+	for _, feed := range []string{"czc", URL.String()} {
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/item?feed="+url.QueryEscape(feed)+"&id=34644", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		result := struct {
+			lookupResult
+			Item map[string]interface{} `json:"item"`
+		}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, "czc", result.Feed)
+		assert.Equal(t, []string{"shop_items", "shop_items_bidding"}, result.Topics)
+		assert.False(t, result.Filtered)
+		assert.Equal(t, "34644", result.Item["id"])
+		// item is redacted as it is before serialization
+		assert.Equal(t, "", result.Item["url"])
+	}
+
+	// options are replaced on reload
+	filter, err := routing.ParseFilter("PRICE_VAT>1000")
+	require.NoError(t, err)
+	opts.itemFilter = filter
+	l.set(opts)
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/item?feed=czc&id=34644", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"topics":[],"filtered":true`)
+}
+
+func TestFindItemCancelled(t *testing.T) {
+	URL, _ := url.Parse("file://testdata/one_item.xml")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, found, err := findItem(ctx, URL, provider.Request{}, parser.Options{}, "34644")
+	require.Error(t, err)
+	assert.False(t, found)
+}
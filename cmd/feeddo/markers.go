@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/kafka"
+)
+
+// kinds of markers of runs
+const (
+	markerBegin = "begin"
+	markerEnd   = "end"
+)
+
+const (
+	// headerMarker is set for markers of runs, so consumers could distinguish them from items
+	headerMarker = "feeddo-marker"
+	// markerKeyPrefix is prefix of key of markers followed by feed, so markers do not replace items in compacted topics
+	markerKeyPrefix = "feeddo-marker:"
+)
+
+// runMarker is sent into every partition of topics of feed when run begins and ends,
+// so stream processors could collect items of single run of feed
+type runMarker struct {
+	Marker    string    `json:"marker"`
+	Feed      string    `json:"feed"`
+	RunID     string    `json:"runId"`
+	Topic     string    `json:"topic"`
+	Partition int32     `json:"partition"`
+	Time      time.Time `json:"time"`
+	// Status of finished run, it is sent only by end marker
+	Status string `json:"status,omitempty"`
+	// Items is number of messages (items and tombstones) of run written into partition, it is sent only by end marker
+	Items *int `json:"items,omitempty"`
+}
+
+// sendMarkers sends marker into every partition of topics of primary cluster. Number of messages written into partition
+// is taken from written for end marker. Markers are sent one by one and the first error stops sending.
+func sendMarkers(p runPublisher, topics []string, m runMarker, written map[string]map[int32]int) error {
+	headers := []kafka.Header{{Key: headerRunID, Value: []byte(m.RunID)}, {Key: headerMarker, Value: []byte(m.Marker)}}
+	key := []byte(markerKeyPrefix + m.Feed)
+	for _, topic := range topics {
+		n, err := p.Partitions(topic)
+		if err != nil {
+			return fmt.Errorf("Unable to send %s markers of run into topic '%s' because of %w", m.Marker, topic, err)
+		}
+		for partition := int32(0); partition < int32(n); partition++ {
+			m.Topic, m.Partition = topic, partition
+			if m.Marker == markerEnd {
+				items := written[topic][partition]
+				m.Items = &items
+			}
+			data, err := json.Marshal(m)
+			if err != nil {
+				return fmt.Errorf("Unable to encode %s marker of run because of %w", m.Marker, err)
+			}
+			if err := p.PublishPartition(topic, partition, key, data, headers); err != nil {
+				return fmt.Errorf("Unable to send %s marker of run into partition %d of topic '%s' because of %w", m.Marker, partition, topic, err)
+			}
+		}
+	}
+	return nil
+}
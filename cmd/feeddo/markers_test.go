@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+	"github.com/grubastik/feeddo/internal/pkg/kafka"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendMarkers(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := &publisherMock{count: map[string]int{"a": 2, "b": 1}}
+	begin := runMarker{Marker: markerBegin, Feed: "shop", RunID: "run", Time: now}
+	require.NoError(t, sendMarkers(p, []string{"a", "b"}, begin, nil))
+	end := runMarker{Marker: markerEnd, Feed: "shop", RunID: "run", Time: now, Status: runStatusSucceeded}
+	require.NoError(t, sendMarkers(p, []string{"a", "b"}, end, map[string]map[int32]int{"a": {1: 5}}))
+
+	zero, five := 0, 5
+	assert.Equal(t, []runMarker{
+		{Marker: markerBegin, Feed: "shop", RunID: "run", Topic: "a", Partition: 0, Time: now},
+		{Marker: markerBegin, Feed: "shop", RunID: "run", Topic: "a", Partition: 1, Time: now},
+		{Marker: markerBegin, Feed: "shop", RunID: "run", Topic: "b", Partition: 0, Time: now},
+		{Marker: markerEnd, Feed: "shop", RunID: "run", Topic: "a", Partition: 0, Time: now, Status: runStatusSucceeded, Items: &zero},
+		{Marker: markerEnd, Feed: "shop", RunID: "run", Topic: "a", Partition: 1, Time: now, Status: runStatusSucceeded, Items: &five},
+		{Marker: markerEnd, Feed: "shop", RunID: "run", Topic: "b", Partition: 0, Time: now, Status: runStatusSucceeded, Items: &zero},
+	}, p.markers)
+	assert.Equal(t, "feeddo-marker:shop", p.keys[0])
+	assert.Equal(t, []kafka.Header{{Key: headerRunID, Value: []byte("run")}, {Key: headerMarker, Value: []byte(markerEnd)}}, p.headers[5])
+
+	err := sendMarkers(p, []string{"c"}, begin, nil)
+	require.Error(t, err)
+	assert.Equal(t, "Unable to send begin markers of run into topic 'c' because of Topic c does not exist", err.Error())
+	p.err = errors.New("test error")
+	err = sendMarkers(p, []string{"a"}, begin, nil)
+	require.Error(t, err)
+	assert.Equal(t, "Unable to send begin marker of run into partition 0 of topic 'a' because of test error", err.Error())
+}
+
+func TestRunOnceRunMarkers(t *testing.T) {
+	URL, _ := url.Parse("file://testdata/invalid_text.xml")
+	p := &publisherMock{count: map[string]int{kafka.TopicShopItems: 2, kafka.TopicShopItemsBidding: 1}}
+	deliveries := newDeliveryTracker()
+	var m AdderCustom
+	mc := make(metrics.Container)
+	mc[URL.String()] = map[string]metrics.Adder{"feed": &m}
+	chanItem := make(chan sink.Item)
+	// items are delivered alternately into partitions, begin markers are sent before the first item
+	begun := 0
+	go func() {
+		partition := int32(0)
+		for item := range chanItem {
+			begun = len(p.markers)
+			var topics []sink.TopicResult
+			for _, topic := range item.Topics() {
+				topics = append(topics, sink.TopicResult{Topic: topic, Partition: partition})
+			}
+			partition = 1 - partition
+			deliveries.settle(sink.Result{ItemContext: item.GetContext(), ItemID: item.GetID(), Topics: topics})
+		}
+	}()
+	defer close(chanItem)
+	opts := options{feeds: []*url.URL{URL}, router: testRouter(t), runMarkers: true, runPublisher: p, deliveries: deliveries}
+	errs := runOnce(context.Background(), opts, chanItem, newMetricsObserver(mc))
+	require.Equal(t, 0, len(errs))
+	// summaries are not sent without topic of runs
+	assert.Empty(t, p.messages)
+
+	require.Equal(t, 6, len(p.markers))
+	assert.Equal(t, 3, begun)
+	counts := map[string]int{}
+	for i, marker := range p.markers {
+		assert.Equal(t, URL.String(), marker.Feed)
+		assert.Equal(t, p.markers[0].RunID, marker.RunID)
+		if i < 3 {
+			assert.Equal(t, markerBegin, marker.Marker)
+			assert.Nil(t, marker.Items)
+			continue
+		}
+		assert.Equal(t, markerEnd, marker.Marker)
+		assert.Equal(t, runStatusSucceeded, marker.Status)
+		require.NotNil(t, marker.Items)
+		counts[fmt.Sprintf("%s/%d", marker.Topic, marker.Partition)] = *marker.Items
+	}
+	assert.Equal(t, map[string]int{kafka.TopicShopItems + "/0": 2, kafka.TopicShopItems + "/1": 1, kafka.TopicShopItemsBidding + "/0": 0}, counts)
+
+	// run fails when begin markers could not be sent
+	p = &publisherMock{}
+	opts.runPublisher = p
+	errs = runOnce(context.Background(), opts, chanItem, newMetricsObserver(mc))
+	require.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0].Error(), "Unable to send begin markers of run")
+}
@@ -1,10 +1,16 @@
 package metrics
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -18,8 +24,153 @@ const (
 	MetricTypeFailed = "failed"
 	//MetricTypeSucceeded defines type for succeeded metric
 	MetricTypeSucceeded = "succeeded"
+	//MetricTypeDangling defines type for dangling references metric
+	MetricTypeDangling = "dangling"
+	//MetricTypeDeadLettered defines type for items stored into dead letter metric
+	MetricTypeDeadLettered = "deadlettered"
+	//MetricTypeTombstones defines type for delivered tombstones metric
+	MetricTypeTombstones = "tombstones"
+	//MetricTypeUnchanged defines type for items not sent because they were not changed metric
+	MetricTypeUnchanged = "unchanged"
 )
 
+const (
+	// StatusSucceeded label value for delivered messages
+	StatusSucceeded = "succeeded"
+	// StatusFailed label value for not delivered messages
+	StatusFailed = "failed"
+)
+
+// data quality issues of items, they do not fail processing of feed
+const (
+	// IssueMalformed label value for items skipped because they could not be identified (no ITEM_ID)
+	IssueMalformed = "malformed"
+	// IssueMissingPrice label value for items without price
+	IssueMissingPrice = "missing_price"
+	// IssueMissingEAN label value for items without EAN
+	IssueMissingEAN = "missing_ean"
+	// IssueDuplicate label value for items with ID which was already seen in the same run of feed
+	IssueDuplicate = "duplicate"
+	// IssueFiltered label value for items dropped by item filters
+	IssueFiltered = "filtered"
+	// IssueInvalidText label value for items with invalid UTF-8 or control characters in string fields
+	IssueInvalidText = "invalid_text"
+)
+
+// Issues lists all data quality issues
+var Issues = []string{IssueMalformed, IssueMissingPrice, IssueMissingEAN, IssueDuplicate, IssueFiltered, IssueInvalidText}
+
+// reNotNameChar matches characters which are not allowed in metric names
+var reNotNameChar = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+const (
+	// OverlapSkipped label value for ticks of feed skipped because feed was processed
+	OverlapSkipped = "skipped"
+	// OverlapQueued label value for ticks of feed which started the next run right after current one
+	OverlapQueued = "queued"
+	// OverlapCancelled label value for ticks of feed which cancelled current run
+	OverlapCancelled = "cancelled"
+)
+
+// phaseBuckets are buckets of durations of phases of run of feed from 10ms to 22 minutes
+var phaseBuckets = prometheus.ExponentialBuckets(0.01, 2, 18)
+
+// per topic metrics are labeled as number of topics is not known in advance
+var (
+	topicMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "topic_messages",
+		Help: "Number of messages produced per feed, cluster and topic by delivery status",
+	}, []string{"feed", "cluster", "topic", "status"})
+	topicLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "topic_delivery_seconds",
+		Help:    "Time from producing of message till delivery report (retries included) per feed, cluster and topic",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"feed", "cluster", "topic"})
+	topicLastOffset = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "topic_last_offset",
+		Help: "Offset of the last delivered message per feed, cluster, topic and partition, used for reconciliation with consumers",
+	}, []string{"feed", "cluster", "topic", "partition"})
+	produceLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_produce_seconds",
+		Help:    "Time of produce operation per cluster, topic and operation: 'enqueue' - putting message into client queue, 'send' - synchronous sending till delivery report",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 18),
+	}, []string{"cluster", "topic", "operation"})
+	feedDownloadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_download_bytes_total",
+		Help: "Number of bytes of feed read from supplier per feed",
+	}, []string{"feed"})
+	feedDownloadDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "feed_download_duration_seconds",
+		Help:    "Time of run of feed spent by waiting for supplier: opening of stream and reading of it",
+		Buckets: phaseBuckets,
+	}, []string{"feed"})
+	feedParseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "feed_parse_duration_seconds",
+		Help:    "Time of run of feed spent by decoding of XML, reading of stream excluded",
+		Buckets: phaseBuckets,
+	}, []string{"feed"})
+	feedProduceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "feed_produce_duration_seconds",
+		Help:    "Time of run of feed spent by passing of parsed items through pipeline into sinks, waiting for throttle and backpressure of sinks included",
+		Buckets: phaseBuckets,
+	}, []string{"feed"})
+	feedLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "feed_last_success_timestamp_seconds",
+		Help: "Unix time when the last successful run of feed was finished",
+	}, []string{"feed"})
+	feedConsecutiveFailures = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "feed_consecutive_failures",
+		Help: "Number of failed runs of feed since the last successful one",
+	}, []string{"feed"})
+	feedCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "feed_circuit_open",
+		Help: "1 when feed is paused by circuit breaker after failed runs in a row, 0 when circuit was closed again",
+	}, []string{"feed"})
+	feedLastRun = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "feed_last_run_info",
+		Help: "Always 1, labels identify the last started run of feed, so metrics could be matched with logs and delivered messages",
+	}, []string{"feed", "run_id"})
+	feedOverlaps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_overlapping_ticks",
+		Help: "Number of ticks of feed which happened while feed was processed per feed and action: skipped, queued or cancelled",
+	}, []string{"feed", "action"})
+	itemIssues = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_item_issues",
+		Help: "Number of items with data quality issue per feed, items could have several issues",
+	}, []string{"feed", "issue"})
+	appErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "app_errors",
+		Help: "Number of errors reported by app per category: download, parse, delivery, config or other",
+	}, []string{"category"})
+	sinkItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sink_items",
+		Help: "Number of items delivered per feed and sink by delivery status when items are delivered by several sinks",
+	}, []string{"feed", "sink", "status"})
+	redeliveredItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "redelivered_items",
+		Help: "Number of items delivered again by retry queue per feed by final delivery status",
+	}, []string{"feed", "status"})
+	expiringItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_expiring_items",
+		Help: "Number of items of feed produced with expiry time",
+	}, []string{"feed"})
+	biddingCPC = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "feed_bidding_cpc",
+		Help: "Sum of HEUREKA_CPC of items of the current or the last run of feed",
+	}, []string{"feed"})
+	overBudgetItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_over_budget_items",
+		Help: "Number of items of feed with bidding parsed after sum of HEUREKA_CPC of run exceeded budget",
+	}, []string{"feed"})
+)
+
+func init() {
+	// categories are exported as zero, so increase of errors is visible from the first error
+	for _, c := range apperror.Categories {
+		appErrors.WithLabelValues(c)
+	}
+}
+
 // Adder add value from param to internal value
 // Gauge and Counter both support method Add
 // the only difference is that val could not be negative for Counter
@@ -33,31 +184,82 @@ type Adder interface {
 type Container map[string]map[string]Adder
 
 // NewMetrics creates container with all metrics per feed
-func NewMetrics(listURL []*url.URL) Container {
+func NewMetrics(listURL []*url.URL, aliases map[string]string) Container {
 	container := make(Container)
+	if err := container.add(listURL, aliases); err != nil {
+		panic(err)
+	}
+	return container
+}
+
+// add registers metrics of feeds which are not in container yet.
+// Metrics of feed which was in container before are reused, so feed could be removed and added again.
+// Feed with alias (aliases are mapped by feed url) is keyed by alias and names of its metrics are based on alias instead of host.
+func (c Container) add(listURL []*url.URL, aliases map[string]string) error {
 	for _, u := range listURL {
 		key := u.String()
-		if _, ok := container[key]; !ok {
-			container[key] = make(map[string]Adder)
+		name := strings.ReplaceAll(u.Host, ".", "_")
+		if alias, ok := aliases[key]; ok {
+			key = alias
+			name = reNotNameChar.ReplaceAllString(alias, "_")
+		}
+		if _, ok := c[key]; ok {
+			continue
+		}
+		// feed is exported before its first run, so failures are visible even if it never succeeded
+		feedConsecutiveFailures.WithLabelValues(key)
+		// issues are exported as zero, so rate of issues could be compared between runs from the first one
+		for _, issue := range Issues {
+			itemIssues.WithLabelValues(key, issue)
 		}
-		container[key][MetricTypeFeed] = promauto.NewGauge(prometheus.GaugeOpts{
-			Name: "feed_processing_" + strings.ReplaceAll(u.Host, ".", "_"),
-			Help: "1 indicates that feed start to process and 0 indicates that feed processing ends for url: " + key,
-		})
-		container[key][MetricTypeTotal] = promauto.NewCounter(prometheus.CounterOpts{
-			Name: "total_processed_" + strings.ReplaceAll(u.Host, ".", "_"),
-			Help: "Number of items processed for url: " + key,
-		})
-		container[key][MetricTypeSucceeded] = promauto.NewCounter(prometheus.CounterOpts{
-			Name: "succeeded_" + strings.ReplaceAll(u.Host, ".", "_"),
-			Help: "Number of items succeeded for url: " + u.String(),
-		})
-		container[key][MetricTypeFailed] = promauto.NewCounter(prometheus.CounterOpts{
-			Name: "failed_" + strings.ReplaceAll(u.Host, ".", "_"),
-			Help: "Number of items failed for url: " + u.String(),
-		})
+		collectors := map[string]prometheus.Collector{
+			MetricTypeFeed: prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "feed_processing_" + name,
+				Help: "1 indicates that feed start to process and 0 indicates that feed processing ends for url: " + key,
+			}),
+			MetricTypeTotal: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "total_processed_" + name,
+				Help: "Number of items processed for url: " + key,
+			}),
+			MetricTypeSucceeded: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "succeeded_" + name,
+				Help: "Number of items succeeded for url: " + key,
+			}),
+			MetricTypeFailed: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "failed_" + name,
+				Help: "Number of items failed for url: " + key,
+			}),
+			MetricTypeDeadLettered: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "dead_lettered_" + name,
+				Help: "Number of not delivered items stored into dead letter for url: " + key,
+			}),
+			MetricTypeTombstones: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "tombstones_" + name,
+				Help: "Number of tombstones delivered for items removed from feed for url: " + key,
+			}),
+			MetricTypeUnchanged: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "unchanged_" + name,
+				Help: "Number of items not sent because they were not changed since previous run for url: " + key,
+			}),
+			MetricTypeDangling: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "dangling_references_" + name,
+				Help: "Number of references (accessories and item groups) which could not be resolved within feed for url: " + key,
+			}),
+		}
+		feedMetrics := make(map[string]Adder)
+		for metricType, collector := range collectors {
+			if err := prometheus.Register(collector); err != nil {
+				var are prometheus.AlreadyRegisteredError
+				if !errors.As(err, &are) {
+					return fmt.Errorf("Unable to register metrics of feed '%s' because of %w", key, err)
+				}
+				collector = are.ExistingCollector
+			}
+			feedMetrics[metricType] = collector.(Adder)
+		}
+		c[key] = feedMetrics
 	}
-	return container
+	return nil
 }
 
 // GetMetric returns metric configured. If metric could not be found returns error.
@@ -80,3 +282,246 @@ func (c Container) IncrementMetric(key, metricType string) error {
 	m.Add(1)
 	return nil
 }
+
+// SyncContainer is container which is safe for concurrent use and could be extended by feeds while it is used,
+// e.g. when feeds are added by reload of configuration
+type SyncContainer struct {
+	mu        sync.RWMutex
+	container Container
+}
+
+// NewSyncContainer creates container with all metrics per feed
+func NewSyncContainer(listURL []*url.URL, aliases map[string]string) *SyncContainer {
+	return &SyncContainer{container: NewMetrics(listURL, aliases)}
+}
+
+// Add registers metrics of feeds which are not in container yet
+func (s *SyncContainer) Add(listURL []*url.URL, aliases map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.container.add(listURL, aliases)
+}
+
+// GetMetric returns metric configured. If metric could not be found returns error.
+func (s *SyncContainer) GetMetric(key, typeMetric string) (Adder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.container.GetMetric(key, typeMetric)
+}
+
+// IncrementMetric increments metric
+func (s *SyncContainer) IncrementMetric(key, metricType string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.container.IncrementMetric(key, metricType)
+}
+
+// ObserveDelivery records result of delivery of message produced for feed into topic of cluster.
+// Latency is recorded only for delivered messages.
+func ObserveDelivery(feed, cluster, topic string, err error, latency time.Duration) {
+	if err != nil {
+		topicMessages.WithLabelValues(feed, cluster, topic, StatusFailed).Inc()
+		return
+	}
+	topicMessages.WithLabelValues(feed, cluster, topic, StatusSucceeded).Inc()
+	topicLatency.WithLabelValues(feed, cluster, topic).Observe(latency.Seconds())
+}
+
+// ObserveOffset records offset of the last message of feed delivered into topic partition.
+// Negative offset means that it is not known and it is skipped.
+func ObserveOffset(feed, cluster, topic string, partition int32, offset int64) {
+	if partition < 0 || offset < 0 {
+		return
+	}
+	topicLastOffset.WithLabelValues(feed, cluster, topic, strconv.Itoa(int(partition))).Set(float64(offset))
+}
+
+// ObserveProduce records latency of produce operation of message into topic of cluster
+func ObserveProduce(cluster, topic, operation string, latency time.Duration) {
+	produceLatency.WithLabelValues(cluster, topic, operation).Observe(latency.Seconds())
+}
+
+// ObserveRun records result of run of feed
+func ObserveRun(feed string, err error) {
+	if err != nil {
+		feedConsecutiveFailures.WithLabelValues(feed).Inc()
+		return
+	}
+	feedConsecutiveFailures.WithLabelValues(feed).Set(0)
+	feedLastSuccess.WithLabelValues(feed).SetToCurrentTime()
+}
+
+// ObserveCircuit records state of circuit breaker of feed
+func ObserveCircuit(feed string, open bool) {
+	if open {
+		feedCircuitOpen.WithLabelValues(feed).Set(1)
+		return
+	}
+	feedCircuitOpen.WithLabelValues(feed).Set(0)
+}
+
+// lastRuns keeps id of the last run of every feed, so its series is removed when the next run starts
+var lastRuns = struct {
+	sync.Mutex
+	ids map[string]string
+}{ids: map[string]string{}}
+
+// ObserveRunStart records id of run of feed which was started
+func ObserveRunStart(feed, runID string) {
+	lastRuns.Lock()
+	defer lastRuns.Unlock()
+	if previous, ok := lastRuns.ids[feed]; ok {
+		feedLastRun.DeleteLabelValues(feed, previous)
+	}
+	lastRuns.ids[feed] = runID
+	feedLastRun.WithLabelValues(feed, runID).Set(1)
+}
+
+// Phases of run of feed, they show whether slowness of feed comes from supplier, XML decoding or sinks
+type Phases struct {
+	DownloadBytes int64
+	Download      time.Duration
+	Parse         time.Duration
+	Produce       time.Duration
+}
+
+// ObservePhases records phases of run of feed
+func ObservePhases(feed string, p Phases) {
+	feedDownloadBytes.WithLabelValues(feed).Add(float64(p.DownloadBytes))
+	feedDownloadDuration.WithLabelValues(feed).Observe(p.Download.Seconds())
+	feedParseDuration.WithLabelValues(feed).Observe(p.Parse.Seconds())
+	feedProduceDuration.WithLabelValues(feed).Observe(p.Produce.Seconds())
+}
+
+// ObserveOverlap records action taken for tick of feed which happened while feed was processed
+func ObserveOverlap(feed, action string) {
+	feedOverlaps.WithLabelValues(feed, action).Inc()
+}
+
+// ObserveIssue records data quality issue of item of feed
+func ObserveIssue(feed, issue string) {
+	itemIssues.WithLabelValues(feed, issue).Inc()
+}
+
+// ObserveError records error reported by app by its category
+func ObserveError(err error) {
+	appErrors.WithLabelValues(apperror.Category(err)).Inc()
+}
+
+// ObserveSink records result of delivery of item of feed by sink
+func ObserveSink(feed, sink string, err error) {
+	if err != nil {
+		sinkItems.WithLabelValues(feed, sink, StatusFailed).Inc()
+		return
+	}
+	sinkItems.WithLabelValues(feed, sink, StatusSucceeded).Inc()
+}
+
+// ObserveRedelivery records final result of item which was delivered again by retry queue
+func ObserveRedelivery(feed string, err error) {
+	if err != nil {
+		redeliveredItems.WithLabelValues(feed, StatusFailed).Inc()
+		return
+	}
+	redeliveredItems.WithLabelValues(feed, StatusSucceeded).Inc()
+}
+
+// ObserveExpiring records item of feed produced with expiry time
+func ObserveExpiring(feed string) {
+	expiringItems.WithLabelValues(feed).Inc()
+}
+
+// ObserveBiddingCPC records sum of HEUREKA_CPC of items of run of feed parsed so far
+func ObserveBiddingCPC(feed string, total float64) {
+	biddingCPC.WithLabelValues(feed).Set(total)
+}
+
+// ObserveOverBudget records item of feed with bidding parsed after run exceeded budget
+func ObserveOverBudget(feed string) {
+	overBudgetItems.WithLabelValues(feed).Inc()
+}
+
+// ProducerStats provides state of producers queue
+type ProducerStats interface {
+	// Inflight returns number of items produced but not delivered yet
+	Inflight() int
+	// BackpressureTime returns total time producers waited because limit of items in flight was reached
+	BackpressureTime() time.Duration
+	// ThrottledTime returns total time messages waited because of rate limits
+	ThrottledTime() time.Duration
+	// Producers returns number of running producers
+	Producers() int
+	// DeliveryTimeouts returns number of items and dead letters which delivery was not reported in time
+	DeliveryTimeouts() int64
+}
+
+// RegisterProducerStats exposes state of producers queue. Should be called once per process.
+func RegisterProducerStats(stats ProducerStats) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "kafka_inflight_items",
+		Help: "Number of items which were produced but not delivered yet",
+	}, func() float64 { return float64(stats.Inflight()) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "kafka_backpressure_seconds",
+		Help: "Total time producers waited because limit of items in flight was reached",
+	}, func() float64 { return stats.BackpressureTime().Seconds() })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "kafka_throttled_seconds",
+		Help: "Total time messages waited because of produce rate limits",
+	}, func() float64 { return stats.ThrottledTime().Seconds() })
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "kafka_producers",
+		Help: "Number of running producers",
+	}, func() float64 { return float64(stats.Producers()) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "kafka_delivery_timeouts",
+		Help: "Number of items and dead letters which delivery was not reported within delivery timeout",
+	}, func() float64 { return float64(stats.DeliveryTimeouts()) })
+}
+
+// ClusterStats provides state of kafka client queues per cluster
+type ClusterStats interface {
+	// QueueLengths returns number of messages in client queue per cluster
+	QueueLengths() map[string]int
+	// TransmittedMessages returns total number of messages transmitted to brokers per cluster
+	TransmittedMessages() map[string]int64
+	// TransmittedBytes returns total size of messages transmitted to brokers per cluster
+	TransmittedBytes() map[string]int64
+}
+
+var (
+	queueMessagesDesc = prometheus.NewDesc("kafka_queue_messages",
+		"Number of messages in client queue waiting for sending or acknowledgement per cluster", []string{"cluster"}, nil)
+	transmittedMessagesDesc = prometheus.NewDesc("kafka_transmitted_messages",
+		"Number of messages transmitted to brokers per cluster", []string{"cluster"}, nil)
+	transmittedBytesDesc = prometheus.NewDesc("kafka_transmitted_bytes",
+		"Size of messages transmitted to brokers per cluster", []string{"cluster"}, nil)
+)
+
+// clusterCollector collects state of client queues when metrics are scraped
+type clusterCollector struct {
+	stats ClusterStats
+}
+
+func (c clusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueMessagesDesc
+	ch <- transmittedMessagesDesc
+	ch <- transmittedBytesDesc
+}
+
+func (c clusterCollector) Collect(ch chan<- prometheus.Metric) {
+	for cluster, n := range c.stats.QueueLengths() {
+		ch <- prometheus.MustNewConstMetric(queueMessagesDesc, prometheus.GaugeValue, float64(n), cluster)
+	}
+	for cluster, n := range c.stats.TransmittedMessages() {
+		ch <- prometheus.MustNewConstMetric(transmittedMessagesDesc, prometheus.CounterValue, float64(n), cluster)
+	}
+	for cluster, n := range c.stats.TransmittedBytes() {
+		ch <- prometheus.MustNewConstMetric(transmittedBytesDesc, prometheus.CounterValue, float64(n), cluster)
+	}
+}
+
+// RegisterClusterStats exposes state of kafka client queues. Should be called once per process.
+func RegisterClusterStats(stats ClusterStats) {
+	prometheus.MustRegister(clusterCollector{stats: stats})
+}
@@ -1,11 +1,16 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -14,15 +19,64 @@ func TestNewMetrics(t *testing.T) {
 	testURL, err := url.Parse("http://test.com")
 	require.NoError(t, err)
 	urls := []*url.URL{testURL}
-	c := NewMetrics(urls)
+	c := NewMetrics(urls, nil)
 	require.NotEmpty(t, c)
 	require.NotEmpty(t, c[testURL.String()])
-	for _, key := range []string{"feed", "total", "succeeded", "failed"} {
+	for _, key := range []string{"feed", "total", "succeeded", "failed", "dangling", "deadlettered", "tombstones", "unchanged"} {
 		assert.NotEmpty(t, c[testURL.String()][key])
 		assert.Implements(t, (*Adder)(nil), c[testURL.String()][key])
 	}
 }
 
+func TestSyncContainerAdd(t *testing.T) {
+	u1, err := url.Parse("http://sync.test.com/a")
+	require.NoError(t, err)
+	u2, err := url.Parse("http://sync.test.org")
+	require.NoError(t, err)
+	c := NewSyncContainer([]*url.URL{u1}, nil)
+	m1, err := c.GetMetric(u1.String(), MetricTypeTotal)
+	require.NoError(t, err)
+	_, err = c.GetMetric(u2.String(), MetricTypeTotal)
+	require.Error(t, err)
+
+	require.NoError(t, c.Add([]*url.URL{u1, u2}, nil))
+	require.NoError(t, c.IncrementMetric(u2.String(), MetricTypeTotal))
+	// metrics of feed which was registered before are reused
+	other := NewSyncContainer([]*url.URL{u1}, nil)
+	m2, err := other.GetMetric(u1.String(), MetricTypeTotal)
+	require.NoError(t, err)
+	assert.Equal(t, m1, m2)
+
+	// metric names are based on host, so other feed of the same host could not be registered
+	u3, err := url.Parse("http://sync.test.com/b")
+	require.NoError(t, err)
+	err = c.Add([]*url.URL{u3}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unable to register metrics of feed 'http://sync.test.com/b'")
+}
+
+func TestSyncContainerAliases(t *testing.T) {
+	u1, err := url.Parse("http://alias.test.com/a?signature=1")
+	require.NoError(t, err)
+	u2, err := url.Parse("http://alias.test.com/b")
+	require.NoError(t, err)
+	// feeds of the same host are registered under their aliases
+	c := NewSyncContainer([]*url.URL{u1, u2}, map[string]string{u1.String(): "catalog", u2.String(): "prices-cz"})
+	m, err := c.GetMetric("catalog", MetricTypeTotal)
+	require.NoError(t, err)
+	require.NoError(t, c.IncrementMetric("prices-cz", MetricTypeTotal))
+	_, err = c.GetMetric(u1.String(), MetricTypeTotal)
+	require.Error(t, err)
+
+	// rotated url of feed keeps its metrics
+	u3, err := url.Parse("http://alias.test.com/a?signature=2")
+	require.NoError(t, err)
+	other := NewSyncContainer([]*url.URL{u3}, map[string]string{u3.String(): "catalog"})
+	m2, err := other.GetMetric("catalog", MetricTypeTotal)
+	require.NoError(t, err)
+	assert.Equal(t, m, m2)
+}
+
 func TestGetMetric(t *testing.T) {
 	m := make(Container)
 	m["a"] = make(map[string]Adder)
@@ -88,3 +142,184 @@ func TestIncrementMetric(t *testing.T) {
 		})
 	}
 }
+
+func TestObserveDelivery(t *testing.T) {
+	ObserveDelivery("http://test.org", "primary", "items", nil, 10*time.Millisecond)
+	ObserveDelivery("http://test.org", "primary", "items", nil, 20*time.Millisecond)
+	ObserveDelivery("http://test.org", "primary", "items", errors.New("test error"), 0)
+	ObserveDelivery("http://test.org", "primary", "bidding", nil, time.Millisecond)
+	assert.Equal(t, float64(2), testutil.ToFloat64(topicMessages.WithLabelValues("http://test.org", "primary", "items", StatusSucceeded)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(topicMessages.WithLabelValues("http://test.org", "primary", "items", StatusFailed)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(topicMessages.WithLabelValues("http://test.org", "primary", "bidding", StatusSucceeded)))
+	assert.Equal(t, 2, testutil.CollectAndCount(topicLatency))
+}
+
+func TestObserveOffset(t *testing.T) {
+	ObserveOffset("http://test.org", "primary", "items", 1, 10)
+	ObserveOffset("http://test.org", "primary", "items", 1, 11)
+	ObserveOffset("http://test.org", "primary", "items", 2, 5)
+	// unknown positions are skipped
+	ObserveOffset("http://test.org", "primary", "items", -1, 20)
+	ObserveOffset("http://test.org", "primary", "items", 2, -1)
+	assert.Equal(t, float64(11), testutil.ToFloat64(topicLastOffset.WithLabelValues("http://test.org", "primary", "items", "1")))
+	assert.Equal(t, float64(5), testutil.ToFloat64(topicLastOffset.WithLabelValues("http://test.org", "primary", "items", "2")))
+	assert.Equal(t, 2, testutil.CollectAndCount(topicLastOffset))
+}
+
+func TestObserveSink(t *testing.T) {
+	ObserveSink("http://test.org", "kafka", nil)
+	ObserveSink("http://test.org", "file", nil)
+	ObserveSink("http://test.org", "file", errors.New("test error"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(sinkItems.WithLabelValues("http://test.org", "kafka", StatusSucceeded)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(sinkItems.WithLabelValues("http://test.org", "file", StatusSucceeded)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(sinkItems.WithLabelValues("http://test.org", "file", StatusFailed)))
+}
+
+type producerStatsMock struct{}
+
+func (producerStatsMock) Inflight() int                   { return 3 }
+func (producerStatsMock) BackpressureTime() time.Duration { return 1500 * time.Millisecond }
+func (producerStatsMock) ThrottledTime() time.Duration    { return 2 * time.Second }
+func (producerStatsMock) Producers() int                  { return 4 }
+func (producerStatsMock) DeliveryTimeouts() int64         { return 5 }
+
+func TestRegisterProducerStats(t *testing.T) {
+	RegisterProducerStats(producerStatsMock{})
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	values := map[string]float64{}
+	for _, f := range families {
+		switch f.GetName() {
+		case "kafka_inflight_items", "kafka_producers":
+			values[f.GetName()] = f.GetMetric()[0].GetGauge().GetValue()
+		case "kafka_backpressure_seconds", "kafka_throttled_seconds", "kafka_delivery_timeouts":
+			values[f.GetName()] = f.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{"kafka_inflight_items": 3, "kafka_backpressure_seconds": 1.5, "kafka_throttled_seconds": 2, "kafka_producers": 4,
+		"kafka_delivery_timeouts": 5}, values)
+}
+
+func TestObserveProduce(t *testing.T) {
+	ObserveProduce("primary", "items", "enqueue", time.Millisecond)
+	ObserveProduce("primary", "dead_letters", "send", 20*time.Millisecond)
+	assert.Equal(t, 2, testutil.CollectAndCount(produceLatency))
+}
+
+type clusterStatsMock struct{}
+
+func (clusterStatsMock) QueueLengths() map[string]int {
+	return map[string]int{"primary": 5, "cloud": 1}
+}
+func (clusterStatsMock) TransmittedMessages() map[string]int64 {
+	return map[string]int64{"primary": 100, "cloud": 50}
+}
+func (clusterStatsMock) TransmittedBytes() map[string]int64 {
+	return map[string]int64{"primary": 1000, "cloud": 500}
+}
+
+func TestRegisterClusterStats(t *testing.T) {
+	RegisterClusterStats(clusterStatsMock{})
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	values := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			switch f.GetName() {
+			case "kafka_queue_messages":
+				values[f.GetName()+"/"+m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+			case "kafka_transmitted_messages", "kafka_transmitted_bytes":
+				values[f.GetName()+"/"+m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		"kafka_queue_messages/primary": 5, "kafka_queue_messages/cloud": 1,
+		"kafka_transmitted_messages/primary": 100, "kafka_transmitted_messages/cloud": 50,
+		"kafka_transmitted_bytes/primary": 1000, "kafka_transmitted_bytes/cloud": 500,
+	}, values)
+}
+
+func TestObserveRun(t *testing.T) {
+	ObserveRun("http://test.org/run", errors.New("test error"))
+	ObserveRun("http://test.org/run", errors.New("test error"))
+	assert.Equal(t, float64(2), testutil.ToFloat64(feedConsecutiveFailures.WithLabelValues("http://test.org/run")))
+	assert.Equal(t, 0, testutil.CollectAndCount(feedLastSuccess))
+	started := float64(time.Now().Unix())
+	ObserveRun("http://test.org/run", nil)
+	assert.Equal(t, float64(0), testutil.ToFloat64(feedConsecutiveFailures.WithLabelValues("http://test.org/run")))
+	assert.GreaterOrEqual(t, testutil.ToFloat64(feedLastSuccess.WithLabelValues("http://test.org/run")), started)
+}
+
+func TestObserveCircuit(t *testing.T) {
+	ObserveCircuit("http://test.org/circuit", true)
+	assert.Equal(t, float64(1), testutil.ToFloat64(feedCircuitOpen.WithLabelValues("http://test.org/circuit")))
+	ObserveCircuit("http://test.org/circuit", false)
+	assert.Equal(t, float64(0), testutil.ToFloat64(feedCircuitOpen.WithLabelValues("http://test.org/circuit")))
+}
+
+func TestObserveRunStart(t *testing.T) {
+	ObserveRunStart("http://test.org/runs", "1")
+	ObserveRunStart("http://test.org/runs", "2")
+	// only the last run is exposed
+	assert.Equal(t, 1, testutil.CollectAndCount(feedLastRun))
+	assert.Equal(t, float64(1), testutil.ToFloat64(feedLastRun.WithLabelValues("http://test.org/runs", "2")))
+}
+
+func TestObserveOverlap(t *testing.T) {
+	ObserveOverlap("http://test.org/overlap", OverlapSkipped)
+	ObserveOverlap("http://test.org/overlap", OverlapSkipped)
+	ObserveOverlap("http://test.org/overlap", OverlapQueued)
+	assert.Equal(t, float64(2), testutil.ToFloat64(feedOverlaps.WithLabelValues("http://test.org/overlap", OverlapSkipped)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(feedOverlaps.WithLabelValues("http://test.org/overlap", OverlapQueued)))
+}
+
+func TestObserveIssue(t *testing.T) {
+	u, err := url.Parse("http://test.org/issues")
+	require.NoError(t, err)
+	NewMetrics([]*url.URL{u}, nil)
+	for _, issue := range Issues {
+		assert.Equal(t, float64(0), testutil.ToFloat64(itemIssues.WithLabelValues(u.String(), issue)))
+	}
+	ObserveIssue(u.String(), IssueMissingEAN)
+	ObserveIssue(u.String(), IssueMissingEAN)
+	ObserveIssue(u.String(), IssueDuplicate)
+	assert.Equal(t, float64(2), testutil.ToFloat64(itemIssues.WithLabelValues(u.String(), IssueMissingEAN)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(itemIssues.WithLabelValues(u.String(), IssueDuplicate)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(itemIssues.WithLabelValues(u.String(), IssueMissingPrice)))
+}
+
+func TestObserveExpiring(t *testing.T) {
+	ObserveExpiring("http://test.org/expiring")
+	ObserveExpiring("http://test.org/expiring")
+	assert.Equal(t, float64(2), testutil.ToFloat64(expiringItems.WithLabelValues("http://test.org/expiring")))
+}
+
+func TestObserveBudget(t *testing.T) {
+	ObserveBiddingCPC("http://test.org/budget", 12.5)
+	ObserveOverBudget("http://test.org/budget")
+	assert.Equal(t, 12.5, testutil.ToFloat64(biddingCPC.WithLabelValues("http://test.org/budget")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(overBudgetItems.WithLabelValues("http://test.org/budget")))
+}
+
+func TestObservePhases(t *testing.T) {
+	feed := "http://test.org/phases"
+	ObservePhases(feed, Phases{DownloadBytes: 1024, Download: 2 * time.Second, Parse: 500 * time.Millisecond, Produce: time.Second})
+	ObservePhases(feed, Phases{DownloadBytes: 1024, Download: time.Second})
+	assert.Equal(t, float64(2048), testutil.ToFloat64(feedDownloadBytes.WithLabelValues(feed)))
+	for _, h := range []*prometheus.HistogramVec{feedDownloadDuration, feedParseDuration, feedProduceDuration} {
+		assert.Equal(t, 1, testutil.CollectAndCount(h))
+	}
+}
+
+func TestObserveError(t *testing.T) {
+	for _, c := range apperror.Categories {
+		assert.Equal(t, float64(0), testutil.ToFloat64(appErrors.WithLabelValues(c)))
+	}
+	ObserveError(fmt.Errorf("Failed to get stream: %w", &apperror.DownloadError{Err: errors.New("test error")}))
+	ObserveError(errors.New("test error"))
+	ObserveError(errors.New("test error"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(appErrors.WithLabelValues(apperror.CategoryDownload)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(appErrors.WithLabelValues(apperror.CategoryOther)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(appErrors.WithLabelValues(apperror.CategoryConfig)))
+}
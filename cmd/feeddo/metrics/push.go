@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const (
+	// DefaultPushJob is job label of metrics pushed to Pushgateway
+	DefaultPushJob = "feeddo"
+
+	// pushTimeout limits pushing so that exit of app is not blocked by unavailable Pushgateway
+	pushTimeout = 10 * time.Second
+)
+
+// Pushgateway defines where metrics are pushed when app runs once, empty URL means that metrics are not pushed
+type Pushgateway struct {
+	URL string
+	Job string
+	// Instance is added to grouping key when it is set, so several jobs with the same name do not overwrite metrics of each other
+	Instance string
+	BasicAuth
+}
+
+// Push replaces metrics of job (and instance) in Pushgateway with all metrics of default registry
+func Push(pg Pushgateway) error {
+	p := push.New(pg.URL, pg.Job).
+		Gatherer(prometheus.DefaultGatherer).
+		Client(&http.Client{Timeout: pushTimeout})
+	if pg.Instance != "" {
+		p = p.Grouping("instance", pg.Instance)
+	}
+	if pg.User != "" {
+		p = p.BasicAuth(pg.User, pg.Password)
+	}
+	if err := p.Push(); err != nil {
+		return fmt.Errorf("Unable to push metrics to %s because of %w", pg.URL, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPush(t *testing.T) {
+	tests := []struct {
+		name   string
+		pg     Pushgateway
+		status int
+		path   string
+		user   string
+		err    bool
+	}{
+		{
+			name:   "job",
+			pg:     Pushgateway{Job: DefaultPushJob},
+			status: http.StatusOK,
+			path:   "/metrics/job/feeddo",
+		},
+		{
+			name:   "job and instance with credentials",
+			pg:     Pushgateway{Job: "cron", Instance: "pod-1", BasicAuth: BasicAuth{User: "prometheus", Password: "secret"}},
+			status: http.StatusOK,
+			path:   "/metrics/job/cron/instance/pod-1",
+			user:   "prometheus",
+		},
+		{
+			name:   "pushgateway failed",
+			pg:     Pushgateway{Job: DefaultPushJob},
+			status: http.StatusInternalServerError,
+			path:   "/metrics/job/feeddo",
+			err:    true,
+		},
+	}
+	ObserveRun("http://push.test.org", nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path, user string
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, path = r.Method, r.URL.Path
+				user, _, _ = r.BasicAuth()
+				body, _ = ioutil.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			tt.pg.URL = srv.URL
+			err := Push(tt.pg)
+			if tt.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			// whole group is replaced, so metrics of previous run do not remain
+			assert.Equal(t, http.MethodPut, method)
+			assert.Equal(t, tt.path, path)
+			assert.Equal(t, tt.user, user)
+			assert.Contains(t, string(body), "feed_last_success_timestamp_seconds")
+		})
+	}
+}
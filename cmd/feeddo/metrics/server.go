@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net"
@@ -9,29 +10,65 @@ import (
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
-	//MetricsAddressCtxKey defines key for context value of the addres for server
-	MetricsAddressCtxKey = "metricsServerAddress"
+	// pprofWriteTimeout allows to collect cpu profiles and traces which are written after collection
+	pprofWriteTimeout = 5 * time.Minute
+	// itemWriteTimeout allows to download and parse feed before item is written
+	itemWriteTimeout = 5 * time.Minute
+	// tlsTimeout allows to finish TLS handshake with clients outside of local network
+	tlsTimeout = 5 * time.Second
+	// realm of basic authentication
+	authRealm = "feeddo"
 )
 
-// RunServer - run  server on the provided address and expose /metrics endpoint
+// TLS defines certificate and private key files of server
+type TLS struct {
+	CertFile string
+	KeyFile  string
+}
+
+// BasicAuth defines credentials required for access to server, empty user means that authentication is disabled
+type BasicAuth struct {
+	User     string
+	Password string
+}
+
+// ServerConfig configures metrics server
+type ServerConfig struct {
+	// Address is local address to which server is bound
+	Address string
+	// server uses https when certificate is set and requires credentials when user is set
+	TLS  TLS
+	Auth BasicAuth
+	// Pprof exposes /debug/pprof endpoints
+	Pprof bool
+	// Runs is handler of /runs endpoint, endpoint is not exposed when it is nil
+	Runs http.Handler
+	// Item is handler of /item endpoint, endpoint is not exposed when it is nil
+	Item http.Handler
+}
+
+// RunServer - run  server on the configured address and expose /metrics endpoint.
+// Go runtime and process metrics are exposed by default registry as well.
+// When Pprof is set /debug/pprof endpoints are exposed too, history of runs is exposed on /runs when Runs is set
+// and single item of feed on /item when Item is set.
+// Server uses https when TLS is set and requires credentials when Auth is set.
 // return 2 channels: first for getting error messages and second channel idenifies status of the server
 // if second channel will be closed - server exited
-// Context should contain under key "serverAddressMetrics" string with local address to which it will be binded
-// If context cancelled - server also exits
-func RunServer(ctx context.Context) (<-chan error, <-chan struct{}) {
+// Context is used only for cancellation: if context cancelled - server also exits
+func RunServer(ctx context.Context, c ServerConfig) (<-chan error, <-chan struct{}) {
 	// make this channel buffered to not block execution on writing
 	// and values still could be read from closed channel
 	chanErr := make(chan error, 1)
 	chanSrvExit := make(chan struct{})
 
-	addr, err := getAddressFromContext(ctx)
-	if err != nil {
+	if c.Address == "" {
 		defer func() {
-			chanErr <- fmt.Errorf("Error in server address for metrics: %w", err)
+			chanErr <- fmt.Errorf("Error in server address for metrics: address is not configured")
 			// we did not start goroutine yet.
 			// it is required to close both channels here
 			close(chanErr)
@@ -44,11 +81,15 @@ func RunServer(ctx context.Context) (<-chan error, <-chan struct{}) {
 		defer close(chanErr)
 		defer close(chanSrvExit)
 		chanSrvExitInner := make(chan struct{})
-		s := getServer(ctx, addr)
+		s := getServer(ctx, c)
 		var err error
 		go func() {
 			defer close(chanSrvExitInner)
-			err = s.ListenAndServe()
+			if c.TLS.CertFile != "" {
+				err = s.ListenAndServeTLS(c.TLS.CertFile, c.TLS.KeyFile)
+			} else {
+				err = s.ListenAndServe()
+			}
 			if err != nil && !errors.Is(err, http.ErrServerClosed) {
 				chanErr <- err
 			}
@@ -69,27 +110,35 @@ func RunServer(ctx context.Context) (<-chan error, <-chan struct{}) {
 	return chanErr, chanSrvExit
 }
 
-func getAddressFromContext(ctx context.Context) (string, error) {
-	addrRaw := ctx.Value(MetricsAddressCtxKey)
-	if addrRaw == nil {
-		return "", fmt.Errorf("Provided context does not contain key '%s'", MetricsAddressCtxKey)
-	}
-	addr, ok := addrRaw.(string)
-	if !ok || addr == "" {
-		return "", fmt.Errorf("Provided context does not contain string value in key '%s'", MetricsAddressCtxKey)
-	}
-	return addr, nil
-}
-
-func getServer(ctx context.Context, addr string) *http.Server {
+func getServer(ctx context.Context, c ServerConfig) *http.Server {
 	router := chi.NewRouter()
+	if c.Auth.User != "" {
+		router.Use(basicAuth(c.Auth))
+	}
 	router.Get("/metrics", promhttp.Handler().(http.HandlerFunc))
+	if c.Runs != nil {
+		router.Method(http.MethodGet, "/runs", c.Runs)
+	}
+	readTimeout := 5 * time.Millisecond
+	writeTimeout := 5 * time.Millisecond
+	if c.TLS.CertFile != "" {
+		// handshake is limited by read timeout
+		readTimeout, writeTimeout = tlsTimeout, tlsTimeout
+	}
+	if c.Item != nil {
+		router.Method(http.MethodGet, "/item", c.Item)
+		writeTimeout = itemWriteTimeout
+	}
+	if c.Pprof {
+		router.Mount("/debug", middleware.Profiler())
+		writeTimeout = pprofWriteTimeout
+	}
 	return &http.Server{
-		ReadTimeout:       5 * time.Millisecond,
-		WriteTimeout:      5 * time.Millisecond,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
 		IdleTimeout:       120 * time.Second,
-		ReadHeaderTimeout: 5 * time.Millisecond,
-		Addr:              addr,
+		ReadHeaderTimeout: readTimeout,
+		Addr:              c.Address,
 		TLSConfig:         nil,
 		Handler:           router,
 		MaxHeaderBytes:    1 << 8,
@@ -98,3 +147,20 @@ func getServer(ctx context.Context, addr string) *http.Server {
 		},
 	}
 }
+
+// basicAuth rejects requests without valid credentials. Credentials are compared in constant time.
+func basicAuth(auth BasicAuth) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			validUser := subtle.ConstantTimeCompare([]byte(user), []byte(auth.User)) == 1
+			validPassword := subtle.ConstantTimeCompare([]byte(password), []byte(auth.Password)) == 1
+			if !ok || !validUser || !validPassword {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, authRealm))
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,10 +2,21 @@ package metrics
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
-	"math/rand"
+	"io/ioutil"
+	"math/big"
+	mrand "math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -14,23 +25,18 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestRunServerContextError(t *testing.T) {
+func TestRunServerConfigError(t *testing.T) {
 	tests := []struct {
-		name string
-		ctx  context.Context
-		err  string
+		name    string
+		address string
+		err     string
 	}{
-		{"No key", context.Background(),
-			fmt.Sprintf("Error in server address for metrics: Provided context does not contain key '%s'", MetricsAddressCtxKey)},
-		{"No value", context.WithValue(context.Background(), MetricsAddressCtxKey, nil),
-			fmt.Sprintf("Error in server address for metrics: Provided context does not contain key '%s'", MetricsAddressCtxKey)},
-		{"Empty string", context.WithValue(context.Background(), MetricsAddressCtxKey, ""),
-			fmt.Sprintf("Error in server address for metrics: Provided context does not contain string value in key '%s'", MetricsAddressCtxKey)},
-		{"fake address", context.WithValue(context.Background(), MetricsAddressCtxKey, "abc:-10050"), "listen tcp: address -10050: invalid port"},
+		{"No address", "", "Error in server address for metrics: address is not configured"},
+		{"fake address", "abc:-10050", "listen tcp: address -10050: invalid port"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			chanErr, chanClose := RunServer(tt.ctx)
+			chanErr, chanClose := RunServer(context.Background(), ServerConfig{Address: tt.address})
 			// all scenarios should fail need to wat for server to close this channel
 			<-chanClose
 			err := <-chanErr
@@ -47,8 +53,7 @@ func TestRunServerPortAlreadyInUse(t *testing.T) {
 	}))
 	defer s.Close()
 	address := strings.Replace(s.URL, "http://", "", 1)
-	ctx := context.WithValue(context.Background(), MetricsAddressCtxKey, address)
-	chanErr, chanClose := RunServer(ctx)
+	chanErr, chanClose := RunServer(context.Background(), ServerConfig{Address: address})
 	// this scenario should result in error and this channel should be closed
 	<-chanClose
 	err := <-chanErr
@@ -57,11 +62,10 @@ func TestRunServerPortAlreadyInUse(t *testing.T) {
 }
 
 func TestRunServerHappyPath(t *testing.T) {
-	rand.Seed(time.Now().UnixNano())
-	randPort := rand.Intn(1000) + 55000
-	ctx := context.WithValue(context.Background(), MetricsAddressCtxKey, fmt.Sprintf("127.0.0.1:%d", randPort))
-	ctx, cancelFunc := context.WithCancel(ctx)
-	chanErr, chanClose := RunServer(ctx)
+	mrand.Seed(time.Now().UnixNano())
+	randPort := mrand.Intn(1000) + 55000
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	chanErr, chanClose := RunServer(ctx, ServerConfig{Address: fmt.Sprintf("127.0.0.1:%d", randPort)})
 	//set some timeout
 	<-time.After(10 * time.Millisecond)
 	//cancel context
@@ -72,3 +76,146 @@ func TestRunServerHappyPath(t *testing.T) {
 	err := <-chanErr
 	require.NoError(t, err)
 }
+
+func TestGetServer(t *testing.T) {
+	tests := []struct {
+		name         string
+		pprof        bool
+		pprofStatus  int
+		writeTimeout time.Duration
+	}{
+		{name: "without pprof", pprofStatus: http.StatusNotFound, writeTimeout: 5 * time.Millisecond},
+		{name: "with pprof", pprof: true, pprofStatus: http.StatusOK, writeTimeout: pprofWriteTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := getServer(context.Background(), ServerConfig{Address: "127.0.0.1:0", Pprof: tt.pprof})
+			assert.Equal(t, tt.writeTimeout, s.WriteTimeout)
+
+			w := httptest.NewRecorder()
+			s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+			// runtime metrics are exposed by default registry
+			assert.Contains(t, w.Body.String(), "go_memstats_heap_inuse_bytes")
+			assert.Contains(t, w.Body.String(), "go_goroutines")
+
+			w = httptest.NewRecorder()
+			s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/heap?debug=1", nil))
+			assert.Equal(t, tt.pprofStatus, w.Code)
+		})
+	}
+}
+
+func TestGetServerRuns(t *testing.T) {
+	s := getServer(context.Background(), ServerConfig{Address: "127.0.0.1:0"})
+	w := httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/runs", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	runs := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	s = getServer(context.Background(), ServerConfig{Address: "127.0.0.1:0", Runs: runs})
+	w = httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/runs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+}
+
+func TestGetServerItem(t *testing.T) {
+	s := getServer(context.Background(), ServerConfig{Address: "127.0.0.1:0"})
+	w := httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/item?feed=a&id=1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	item := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Query().Get("id")))
+	})
+	s = getServer(context.Background(), ServerConfig{Address: "127.0.0.1:0", Item: item})
+	// feed is downloaded and parsed before item is written
+	assert.Equal(t, itemWriteTimeout, s.WriteTimeout)
+	w = httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/item?feed=a&id=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Body.String())
+}
+
+func TestBasicAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		status   int
+	}{
+		{name: "valid credentials", user: "prometheus", password: "secret", status: http.StatusOK},
+		{name: "wrong password", user: "prometheus", password: "abc", status: http.StatusUnauthorized},
+		{name: "wrong user", user: "abc", password: "secret", status: http.StatusUnauthorized},
+		{name: "without credentials", status: http.StatusUnauthorized},
+	}
+	s := getServer(context.Background(), ServerConfig{Address: "127.0.0.1:0", Auth: BasicAuth{User: "prometheus", Password: "secret"}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			w := httptest.NewRecorder()
+			s.Handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusUnauthorized {
+				assert.Equal(t, `Basic realm="feeddo"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+// writeCertificate writes self signed certificate for localhost and its key into directory
+func writeCertificate(t *testing.T, dir string) TLS {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	result := TLS{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	require.NoError(t, ioutil.WriteFile(result.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600))
+	require.NoError(t, ioutil.WriteFile(result.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600))
+	return result
+}
+
+func TestRunServerTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certificate := writeCertificate(t, dir)
+	// free port is found by listening on it
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	l.Close()
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	chanErr, chanClose := RunServer(ctx, ServerConfig{Address: address, TLS: certificate})
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	var resp *http.Response
+	// wait till server starts
+	for i := 0; i < 100; i++ {
+		resp, err = client.Get("https://" + address + "/metrics")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	cancelFunc()
+	<-chanClose
+	require.NoError(t, <-chanErr)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+)
+
+// events of items reported to observers besides issues of data quality (see metrics.Issues)
+const (
+	// itemExpiring is reported for item produced with expiry time
+	itemExpiring = "expiring"
+	// itemUnchanged is reported for item which is not sent because it did not change since the last run
+	itemUnchanged = "unchanged"
+	// itemDangling is reported for every reference of item to item which is not in feed
+	itemDangling = "dangling"
+)
+
+// Observer is notified about progress of runs of feeds, so instrumentation (metrics, logs, tracing)
+// is kept apart from processing of feeds. Runs of different feeds notify observer concurrently.
+type Observer interface {
+	// OnFeedStart is called when run of feed starts, before feed is downloaded
+	OnFeedStart(feed, runID string)
+	// OnItem is called for every event of item parsed by run of feed, events are issues of data quality or item events
+	OnItem(feed, event string)
+	// OnFeedEnd is called once for every started run of feed, whatever its result is
+	OnFeedEnd(feed string, end FeedEnd)
+}
+
+// FeedEnd is result of finished run of feed
+type FeedEnd struct {
+	RunID string
+	// Items is number of items parsed from feed
+	Items int
+	// Err is nil for successful run
+	Err error
+	// Phases are nil when parsing of feed did not finish, e.g. feed could not be downloaded or run was cancelled
+	Phases *metrics.Phases
+}
+
+// metricsObserver records progress of runs into prometheus metrics. Metrics of feeds are taken from container,
+// event of feed without metrics (or when container is nil) is not counted there.
+type metricsObserver struct {
+	mg MetricsGetter
+}
+
+func newMetricsObserver(mg MetricsGetter) Observer {
+	return metricsObserver{mg: mg}
+}
+
+func (o metricsObserver) OnFeedStart(feed, runID string) {
+	metrics.ObserveRunStart(feed, runID)
+	o.add(feed, metrics.MetricTypeFeed, 1)
+}
+
+func (o metricsObserver) OnItem(feed, event string) {
+	switch event {
+	case itemExpiring:
+		metrics.ObserveExpiring(feed)
+	case itemUnchanged:
+		o.add(feed, metrics.MetricTypeUnchanged, 1)
+	case itemDangling:
+		o.add(feed, metrics.MetricTypeDangling, 1)
+	default:
+		metrics.ObserveIssue(feed, event)
+	}
+}
+
+func (o metricsObserver) OnFeedEnd(feed string, end FeedEnd) {
+	if end.Phases != nil {
+		metrics.ObservePhases(feed, *end.Phases)
+	}
+	metrics.ObserveRun(feed, end.Err)
+	o.add(feed, metrics.MetricTypeFeed, -1)
+}
+
+// add adds value to metric of feed, missing metric does not affect processing of feed
+func (o metricsObserver) add(feed, metricType string, value float64) {
+	if o.mg == nil {
+		return
+	}
+	if m, err := o.mg.GetMetric(feed, metricType); err == nil {
+		m.Add(value)
+	}
+}
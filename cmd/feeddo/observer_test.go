@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+	"github.com/grubastik/feeddo/cmd/feeddo/quality"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// observerMock records notifications of runs by feed
+type observerMock struct {
+	mu     sync.Mutex
+	starts map[string][]string
+	events map[string]map[string]int
+	ends   map[string][]FeedEnd
+}
+
+func newObserverMock() *observerMock {
+	return &observerMock{starts: map[string][]string{}, events: map[string]map[string]int{}, ends: map[string][]FeedEnd{}}
+}
+
+func (o *observerMock) OnFeedStart(feed, runID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.starts[feed] = append(o.starts[feed], runID)
+}
+
+func (o *observerMock) OnItem(feed, event string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.events[feed] == nil {
+		o.events[feed] = map[string]int{}
+	}
+	o.events[feed][event]++
+}
+
+func (o *observerMock) OnFeedEnd(feed string, end FeedEnd) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ends[feed] = append(o.ends[feed], end)
+}
+
+func TestRunOnceObserver(t *testing.T) {
+	URL, _ := url.Parse("file://testdata/invalid_text.xml")
+	missing, _ := url.Parse("file://testdata/missing.xml")
+	scrubber, err := quality.NewScrubber(quality.ScrubReject)
+	require.NoError(t, err)
+	obs := newObserverMock()
+	chanItem := make(chan sink.Item, 3)
+	opts := options{feeds: []*url.URL{URL, missing}, router: testRouter(t), scrubber: scrubber}
+	errs := runOnce(context.Background(), opts, chanItem, obs)
+	close(chanItem)
+	require.Equal(t, 1, len(errs))
+
+	require.Equal(t, 1, len(obs.starts[URL.String()]))
+	require.Equal(t, 1, len(obs.ends[URL.String()]))
+	end := obs.ends[URL.String()][0]
+	assert.Equal(t, obs.starts[URL.String()][0], end.RunID)
+	assert.Equal(t, 3, end.Items)
+	assert.NoError(t, end.Err)
+	require.NotNil(t, end.Phases)
+	assert.True(t, end.Phases.DownloadBytes > 0)
+	assert.Equal(t, 2, obs.events[URL.String()][metrics.IssueInvalidText])
+
+	// run which failed to download feed is finished without phases
+	require.Equal(t, 1, len(obs.ends[missing.String()]))
+	end = obs.ends[missing.String()][0]
+	assert.Equal(t, obs.starts[missing.String()][0], end.RunID)
+	assert.Error(t, end.Err)
+	assert.Nil(t, end.Phases)
+	assert.Empty(t, obs.events[missing.String()])
+}
+
+func TestMetricsObserver(t *testing.T) {
+	var processing, unchanged, dangling AdderCustom
+	mc := metrics.Container{"shop": {metrics.MetricTypeFeed: &processing, metrics.MetricTypeUnchanged: &unchanged, metrics.MetricTypeDangling: &dangling}}
+	obs := newMetricsObserver(mc)
+	obs.OnFeedStart("shop", "run")
+	assert.Equal(t, int32(1), processing.c)
+	obs.OnItem("shop", itemUnchanged)
+	obs.OnItem("shop", itemDangling)
+	obs.OnItem("shop", itemDangling)
+	obs.OnItem("shop", metrics.IssueFiltered)
+	assert.Equal(t, int32(1), unchanged.c)
+	assert.Equal(t, int32(2), dangling.c)
+	obs.OnFeedEnd("shop", FeedEnd{RunID: "run", Err: errors.New("test error")})
+	assert.Equal(t, int32(0), processing.c)
+
+	// feeds without metrics are not counted in container
+	obs.OnFeedStart("other", "run")
+	obs.OnItem("other", itemUnchanged)
+	obs.OnFeedEnd("other", FeedEnd{RunID: "run", Phases: &metrics.Phases{}})
+	newMetricsObserver(nil).OnItem("shop", itemUnchanged)
+	assert.Equal(t, int32(1), unchanged.c)
+}
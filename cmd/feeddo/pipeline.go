@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/archive"
+	"github.com/grubastik/feeddo/cmd/feeddo/quality"
+	"github.com/grubastik/feeddo/cmd/feeddo/refcheck"
+	"github.com/grubastik/feeddo/cmd/feeddo/throttle"
+)
+
+// stages of pipeline which parsed items pass before they are sent into sinks
+const (
+	// stageIssues reports problems of data quality
+	stageIssues = "issues"
+	// stageReferences collects references between items, so dangling ones are reported when feed is processed
+	stageReferences = "references"
+	// stageArchive writes item into archive of run
+	stageArchive = "archive"
+	// stageState tracks items in state of feed and skips unchanged items when dedup is enabled
+	stageState = "state"
+	// stageThrottle limits throughput of feed
+	stageThrottle = "throttle"
+)
+
+// defaultPipeline is order of stages when pipeline is not configured
+var defaultPipeline = []string{stageIssues, stageReferences, stageArchive, stageState, stageThrottle}
+
+// itemHandler handles item parsed by run of feed. Error is returned only when run should be stopped, e.g. it was cancelled.
+type itemHandler func(ctx context.Context, ai *appItem) error
+
+// itemMiddleware is stage of pipeline, it decides whether and when item is passed to next handler
+type itemMiddleware func(next itemHandler) itemHandler
+
+// itemStages wrap next handler by stage of pipeline of run of feed
+var itemStages = map[string]func(r *feedRun, next itemHandler) itemHandler{
+	stageIssues:     (*feedRun).issues,
+	stageReferences: (*feedRun).references,
+	stageArchive:    (*feedRun).archive,
+	stageState:      (*feedRun).state,
+	stageThrottle:   (*feedRun).throttle,
+}
+
+// feedRun is state of run of feed shared by stages of pipeline.
+// Checker, archived run and state are nil when they are disabled.
+type feedRun struct {
+	feed    string
+	obs     Observer
+	quality *quality.Checker
+	checker *refcheck.Checker
+	run     *archive.Run
+	fs      *feedState
+	th      *throttle.Throttle
+}
+
+// parsePipeline parses stages of pipeline, every value could contain several comma separated stages
+func parsePipeline(values []string) ([]string, error) {
+	stages := []string{}
+	seen := map[string]bool{}
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if _, ok := itemStages[s]; !ok {
+				return nil, fmt.Errorf("Unknown stage of pipeline '%s', supported stages are '%s'", s, strings.Join(defaultPipeline, "', '"))
+			}
+			if seen[s] {
+				return nil, fmt.Errorf("Stage '%s' is used in pipeline more than once", s)
+			}
+			seen[s] = true
+			stages = append(stages, s)
+		}
+	}
+	return stages, nil
+}
+
+// hasStage returns true when stage is part of pipeline
+func hasStage(stages []string, stage string) bool {
+	for _, s := range stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// pipeline chains stages in order, so the first stage handles item first and send is the last handler
+func (r *feedRun) pipeline(stages []string, send itemHandler) itemHandler {
+	middlewares := make([]itemMiddleware, 0, len(stages))
+	for _, s := range stages {
+		stage := itemStages[s]
+		middlewares = append(middlewares, func(next itemHandler) itemHandler { return stage(r, next) })
+	}
+	return chain(send, middlewares...)
+}
+
+// chain wraps handler by middlewares, the first middleware handles item first
+func chain(h itemHandler, middlewares ...itemMiddleware) itemHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+func (r *feedRun) issues(next itemHandler) itemHandler {
+	return func(ctx context.Context, ai *appItem) error {
+		for _, issue := range r.quality.Check(ai.product) {
+			r.obs.OnItem(r.feed, issue)
+		}
+		return next(ctx, ai)
+	}
+}
+
+func (r *feedRun) references(next itemHandler) itemHandler {
+	return func(ctx context.Context, ai *appItem) error {
+		if r.checker != nil {
+			r.checker.Add(ai.product)
+		}
+		return next(ctx, ai)
+	}
+}
+
+func (r *feedRun) archive(next itemHandler) itemHandler {
+	return func(ctx context.Context, ai *appItem) error {
+		if r.run != nil {
+			r.run.Item(*ai)
+		}
+		return next(ctx, ai)
+	}
+}
+
+func (r *feedRun) state(next itemHandler) itemHandler {
+	return func(ctx context.Context, ai *appItem) error {
+		if r.fs != nil && !r.fs.track(ai) {
+			r.obs.OnItem(r.feed, itemUnchanged)
+			return nil
+		}
+		return next(ctx, ai)
+	}
+}
+
+// throttle limits throughput of feed before item reaches sinks shared by all feeds
+func (r *feedRun) throttle(next itemHandler) itemHandler {
+	return func(ctx context.Context, ai *appItem) error {
+		if err := r.th.Wait(ctx); err != nil {
+			return err
+		}
+		return next(ctx, ai)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+	"github.com/grubastik/feeddo/cmd/feeddo/quality"
+	"github.com/grubastik/feeddo/cmd/feeddo/refcheck"
+	"github.com/grubastik/feeddo/cmd/feeddo/state"
+	"github.com/grubastik/feeddo/cmd/feeddo/throttle"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		stages []string
+		err    string
+	}{
+		{name: "default", values: []string{"issues,references,archive,state,throttle"}, stages: defaultPipeline},
+		{name: "several values", values: []string{"throttle", " state , issues"}, stages: []string{stageThrottle, stageState, stageIssues}},
+		{name: "empty", values: []string{""}, stages: []string{}},
+		{name: "unknown", values: []string{"issues,enrich"}, err: "Unknown stage of pipeline 'enrich', supported stages are 'issues', 'references', 'archive', 'state', 'throttle'"},
+		{name: "duplicated", values: []string{"issues", "issues"}, err: "Stage 'issues' is used in pipeline more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := parsePipeline(tt.values)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.stages, stages)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	calls := []string{}
+	middleware := func(name string) itemMiddleware {
+		return func(next itemHandler) itemHandler {
+			return func(ctx context.Context, ai *appItem) error {
+				calls = append(calls, name)
+				return next(ctx, ai)
+			}
+		}
+	}
+	h := chain(func(ctx context.Context, ai *appItem) error {
+		calls = append(calls, "send")
+		return nil
+	}, middleware("first"), middleware("second"))
+	require.NoError(t, h(context.Background(), &appItem{}))
+	assert.Equal(t, []string{"first", "second", "send"}, calls)
+}
+
+func TestFeedRunPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := state.NewStore(dir)
+	require.NoError(t, err)
+	fs, err := newFeedState("http://test.org", store, true, 0)
+	require.NoError(t, err)
+	mc := metrics.Container{"http://test.org": {metrics.MetricTypeUnchanged: &AdderCustom{}}}
+	r := &feedRun{feed: "http://test.org", obs: newMetricsObserver(mc), quality: quality.NewChecker(10), checker: refcheck.NewChecker(10), fs: fs, th: throttle.New(0)}
+	sent := []string{}
+	send := func(ctx context.Context, ai *appItem) error {
+		sent = append(sent, ai.product.ID)
+		return nil
+	}
+
+	// item which was already sent during run is skipped by state
+	h := r.pipeline(defaultPipeline, send)
+	for _, id := range []string{"1", "2", "1"} {
+		require.NoError(t, h(context.Background(), &appItem{product: product.Product{ID: id}, feed: r.feed}))
+	}
+	assert.Equal(t, []string{"1", "2"}, sent)
+	assert.Equal(t, int32(1), mc["http://test.org"][metrics.MetricTypeUnchanged].(*AdderCustom).c)
+
+	// stages which are not part of pipeline are skipped
+	sent = sent[:0]
+	h = r.pipeline([]string{stageIssues}, send)
+	require.NoError(t, h(context.Background(), &appItem{product: product.Product{ID: "1"}, feed: r.feed}))
+	assert.Equal(t, []string{"1"}, sent)
+
+	// error of stage stops item, the only token of throttle is taken, so item waits till ctx is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.th = throttle.New(1)
+	require.NoError(t, r.th.Wait(context.Background()))
+	h = r.pipeline([]string{stageThrottle}, send)
+	err = h(ctx, &appItem{product: product.Product{ID: "3"}, feed: r.feed})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, []string{"1"}, sent)
+}
@@ -1,30 +0,0 @@
-package provider
-
-import (
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"os"
-)
-
-// CreateStream generate stream from provided url
-func CreateStream(u *url.URL) (io.ReadCloser, error) {
-	var readCloser io.ReadCloser
-	var err error
-	if u.Scheme == "file" {
-		readCloser, err = os.Open(u.Hostname() + u.EscapedPath())
-		if err != nil {
-			return nil, fmt.Errorf("Unable to read file `%v` because of %w", u, err)
-		}
-	} else {
-		resp, err := http.Get(u.String())
-		if err == nil && resp.Body != nil {
-			readCloser = resp.Body
-		}
-		if err != nil {
-			return nil, fmt.Errorf("Unable to download file `%v` because of %w", u, err)
-		}
-	}
-	return readCloser, nil
-}
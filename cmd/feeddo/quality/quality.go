@@ -0,0 +1,66 @@
+package quality
+
+import (
+	"hash/fnv"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+)
+
+// Checker detects data quality issues of items of a single feed.
+// Duplicates are detected by hashes of ids, memory is bounded by maxIDs:
+// once limit is reached duplicates of not indexed ids are not detected and checker is marked as truncated.
+// Checker is not thread safe and supposed to be used by single feed processing goroutine.
+type Checker struct {
+	maxIDs    int
+	truncated bool
+	ids       map[uint64]struct{}
+}
+
+// NewChecker creates checker which will keep at most maxIDs hashes of ids in memory.
+// Duplicates are not detected when maxIDs is zero.
+func NewChecker(maxIDs int) *Checker {
+	return &Checker{maxIDs: maxIDs, ids: make(map[uint64]struct{})}
+}
+
+// Check returns issues of item (metrics.Issue* values), result is empty for item without issues
+func (c *Checker) Check(p product.Product) []string {
+	issues := []string{}
+	if p.ID == "" {
+		// item could not be identified, so other issues are not relevant
+		return append(issues, metrics.IssueMalformed)
+	}
+	if p.PriceVAT.IsZero() {
+		issues = append(issues, metrics.IssueMissingPrice)
+	}
+	if p.EAN == "" {
+		issues = append(issues, metrics.IssueMissingEAN)
+	}
+	if c.duplicate(p.ID) {
+		issues = append(issues, metrics.IssueDuplicate)
+	}
+	return issues
+}
+
+// Truncated indicates that index limit was reached and not all duplicates were detected
+func (c *Checker) Truncated() bool {
+	return c.truncated
+}
+
+func (c *Checker) duplicate(id string) bool {
+	if c.maxIDs == 0 {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	key := h.Sum64()
+	if _, ok := c.ids[key]; ok {
+		return true
+	}
+	if len(c.ids) >= c.maxIDs {
+		c.truncated = true
+		return false
+	}
+	c.ids[key] = struct{}{}
+	return false
+}
@@ -0,0 +1,73 @@
+package quality
+
+import (
+	"testing"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	price := decimal.NewFromInt(10)
+	tests := []struct {
+		name      string
+		maxIDs    int
+		items     []product.Product
+		expected  [][]string
+		truncated bool
+	}{
+		{
+			"valid items",
+			10,
+			[]product.Product{{ID: "1", PriceVAT: price, EAN: "123"}, {ID: "2", PriceVAT: price, EAN: "456"}},
+			[][]string{{}, {}},
+			false,
+		},
+		{
+			"malformed item",
+			10,
+			[]product.Product{{}},
+			[][]string{{metrics.IssueMalformed}},
+			false,
+		},
+		{
+			"missing price and ean",
+			10,
+			[]product.Product{{ID: "1"}, {ID: "2", PriceVAT: decimal.Zero, EAN: "123"}},
+			[][]string{{metrics.IssueMissingPrice, metrics.IssueMissingEAN}, {metrics.IssueMissingPrice}},
+			false,
+		},
+		{
+			"duplicates",
+			10,
+			[]product.Product{{ID: "1", PriceVAT: price, EAN: "123"}, {ID: "2", PriceVAT: price, EAN: "123"}, {ID: "1", PriceVAT: price, EAN: "123"}},
+			[][]string{{}, {}, {metrics.IssueDuplicate}},
+			false,
+		},
+		{
+			"duplicates are not detected",
+			0,
+			[]product.Product{{ID: "1", PriceVAT: price, EAN: "123"}, {ID: "1", PriceVAT: price, EAN: "123"}},
+			[][]string{{}, {}},
+			false,
+		},
+		{
+			"index limit reached",
+			1,
+			[]product.Product{{ID: "1", PriceVAT: price, EAN: "123"}, {ID: "2", PriceVAT: price, EAN: "123"}, {ID: "2", PriceVAT: price, EAN: "123"}, {ID: "1", PriceVAT: price, EAN: "123"}},
+			[][]string{{}, {}, {}, {metrics.IssueDuplicate}},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(tt.maxIDs)
+			for i, p := range tt.items {
+				assert.Equal(t, tt.expected[i], c.Check(p))
+			}
+			assert.Equal(t, tt.truncated, c.Truncated())
+		})
+	}
+}
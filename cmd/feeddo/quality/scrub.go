@@ -0,0 +1,166 @@
+package quality
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+)
+
+// policies of handling of invalid text of string fields
+const (
+	// ScrubReject item with invalid text is skipped
+	ScrubReject = "reject"
+	// ScrubReplace invalid bytes and control characters are replaced by U+FFFD
+	ScrubReplace = "replace"
+	// ScrubStrip invalid bytes and control characters are removed
+	ScrubStrip = "strip"
+)
+
+// Scrubber checks that string fields of items are valid UTF-8 without control characters,
+// which break JSON consumers. Tab, new line and carriage return are allowed.
+// Zero value does not check items.
+type Scrubber struct {
+	policy string
+}
+
+// NewScrubber creates scrubber with policy, items are not checked when policy is empty
+func NewScrubber(policy string) (Scrubber, error) {
+	switch policy {
+	case "", ScrubReject, ScrubReplace, ScrubStrip:
+		return Scrubber{policy: policy}, nil
+	}
+	return Scrubber{}, fmt.Errorf("Invalid text policy '%s' is not supported, use '%s', '%s' or '%s'", policy, ScrubReject, ScrubReplace, ScrubStrip)
+}
+
+// Rejects reports whether items with invalid text are skipped
+func (s Scrubber) Rejects() bool {
+	return s.policy == ScrubReject
+}
+
+// Scrub returns product with scrubbed string fields and whether invalid text was found.
+// Product is returned unchanged when invalid text is found and policy is ScrubReject, caller should skip it.
+// Lists of product are copied before they are changed.
+func (s Scrubber) Scrub(p product.Product) (product.Product, bool) {
+	if s.policy == "" || valid(p) {
+		return p, false
+	}
+	if s.policy == ScrubReject {
+		return p, true
+	}
+	p.ID = s.clean(p.ID)
+	p.Name = s.clean(p.Name)
+	p.Title = s.clean(p.Title)
+	p.Description = s.clean(p.Description)
+	p.URL = s.clean(p.URL)
+	p.ImageURL = s.clean(p.ImageURL)
+	p.AlternativeImageURLs = s.cleanList(p.AlternativeImageURLs)
+	p.VideoURL = s.clean(p.VideoURL)
+	p.VAT = s.clean(p.VAT)
+	p.Type = s.clean(p.Type)
+	p.Manufacturer = s.clean(p.Manufacturer)
+	p.Category = s.clean(p.Category)
+	p.EAN = s.clean(p.EAN)
+	p.ISBN = s.clean(p.ISBN)
+	if p.Parameters != nil {
+		params := make([]product.Parameter, len(p.Parameters))
+		for i, param := range p.Parameters {
+			params[i] = product.Parameter{Name: s.clean(param.Name), Value: s.clean(param.Value)}
+		}
+		p.Parameters = params
+	}
+	p.DeliveryDate = s.clean(p.DeliveryDate)
+	if p.Deliveries != nil {
+		deliveries := make([]product.Delivery, len(p.Deliveries))
+		for i, d := range p.Deliveries {
+			d.ID = s.clean(d.ID)
+			deliveries[i] = d
+		}
+		p.Deliveries = deliveries
+	}
+	p.GroupID = s.clean(p.GroupID)
+	p.Accessories = s.cleanList(p.Accessories)
+	if p.Gifts != nil {
+		gifts := make([]product.Gift, len(p.Gifts))
+		for i, g := range p.Gifts {
+			gifts[i] = product.Gift{ID: s.clean(g.ID), Name: s.clean(g.Name)}
+		}
+		p.Gifts = gifts
+	}
+	return p, true
+}
+
+// valid reports whether all string fields of product are valid
+func valid(p product.Product) bool {
+	fields := []string{p.ID, p.Name, p.Title, p.Description, p.URL, p.ImageURL, p.VideoURL, p.VAT, p.Type,
+		p.Manufacturer, p.Category, p.EAN, p.ISBN, p.DeliveryDate, p.GroupID}
+	fields = append(fields, p.AlternativeImageURLs...)
+	fields = append(fields, p.Accessories...)
+	for _, param := range p.Parameters {
+		fields = append(fields, param.Name, param.Value)
+	}
+	for _, d := range p.Deliveries {
+		fields = append(fields, d.ID)
+	}
+	for _, g := range p.Gifts {
+		fields = append(fields, g.ID, g.Name)
+	}
+	for _, f := range fields {
+		if !validText(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// validText reports whether text is valid UTF-8 without control characters
+func validText(s string) bool {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !allowed(r, size) {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
+// allowed reports whether rune decoded from size bytes is kept in text, invalid UTF-8 is decoded as single byte utf8.RuneError
+func allowed(r rune, size int) bool {
+	if r == utf8.RuneError && size == 1 {
+		return false
+	}
+	return r == '\t' || r == '\n' || r == '\r' || !unicode.IsControl(r)
+}
+
+// clean replaces or removes invalid bytes and control characters of text
+func (s Scrubber) clean(text string) string {
+	if validText(text) {
+		return text
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if allowed(r, size) {
+			b.WriteString(text[i : i+size])
+		} else if s.policy == ScrubReplace {
+			b.WriteRune(utf8.RuneError)
+		}
+		i += size
+	}
+	return b.String()
+}
+
+func (s Scrubber) cleanList(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	cleaned := make([]string, len(list))
+	for i, text := range list {
+		cleaned[i] = s.clean(text)
+	}
+	return cleaned
+}
@@ -0,0 +1,78 @@
+package quality
+
+import (
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrub(t *testing.T) {
+	broken := product.Product{
+		ID:          "1",
+		Name:        "bad\xffbyte",
+		Description: "line\nnext\tcolumn\x00end",
+		Accessories: []string{"2", "3\u0085"},
+		Parameters:  []product.Parameter{{Name: "color\x1b", Value: "red"}},
+		Gifts:       []product.Gift{{ID: "g", Name: "mug\u0092"}},
+	}
+	tests := []struct {
+		name     string
+		policy   string
+		item     product.Product
+		expected product.Product
+		invalid  bool
+	}{
+		{"not checked", "", broken, broken, false},
+		{"valid item", ScrubStrip, product.Product{ID: "1", Name: "Tiskárna �", Description: "a\r\nb\tc"}, product.Product{ID: "1", Name: "Tiskárna �", Description: "a\r\nb\tc"}, false},
+		{"rejected", ScrubReject, broken, broken, true},
+		{
+			"replaced",
+			ScrubReplace,
+			broken,
+			product.Product{
+				ID:          "1",
+				Name:        "bad�byte",
+				Description: "line\nnext\tcolumn�end",
+				Accessories: []string{"2", "3�"},
+				Parameters:  []product.Parameter{{Name: "color�", Value: "red"}},
+				Gifts:       []product.Gift{{ID: "g", Name: "mug�"}},
+			},
+			true,
+		},
+		{
+			"stripped",
+			ScrubStrip,
+			broken,
+			product.Product{
+				ID:          "1",
+				Name:        "badbyte",
+				Description: "line\nnext\tcolumnend",
+				Accessories: []string{"2", "3"},
+				Parameters:  []product.Parameter{{Name: "color", Value: "red"}},
+				Gifts:       []product.Gift{{ID: "g", Name: "mug"}},
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewScrubber(tt.policy)
+			require.NoError(t, err)
+			p, invalid := s.Scrub(tt.item)
+			assert.Equal(t, tt.invalid, invalid)
+			assert.Equal(t, tt.expected, p)
+		})
+	}
+	// lists of original item are not changed
+	assert.Equal(t, "3\u0085", broken.Accessories[1])
+}
+
+func TestNewScrubber(t *testing.T) {
+	s, err := NewScrubber(ScrubReject)
+	require.NoError(t, err)
+	assert.True(t, s.Rejects())
+	_, err = NewScrubber("fix")
+	assert.EqualError(t, err, "Invalid text policy 'fix' is not supported, use 'reject', 'replace' or 'strip'")
+}
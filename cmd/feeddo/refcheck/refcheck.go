@@ -0,0 +1,119 @@
+package refcheck
+
+import (
+	"github.com/grubastik/feeddo/internal/pkg/product"
+)
+
+const (
+	// KindAccessory identifies reference from ACCESSORY element
+	KindAccessory = "ACCESSORY"
+	// KindGroup identifies reference from ITEMGROUP_ID element
+	KindGroup = "ITEMGROUP_ID"
+)
+
+// Reference describes link from one item to another one which could not be resolved within feed
+type Reference struct {
+	Kind   string
+	ItemID string
+	Target string
+}
+
+// Checker collects item ids and references between items of a single feed.
+// Memory is bounded by maxEntries: once limit is reached checker stops collecting
+// and report is marked as truncated (references which were not collected are not validated).
+// Items added after limit was reached still resolve references and groups collected before,
+// so only references which really could not be resolved are reported.
+// Checker is not thread safe and supposed to be used by single feed processing goroutine.
+type Checker struct {
+	maxEntries  int
+	entries     int
+	truncated   bool
+	ids         map[string]struct{}
+	groups      map[string]string // group id -> item id of the first member, empty if group has more members
+	accessories map[string]string // referenced item id -> item id which references it first
+}
+
+// NewChecker creates checker which will keep at most maxEntries ids and references in memory
+func NewChecker(maxEntries int) *Checker {
+	return &Checker{
+		maxEntries:  maxEntries,
+		ids:         make(map[string]struct{}),
+		groups:      make(map[string]string),
+		accessories: make(map[string]string),
+	}
+}
+
+// Add registers item and its references
+func (c *Checker) Add(item product.Product) {
+	id := item.ID
+	if id == "" {
+		return
+	}
+	if _, ok := c.ids[id]; !ok {
+		if !c.reserve() {
+			c.resolve(id, item.GroupID)
+			return
+		}
+		c.ids[id] = struct{}{}
+	}
+	if item.GroupID != "" {
+		if _, ok := c.groups[item.GroupID]; ok {
+			// group has at least 2 members - it is valid
+			c.groups[item.GroupID] = ""
+		} else if c.reserve() {
+			c.groups[item.GroupID] = id
+		}
+	}
+	for _, a := range item.Accessories {
+		if a == "" {
+			continue
+		}
+		if _, ok := c.accessories[a]; ok {
+			continue
+		}
+		if !c.reserve() {
+			return
+		}
+		c.accessories[a] = id
+	}
+}
+
+// resolve marks collected references to item and its group as valid, item itself is not collected
+func (c *Checker) resolve(id, groupID string) {
+	delete(c.accessories, id)
+	if _, ok := c.groups[groupID]; ok {
+		c.groups[groupID] = ""
+	}
+}
+
+// Dangling returns references which could not be resolved within items added so far.
+// It should be called once all items of the feed were added.
+func (c *Checker) Dangling() []Reference {
+	refs := []Reference{}
+	for target, itemID := range c.accessories {
+		if _, ok := c.ids[target]; !ok {
+			refs = append(refs, Reference{Kind: KindAccessory, ItemID: itemID, Target: target})
+		}
+	}
+	// group with single member does not link item to any other item
+	for group, itemID := range c.groups {
+		if itemID != "" {
+			refs = append(refs, Reference{Kind: KindGroup, ItemID: itemID, Target: group})
+		}
+	}
+	return refs
+}
+
+// Truncated indicates that index limit was reached and not all references were validated
+func (c *Checker) Truncated() bool {
+	return c.truncated
+}
+
+func (c *Checker) reserve() bool {
+	if c.entries >= c.maxEntries {
+		c.truncated = true
+		return false
+	}
+	c.entries++
+	return true
+}
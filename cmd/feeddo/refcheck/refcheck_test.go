@@ -0,0 +1,85 @@
+package refcheck
+
+import (
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDangling(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		items      []product.Product
+		expected   []Reference
+		truncated  bool
+	}{
+		{
+			"no references",
+			10,
+			[]product.Product{{ID: "1"}, {ID: "2"}},
+			[]Reference{},
+			false,
+		},
+		{
+			"accessory resolved by later item",
+			10,
+			[]product.Product{{ID: "1", Accessories: []string{"2"}}, {ID: "2", Accessories: []string{"1"}}},
+			[]Reference{},
+			false,
+		},
+		{
+			"dangling accessory",
+			10,
+			[]product.Product{{ID: "1", Accessories: []string{"2", "3"}}, {ID: "2"}},
+			[]Reference{{Kind: KindAccessory, ItemID: "1", Target: "3"}},
+			false,
+		},
+		{
+			"group with multiple members",
+			10,
+			[]product.Product{{ID: "1", GroupID: "g"}, {ID: "2", GroupID: "g"}},
+			[]Reference{},
+			false,
+		},
+		{
+			"group with single member",
+			10,
+			[]product.Product{{ID: "1", GroupID: "g"}, {ID: "2", GroupID: "h"}, {ID: "3", GroupID: "h"}},
+			[]Reference{{Kind: KindGroup, ItemID: "1", Target: "g"}},
+			false,
+		},
+		{
+			"index limit reached",
+			2,
+			[]product.Product{{ID: "1", Accessories: []string{"4"}}, {ID: "2"}, {ID: "3"}},
+			[]Reference{{Kind: KindAccessory, ItemID: "1", Target: "4"}},
+			true,
+		},
+		{
+			"references resolved by items after index limit",
+			3,
+			[]product.Product{
+				{ID: "1", GroupID: "g", Accessories: []string{"4"}},
+				{ID: "2", GroupID: "h", Accessories: []string{"5"}},
+				{ID: "3"},
+				{ID: "4", GroupID: "g"},
+				{ID: "5", GroupID: "i", Accessories: []string{"6"}},
+			},
+			// group and accessory of the second item were not collected
+			[]Reference{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(tt.maxEntries)
+			for _, i := range tt.items {
+				c.Add(i)
+			}
+			assert.ElementsMatch(t, tt.expected, c.Dangling())
+			assert.Equal(t, tt.truncated, c.Truncated())
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/archive"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+const (
+	// replayCommand is the first argument which switches app into replay of archived items
+	replayCommand = "replay"
+	// replayAllTopics overrides all topics of items
+	replayAllTopics = "*"
+)
+
+// replayArgs are flags of replay command
+type replayArgs struct {
+	From   string   `long:"from" description:"Archived items or any NDJSON file in format of file sink: path, 'file://', 'http(s)://', 's3://<bucket>/<key>' or 'gs://<bucket>/<key>'. Files with suffix '.gz' are decompressed" required:"true" env:"REPLAY_FROM"`
+	Topics []string `long:"replayTopic" description:"Override of topic in format '<original topic>=<topic>', '*=<topic>' replays all items into single topic. Can be used multiple times" env:"REPLAY_TOPICS" env-delim:","`
+}
+
+// replayOptions define which items are replayed and where
+type replayOptions struct {
+	from string
+	// topics maps original topic of item to topic where item is replayed
+	topics map[string]string
+}
+
+// parseTopicOverrides parses overrides in format '<original topic>=<topic>'
+func parseTopicOverrides(values []string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, v := range values {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("Topic override '%s' should have format '<original topic>=<topic>'", v)
+		}
+		overrides[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return overrides, nil
+}
+
+// replayItem is item read from NDJSON file
+type replayItem struct {
+	record sink.Record
+	topics []string
+}
+
+func (ri replayItem) GetContext() string { return ri.record.Context }
+func (ri replayItem) GetID() string      { return ri.record.ID }
+func (ri replayItem) Topics() []string   { return ri.topics }
+
+func (ri replayItem) Marshal() ([]byte, error) {
+	if ri.record.Tombstone {
+		return nil, nil
+	}
+	return ri.record.Payload, nil
+}
+
+func (ri replayItem) Headers() []sink.Header {
+	headers := make([]sink.Header, 0, len(ri.record.Headers))
+	for k, v := range ri.record.Headers {
+		headers = append(headers, sink.Header{Key: k, Value: []byte(v)})
+	}
+	// headers are sent in stable order
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Key < headers[j].Key })
+	return headers
+}
+
+// replayTopics returns topics where item is replayed. The same topic is used only once.
+func replayTopics(topics []string, overrides map[string]string) []string {
+	if len(overrides) == 0 {
+		return topics
+	}
+	result := make([]string, 0, len(topics))
+	seen := map[string]struct{}{}
+	for _, t := range topics {
+		if o, ok := overrides[t]; ok {
+			t = o
+		} else if o, ok := overrides[replayAllTopics]; ok {
+			t = o
+		}
+		if _, ok := seen[t]; !ok {
+			seen[t] = struct{}{}
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// replaySummary is result of replay
+type replaySummary struct {
+	total     int
+	failed    int
+	tombstone int
+}
+
+// replayRun delivers items from file by configured sinks. Error is returned when some items were not delivered.
+func replayRun(opts options) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := newSink(ctx, opts)
+	if err != nil {
+		return err
+	}
+	// deferred functions are called in LIFO order - sink will be closed before cancelling of its context
+	defer s.Close()
+	chanItem := make(chan sink.Item, opts.itemBuffer)
+	defer close(chanItem)
+	chanRes, chanExited := sink.Run(ctx, s, opts.maxProducers, chanItem)
+	chanSummary := make(chan replaySummary)
+	go func() {
+		chanSummary <- collectReplayResults(chanRes, chanExited)
+	}()
+	read, errRead := readReplay(ctx, opts.replay, chanItem)
+	// all items were read - wait for delivery of buffered ones
+	cancel()
+	summary := <-chanSummary
+	log.Printf("Replayed %d of %d items from '%s': %d failed, %d tombstones", summary.total-summary.failed, read, opts.replay.from, summary.failed, summary.tombstone)
+	if errRead == nil && summary.failed > 0 {
+		errRead = fmt.Errorf("%d of %d items were not replayed", summary.failed, read)
+	}
+	if errRead != nil {
+		log.Println(errRead)
+	}
+	return errRead
+}
+
+// readReplay sends items from file into channel and returns number of sent items
+func readReplay(ctx context.Context, r *replayOptions, chanItem chan<- sink.Item) (int, error) {
+	rc, err := archive.Open(ctx, r.from)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	reader := bufio.NewReader(rc)
+	read := 0
+	for line := 1; ; line++ {
+		// lines are not limited in size as payload of item could be large
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return read, fmt.Errorf("Unable to read '%s' because of %w", r.from, err)
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			record := sink.Record{}
+			if errJ := json.Unmarshal(data, &record); errJ != nil {
+				return read, fmt.Errorf("Unable to parse line %d of '%s' because of %w", line, r.from, errJ)
+			}
+			if record.Context == "" || record.ID == "" {
+				return read, fmt.Errorf("Line %d of '%s' does not contain context and id of item", line, r.from)
+			}
+			chanItem <- replayItem{record: record, topics: replayTopics(record.Topics, r.topics)}
+			read++
+		}
+		if err == io.EOF {
+			return read, nil
+		}
+	}
+}
+
+// collectReplayResults logs errors of delivery and counts results
+func collectReplayResults(chanRes <-chan sink.Result, chanExited <-chan struct{}) replaySummary {
+	summary := replaySummary{}
+	for {
+		select {
+		case res := <-chanRes:
+			if res.ItemContext == "" {
+				if res.Err != nil {
+					log.Println(fmt.Errorf("got the following error in app: %w", res.Err))
+				}
+				continue
+			}
+			// item is replayed once even when it is delivered by several sinks
+			if !res.Secondary {
+				summary.total++
+				if res.Tombstone {
+					summary.tombstone++
+				}
+			}
+			if res.Err != nil {
+				log.Println(fmt.Errorf("Failed to replay item %s of feed '%s': %w", res.ItemID, res.ItemContext, res.Err))
+				if !res.Secondary {
+					summary.failed++
+				}
+			}
+		case <-chanExited:
+			return summary
+		}
+	}
+}
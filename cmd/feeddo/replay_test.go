@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/grubastik/feeddo/internal/pkg/sink/filesink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const replayItems = `{"context":"http://test.org","id":"1","topics":["shop_items","shop_bidding"],"headers":{"feed":"http://test.org"},"payload":{"id":"1"}}
+
+{"context":"http://test.org","id":"2","topics":["shop_items"],"tombstone":true}
+`
+
+func TestParseTopicOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected map[string]string
+		err      string
+	}{
+		{name: "empty", expected: map[string]string{}},
+		{name: "overrides", values: []string{"shop_items=items_backfill", " *=all "}, expected: map[string]string{"shop_items": "items_backfill", "*": "all"}},
+		{name: "without topic", values: []string{"shop_items="}, err: "Topic override 'shop_items=' should have format '<original topic>=<topic>'"},
+		{name: "without separator", values: []string{"shop_items"}, err: "Topic override 'shop_items' should have format '<original topic>=<topic>'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := parseTopicOverrides(tt.values)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, o)
+		})
+	}
+}
+
+func TestReplayTopics(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		expected  []string
+	}{
+		{name: "original topics", expected: []string{"shop_items", "shop_bidding"}},
+		{name: "overridden topic", overrides: map[string]string{"shop_items": "backfill"}, expected: []string{"backfill", "shop_bidding"}},
+		{name: "all topics", overrides: map[string]string{"*": "backfill"}, expected: []string{"backfill"}},
+		{name: "exact override wins", overrides: map[string]string{"*": "backfill", "shop_bidding": "bidding"}, expected: []string{"backfill", "bidding"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, replayTopics([]string{"shop_items", "shop_bidding"}, tt.overrides))
+		})
+	}
+}
+
+func TestReadReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		name    string
+		content string
+		read    int
+		err     string
+	}{
+		{name: "items", content: replayItems, read: 2},
+		{name: "without trailing new line", content: `{"context":"http://test.org","id":"1","payload":{}}`, read: 1},
+		{name: "invalid line", content: replayItems + "{\n", read: 2, err: "Unable to parse line 4 of '%s' because of unexpected end of JSON input"},
+		{name: "without id", content: `{"context":"http://test.org"}`, err: "Line 1 of '%s' does not contain context and id of item"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "items.ndjson")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.content), 0644))
+			items := make(chan sink.Item, 10)
+			read, err := readReplay(context.Background(), &replayOptions{from: path}, items)
+			assert.Equal(t, tt.read, read)
+			assert.Equal(t, tt.read, len(items))
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, fmt.Sprintf(tt.err, path), err.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+	_, err = readReplay(context.Background(), &replayOptions{from: filepath.Join(dir, "absent.ndjson")}, make(chan sink.Item))
+	require.Error(t, err)
+}
+
+func TestReplayRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	from := filepath.Join(dir, "items.ndjson")
+	require.NoError(t, ioutil.WriteFile(from, []byte(replayItems), 0644))
+	to := filepath.Join(dir, "replayed.ndjson")
+	opts := options{
+		sinks:        []string{filesink.SinkName},
+		sinkFile:     to,
+		maxProducers: 2,
+		itemBuffer:   10,
+		replay:       &replayOptions{from: from, topics: map[string]string{"shop_items": "backfill"}},
+	}
+	require.NoError(t, replayRun(opts))
+
+	f, err := os.Open(to)
+	require.NoError(t, err)
+	defer f.Close()
+	records := map[string]sink.Record{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		r := sink.Record{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		records[r.ID] = r
+	}
+	assert.Equal(t, map[string]sink.Record{
+		"1": {Context: "http://test.org", ID: "1", Topics: []string{"backfill", "shop_bidding"}, Headers: map[string]string{"feed": "http://test.org"}, Payload: json.RawMessage(`{"id":"1"}`)},
+		"2": {Context: "http://test.org", ID: "2", Topics: []string{"backfill"}, Tombstone: true, Payload: json.RawMessage(`null`)},
+	}, records)
+
+	opts.replay.from = filepath.Join(dir, "absent.ndjson")
+	require.Error(t, replayRun(opts))
+}
@@ -0,0 +1,84 @@
+package reporter
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+)
+
+const (
+	// TagCategory is tag of reported error with its category
+	TagCategory = "category"
+	// TagFeed is tag of reported error with url of feed which was processed
+	TagFeed = "feed"
+	// TagRunID is tag of reported error with id of run of feed which failed
+	TagRunID = "run_id"
+	// TagItemID is tag of reported error with id of item which was processed
+	TagItemID = "item_id"
+	// TagLine is tag of reported parse error with line of feed where it happened
+	TagLine = "line"
+	// TagOffset is tag of reported parse error with byte offset of feed where it happened
+	TagOffset = "offset"
+	// TagPreviousItemID is tag of reported parse error with id of the last item parsed before it
+	TagPreviousItemID = "previous_item_id"
+)
+
+// Reporter forwards errors of app into error tracking service
+type Reporter interface {
+	// Report sends error asynchronously
+	Report(err error)
+	// Flush waits till reported errors are sent, but not longer than timeout
+	Flush(timeout time.Duration)
+}
+
+// Sentry reports errors into Sentry
+type Sentry struct {
+	client *sentry.Client
+}
+
+// NewSentry creates reporter into Sentry project set by dsn. Environment is optional.
+func NewSentry(dsn, environment string) (*Sentry, error) {
+	return newSentry(sentry.ClientOptions{Dsn: dsn, Environment: environment})
+}
+
+func newSentry(options sentry.ClientOptions) (*Sentry, error) {
+	client, err := sentry.NewClient(options)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create Sentry client because of %w", err)
+	}
+	return &Sentry{client: client}, nil
+}
+
+// Report sends error with its category, feed, run, item and position of parse error as tags, so errors could be grouped and searched by them
+func (s *Sentry) Report(err error) {
+	scope := sentry.NewScope()
+	scope.SetTag(TagCategory, apperror.Category(err))
+	feed, itemID := apperror.Context(err)
+	if feed != "" {
+		scope.SetTag(TagFeed, feed)
+	}
+	if runID := apperror.RunID(err); runID != "" {
+		scope.SetTag(TagRunID, runID)
+	}
+	if itemID != "" {
+		scope.SetTag(TagItemID, itemID)
+	}
+	if pos := apperror.ParsePosition(err); pos != nil {
+		if pos.Line > 0 {
+			scope.SetTag(TagLine, strconv.Itoa(pos.Line))
+		}
+		scope.SetTag(TagOffset, strconv.FormatInt(pos.Offset, 10))
+		if pos.PreviousItemID != "" {
+			scope.SetTag(TagPreviousItemID, pos.PreviousItemID)
+		}
+	}
+	s.client.CaptureException(err, nil, scope)
+}
+
+// Flush waits till reported errors are sent, but not longer than timeout
+func (s *Sentry) Flush(timeout time.Duration) {
+	s.client.Flush(timeout)
+}
@@ -0,0 +1,78 @@
+package reporter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transportMock collects events instead of sending them
+type transportMock struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *transportMock) Configure(options sentry.ClientOptions) {}
+func (t *transportMock) Flush(timeout time.Duration) bool       { return true }
+func (t *transportMock) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func TestReport(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		tags map[string]string
+	}{
+		{
+			"not categorized",
+			errors.New("test error"),
+			map[string]string{TagCategory: apperror.CategoryOther},
+		},
+		{
+			"feed",
+			&apperror.ContextError{Feed: "http://test.org", RunID: "run", Err: &apperror.ParseError{Err: errors.New("test error")}},
+			map[string]string{TagCategory: apperror.CategoryParse, TagFeed: "http://test.org", TagRunID: "run"},
+		},
+		{
+			"parse position",
+			&apperror.ContextError{Feed: "http://test.org", Err: &apperror.ParseError{Err: errors.New("test error"), Position: &apperror.Position{Line: 3, Offset: 42, PreviousItemID: "1"}}},
+			map[string]string{TagCategory: apperror.CategoryParse, TagFeed: "http://test.org", TagLine: "3", TagOffset: "42", TagPreviousItemID: "1"},
+		},
+		{
+			"item",
+			fmt.Errorf("wrapped: %w", &apperror.ContextError{Feed: "http://test.org", ItemID: "1", Err: &apperror.DeliveryError{Err: errors.New("test error")}}),
+			map[string]string{TagCategory: apperror.CategoryDelivery, TagFeed: "http://test.org", TagItemID: "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &transportMock{}
+			s, err := newSentry(sentry.ClientOptions{Dsn: "https://key@sentry.test.org/1", Environment: "test", Transport: transport})
+			require.NoError(t, err)
+			s.Report(tt.err)
+			s.Flush(time.Second)
+			require.Equal(t, 1, len(transport.events))
+			e := transport.events[0]
+			assert.Equal(t, tt.tags, e.Tags)
+			assert.Equal(t, "test", e.Environment)
+			require.NotEmpty(t, e.Exception)
+			assert.Equal(t, tt.err.Error(), e.Exception[len(e.Exception)-1].Value)
+		})
+	}
+}
+
+func TestNewSentry(t *testing.T) {
+	_, err := NewSentry("wrong dsn", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unable to create Sentry client")
+}
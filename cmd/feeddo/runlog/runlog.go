@@ -0,0 +1,162 @@
+package runlog
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+)
+
+// Run is summary of single run of feed
+type Run struct {
+	// ID is unique id of run, it is attached to logs, errors and delivered items of run
+	ID    string    `json:"id,omitempty"`
+	Feed  string    `json:"feed"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Items is number of items parsed from feed
+	Items int `json:"items"`
+	// Error is empty for successful run
+	Error string `json:"error,omitempty"`
+	// ErrorPosition locates error in feed when run failed because feed could not be parsed
+	ErrorPosition *apperror.Position `json:"errorPosition,omitempty"`
+	// Metadata of feed, e.g. time when feed was generated, it is empty when feed has no metadata
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// NewID returns random UUID (version 4) identifying run of feed
+func NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// reader of system random numbers does not fail in practice, time keeps id unique enough
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Log keeps summaries of the last runs of feeds in memory.
+// When file is set, summaries are persisted there after every run and loaded on start, so history survives restarts.
+// Log is safe for concurrent use.
+type Log struct {
+	size int
+	file string
+	mu   sync.Mutex
+	// oldest run first
+	runs []Run
+}
+
+// New creates log which keeps size last runs. Runs are loaded from file if it exists, empty file disables persistence.
+func New(size int, file string) (*Log, error) {
+	l := &Log{size: size, file: file, runs: make([]Run, 0, size)}
+	if file == "" {
+		return l, nil
+	}
+	runs, err := Load(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) > size {
+		runs = runs[len(runs)-size:]
+	}
+	l.runs = append(l.runs, runs...)
+	return l, nil
+}
+
+// Load reads runs persisted in file, oldest run first. Missing file has no runs.
+func Load(file string) ([]Run, error) {
+	runs := []Run{}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return runs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read run log '%s' because of %w", file, err)
+	}
+	err = json.Unmarshal(data, &runs)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode run log '%s' because of %w", file, err)
+	}
+	return runs, nil
+}
+
+// Add appends summary of run, the oldest run is removed when log is full.
+// Run is kept in memory even if it could not be persisted.
+func (l *Log) Add(r Run) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.runs) >= l.size {
+		l.runs = append(l.runs[:0], l.runs[len(l.runs)-l.size+1:]...)
+	}
+	l.runs = append(l.runs, r)
+	if l.file == "" {
+		return nil
+	}
+	return l.save()
+}
+
+// Runs returns the last runs of feed (all feeds when feed is empty), newest first.
+// At most limit runs are returned, zero limit means no limit.
+func (l *Log) Runs(feed string, limit int) []Run {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	runs := []Run{}
+	for i := len(l.runs) - 1; i >= 0; i-- {
+		if limit > 0 && len(runs) >= limit {
+			break
+		}
+		if feed == "" || l.runs[i].Feed == feed {
+			runs = append(runs, l.runs[i])
+		}
+	}
+	return runs
+}
+
+// ServeHTTP responds with json list of runs, newest first.
+// Query parameters "feed" and "limit" filter runs by feed url and limit number of runs.
+func (l *Log) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if s := r.URL.Query().Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 0 {
+			http.Error(w, fmt.Sprintf("Limit '%s' should be non negative number", s), http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(l.Runs(r.URL.Query().Get("feed"), limit))
+}
+
+// save replaces file with runs. File is replaced atomically so crash does not leave broken log.
+func (l *Log) save() error {
+	data, err := json.Marshal(l.runs)
+	if err != nil {
+		return fmt.Errorf("Unable to encode run log because of %w", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(l.file), "runlog-*")
+	if err != nil {
+		return fmt.Errorf("Unable to save run log '%s' because of %w", l.file, err)
+	}
+	_, err = tmp.Write(data)
+	if errC := tmp.Close(); err == nil {
+		err = errC
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Unable to save run log '%s' because of %w", l.file, err)
+	}
+	return nil
+}
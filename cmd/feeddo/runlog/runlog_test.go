@@ -0,0 +1,118 @@
+package runlog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRuns() []Run {
+	start := time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)
+	return []Run{
+		{Feed: "http://a.org", Start: start, End: start.Add(time.Minute), Items: 10},
+		{Feed: "http://b.org", Start: start, End: start.Add(time.Second), Error: "Failed to get stream"},
+		{Feed: "http://a.org", Start: start.Add(time.Hour), End: start.Add(time.Hour + time.Minute), Items: 11},
+	}
+}
+
+func TestRuns(t *testing.T) {
+	runs := testRuns()
+	tests := []struct {
+		name     string
+		size     int
+		feed     string
+		limit    int
+		expected []Run
+	}{
+		{"all runs", 10, "", 0, []Run{runs[2], runs[1], runs[0]}},
+		{"oldest runs removed", 2, "", 0, []Run{runs[2], runs[1]}},
+		{"runs of feed", 10, "http://a.org", 0, []Run{runs[2], runs[0]}},
+		{"limited runs", 10, "", 1, []Run{runs[2]}},
+		{"unknown feed", 10, "http://c.org", 0, []Run{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := New(tt.size, "")
+			require.NoError(t, err)
+			for _, r := range runs {
+				require.NoError(t, l.Add(r))
+			}
+			assert.Equal(t, tt.expected, l.Runs(tt.feed, tt.limit))
+		})
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "runs.json")
+	runs := testRuns()
+	l, err := New(10, file)
+	require.NoError(t, err)
+	for _, r := range runs {
+		require.NoError(t, l.Add(r))
+	}
+	loaded, err := Load(file)
+	require.NoError(t, err)
+	assert.Equal(t, runs, loaded)
+	loaded, err = Load(filepath.Join(dir, "absent.json"))
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+	// history is loaded after restart, only the last runs are kept when size was decreased
+	l, err = New(2, file)
+	require.NoError(t, err)
+	assert.Equal(t, []Run{runs[2], runs[1]}, l.Runs("", 0))
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("{"), 0644))
+	_, err = New(2, file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unable to decode run log")
+}
+
+func TestServeHTTP(t *testing.T) {
+	runs := testRuns()
+	l, err := New(10, "")
+	require.NoError(t, err)
+	for _, r := range runs {
+		require.NoError(t, l.Add(r))
+	}
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		expected []Run
+	}{
+		{"all runs", "", http.StatusOK, []Run{runs[2], runs[1], runs[0]}},
+		{"runs of feed with limit", "?feed=http://a.org&limit=1", http.StatusOK, []Run{runs[2]}},
+		{"wrong limit", "?limit=abc", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/runs"+tt.query, nil))
+			require.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			var actual []Run
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &actual))
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestNewID(t *testing.T) {
+	id := NewID()
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+	assert.NotEqual(t, id, NewID())
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/kafka"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+// statuses of runs in summaries sent into topic of runs
+const (
+	runStatusSucceeded   = "succeeded"
+	runStatusFailed      = "failed"
+	runStatusNotModified = "not_modified"
+)
+
+// runDeliveryWait limits how long summary of run waits for delivery of its items, so run is not blocked by lost result
+const runDeliveryWait = 10 * time.Minute
+
+// runPublisher sends summaries and markers of runs, it is implemented by kafka producer
+type runPublisher interface {
+	Publish(topic string, key, value []byte, headers []kafka.Header) error
+	PublishPartition(topic string, partition int32, key, value []byte, headers []kafka.Header) error
+	Partitions(topic string) (int, error)
+}
+
+// deliveryCounts counts items of feed handed over to sinks and results of their delivery
+type deliveryCounts struct {
+	Sent       int `json:"sent"`
+	Delivered  int `json:"delivered"`
+	Failed     int `json:"failed"`
+	Tombstones int `json:"tombstones"`
+	// Pending items were handed over to sinks but their delivery was not reported yet
+	Pending int `json:"pending"`
+	// written counts messages delivered into partitions of topics, it is sent by end markers of run
+	written map[string]map[int32]int
+}
+
+// deliveryTracker tracks delivery of items of every feed, so summary of run is sent after all its items were delivered.
+// Nil tracker does not track anything. Tracker is safe for concurrent use.
+type deliveryTracker struct {
+	mu     sync.Mutex
+	counts map[string]*deliveryCounts
+	// changed is closed and replaced whenever delivery of some item is reported
+	changed chan struct{}
+}
+
+func newDeliveryTracker() *deliveryTracker {
+	return &deliveryTracker{counts: map[string]*deliveryCounts{}, changed: make(chan struct{})}
+}
+
+// feedCounts returns counts of feed, it should be called with lock held
+func (t *deliveryTracker) feedCounts(feed string) *deliveryCounts {
+	c, ok := t.counts[feed]
+	if !ok {
+		c = &deliveryCounts{}
+		t.counts[feed] = c
+	}
+	return c
+}
+
+// sent records item of feed which is handed over to sinks
+func (t *deliveryTracker) sent(feed string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.feedCounts(feed)
+	c.Sent++
+	c.Pending++
+}
+
+// unsent records item of feed which was not handed over to sinks after all, e.g. because run was cancelled
+func (t *deliveryTracker) unsent(feed string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.feedCounts(feed)
+	c.Sent--
+	c.Pending--
+}
+
+// settle records result of delivery reported by primary sink
+func (t *deliveryTracker) settle(res sink.Result) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.feedCounts(res.ItemContext)
+	c.Pending--
+	switch {
+	case res.Err != nil:
+		c.Failed++
+	case res.Tombstone:
+		c.Tombstones++
+	default:
+		c.Delivered++
+	}
+	// message could be written into some topics even when delivery of item failed
+	for _, tr := range res.Topics {
+		if tr.Err != nil || tr.Partition < 0 {
+			continue
+		}
+		if c.written == nil {
+			c.written = map[string]map[int32]int{}
+		}
+		if c.written[tr.Topic] == nil {
+			c.written[tr.Topic] = map[int32]int{}
+		}
+		c.written[tr.Topic][tr.Partition]++
+	}
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// wait waits till all items of feed are delivered, ctx is done or timeout expires. It returns counts of feed and resets them,
+// only items which are still pending are kept, so they are awaited by the next run of feed.
+func (t *deliveryTracker) wait(ctx context.Context, feed string, timeout time.Duration) deliveryCounts {
+	if t == nil {
+		return deliveryCounts{}
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.feedCounts(feed).Pending > 0 {
+		changed := t.changed
+		t.mu.Unlock()
+		expired := false
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			expired = true
+		case <-timer.C:
+			expired = true
+		}
+		t.mu.Lock()
+		if expired {
+			break
+		}
+	}
+	c := *t.feedCounts(feed)
+	t.counts[feed] = &deliveryCounts{Pending: c.Pending}
+	return c
+}
+
+// runMessage is summary of finished run of feed sent into topic of runs,
+// so consumers could start processing of feed when its run is complete
+type runMessage struct {
+	Feed    string    `json:"feed"`
+	RunID   string    `json:"runId"`
+	Status  string    `json:"status"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Seconds float64   `json:"seconds"`
+	// Items is number of items parsed from feed
+	Items int `json:"items"`
+	// Skipped items were filtered out or rejected because of invalid text
+	Skipped int `json:"skipped"`
+	// Malformed items could not be parsed
+	Malformed int `json:"malformed"`
+	deliveryCounts
+	// Error is empty for successful run
+	Error string `json:"error,omitempty"`
+	// ContentHash is hex encoded sha256 of downloaded feed, it is set only for successful run
+	ContentHash string            `json:"contentHash,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// publishRun sends summary of run into topic of runs keyed by feed, so summaries of feed keep their order
+func publishRun(p runPublisher, topic string, m runMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("Unable to encode summary of run because of %w", err)
+	}
+	headers := []kafka.Header{{Key: headerRunID, Value: []byte(m.RunID)}}
+	if err := p.Publish(topic, []byte(m.Feed), data, headers); err != nil {
+		return fmt.Errorf("Unable to send summary of run into topic '%s' because of %w", topic, err)
+	}
+	return nil
+}
+
+// hashingReadCloser computes hash of feed while it is read
+type hashingReadCloser struct {
+	io.ReadCloser
+	h hash.Hash
+}
+
+func newHashingReadCloser(rc io.ReadCloser) *hashingReadCloser {
+	return &hashingReadCloser{ReadCloser: rc, h: sha256.New()}
+}
+
+func (hr *hashingReadCloser) Read(p []byte) (int, error) {
+	n, err := hr.ReadCloser.Read(p)
+	hr.h.Write(p[:n])
+	return n, err
+}
+
+// sum reads rest of feed which parser did not need (e.g. whitespace after root element) and returns hex encoded hash of whole feed
+func (hr *hashingReadCloser) sum() string {
+	// feed was parsed already, so content which could not be read does not matter
+	_, _ = io.Copy(ioutil.Discard, hr)
+	return hex.EncodeToString(hr.h.Sum(nil))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+	"github.com/grubastik/feeddo/cmd/feeddo/quality"
+	"github.com/grubastik/feeddo/internal/pkg/kafka"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publisherMock records published summaries and markers, topics have partitions set by count
+type publisherMock struct {
+	mu       sync.Mutex
+	err      error
+	count    map[string]int
+	topics   []string
+	keys     []string
+	messages []runMessage
+	headers  [][]kafka.Header
+	markers  []runMarker
+}
+
+func (p *publisherMock) Publish(topic string, key, value []byte, headers []kafka.Header) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	var m runMessage
+	if err := json.Unmarshal(value, &m); err != nil {
+		return err
+	}
+	p.topics = append(p.topics, topic)
+	p.keys = append(p.keys, string(key))
+	p.messages = append(p.messages, m)
+	p.headers = append(p.headers, headers)
+	return nil
+}
+
+func (p *publisherMock) PublishPartition(topic string, partition int32, key, value []byte, headers []kafka.Header) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	var m runMarker
+	if err := json.Unmarshal(value, &m); err != nil {
+		return err
+	}
+	if m.Topic != topic || m.Partition != partition {
+		return fmt.Errorf("Marker of %s/%d was sent into %s/%d", m.Topic, m.Partition, topic, partition)
+	}
+	p.keys = append(p.keys, string(key))
+	p.headers = append(p.headers, headers)
+	p.markers = append(p.markers, m)
+	return nil
+}
+
+func (p *publisherMock) Partitions(topic string) (int, error) {
+	n, ok := p.count[topic]
+	if !ok {
+		return 0, fmt.Errorf("Topic %s does not exist", topic)
+	}
+	return n, nil
+}
+
+func TestDeliveryTracker(t *testing.T) {
+	tr := newDeliveryTracker()
+	for i := 0; i < 4; i++ {
+		tr.sent("shop")
+	}
+	tr.unsent("shop")
+	tr.sent("other")
+	go func() {
+		tr.settle(sink.Result{ItemContext: "shop", ItemID: "1"})
+		tr.settle(sink.Result{ItemContext: "shop", ItemID: "2", Err: errors.New("test error")})
+		tr.settle(sink.Result{ItemContext: "shop", ItemID: "3", Tombstone: true})
+	}()
+	assert.Equal(t, deliveryCounts{Sent: 3, Delivered: 1, Failed: 1, Tombstones: 1}, tr.wait(context.Background(), "shop", time.Minute))
+	// counts are reset for the next run
+	assert.Equal(t, deliveryCounts{}, tr.wait(context.Background(), "shop", time.Minute))
+
+	// pending items are kept for the next run when waiting expires
+	assert.Equal(t, deliveryCounts{Sent: 1, Pending: 1}, tr.wait(context.Background(), "other", time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, deliveryCounts{Pending: 1}, tr.wait(ctx, "other", time.Minute))
+
+	// nil tracker does not track anything
+	var nilTracker *deliveryTracker
+	nilTracker.sent("shop")
+	nilTracker.settle(sink.Result{ItemContext: "shop"})
+	assert.Equal(t, deliveryCounts{}, nilTracker.wait(context.Background(), "shop", time.Minute))
+}
+
+func TestPublishRun(t *testing.T) {
+	p := &publisherMock{}
+	m := runMessage{Feed: "shop", RunID: "run", Status: runStatusSucceeded, Items: 2, deliveryCounts: deliveryCounts{Sent: 2, Delivered: 2}}
+	require.NoError(t, publishRun(p, "feeds_runs", m))
+	assert.Equal(t, []string{"feeds_runs"}, p.topics)
+	assert.Equal(t, []string{"shop"}, p.keys)
+	assert.Equal(t, []runMessage{m}, p.messages)
+	assert.Equal(t, [][]kafka.Header{{{Key: headerRunID, Value: []byte("run")}}}, p.headers)
+
+	p.err = errors.New("test error")
+	err := publishRun(p, "feeds_runs", m)
+	require.Error(t, err)
+	assert.Equal(t, "Unable to send summary of run into topic 'feeds_runs' because of test error", err.Error())
+}
+
+func TestHashingReadCloser(t *testing.T) {
+	hr := newHashingReadCloser(ioutil.NopCloser(strings.NewReader("<SHOP></SHOP>\n")))
+	buf := make([]byte, 6)
+	_, err := hr.Read(buf)
+	require.NoError(t, err)
+	// rest of feed is hashed too
+	h := sha256.Sum256([]byte("<SHOP></SHOP>\n"))
+	assert.Equal(t, hex.EncodeToString(h[:]), hr.sum())
+}
+
+func TestRunOnceRunTopic(t *testing.T) {
+	URL, _ := url.Parse("file://testdata/invalid_text.xml")
+	missing, _ := url.Parse("file://testdata/missing.xml")
+	scrubber, err := quality.NewScrubber(quality.ScrubReject)
+	require.NoError(t, err)
+	p := &publisherMock{}
+	deliveries := newDeliveryTracker()
+	var m AdderCustom
+	mc := make(metrics.Container)
+	mc[URL.String()] = map[string]metrics.Adder{"feed": &m}
+	mc[missing.String()] = map[string]metrics.Adder{"feed": &m}
+	chanItem := make(chan sink.Item)
+	// items are delivered by sink while run waits for them
+	go func() {
+		for item := range chanItem {
+			deliveries.settle(sink.Result{ItemContext: item.GetContext(), ItemID: item.GetID()})
+		}
+	}()
+	defer close(chanItem)
+	opts := options{feeds: []*url.URL{URL, missing}, router: testRouter(t), scrubber: scrubber, runTopic: "feeds_runs", runPublisher: p, deliveries: deliveries}
+	errs := runOnce(context.Background(), opts, chanItem, newMetricsObserver(mc))
+	require.Equal(t, 1, len(errs))
+
+	require.Equal(t, 2, len(p.messages))
+	byFeed := map[string]runMessage{}
+	for i, msg := range p.messages {
+		assert.Equal(t, "feeds_runs", p.topics[i])
+		assert.Equal(t, msg.Feed, p.keys[i])
+		assert.NotEmpty(t, msg.RunID)
+		assert.False(t, msg.End.Before(msg.Start))
+		byFeed[msg.Feed] = msg
+	}
+	ok := byFeed[URL.String()]
+	assert.Equal(t, runStatusSucceeded, ok.Status)
+	assert.Equal(t, 3, ok.Items)
+	assert.Equal(t, 2, ok.Skipped)
+	assert.Equal(t, deliveryCounts{Sent: 1, Delivered: 1}, ok.deliveryCounts)
+	data, err := ioutil.ReadFile("testdata/invalid_text.xml")
+	require.NoError(t, err)
+	h := sha256.Sum256(data)
+	assert.Equal(t, hex.EncodeToString(h[:]), ok.ContentHash)
+	assert.Empty(t, ok.Error)
+
+	failed := byFeed[missing.String()]
+	assert.Equal(t, runStatusFailed, failed.Status)
+	assert.NotEmpty(t, failed.Error)
+	assert.Empty(t, failed.ContentHash)
+}
@@ -0,0 +1,78 @@
+package sdnotify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Ready tells service manager that startup is finished
+	Ready = "READY=1"
+	// Stopping tells service manager that app is shutting down
+	Stopping = "STOPPING=1"
+	// Watchdog keeps app alive when watchdog of service manager is enabled
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state to service manager by socket set in NOTIFY_SOCKET, see sd_notify(3).
+// False is returned when app is not supervised by service manager.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// abstract socket names start with '@'
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("Unable to connect to notify socket because of %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("Unable to send '%s' to notify socket because of %w", state, err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns interval in which service manager expects keep-alive notifications,
+// zero is returned when watchdog is not enabled for this process, see sd_watchdog_enabled(3).
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("Value '%s' of WATCHDOG_USEC is not valid", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
+
+// RunWatchdog sends keep-alive notifications twice per interval till ctx is done.
+// Failed notifications are returned into errChan, they do not stop watchdog.
+func RunWatchdog(ctx context.Context, interval time.Duration, errChan chan<- error) {
+	t := time.NewTicker(interval / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := Notify(Watchdog); err != nil {
+				select {
+				case errChan <- err:
+				case <-ctx.Done():
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package sdnotify
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listen creates notify socket and points NOTIFY_SOCKET to it
+func listen(t *testing.T) (*net.UnixConn, func()) {
+	dir, err := ioutil.TempDir("", "sdnotify")
+	require.NoError(t, err)
+	socket := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	require.NoError(t, os.Setenv("NOTIFY_SOCKET", socket))
+	return conn, func() {
+		os.Unsetenv("NOTIFY_SOCKET")
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func read(t *testing.T, conn *net.UnixConn) string {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	sent, err := Notify(Ready)
+	require.NoError(t, err)
+	assert.False(t, sent)
+
+	conn, cleanup := listen(t)
+	defer cleanup()
+	sent, err = Notify(Ready)
+	require.NoError(t, err)
+	assert.True(t, sent)
+	assert.Equal(t, Ready, read(t, conn))
+
+	require.NoError(t, os.Setenv("NOTIFY_SOCKET", "/nonexistent/notify.sock"))
+	_, err = Notify(Ready)
+	require.Error(t, err)
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+	tests := []struct {
+		name     string
+		usec     string
+		pid      string
+		interval time.Duration
+		err      string
+	}{
+		{"not enabled", "", "", 0, ""},
+		{"enabled", "30000000", "", 30 * time.Second, ""},
+		{"enabled for process", "1000", strconv.Itoa(os.Getpid()), time.Millisecond, ""},
+		{"enabled for other process", "1000", "1", 0, ""},
+		{"wrong value", "soon", "", 0, "Value 'soon' of WATCHDOG_USEC is not valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.Setenv("WATCHDOG_USEC", tt.usec))
+			require.NoError(t, os.Setenv("WATCHDOG_PID", tt.pid))
+			interval, err := WatchdogInterval()
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.interval, interval)
+		})
+	}
+}
+
+func TestRunWatchdog(t *testing.T) {
+	conn, cleanup := listen(t)
+	defer cleanup()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunWatchdog(ctx, 20*time.Millisecond, make(chan error, 10))
+		close(done)
+	}()
+	assert.Equal(t, Watchdog, read(t, conn))
+	assert.Equal(t, Watchdog, read(t, conn))
+	cancel()
+	<-done
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/heureka"
+	"github.com/jessevdk/go-flags"
+)
+
+const (
+	// splitCommand is the first argument which switches app into extraction of parts of feed into separate files
+	splitCommand = "split"
+	// gzipSuffix is appended to names of compressed files
+	gzipSuffix = ".gz"
+)
+
+// splitOptions define which items are extracted from feed
+type splitOptions struct {
+	file   *url.URL
+	count  int
+	offset int
+	// chunk is number of items per file when whole feed is split
+	chunk int
+	gzip  bool
+	// raw items are copied from source instead of being decoded and encoded again
+	raw bool
+}
+
+// parseSplitArgs parses flags of split command
+func parseSplitArgs(args []string) (splitOptions, error) {
+	var opts struct {
+		File   string `short:"f" long:"file" description:"Original file" required:"true"`
+		Count  int    `short:"c" long:"count" description:"Number of items to extract"`
+		Offset int    `short:"o" long:"offset" description:"Number of items to skip"`
+		Chunk  int    `long:"chunk" description:"Split whole feed into sequentially numbered files of given number of items instead of extracting single part"`
+		Gzip   bool   `long:"gzip" description:"Compress written files by gzip, suffix '.gz' is added to their names"`
+		Raw    bool   `long:"raw" description:"Copy items and root element from original file verbatim, so unknown elements and attributes are preserved"`
+	}
+	parser := flags.NewParser(&opts, flags.PassDoubleDash|flags.IgnoreUnknown)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		return splitOptions{}, fmt.Errorf("Unable to parse flags: %w", err)
+	}
+	if opts.File == "" {
+		return splitOptions{}, fmt.Errorf("File is required")
+	}
+	file, err := url.Parse(strings.TrimSpace(opts.File))
+	if err != nil {
+		return splitOptions{}, fmt.Errorf("Unable to parse file '%s' because of %w", opts.File, err)
+	}
+	if opts.Chunk < 0 {
+		return splitOptions{}, fmt.Errorf("chunk argument should be greater than zero")
+	}
+	if opts.Chunk > 0 && (opts.Count != 0 || opts.Offset != 0) {
+		return splitOptions{}, fmt.Errorf("chunk argument could not be used together with count and offset")
+	}
+	if opts.Chunk == 0 && opts.Count <= 0 {
+		return splitOptions{}, fmt.Errorf("count argument is required and should be greater than zero")
+	}
+	if opts.Offset < 0 {
+		return splitOptions{}, fmt.Errorf("offset argument is required and should be greater or equal than zero")
+	}
+	return splitOptions{file: file, count: opts.Count, offset: opts.Offset, chunk: opts.Chunk, gzip: opts.Gzip, raw: opts.Raw}, nil
+}
+
+// splitRun extracts items of feed into file named by original file, offset and count
+// or splits whole feed into files numbered from 1 when chunk is set
+func splitRun(args []string) error {
+	opts, err := parseSplitArgs(args)
+	if err != nil {
+		return &apperror.ConfigError{Err: err}
+	}
+	path := opts.file.Hostname() + opts.file.EscapedPath()
+	readCloser, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read file `%v` because of %w", opts.file, err)
+	}
+	defer readCloser.Close()
+	fs := newFeedScanner(readCloser, opts.raw)
+	if opts.chunk > 0 {
+		files, err := chunkFeed(fs, path, opts.chunk, opts.gzip)
+		if err != nil {
+			return err
+		}
+		log.Printf("Feed `%v` was split into %d files", opts.file, files)
+		return nil
+	}
+	name := path + strconv.Itoa(opts.offset) + "-" + strconv.Itoa(opts.count) + ".xml"
+	if opts.gzip {
+		name += gzipSuffix
+	}
+	out, err := createSplitFile(name, opts.gzip, fs)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.scan(opts.offset, opts.count, out.write); err != nil {
+		out.close()
+		return err
+	}
+	return out.close()
+}
+
+// splitFeed writes count items of feed after skipping offset items. Items are written as soon as they are read,
+// so memory does not depend on number of items. Number of written items is returned.
+func splitFeed(fs *feedScanner, w io.Writer, offset, count int) (int, error) {
+	sw := newShopWriter(w, fs)
+	written, err := fs.scan(offset, count, sw.write)
+	if err != nil {
+		return written, err
+	}
+	return written, sw.close()
+}
+
+// chunkFeed splits whole feed into files of size items named '<file without extension>-<number>.xml'.
+// Number of written files is returned.
+func chunkFeed(fs *feedScanner, path string, size int, compress bool) (int, error) {
+	var out *splitFile
+	files := 0
+	inFile := 0
+	_, err := fs.scan(0, 0, func(item splitItem) error {
+		if out == nil {
+			files++
+			var err error
+			if out, err = createSplitFile(chunkName(path, files, compress), compress, fs); err != nil {
+				return err
+			}
+		}
+		if err := out.write(item); err != nil {
+			return err
+		}
+		if inFile++; inFile == size {
+			inFile = 0
+			err := out.close()
+			out = nil
+			return err
+		}
+		return nil
+	})
+	if out != nil {
+		if errC := out.close(); err == nil {
+			err = errC
+		}
+	}
+	return files, err
+}
+
+// chunkName returns name of numbered file of chunk
+func chunkName(path string, number int, compress bool) string {
+	name := strings.TrimSuffix(path, filepath.Ext(path)) + "-" + strconv.Itoa(number) + ".xml"
+	if compress {
+		name += gzipSuffix
+	}
+	return name
+}
+
+// splitItem is decoded item or source of item in raw mode
+type splitItem struct {
+	item heureka.Item
+	raw  []byte
+}
+
+// feedScanner reads SHOPITEM elements of feed
+type feedScanner struct {
+	d *xml.Decoder
+	// source of feed is recorded in raw mode
+	source *recordingReader
+	// prolog is source of feed till the end of start of root element, it is kept in raw mode
+	prolog []byte
+	rooted bool
+}
+
+// newFeedScanner creates scanner of feed, in raw mode items are not decoded and their source is kept instead
+func newFeedScanner(r io.Reader, raw bool) *feedScanner {
+	fs := &feedScanner{}
+	if raw {
+		fs.source = &recordingReader{r: r}
+		r = fs.source
+	}
+	fs.d = xml.NewDecoder(r)
+	return fs
+}
+
+// scan passes items of feed to fn after skipping offset items till count items are passed, zero count means all items.
+// Number of passed items is returned.
+func (fs *feedScanner) scan(offset, count int, fn func(splitItem) error) (int, error) {
+	passed := 0
+	counter := 0
+	for count == 0 || counter < offset+count {
+		start := fs.d.InputOffset()
+		token, err := fs.d.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return passed, fmt.Errorf("Failed to read node element: %w", err)
+		}
+		startElem, ok := token.(xml.StartElement)
+		if ok && !fs.rooted {
+			fs.rooted = true
+			if fs.source != nil {
+				fs.prolog = append([]byte{}, fs.source.bytes(0, fs.d.InputOffset())...)
+				fs.source.discard(fs.d.InputOffset())
+			}
+			continue
+		}
+		if !ok || startElem.Name.Local != "SHOPITEM" {
+			fs.discard()
+			continue
+		}
+		counter++
+		if counter <= offset || fs.source != nil {
+			// skipped and raw items are not decoded
+			if err := fs.d.Skip(); err != nil {
+				return passed, fmt.Errorf("Failed to skip xml node: %w", err)
+			}
+			if counter <= offset {
+				fs.discard()
+				continue
+			}
+			err := fn(splitItem{raw: fs.source.bytes(start, fs.d.InputOffset())})
+			fs.discard()
+			if err != nil {
+				return passed, err
+			}
+			passed++
+			continue
+		}
+		item := heureka.Item{}
+		if err := fs.d.DecodeElement(&item, &startElem); err != nil {
+			return passed, fmt.Errorf("Failed to unmarshal xml node: %w", err)
+		}
+		if err := fn(splitItem{item: item}); err != nil {
+			return passed, err
+		}
+		passed++
+	}
+	return passed, nil
+}
+
+// discard drops recorded source which was already read by decoder
+func (fs *feedScanner) discard() {
+	if fs.source != nil && fs.rooted {
+		fs.source.discard(fs.d.InputOffset())
+	}
+}
+
+// root returns start and end of root element, source of feed is used in raw mode
+func (fs *feedScanner) root() ([]byte, []byte) {
+	if len(fs.prolog) == 0 {
+		return []byte("<SHOP>"), []byte("</SHOP>")
+	}
+	tag := fs.prolog[bytes.LastIndexByte(fs.prolog, '<')+1:]
+	if i := bytes.IndexAny(tag, " \t\r\n/>"); i >= 0 {
+		tag = tag[:i]
+	}
+	return fs.prolog, []byte("</" + string(tag) + ">")
+}
+
+// recordingReader keeps bytes which were read from source till they are discarded
+type recordingReader struct {
+	r   io.Reader
+	buf []byte
+	// offset of the first byte of buf in source
+	base int64
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	return n, err
+}
+
+// bytes returns recorded source between offsets
+func (rr *recordingReader) bytes(from, to int64) []byte {
+	return rr.buf[from-rr.base : to-rr.base]
+}
+
+// discard drops recorded source before offset
+func (rr *recordingReader) discard(offset int64) {
+	rr.buf = rr.buf[offset-rr.base:]
+	rr.base = offset
+}
+
+// shopWriter writes items inside of root element. Root element is started by the first item or on close,
+// so source of root element is known in raw mode.
+type shopWriter struct {
+	w       io.Writer
+	enc     *xml.Encoder
+	fs      *feedScanner
+	started bool
+}
+
+func newShopWriter(w io.Writer, fs *feedScanner) *shopWriter {
+	return &shopWriter{w: w, enc: xml.NewEncoder(w), fs: fs}
+}
+
+// start starts root element unless it is started already
+func (sw *shopWriter) start() error {
+	if sw.started {
+		return nil
+	}
+	sw.started = true
+	var err error
+	if sw.fs.source != nil {
+		start, _ := sw.fs.root()
+		err = writeLine(sw.w, start)
+	} else {
+		err = sw.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: "SHOP"}})
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write result because of %w", err)
+	}
+	return nil
+}
+
+func (sw *shopWriter) write(item splitItem) error {
+	if err := sw.start(); err != nil {
+		return err
+	}
+	if sw.fs.source != nil {
+		if err := writeLine(sw.w, item.raw); err != nil {
+			return fmt.Errorf("Unable to write item because of %w", err)
+		}
+		return nil
+	}
+	if err := sw.enc.Encode(item.item); err != nil {
+		return fmt.Errorf("Unable to write item %s because of %w", item.item.ID, err)
+	}
+	return nil
+}
+
+// close ends root element and flushes encoder
+func (sw *shopWriter) close() error {
+	if err := sw.start(); err != nil {
+		return err
+	}
+	var err error
+	if sw.fs.source != nil {
+		_, end := sw.fs.root()
+		err = writeLine(sw.w, end)
+	} else if err = sw.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "SHOP"}}); err == nil {
+		err = sw.enc.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write result because of %w", err)
+	}
+	return nil
+}
+
+// writeLine writes source followed by new line, source is not modified as it could be shared with recorded feed
+func writeLine(w io.Writer, source []byte) error {
+	if _, err := w.Write(source); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
+
+// splitFile is file written by split command, optionally compressed by gzip
+type splitFile struct {
+	*shopWriter
+	name string
+	file *os.File
+	buf  *bufio.Writer
+	gz   *gzip.Writer
+}
+
+// createSplitFile creates file where items of feed are written
+func createSplitFile(name string, compress bool, fs *feedScanner) (*splitFile, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create file `%v` because of %w", name, err)
+	}
+	sf := &splitFile{name: name, file: f, buf: bufio.NewWriter(f)}
+	var w io.Writer = sf.buf
+	if compress {
+		sf.gz = gzip.NewWriter(sf.buf)
+		w = sf.gz
+	}
+	sf.shopWriter = newShopWriter(w, fs)
+	return sf, nil
+}
+
+// close ends root element and closes file
+func (sf *splitFile) close() error {
+	err := sf.shopWriter.close()
+	if sf.gz != nil && err == nil {
+		err = sf.gz.Close()
+	}
+	if err == nil {
+		err = sf.buf.Flush()
+	}
+	if errC := sf.file.Close(); err == nil {
+		err = errC
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write file `%v` because of %w", sf.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/grubastik/feeddo/internal/pkg/heureka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const splitItems = `<?xml version="1.0" encoding="UTF-8"?>
+<SHOP>
+	<SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM>
+	<SHOPITEM><ITEM_ID>2</ITEM_ID></SHOPITEM>
+	<SHOPITEM><ITEM_ID>3</ITEM_ID></SHOPITEM>
+</SHOP>`
+
+func TestParseSplitArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected splitOptions
+		err      string
+	}{
+		{"window", []string{"-f", "file://testdata/feed.xml", "-c", "2", "-o", "1"}, splitOptions{count: 2, offset: 1}, ""},
+		{"no file", []string{"-c", "2"}, splitOptions{}, "Unable to parse flags: the required flag `-f, --file' was not specified"},
+		{"chunks", []string{"-f", "file://testdata/feed.xml", "--chunk", "100", "--gzip"}, splitOptions{chunk: 100, gzip: true}, ""},
+		{"no count", []string{"-f", "feed.xml", "-c", "0"}, splitOptions{}, "count argument is required and should be greater than zero"},
+		{"chunk with count", []string{"-f", "feed.xml", "-c", "1", "--chunk", "2"}, splitOptions{}, "chunk argument could not be used together with count and offset"},
+		{"negative chunk", []string{"-f", "feed.xml", "--chunk", "-2"}, splitOptions{}, "chunk argument should be greater than zero"},
+		{"negative offset", []string{"-f", "feed.xml", "-c", "1", "-o", "-1"}, splitOptions{}, "offset argument is required and should be greater or equal than zero"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseSplitArgs(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "file://testdata/feed.xml", opts.file.String())
+			opts.file = nil
+			assert.Equal(t, tt.expected, opts)
+		})
+	}
+}
+
+func TestSplitFeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int
+		count  int
+		ids    []string
+	}{
+		{"first", 0, 1, []string{"1"}},
+		{"window", 1, 1, []string{"2"}},
+		{"beyond end", 2, 5, []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			written, err := splitFeed(newFeedScanner(strings.NewReader(splitItems), false), &out, tt.offset, tt.count)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.ids), written)
+			shop := heureka.Shop{}
+			require.NoError(t, xml.Unmarshal(out.Bytes(), &shop))
+			ids := []string{}
+			for _, item := range shop.ShopItem {
+				ids = append(ids, string(item.ID))
+			}
+			assert.Equal(t, tt.ids, ids)
+		})
+	}
+	_, err := splitFeed(newFeedScanner(strings.NewReader("<SHOP><SHOPITEM><ITEM_ID>1</SHOPITEM>"), false), ioutil.Discard, 0, 1)
+	assert.Error(t, err)
+}
+
+func TestSplitFeedRaw(t *testing.T) {
+	source := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- supplier feed -->
+<SHOP xmlns:g="http://base.google.com/ns/1.0" version="2">
+	<SHOPITEM><ITEM_ID>1</ITEM_ID><g:LABEL>new</g:LABEL></SHOPITEM>
+	<SHOPITEM status="active"><ITEM_ID>2</ITEM_ID><UNKNOWN a="b"/></SHOPITEM>
+	<SHOPITEM><ITEM_ID>3</ITEM_ID></SHOPITEM>
+</SHOP>`
+	tests := []struct {
+		name     string
+		offset   int
+		count    int
+		expected string
+	}{
+		{"window", 1, 2, `<?xml version="1.0" encoding="UTF-8"?>
+<!-- supplier feed -->
+<SHOP xmlns:g="http://base.google.com/ns/1.0" version="2">
+<SHOPITEM status="active"><ITEM_ID>2</ITEM_ID><UNKNOWN a="b"/></SHOPITEM>
+<SHOPITEM><ITEM_ID>3</ITEM_ID></SHOPITEM>
+</SHOP>
+`},
+		{"first", 0, 1, `<?xml version="1.0" encoding="UTF-8"?>
+<!-- supplier feed -->
+<SHOP xmlns:g="http://base.google.com/ns/1.0" version="2">
+<SHOPITEM><ITEM_ID>1</ITEM_ID><g:LABEL>new</g:LABEL></SHOPITEM>
+</SHOP>
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			// source is read in small parts, so recorded source is discarded and appended many times
+			written, err := splitFeed(newFeedScanner(iotest.OneByteReader(strings.NewReader(source)), true), &out, tt.offset, tt.count)
+			require.NoError(t, err)
+			assert.Equal(t, tt.count, written)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
+
+func TestSplitRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "split")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	feed := filepath.Join(dir, "feed.xml")
+	require.NoError(t, ioutil.WriteFile(feed, []byte(splitItems), 0644))
+	require.NoError(t, splitRun([]string{"-f", "file://" + feed, "-c", "2", "-o", "1"}))
+	data, err := ioutil.ReadFile(feed + "1-2.xml")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<ITEM_ID>2</ITEM_ID>")
+	assert.Contains(t, string(data), "<ITEM_ID>3</ITEM_ID>")
+	assert.NotContains(t, string(data), "<ITEM_ID>1</ITEM_ID>")
+}
+
+func TestChunkFeed(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("gzip %v", compress), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "split")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "feed.xml")
+			suffix := ""
+			if compress {
+				suffix = gzipSuffix
+			}
+			files, err := chunkFeed(newFeedScanner(strings.NewReader(splitItems), false), path, 2, compress)
+			require.NoError(t, err)
+			assert.Equal(t, 2, files)
+			for number, expected := range map[int][]string{1: {"1", "2"}, 2: {"3"}} {
+				f, err := os.Open(filepath.Join(dir, "feed-"+strconv.Itoa(number)+".xml"+suffix))
+				require.NoError(t, err)
+				defer f.Close()
+				var r io.Reader = f
+				if compress {
+					r, err = gzip.NewReader(f)
+					require.NoError(t, err)
+				}
+				shop := heureka.Shop{}
+				require.NoError(t, xml.NewDecoder(r).Decode(&shop))
+				ids := []string{}
+				for _, item := range shop.ShopItem {
+					ids = append(ids, string(item.ID))
+				}
+				assert.Equal(t, expected, ids)
+			}
+			_, err = os.Stat(filepath.Join(dir, "feed-3.xml"+suffix))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/state"
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/jessevdk/go-flags"
+)
+
+// stateCommand is the first argument which switches app into inspection or reset of state of feeds
+const stateCommand = "state"
+
+// stateOptions define which state is inspected and whether it is reset
+type stateOptions struct {
+	dir string
+	// feed is url or alias of feed, all feeds are inspected when it is empty
+	feed   string
+	items  bool
+	reset  bool
+	format string
+}
+
+// stateItem is item kept in state of feed
+type stateItem struct {
+	ID       string    `json:"id"`
+	Topics   []string  `json:"topics"`
+	Hash     string    `json:"hash,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// stateReport summarizes state of feed
+type stateReport struct {
+	Feed        string    `json:"feed"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	Items       int       `json:"items"`
+	// Absent are items which were not seen by the last successful run, they are kept until dedup TTL expires
+	Absent int `json:"absent"`
+	// Interrupted is cursor of run which did not finish
+	Interrupted *state.Cursor `json:"interrupted,omitempty"`
+	// ItemList is listed only when it is requested
+	ItemList []stateItem `json:"itemList,omitempty"`
+}
+
+// parseStateArgs parses flags of state command
+func parseStateArgs(args []string) (stateOptions, error) {
+	var opts struct {
+		StateDir string `long:"stateDir" description:"Directory where state of feeds is stored" required:"true"`
+		Feed     string `short:"f" long:"feedUrl" description:"Url or alias of feed which state is inspected or reset, all feeds are inspected when it is not set"`
+		Items    bool   `long:"items" description:"List items kept in state"`
+		Reset    bool   `long:"reset" description:"Remove state of feed, so all its items are sent and no tombstones are sent during its next run"`
+		Format   string `long:"format" description:"Format of report: 'text' or 'json'" default:"text"`
+	}
+	parser := flags.NewParser(&opts, flags.PassDoubleDash|flags.IgnoreUnknown)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		return stateOptions{}, fmt.Errorf("Unable to parse flags: %w", err)
+	}
+	if opts.Format != reportFormatText && opts.Format != reportFormatJSON {
+		return stateOptions{}, fmt.Errorf("Unknown format of report '%s', supported formats are '%s' and '%s'", opts.Format, reportFormatText, reportFormatJSON)
+	}
+	feed := strings.TrimSpace(opts.Feed)
+	if opts.Reset && feed == "" {
+		return stateOptions{}, fmt.Errorf("Feed should be provided for reset of state")
+	}
+	return stateOptions{dir: opts.StateDir, feed: feed, items: opts.Items, reset: opts.Reset, format: opts.Format}, nil
+}
+
+// stateRun prints state of feeds into stdout or resets state of feed
+func stateRun(args []string) error {
+	opts, err := parseStateArgs(args)
+	if err != nil {
+		return &apperror.ConfigError{Err: err}
+	}
+	if _, err := os.Stat(opts.dir); err != nil {
+		return &apperror.ConfigError{Err: fmt.Errorf("Unable to open state directory '%s' because of %w", opts.dir, err)}
+	}
+	store, err := state.NewStore(opts.dir)
+	if err != nil {
+		return err
+	}
+	if opts.reset {
+		if err := store.Reset(opts.feed); err != nil {
+			return err
+		}
+		fmt.Printf("State of feed '%s' was reset\n", opts.feed)
+		return nil
+	}
+	feeds := []string{opts.feed}
+	if opts.feed == "" {
+		if feeds, err = store.Feeds(); err != nil {
+			return fmt.Errorf("Unable to list feeds because of %w", err)
+		}
+	}
+	reports := make([]stateReport, 0, len(feeds))
+	for _, feed := range feeds {
+		r, err := feedStateReport(store, feed, opts.items)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, r)
+	}
+	return writeStateReports(os.Stdout, reports, opts.format)
+}
+
+// feedStateReport summarizes state of feed, items are listed ordered by id when they are requested
+func feedStateReport(store *state.Store, feed string, items bool) (stateReport, error) {
+	c, err := store.LoadCheckpoint(feed)
+	if err != nil {
+		return stateReport{}, err
+	}
+	cursor, err := store.LoadCursor(feed)
+	if err != nil {
+		return stateReport{}, err
+	}
+	r := stateReport{Feed: feed, LastSuccess: c.LastSuccess, Items: len(c.Items), Interrupted: cursor}
+	for id, item := range c.Items {
+		// items saved by previous versions were seen by the last successful run
+		if item.LastSeen.IsZero() {
+			item.LastSeen = c.LastSuccess
+		}
+		if item.LastSeen.Before(c.LastSuccess) {
+			r.Absent++
+		}
+		if items {
+			r.ItemList = append(r.ItemList, stateItem{ID: id, Topics: item.Topics, Hash: item.Hash, LastSeen: item.LastSeen})
+		}
+	}
+	sort.Slice(r.ItemList, func(i, j int) bool { return r.ItemList[i].ID < r.ItemList[j].ID })
+	return r, nil
+}
+
+// writeStateReports writes reports in format
+func writeStateReports(w io.Writer, reports []stateReport, format string) error {
+	var err error
+	if format == reportFormatJSON {
+		err = json.NewEncoder(w).Encode(reports)
+	} else {
+		texts := make([]string, len(reports))
+		for i, r := range reports {
+			texts[i] = stateReportText(r)
+		}
+		_, err = io.WriteString(w, strings.Join(texts, "\n"))
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write state report because of %w", err)
+	}
+	return nil
+}
+
+// stateReportText formats report as human readable text
+func stateReportText(r stateReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "feed: %s\n", r.Feed)
+	if !r.LastSuccess.IsZero() {
+		fmt.Fprintf(&b, "last success: %s\n", r.LastSuccess.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "items: %d\nabsent items: %d\n", r.Items, r.Absent)
+	if r.Interrupted != nil {
+		fmt.Fprintf(&b, "interrupted run: started %s, %d items processed\n", r.Interrupted.Started.Format(time.RFC3339), r.Interrupted.Processed)
+	}
+	for _, item := range r.ItemList {
+		fmt.Fprintf(&b, "  %s %s %s", item.ID, item.LastSeen.Format(time.RFC3339), strings.Join(item.Topics, ","))
+		if item.Hash != "" {
+			fmt.Fprintf(&b, " %s", item.Hash)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,291 @@
+package state
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Item describes item which was sent during previous run of feed
+type Item struct {
+	Topics []string `json:"topics"`
+	// Hash of item content, empty when it is not known
+	Hash string `json:"hash,omitempty"`
+	// LastSeen is time when item was seen in feed for the last time, zero when it is not known
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Snapshot holds items of the feed sent during single run keyed by item id
+type Snapshot map[string]Item
+
+// Removed returns items which are present in snapshot but absent in current one
+func (s Snapshot) Removed(current Snapshot) Snapshot {
+	removed := Snapshot{}
+	for id, item := range s {
+		if _, ok := current[id]; !ok {
+			removed[id] = item
+		}
+	}
+	return removed
+}
+
+// Unchanged returns true when snapshot contains item with the same hash and topics
+func (s Snapshot) Unchanged(id string, item Item) bool {
+	prev, ok := s[id]
+	if !ok || prev.Hash == "" || prev.Hash != item.Hash || len(prev.Topics) != len(item.Topics) {
+		return false
+	}
+	for i, t := range prev.Topics {
+		if item.Topics[i] != t {
+			return false
+		}
+	}
+	return true
+}
+
+// Checkpoint is state of feed saved after its successful run
+type Checkpoint struct {
+	// ETag and LastModified identify version of feed downloaded by the last successful run, they are used for conditional download
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	// LastSuccess is time when the last successful run was finished
+	LastSuccess time.Time `json:"lastSuccess"`
+	// Items which were sent during the last successful run
+	Items Snapshot `json:"items"`
+}
+
+// Cursor is position of run of feed which is in progress. It is removed when run is finished successfully,
+// so cursor which is found on start belongs to run which was interrupted, e.g. by crash or cancellation.
+type Cursor struct {
+	Started time.Time `json:"started"`
+	// Processed is number of items processed by run
+	Processed int `json:"processed"`
+}
+
+// checkpointVersion identifies format of state file, files without version contain only snapshot of items
+const checkpointVersion = 2
+
+// checkpointFile is format of state file
+type checkpointFile struct {
+	Version int `json:"version"`
+	// Feed is url or alias of feed, files are named by its hash so it could not be recovered otherwise
+	Feed string `json:"feed,omitempty"`
+	Checkpoint
+}
+
+// Store keeps checkpoints of feeds between runs in local directory.
+// Each feed is stored in separate json file named by hash of feed url, cursor of run in progress is stored next to it.
+// Store is safe for concurrent use as long as each feed is handled by single goroutine.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+	// items which were not delivered per feed. Their hashes are cleared, so they are sent again during next run
+	forgotten map[string]map[string]struct{}
+}
+
+// NewStore creates store in provided directory. Directory is created if it does not exist.
+func NewStore(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create state directory '%s' because of %w", dir, err)
+	}
+	return &Store{dir: dir, forgotten: make(map[string]map[string]struct{})}, nil
+}
+
+// Load returns snapshot of the last successful run of feed. Empty snapshot is returned for unknown feed.
+func (s *Store) Load(feed string) (Snapshot, error) {
+	c, err := s.LoadCheckpoint(feed)
+	if err != nil {
+		return nil, err
+	}
+	return c.Items, nil
+}
+
+// LoadCheckpoint returns checkpoint of the last successful run of feed. Empty checkpoint is returned for unknown feed.
+// Hashes of items which were not delivered are cleared.
+func (s *Store) LoadCheckpoint(feed string) (Checkpoint, error) {
+	data, err := ioutil.ReadFile(s.path(feed))
+	if os.IsNotExist(err) {
+		return Checkpoint{Items: Snapshot{}}, nil
+	}
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("Unable to read state of feed '%s' because of %w", feed, err)
+	}
+	c, err := decodeCheckpoint(data)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("Unable to decode state of feed '%s' because of %w", feed, err)
+	}
+	s.clearForgotten(feed, c.Items, false)
+	return c, nil
+}
+
+// decodeCheckpoint decodes state file, files of previous format are decoded as checkpoint with items only
+func decodeCheckpoint(data []byte) (Checkpoint, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Checkpoint{}, err
+	}
+	var version int
+	if err := json.Unmarshal(fields["version"], &version); err != nil || version == 0 {
+		snapshot := Snapshot{}
+		err = json.Unmarshal(data, &snapshot)
+		return Checkpoint{Items: snapshot}, err
+	}
+	f := checkpointFile{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Checkpoint{}, err
+	}
+	if f.Items == nil {
+		f.Items = Snapshot{}
+	}
+	return f.Checkpoint, nil
+}
+
+// Forget marks item as not delivered, so it is not considered unchanged during next run.
+// Items which failed after snapshot was saved are kept in memory only and are lost on restart.
+func (s *Store) Forget(feed, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.forgotten[feed]; !ok {
+		s.forgotten[feed] = make(map[string]struct{})
+	}
+	s.forgotten[feed][id] = struct{}{}
+}
+
+// clearForgotten clears hashes of forgotten items of feed. When reset is set forgotten items are applied for the last time.
+func (s *Store) clearForgotten(feed string, snapshot Snapshot, reset bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.forgotten[feed] {
+		if item, ok := snapshot[id]; ok {
+			item.Hash = ""
+			snapshot[id] = item
+		}
+	}
+	if reset {
+		delete(s.forgotten, feed)
+	}
+}
+
+// Save replaces snapshot of feed. Hashes of items which were not delivered are not saved.
+func (s *Store) Save(feed string, snapshot Snapshot) error {
+	return s.SaveCheckpoint(feed, Checkpoint{LastSuccess: time.Now(), Items: snapshot})
+}
+
+// SaveCheckpoint replaces checkpoint of feed after its successful run and removes cursor of the run.
+// File is replaced atomically so crash does not leave broken state. Hashes of items which were not delivered are not saved.
+func (s *Store) SaveCheckpoint(feed string, c Checkpoint) error {
+	s.clearForgotten(feed, c.Items, true)
+	if err := s.write(s.path(feed), checkpointFile{Version: checkpointVersion, Feed: feed, Checkpoint: c}); err != nil {
+		return fmt.Errorf("Unable to save state of feed '%s' because of %w", feed, err)
+	}
+	if err := os.Remove(s.cursorPath(feed)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to remove cursor of feed '%s' because of %w", feed, err)
+	}
+	return nil
+}
+
+// Reset removes checkpoint and cursor of feed, so all its items are considered new during next run
+func (s *Store) Reset(feed string) error {
+	for _, path := range []string{s.path(feed), s.cursorPath(feed)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Unable to reset state of feed '%s' because of %w", feed, err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.forgotten, feed)
+	return nil
+}
+
+// Feeds returns sorted feeds which checkpoints are stored. Feeds saved by previous versions of app are not known
+// until their next successful run, as files are named by hash of feed.
+func (s *Store) Feeds() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	feeds := []string{}
+	for _, file := range files {
+		if strings.HasSuffix(file, ".cursor.json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read state file '%s' because of %w", file, err)
+		}
+		f := struct {
+			Feed string `json:"feed"`
+		}{}
+		// files of previous format are snapshots of items which could not be decoded into struct
+		if json.Unmarshal(data, &f) == nil && f.Feed != "" {
+			feeds = append(feeds, f.Feed)
+		}
+	}
+	sort.Strings(feeds)
+	return feeds, nil
+}
+
+// LoadCursor returns cursor of run of feed which was not finished, nil is returned when there is no such run
+func (s *Store) LoadCursor(feed string) (*Cursor, error) {
+	data, err := ioutil.ReadFile(s.cursorPath(feed))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read cursor of feed '%s' because of %w", feed, err)
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("Unable to decode cursor of feed '%s' because of %w", feed, err)
+	}
+	return c, nil
+}
+
+// SaveCursor saves position of run of feed in progress. It is small, so it could be saved often during run.
+func (s *Store) SaveCursor(feed string, c Cursor) error {
+	if err := s.write(s.cursorPath(feed), c); err != nil {
+		return fmt.Errorf("Unable to save cursor of feed '%s' because of %w", feed, err)
+	}
+	return nil
+}
+
+// write replaces file by json of v atomically
+func (s *Store) write(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.dir, "state-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if errC := tmp.Close(); err == nil {
+		err = errC
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (s *Store) path(feed string) string {
+	h := sha1.Sum([]byte(feed))
+	return filepath.Join(s.dir, hex.EncodeToString(h[:])+".json")
+}
+
+func (s *Store) cursorPath(feed string) string {
+	return strings.TrimSuffix(s.path(feed), ".json") + ".cursor.json"
+}
@@ -0,0 +1,198 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoved(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous Snapshot
+		current  Snapshot
+		expected Snapshot
+	}{
+		{"first run", Snapshot{}, Snapshot{"1": {Topics: []string{"a"}}}, Snapshot{}},
+		{"nothing removed", Snapshot{"1": {Topics: []string{"a"}}}, Snapshot{"1": {Topics: []string{"b"}}, "2": {}}, Snapshot{}},
+		{
+			"item removed",
+			Snapshot{"1": {Topics: []string{"a"}}, "2": {Topics: []string{"a", "b"}}},
+			Snapshot{"1": {Topics: []string{"a"}}},
+			Snapshot{"2": {Topics: []string{"a", "b"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.previous.Removed(tt.current))
+		})
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	s := Snapshot{
+		"1": {Topics: []string{"a", "b"}, Hash: "h1"},
+		"2": {Topics: []string{"a"}},
+	}
+	tests := []struct {
+		name     string
+		id       string
+		item     Item
+		expected bool
+	}{
+		{"unknown item", "3", Item{Topics: []string{"a"}, Hash: "h3"}, false},
+		{"unchanged", "1", Item{Topics: []string{"a", "b"}, Hash: "h1"}, true},
+		{"changed content", "1", Item{Topics: []string{"a", "b"}, Hash: "h2"}, false},
+		{"changed topics", "1", Item{Topics: []string{"a", "c"}, Hash: "h1"}, false},
+		{"less topics", "1", Item{Topics: []string{"a"}, Hash: "h1"}, false},
+		{"hash is not known", "2", Item{Topics: []string{"a"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, s.Unchanged(tt.id, tt.item))
+		})
+	}
+}
+
+func TestForget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	s, err := NewStore(dir)
+	require.NoError(t, err)
+
+	// failed during run - hash is not saved
+	s.Forget("feed", "1")
+	require.NoError(t, s.Save("feed", Snapshot{"1": {Topics: []string{"a"}, Hash: "h1"}, "2": {Topics: []string{"a"}, Hash: "h2"}}))
+	// failed after snapshot was saved - hash is cleared on load
+	s.Forget("feed", "2")
+	snapshot, err := s.Load("feed")
+	require.NoError(t, err)
+	assert.Equal(t, Snapshot{"1": {Topics: []string{"a"}}, "2": {Topics: []string{"a"}}}, snapshot)
+	require.NoError(t, s.Save("feed", Snapshot{"2": {Topics: []string{"a"}, Hash: "h2"}}))
+	snapshot, err = s.Load("feed")
+	require.NoError(t, err)
+	assert.Equal(t, Snapshot{"2": {Topics: []string{"a"}}}, snapshot)
+	// forgotten items are applied till next save
+	require.NoError(t, s.Save("feed", Snapshot{"2": {Topics: []string{"a"}, Hash: "h2"}}))
+	snapshot, err = s.Load("feed")
+	require.NoError(t, err)
+	assert.Equal(t, Snapshot{"2": {Topics: []string{"a"}, Hash: "h2"}}, snapshot)
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := NewStore(filepath.Join(dir, "nested"))
+	require.NoError(t, err)
+
+	snapshot, err := s.Load("http://test.org/feed.xml")
+	require.NoError(t, err)
+	assert.Equal(t, Snapshot{}, snapshot)
+
+	expected := Snapshot{"1": {Topics: []string{"shop_items", "shop_items_bidding"}}}
+	require.NoError(t, s.Save("http://test.org/feed.xml", expected))
+	snapshot, err = s.Load("http://test.org/feed.xml")
+	require.NoError(t, err)
+	assert.Equal(t, expected, snapshot)
+
+	// feeds do not share state
+	snapshot, err = s.Load("http://test.org/other.xml")
+	require.NoError(t, err)
+	assert.Equal(t, Snapshot{}, snapshot)
+
+	require.NoError(t, ioutil.WriteFile(s.path("broken"), []byte("{"), 0644))
+	_, err = s.Load("broken")
+	require.Error(t, err)
+	assert.Equal(t, "Unable to decode state of feed 'broken' because of unexpected end of JSON input", err.Error())
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "nested"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	s, err := NewStore(dir)
+	require.NoError(t, err)
+
+	c, err := s.LoadCheckpoint("feed")
+	require.NoError(t, err)
+	assert.Equal(t, Checkpoint{Items: Snapshot{}}, c)
+
+	// state of previous format contains items only
+	require.NoError(t, ioutil.WriteFile(s.path("feed"), []byte(`{"1":{"topics":["a"],"hash":"h1"}}`), 0644))
+	c, err = s.LoadCheckpoint("feed")
+	require.NoError(t, err)
+	assert.Equal(t, Checkpoint{Items: Snapshot{"1": {Topics: []string{"a"}, Hash: "h1"}}}, c)
+
+	cursor, err := s.LoadCursor("feed")
+	require.NoError(t, err)
+	assert.Nil(t, cursor)
+	started := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, s.SaveCursor("feed", Cursor{Started: started, Processed: 10}))
+	cursor, err = s.LoadCursor("feed")
+	require.NoError(t, err)
+	assert.Equal(t, &Cursor{Started: started, Processed: 10}, cursor)
+
+	expected := Checkpoint{
+		ETag:         `"v1"`,
+		LastModified: "Thu, 02 Jan 2020 03:04:05 GMT",
+		LastSuccess:  started,
+		Items:        Snapshot{"1": {Topics: []string{"a"}, Hash: "h2"}},
+	}
+	require.NoError(t, s.SaveCheckpoint("feed", expected))
+	c, err = s.LoadCheckpoint("feed")
+	require.NoError(t, err)
+	assert.Equal(t, expected, c)
+	// cursor is removed by successful run
+	cursor, err = s.LoadCursor("feed")
+	require.NoError(t, err)
+	assert.Nil(t, cursor)
+
+	require.NoError(t, ioutil.WriteFile(s.cursorPath("feed"), []byte("{"), 0644))
+	_, err = s.LoadCursor("feed")
+	require.Error(t, err)
+	assert.Equal(t, "Unable to decode cursor of feed 'feed' because of unexpected end of JSON input", err.Error())
+}
+
+func TestFeedsAndReset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	s, err := NewStore(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Save("http://test.org/b.xml", Snapshot{"1": {Topics: []string{"a"}}}))
+	require.NoError(t, s.Save("a", Snapshot{"1": {Topics: []string{"a"}}}))
+	require.NoError(t, s.SaveCursor("a", Cursor{Processed: 1}))
+	// feed of previous format is not known
+	require.NoError(t, ioutil.WriteFile(s.path("old"), []byte(`{"feed":{"topics":["a"]}}`), 0644))
+	feeds, err := s.Feeds()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "http://test.org/b.xml"}, feeds)
+
+	s.Forget("a", "1")
+	require.NoError(t, s.Reset("a"))
+	// unknown feed is reset as well
+	require.NoError(t, s.Reset("unknown"))
+	c, err := s.LoadCheckpoint("a")
+	require.NoError(t, err)
+	assert.Equal(t, Checkpoint{Items: Snapshot{}}, c)
+	cursor, err := s.LoadCursor("a")
+	require.NoError(t, err)
+	assert.Nil(t, cursor)
+	assert.Empty(t, s.forgotten["a"])
+	feeds, err = s.Feeds()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://test.org/b.xml"}, feeds)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/state"
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStateArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected stateOptions
+		err      string
+	}{
+		{"all feeds", []string{"--stateDir", "/var/state"}, stateOptions{dir: "/var/state", format: reportFormatText}, ""},
+		{"items of feed", []string{"--stateDir", "/var/state", "-f", " shop ", "--items", "--format", "json"}, stateOptions{dir: "/var/state", feed: "shop", items: true, format: reportFormatJSON}, ""},
+		{"reset", []string{"--stateDir", "/var/state", "-f", "shop", "--reset"}, stateOptions{dir: "/var/state", feed: "shop", reset: true, format: reportFormatText}, ""},
+		{"missing state dir", []string{"-f", "shop"}, stateOptions{}, "Unable to parse flags"},
+		{"reset of all feeds", []string{"--stateDir", "/var/state", "--reset"}, stateOptions{}, "Feed should be provided for reset of state"},
+		{"wrong format", []string{"--stateDir", "/var/state", "--format", "xml"}, stateOptions{}, "Unknown format of report 'xml'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseStateArgs(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, opts)
+		})
+	}
+}
+
+func TestFeedStateReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := state.NewStore(dir)
+	require.NoError(t, err)
+	success := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, store.SaveCheckpoint("shop", state.Checkpoint{LastSuccess: success, Items: state.Snapshot{
+		"2": {Topics: []string{"a"}, Hash: "h2", LastSeen: success},
+		"1": {Topics: []string{"a", "b"}, LastSeen: success.Add(-time.Hour)},
+		// saved by previous version
+		"3": {Topics: []string{"a"}},
+	}}))
+	require.NoError(t, store.SaveCursor("shop", state.Cursor{Started: success.Add(time.Hour), Processed: 5}))
+
+	r, err := feedStateReport(store, "shop", true)
+	require.NoError(t, err)
+	assert.Equal(t, stateReport{
+		Feed:        "shop",
+		LastSuccess: success,
+		Items:       3,
+		Absent:      1,
+		Interrupted: &state.Cursor{Started: success.Add(time.Hour), Processed: 5},
+		ItemList: []stateItem{
+			{ID: "1", Topics: []string{"a", "b"}, LastSeen: success.Add(-time.Hour)},
+			{ID: "2", Topics: []string{"a"}, Hash: "h2", LastSeen: success},
+			{ID: "3", Topics: []string{"a"}, LastSeen: success},
+		},
+	}, r)
+
+	var out bytes.Buffer
+	require.NoError(t, writeStateReports(&out, []stateReport{r, {Feed: "other"}}, reportFormatText))
+	assert.Equal(t, `feed: shop
+last success: 2020-01-02T03:04:05Z
+items: 3
+absent items: 1
+interrupted run: started 2020-01-02T04:04:05Z, 5 items processed
+  1 2020-01-02T02:04:05Z a,b
+  2 2020-01-02T03:04:05Z a h2
+  3 2020-01-02T03:04:05Z a
+
+feed: other
+items: 0
+absent items: 0
+`, out.String())
+}
+
+func TestStateRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := state.NewStore(filepath.Join(dir, "state"))
+	require.NoError(t, err)
+	require.NoError(t, store.Save("shop", state.Snapshot{"1": {Topics: []string{"a"}}}))
+	require.NoError(t, store.Save("http://test.org/feed.xml", state.Snapshot{}))
+
+	stdout := os.Stdout
+	report, err := os.Create(filepath.Join(dir, "report.json"))
+	require.NoError(t, err)
+	defer report.Close()
+	os.Stdout = report
+	defer func() { os.Stdout = stdout }()
+
+	require.NoError(t, stateRun([]string{"--stateDir", filepath.Join(dir, "state"), "--format", "json"}))
+	require.NoError(t, stateRun([]string{"--stateDir", filepath.Join(dir, "state"), "-f", "shop", "--reset"}))
+	data, err := ioutil.ReadFile(report.Name())
+	require.NoError(t, err)
+	lines := bytes.SplitN(data, []byte("\n"), 2)
+	reports := []stateReport{}
+	require.NoError(t, json.Unmarshal(lines[0], &reports))
+	require.Equal(t, 2, len(reports))
+	assert.Equal(t, "http://test.org/feed.xml", reports[0].Feed)
+	assert.Equal(t, "shop", reports[1].Feed)
+	assert.Equal(t, 1, reports[1].Items)
+	assert.Equal(t, "State of feed 'shop' was reset\n", string(lines[1]))
+
+	feeds, err := store.Feeds()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://test.org/feed.xml"}, feeds)
+
+	// directory is not created by inspection
+	err = stateRun([]string{"--stateDir", filepath.Join(dir, "absent")})
+	require.Error(t, err)
+	assert.Equal(t, apperror.CategoryConfig, apperror.Category(err))
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/heureka"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/jessevdk/go-flags"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// statsCommand is the first argument which switches app into computing of statistics of feed
+	statsCommand = "stats"
+	// minSizeBucket is upper bound of the smallest bucket of sizes of items, every next bucket is twice larger
+	minSizeBucket = 256
+)
+
+// statsQuantiles are quantiles of prices included into statistics
+var statsQuantiles = []float64{0, 0.25, 0.5, 0.75, 0.9, 0.99, 1}
+
+// statsOptions define which feed is profiled and how statistics are printed
+type statsOptions struct {
+	feed   *url.URL
+	format string
+	// topCategories is number of categories with the most items listed in statistics
+	topCategories int
+}
+
+// sizeBucket counts items with size in bytes up to its bound and larger than bound of previous bucket
+type sizeBucket struct {
+	UpTo  int64 `json:"upTo"`
+	Items int   `json:"items"`
+}
+
+// categoryCount is number of items of category
+type categoryCount struct {
+	Category string `json:"category"`
+	Items    int    `json:"items"`
+}
+
+// priceQuantile is price below which given part of priced items is
+type priceQuantile struct {
+	Quantile float64         `json:"quantile"`
+	Price    decimal.Decimal `json:"price"`
+}
+
+// feedStats are statistics of items of feed
+type feedStats struct {
+	Feed  string `json:"feed"`
+	Items int    `json:"items"`
+	// ParseErrors is number of items which could not be parsed, they are not included into other statistics
+	ParseErrors int `json:"parseErrors"`
+	// Bytes is total size of items
+	Bytes int64        `json:"bytes"`
+	Sizes []sizeBucket `json:"sizes"`
+	// Categories is number of distinct categories
+	Categories    int             `json:"categories"`
+	TopCategories []categoryCount `json:"topCategories"`
+	// Priced is number of items with price
+	Priced int             `json:"priced"`
+	Prices []priceQuantile `json:"prices"`
+	// Bidding is number of items with CPC set
+	Bidding     int     `json:"bidding"`
+	CPCCoverage float64 `json:"cpcCoverage"`
+	// Error stopped reading of feed, e.g. feed could not be downloaded or it is not well formed xml
+	Error string `json:"error,omitempty"`
+}
+
+// parseStatsArgs parses flags of stats command
+func parseStatsArgs(args []string) (statsOptions, error) {
+	var opts struct {
+		Feed          string `short:"f" long:"feedUrl" description:"Profiled feed: 'http(s)://' or 'file://' url" required:"true"`
+		Format        string `long:"format" description:"Format of statistics: 'text' or 'json'" default:"text"`
+		TopCategories int    `long:"topCategories" description:"Number of categories with the most items which are listed" default:"10"`
+	}
+	parser := flags.NewParser(&opts, flags.PassDoubleDash|flags.IgnoreUnknown)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		return statsOptions{}, fmt.Errorf("Unable to parse flags: %w", err)
+	}
+	feed, err := url.Parse(strings.TrimSpace(opts.Feed))
+	if err != nil {
+		return statsOptions{}, fmt.Errorf("Unable to parse feed url '%s' because of %w", opts.Feed, err)
+	}
+	if !feed.IsAbs() {
+		return statsOptions{}, fmt.Errorf("Feed url '%s' should be absolute", opts.Feed)
+	}
+	if opts.Format != reportFormatText && opts.Format != reportFormatJSON {
+		return statsOptions{}, fmt.Errorf("Unknown format of statistics '%s', supported formats are '%s' and '%s'", opts.Format, reportFormatText, reportFormatJSON)
+	}
+	if opts.TopCategories < 0 {
+		return statsOptions{}, fmt.Errorf("Number of top categories should not be negative")
+	}
+	return statsOptions{feed: feed, format: opts.Format, topCategories: opts.TopCategories}, nil
+}
+
+// statsRun prints statistics of feed into stdout
+func statsRun(args []string) error {
+	opts, err := parseStatsArgs(args)
+	if err != nil {
+		return &apperror.ConfigError{Err: err}
+	}
+	stream, err := provider.CreateStream(context.Background(), opts.feed)
+	if err != nil {
+		return fmt.Errorf("Failed to get stream: %w", err)
+	}
+	defer stream.Close()
+	s := computeStats(stream, opts)
+	if err := writeStats(os.Stdout, s, opts.format); err != nil {
+		return err
+	}
+	if s.Error != "" {
+		return fmt.Errorf("Feed '%s' was not read completely", opts.feed)
+	}
+	return nil
+}
+
+// computeStats reads all items of feed in one pass. Only counters, categories and prices are kept in memory.
+func computeStats(r io.Reader, opts statsOptions) feedStats {
+	s := feedStats{Feed: opts.feed.String(), Sizes: []sizeBucket{}, TopCategories: []categoryCount{}, Prices: []priceQuantile{}}
+	sizes := map[int64]int{}
+	categories := map[string]int{}
+	prices := []decimal.Decimal{}
+	d := xml.NewDecoder(r)
+	for {
+		start := d.InputOffset()
+		token, err := d.Token()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				s.Error = fmt.Sprintf("Failed to read node element after %d items: %v", s.Items+s.ParseErrors, err)
+			}
+			break
+		}
+		startElem, ok := token.(xml.StartElement)
+		if !ok || startElem.Name.Local != "SHOPITEM" {
+			continue
+		}
+		item := heureka.Item{}
+		if err := d.DecodeElement(&item, &startElem); err != nil {
+			s.ParseErrors++
+			// in case of error - skip this item like parser of feeds does
+			if err := d.Skip(); err != nil {
+				s.Error = fmt.Sprintf("Failed to skip bad part after %d items: %v", s.Items+s.ParseErrors, err)
+				break
+			}
+			continue
+		}
+		s.Items++
+		size := d.InputOffset() - start
+		s.Bytes += size
+		sizes[sizeBucketBound(size)]++
+		categories[item.CategoryText]++
+		if !item.PriceVAT.IsZero() {
+			prices = append(prices, item.PriceVAT.Decimal)
+		}
+		if !item.HeurekaCPC.IsZero() {
+			s.Bidding++
+		}
+	}
+	for bound, items := range sizes {
+		s.Sizes = append(s.Sizes, sizeBucket{UpTo: bound, Items: items})
+	}
+	sort.Slice(s.Sizes, func(i, j int) bool { return s.Sizes[i].UpTo < s.Sizes[j].UpTo })
+	s.Categories = len(categories)
+	for category, items := range categories {
+		s.TopCategories = append(s.TopCategories, categoryCount{Category: category, Items: items})
+	}
+	sort.Slice(s.TopCategories, func(i, j int) bool {
+		if s.TopCategories[i].Items != s.TopCategories[j].Items {
+			return s.TopCategories[i].Items > s.TopCategories[j].Items
+		}
+		return s.TopCategories[i].Category < s.TopCategories[j].Category
+	})
+	if len(s.TopCategories) > opts.topCategories {
+		s.TopCategories = s.TopCategories[:opts.topCategories]
+	}
+	s.Priced = len(prices)
+	if len(prices) > 0 {
+		sort.Slice(prices, func(i, j int) bool { return prices[i].LessThan(prices[j]) })
+		for _, q := range statsQuantiles {
+			s.Prices = append(s.Prices, priceQuantile{Quantile: q, Price: prices[quantileIndex(q, len(prices))]})
+		}
+	}
+	if s.Items > 0 {
+		s.CPCCoverage = float64(s.Bidding) / float64(s.Items)
+	}
+	return s
+}
+
+// sizeBucketBound returns upper bound of bucket of size
+func sizeBucketBound(size int64) int64 {
+	bound := int64(minSizeBucket)
+	for bound < size {
+		bound *= 2
+	}
+	return bound
+}
+
+// quantileIndex returns index of quantile in sorted values by nearest rank method
+func quantileIndex(q float64, n int) int {
+	i := int(math.Ceil(q*float64(n))) - 1
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+// writeStats writes statistics in format
+func writeStats(w io.Writer, s feedStats, format string) error {
+	var err error
+	if format == reportFormatJSON {
+		err = json.NewEncoder(w).Encode(s)
+	} else {
+		_, err = io.WriteString(w, statsText(s))
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write statistics because of %w", err)
+	}
+	return nil
+}
+
+// statsText formats statistics as human readable text
+func statsText(s feedStats) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "feed: %s\n", s.Feed)
+	if s.Error != "" {
+		fmt.Fprintf(&b, "error: %s\n", s.Error)
+	}
+	fmt.Fprintf(&b, "items: %d\nparse errors: %d\n", s.Items, s.ParseErrors)
+	fmt.Fprintf(&b, "size of items: %d bytes\n", s.Bytes)
+	for _, bucket := range s.Sizes {
+		fmt.Fprintf(&b, "  up to %d bytes: %d\n", bucket.UpTo, bucket.Items)
+	}
+	fmt.Fprintf(&b, "priced items: %d\n", s.Priced)
+	for _, q := range s.Prices {
+		fmt.Fprintf(&b, "  p%g: %s\n", q.Quantile*100, q.Price)
+	}
+	fmt.Fprintf(&b, "bidding items: %d (%.1f%%)\n", s.Bidding, s.CPCCoverage*100)
+	fmt.Fprintf(&b, "categories: %d\n", s.Categories)
+	for _, c := range s.TopCategories {
+		category := c.Category
+		if category == "" {
+			category = "(no category)"
+		}
+		fmt.Fprintf(&b, "  %s: %d\n", category, c.Items)
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var statsItems = `<?xml version="1.0" encoding="UTF-8"?>
+<SHOP>
+	<SHOPITEM><ITEM_ID>1</ITEM_ID><PRICE_VAT>100</PRICE_VAT><CATEGORYTEXT>Books</CATEGORYTEXT><HEUREKA_CPC>1,50</HEUREKA_CPC></SHOPITEM>
+	<SHOPITEM><ITEM_ID>2</ITEM_ID><PRICE_VAT>300</PRICE_VAT><CATEGORYTEXT>Books</CATEGORYTEXT></SHOPITEM>
+	<SHOPITEM><ITEM_ID>3</ITEM_ID><PRICE_VAT>200</PRICE_VAT><CATEGORYTEXT>Toys</CATEGORYTEXT><DESCRIPTION>` + strings.Repeat("long description ", 20) + `</DESCRIPTION></SHOPITEM>
+	<SHOPITEM><ITEM_ID>4</ITEM_ID><CATEGORYTEXT>Garden</CATEGORYTEXT></SHOPITEM>
+	<SHOPITEM><ITEM_ID>5</ITEM_ID><PRICE_VAT>abc</PRICE_VAT></SHOPITEM>
+</SHOP>`
+
+func TestComputeStats(t *testing.T) {
+	feed, err := url.Parse("file://testdata/feed.xml")
+	require.NoError(t, err)
+	s := computeStats(strings.NewReader(statsItems), statsOptions{feed: feed, topCategories: 2})
+	assert.Equal(t, "", s.Error)
+	assert.Equal(t, 4, s.Items)
+	assert.Equal(t, 1, s.ParseErrors)
+	assert.Equal(t, []sizeBucket{{UpTo: 256, Items: 3}, {UpTo: 512, Items: 1}}, s.Sizes)
+	assert.Equal(t, 3, s.Categories)
+	assert.Equal(t, []categoryCount{{Category: "Books", Items: 2}, {Category: "Garden", Items: 1}}, s.TopCategories)
+	assert.Equal(t, 3, s.Priced)
+	prices := map[float64]string{}
+	for _, q := range s.Prices {
+		prices[q.Quantile] = q.Price.String()
+	}
+	assert.Equal(t, map[float64]string{0: "100", 0.25: "100", 0.5: "200", 0.75: "300", 0.9: "300", 0.99: "300", 1: "300"}, prices)
+	assert.Equal(t, 1, s.Bidding)
+	assert.Equal(t, 0.25, s.CPCCoverage)
+}
+
+func TestComputeStatsBroken(t *testing.T) {
+	feed, err := url.Parse("file://testdata/feed.xml")
+	require.NoError(t, err)
+	s := computeStats(strings.NewReader("<SHOP><SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM><SHOPITEM>"), statsOptions{feed: feed})
+	assert.Equal(t, 1, s.Items)
+	assert.Equal(t, 1, s.ParseErrors)
+	assert.Contains(t, s.Error, "Failed to skip bad part after 2 items")
+}
+
+func TestSizeBucketBound(t *testing.T) {
+	for size, expected := range map[int64]int64{0: 256, 256: 256, 257: 512, 5000: 8192} {
+		assert.Equal(t, expected, sizeBucketBound(size), size)
+	}
+}
+
+func TestQuantileIndex(t *testing.T) {
+	tests := []struct {
+		q        float64
+		n        int
+		expected int
+	}{
+		{0, 10, 0},
+		{0.5, 10, 4},
+		{0.9, 10, 8},
+		{1, 10, 9},
+		{0.5, 1, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, quantileIndex(tt.q, tt.n), tt)
+	}
+}
+
+func TestWriteStats(t *testing.T) {
+	s := feedStats{
+		Feed:          "file://testdata/feed.xml",
+		Items:         2,
+		Bytes:         300,
+		Sizes:         []sizeBucket{{UpTo: 256, Items: 1}, {UpTo: 512, Items: 1}},
+		Categories:    2,
+		TopCategories: []categoryCount{{Category: "Books", Items: 1}, {Items: 1}},
+		Priced:        2,
+		Prices:        []priceQuantile{{Quantile: 0, Price: decimal.New(100, 0)}, {Quantile: 0.5, Price: decimal.New(100, 0)}, {Quantile: 1, Price: decimal.New(15, -1)}},
+		Bidding:       1,
+		CPCCoverage:   0.5,
+	}
+	var out bytes.Buffer
+	require.NoError(t, writeStats(&out, s, reportFormatText))
+	assert.Equal(t, `feed: file://testdata/feed.xml
+items: 2
+parse errors: 0
+size of items: 300 bytes
+  up to 256 bytes: 1
+  up to 512 bytes: 1
+priced items: 2
+  p0: 100
+  p50: 100
+  p100: 1.5
+bidding items: 1 (50.0%)
+categories: 2
+  Books: 1
+  (no category): 1
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeStats(&out, feedStats{Feed: "file://testdata/feed.xml", Sizes: []sizeBucket{}, TopCategories: []categoryCount{}, Prices: []priceQuantile{}}, reportFormatJSON))
+	assert.Equal(t, `{"feed":"file://testdata/feed.xml","items":0,"parseErrors":0,"bytes":0,"sizes":[],"categories":0,"topCategories":[],"priced":0,"prices":[],"bidding":0,"cpcCoverage":0}`+"\n", out.String())
+}
+
+func TestParseStatsArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"defaults", []string{"-f", "file://testdata/one_item.xml"}, ""},
+		{"no feed", []string{}, "Unable to parse flags: the required flag `-f, --feedUrl' was not specified"},
+		{"relative feed", []string{"-f", "feed.xml"}, "Feed url 'feed.xml' should be absolute"},
+		{"unknown format", []string{"-f", "file://feed.xml", "--format", "csv"}, "Unknown format of statistics 'csv', supported formats are 'text' and 'json'"},
+		{"negative categories", []string{"-f", "file://feed.xml", "--topCategories", "-1"}, "Number of top categories should not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseStatsArgs(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, statsOptions{feed: opts.feed, format: reportFormatText, topCategories: 10}, opts)
+			assert.Equal(t, "file://testdata/one_item.xml", opts.feed.String())
+		})
+	}
+}
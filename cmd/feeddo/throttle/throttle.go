@@ -0,0 +1,50 @@
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Throttle limits number of items passed per second. It is token bucket refilled by rate tokens per second
+// with capacity of one second, so short bursts are allowed while average rate stays under limit.
+// Throttle is not safe for concurrent use, every run of feed has its own throttle.
+type Throttle struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// New creates throttle passing rate items per second. Nil is returned when rate is not positive, nil throttle does not limit items.
+func New(rate int) *Throttle {
+	if rate <= 0 {
+		return nil
+	}
+	return &Throttle{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// Wait blocks until the next item could be passed. Error is returned when ctx is done first.
+func (t *Throttle) Wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Seconds() * t.rate
+	if t.tokens > t.rate {
+		t.tokens = t.rate
+	}
+	t.last = now
+	t.tokens--
+	if t.tokens >= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(-t.tokens / t.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// item is not passed, so its token is returned
+		t.tokens++
+		return ctx.Err()
+	}
+}
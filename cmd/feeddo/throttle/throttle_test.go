@@ -0,0 +1,40 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWait(t *testing.T) {
+	th := New(100)
+	started := time.Now()
+	// capacity of one second is available immediately
+	for i := 0; i < 100; i++ {
+		require.NoError(t, th.Wait(context.Background()))
+	}
+	assert.True(t, time.Since(started) < 50*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, th.Wait(context.Background()))
+	}
+	assert.True(t, time.Since(started) >= 40*time.Millisecond, time.Since(started))
+}
+
+func TestWaitCancelled(t *testing.T) {
+	th := New(1)
+	require.NoError(t, th.Wait(context.Background()))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	started := time.Now()
+	assert.Equal(t, context.Canceled, th.Wait(ctx))
+	assert.True(t, time.Since(started) < 100*time.Millisecond)
+}
+
+func TestNoLimit(t *testing.T) {
+	assert.Nil(t, New(0))
+	var th *Throttle
+	assert.NoError(t, th.Wait(context.Background()))
+}
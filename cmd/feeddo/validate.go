@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/metrics"
+	"github.com/grubastik/feeddo/cmd/feeddo/quality"
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/heureka"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/jessevdk/go-flags"
+)
+
+const (
+	// validateCommand is the first argument which switches app into validation of feed
+	validateCommand = "validate"
+	// formats of validation report
+	reportFormatText = "text"
+	reportFormatJSON = "json"
+)
+
+// validationWarnings are issues which are reported but do not make feed invalid
+var validationWarnings = map[string]bool{metrics.IssueMissingEAN: true}
+
+// fieldValidators validate values of elements of item by their path. Types of heureka items are used,
+// so values are validated in the same way as they are parsed when feed is processed.
+var fieldValidators = map[string]func() encoding.TextUnmarshaler{
+	"ITEM_ID":                     func() encoding.TextUnmarshaler { return new(heureka.ID) },
+	"URL":                         func() encoding.TextUnmarshaler { return new(heureka.URL) },
+	"IMGURL":                      func() encoding.TextUnmarshaler { return new(heureka.URL) },
+	"IMGURL_ALTERNATIVE":          func() encoding.TextUnmarshaler { return new(heureka.URL) },
+	"VIDEO_URL":                   func() encoding.TextUnmarshaler { return new(heureka.URL) },
+	"PRICE_VAT":                   func() encoding.TextUnmarshaler { return new(heureka.Price) },
+	"HEUREKA_CPC":                 func() encoding.TextUnmarshaler { return new(heureka.Price) },
+	"DUES":                        func() encoding.TextUnmarshaler { return new(heureka.Price) },
+	"VAT":                         func() encoding.TextUnmarshaler { return new(heureka.Percent) },
+	"DELIVERY/DELIVERY_PRICE":     func() encoding.TextUnmarshaler { return new(heureka.Price) },
+	"DELIVERY/DELIVERY_PRICE_COD": func() encoding.TextUnmarshaler { return new(heureka.Price) },
+}
+
+// validateOptions define which feed is validated and how report is printed
+type validateOptions struct {
+	feed *url.URL
+	// examples is maximum number of offending items listed per problem
+	examples int
+	format   string
+	// duplicateIndexSize is maximum number of ids kept in memory for detection of duplicates
+	duplicateIndexSize int
+}
+
+// validationProblem counts items with the same problem
+type validationProblem struct {
+	Count int `json:"count"`
+	// Examples identify offending items by id or by position (e.g. '#3') when item has no id
+	Examples []string `json:"examples"`
+}
+
+// add counts offending item and keeps example unless there are enough examples
+func (p *validationProblem) add(example string, examples int) {
+	p.Count++
+	if len(p.Examples) < examples {
+		p.Examples = append(p.Examples, example)
+	}
+}
+
+// validationReport is result of validation of feed
+type validationReport struct {
+	Feed  string `json:"feed"`
+	Items int    `json:"items"`
+	// Valid is number of items without field errors and issues
+	Valid int `json:"valid"`
+	// FieldErrors are values which could not be parsed by path of element, e.g. 'PRICE_VAT'
+	FieldErrors map[string]*validationProblem `json:"fieldErrors"`
+	// Issues are data quality issues, e.g. 'duplicate'
+	Issues map[string]*validationProblem `json:"issues"`
+	// Truncated is set when not all duplicates were detected because of limit of duplicate index
+	Truncated bool `json:"truncated,omitempty"`
+	// Error stopped validation, e.g. feed could not be downloaded or it is not well formed xml
+	Error string `json:"error,omitempty"`
+	// ErrorPosition locates error in feed when feed is not well formed xml
+	ErrorPosition *apperror.Position `json:"errorPosition,omitempty"`
+}
+
+// failed returns true when feed should not be published
+func (r validationReport) failed() bool {
+	if r.Error != "" || len(r.FieldErrors) > 0 {
+		return true
+	}
+	for issue := range r.Issues {
+		if !validationWarnings[issue] {
+			return true
+		}
+	}
+	return false
+}
+
+// validationElement is element of item with its raw value
+type validationElement struct {
+	XMLName  xml.Name
+	Value    string              `xml:",chardata"`
+	Elements []validationElement `xml:",any"`
+}
+
+// parseValidateArgs parses flags of validate command
+func parseValidateArgs(args []string) (validateOptions, error) {
+	var opts struct {
+		Feed               string `short:"f" long:"feedUrl" description:"Validated feed: 'http(s)://' or 'file://' url" required:"true"`
+		Examples           int    `long:"examples" description:"Maximum number of offending items listed per problem" default:"3"`
+		Format             string `long:"format" description:"Format of report: 'text' or 'json'" default:"text"`
+		DuplicateIndexSize int    `long:"duplicateIndexSize" description:"Maximum number of ids kept in memory for detection of duplicate items, '0' disables detection" default:"1000000"`
+	}
+	parser := flags.NewParser(&opts, flags.PassDoubleDash|flags.IgnoreUnknown)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		return validateOptions{}, fmt.Errorf("Unable to parse flags: %w", err)
+	}
+	feed, err := url.Parse(strings.TrimSpace(opts.Feed))
+	if err != nil {
+		return validateOptions{}, fmt.Errorf("Unable to parse feed url '%s' because of %w", opts.Feed, err)
+	}
+	if !feed.IsAbs() {
+		return validateOptions{}, fmt.Errorf("Feed url '%s' should be absolute", opts.Feed)
+	}
+	if opts.Examples < 0 {
+		return validateOptions{}, fmt.Errorf("Number of examples should not be negative")
+	}
+	if opts.Format != reportFormatText && opts.Format != reportFormatJSON {
+		return validateOptions{}, fmt.Errorf("Unknown format of report '%s', supported formats are '%s' and '%s'", opts.Format, reportFormatText, reportFormatJSON)
+	}
+	if opts.DuplicateIndexSize < 0 {
+		return validateOptions{}, fmt.Errorf("Duplicate index size should not be negative")
+	}
+	return validateOptions{feed: feed, examples: opts.Examples, format: opts.Format, duplicateIndexSize: opts.DuplicateIndexSize}, nil
+}
+
+// validateRun prints validation report of feed into stdout. Error is returned when feed is not valid.
+func validateRun(args []string) error {
+	opts, err := parseValidateArgs(args)
+	if err != nil {
+		return &apperror.ConfigError{Err: err}
+	}
+	ctx := context.Background()
+	report := validationReport{Feed: opts.feed.String()}
+	stream, err := provider.CreateStream(ctx, opts.feed)
+	if err != nil {
+		report.Error = err.Error()
+	} else {
+		report = validateFeed(stream, opts)
+		stream.Close()
+	}
+	if err := writeValidationReport(os.Stdout, report, opts.format); err != nil {
+		return err
+	}
+	if report.failed() {
+		return fmt.Errorf("Feed '%s' is not valid", opts.feed)
+	}
+	return nil
+}
+
+// validateFeed reads all items of feed and collects their problems
+func validateFeed(r io.Reader, opts validateOptions) validationReport {
+	report := validationReport{
+		Feed:        opts.feed.String(),
+		FieldErrors: map[string]*validationProblem{},
+		Issues:      map[string]*validationProblem{},
+	}
+	add := func(problems map[string]*validationProblem, key, example string) {
+		p, ok := problems[key]
+		if !ok {
+			p = &validationProblem{Examples: []string{}}
+			problems[key] = p
+		}
+		p.add(example, opts.examples)
+	}
+	qc := quality.NewChecker(opts.duplicateIndexSize)
+	d := xml.NewDecoder(r)
+	// id of the last item locates error of xml
+	lastID := ""
+	position := func(offset int64, err error) *apperror.Position {
+		p := &apperror.Position{Offset: offset, PreviousItemID: lastID}
+		var se *xml.SyntaxError
+		if errors.As(err, &se) {
+			p.Line = se.Line
+		}
+		return p
+	}
+	for {
+		offset := d.InputOffset()
+		token, err := d.Token()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				report.Error = fmt.Sprintf("Failed to read node element after %d items: %v", report.Items, err)
+				report.ErrorPosition = position(d.InputOffset(), err)
+			}
+			break
+		}
+		startElem, ok := token.(xml.StartElement)
+		if !ok || startElem.Name.Local != "SHOPITEM" {
+			continue
+		}
+		report.Items++
+		item := validationElement{}
+		if err := d.DecodeElement(&item, &startElem); err != nil {
+			// decoder could not continue after malformed xml
+			report.Error = fmt.Sprintf("Failed to unmarshal xml node of item #%d: %v", report.Items, err)
+			report.ErrorPosition = position(offset, err)
+			break
+		}
+		p := product.Product{}
+		errs := map[string]error{}
+		validateElements(item.Elements, "", errs, &p)
+		if p.ID != "" {
+			lastID = p.ID
+		}
+		name := p.ID
+		if name == "" {
+			name = "#" + strconv.Itoa(report.Items)
+		}
+		for path, err := range errs {
+			add(report.FieldErrors, path, name+": "+err.Error())
+		}
+		issues := qc.Check(p)
+		for _, issue := range issues {
+			add(report.Issues, issue, name)
+		}
+		if len(errs) == 0 && len(issues) == 0 {
+			report.Valid++
+		}
+	}
+	report.Truncated = qc.Truncated()
+	return report
+}
+
+// validateElements validates values of elements and sets values checked for data quality issues into product.
+// Only the first error of every path is kept.
+func validateElements(elements []validationElement, parent string, errs map[string]error, p *product.Product) {
+	for _, e := range elements {
+		path := parent + e.XMLName.Local
+		if len(e.Elements) > 0 {
+			validateElements(e.Elements, path+"/", errs, p)
+			continue
+		}
+		if newValue, ok := fieldValidators[path]; ok {
+			v := newValue()
+			if err := v.UnmarshalText([]byte(e.Value)); err != nil {
+				if _, ok := errs[path]; !ok {
+					errs[path] = err
+				}
+				continue
+			}
+			switch value := v.(type) {
+			case *heureka.ID:
+				p.ID = string(*value)
+			case *heureka.Price:
+				if path == "PRICE_VAT" {
+					p.PriceVAT = value.Decimal
+				}
+			}
+		}
+		if path == "EAN" {
+			p.EAN = strings.TrimSpace(e.Value)
+		}
+	}
+}
+
+// writeValidationReport writes report in format
+func writeValidationReport(w io.Writer, r validationReport, format string) error {
+	var err error
+	if format == reportFormatJSON {
+		err = json.NewEncoder(w).Encode(r)
+	} else {
+		_, err = io.WriteString(w, validationReportText(r))
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write validation report because of %w", err)
+	}
+	return nil
+}
+
+// validationReportText formats report as human readable text
+func validationReportText(r validationReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "feed: %s\n", r.Feed)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "error: %s\n", r.Error)
+	}
+	if r.ErrorPosition != nil {
+		fmt.Fprintf(&b, "error position: %s\n", r.ErrorPosition)
+	}
+	fmt.Fprintf(&b, "items: %d\nvalid items: %d\n", r.Items, r.Valid)
+	for _, section := range []struct {
+		title    string
+		problems map[string]*validationProblem
+	}{{"field errors", r.FieldErrors}, {"issues", r.Issues}} {
+		if len(section.problems) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:\n", section.title)
+		keys := make([]string, 0, len(section.problems))
+		for k := range section.problems {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "  %s: %d\n", k, section.problems[k].Count)
+			for _, example := range section.problems[k].Examples {
+				fmt.Fprintf(&b, "    %s\n", example)
+			}
+		}
+	}
+	if r.Truncated {
+		b.WriteString("duplicate index limit was reached, not all duplicates were detected\n")
+	}
+	if r.failed() {
+		b.WriteString("result: invalid\n")
+	} else {
+		b.WriteString("result: valid\n")
+	}
+	return b.String()
+}
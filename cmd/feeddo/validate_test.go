@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validateItems = `<?xml version="1.0" encoding="UTF-8"?>
+<SHOP>
+	<SHOPITEM><ITEM_ID>1</ITEM_ID><PRICE_VAT>100</PRICE_VAT><EAN>8715946360188</EAN></SHOPITEM>
+	<SHOPITEM><ITEM_ID>2</ITEM_ID><PRICE_VAT>abc</PRICE_VAT><EAN>8715946360188</EAN><URL>/relative</URL></SHOPITEM>
+	<SHOPITEM><ITEM_ID>1</ITEM_ID><PRICE_VAT>100</PRICE_VAT><EAN>8715946360188</EAN></SHOPITEM>
+	<SHOPITEM><PRODUCTNAME>no id</PRODUCTNAME></SHOPITEM>
+	<SHOPITEM><ITEM_ID>3</ITEM_ID><PRICE_VAT>100</PRICE_VAT>
+		<DELIVERY><DELIVERY_ID>PPL</DELIVERY_ID><DELIVERY_PRICE>free</DELIVERY_PRICE></DELIVERY>
+	</SHOPITEM>
+</SHOP>`
+
+func TestValidateFeed(t *testing.T) {
+	feed, err := url.Parse("file://testdata/feed.xml")
+	require.NoError(t, err)
+	report := validateFeed(strings.NewReader(validateItems), validateOptions{feed: feed, examples: 1, duplicateIndexSize: 10})
+	assert.Equal(t, validationReport{
+		Feed:  "file://testdata/feed.xml",
+		Items: 5,
+		Valid: 1,
+		FieldErrors: map[string]*validationProblem{
+			"PRICE_VAT":               {Count: 1, Examples: []string{"2: Unmarshal of price 'abc' failed: error decoding string 'abc': can't convert abc to decimal"}},
+			"URL":                     {Count: 1, Examples: []string{"2: The following URL '/relative' is not absolute"}},
+			"DELIVERY/DELIVERY_PRICE": {Count: 1, Examples: []string{"3: Unmarshal of price 'free' failed: error decoding string 'free': can't convert free to decimal: exponent is not numeric"}},
+		},
+		Issues: map[string]*validationProblem{
+			"missing_price": {Count: 1, Examples: []string{"2"}},
+			"duplicate":     {Count: 1, Examples: []string{"1"}},
+			"malformed":     {Count: 1, Examples: []string{"#4"}},
+			"missing_ean":   {Count: 1, Examples: []string{"3"}},
+		},
+	}, report)
+	assert.True(t, report.failed())
+}
+
+func TestValidateFeedBroken(t *testing.T) {
+	feed, err := url.Parse("file://testdata/badFeed.xml")
+	require.NoError(t, err)
+	f, err := os.Open("testdata/badFeed.xml")
+	require.NoError(t, err)
+	defer f.Close()
+	report := validateFeed(f, validateOptions{feed: feed, examples: 3})
+	assert.Equal(t, 1, report.Items)
+	assert.Equal(t, "Failed to unmarshal xml node of item #1: XML syntax error on line 21: element <PRODUCTNO> closed by </SHOPITEM>", report.Error)
+	// error is located at start of broken item
+	assert.Equal(t, &apperror.Position{Line: 21, Offset: 47}, report.ErrorPosition)
+	assert.True(t, report.failed())
+
+	out := bytes.Buffer{}
+	require.NoError(t, writeValidationReport(&out, report, reportFormatText))
+	assert.Contains(t, out.String(), "error position: line 21, offset 47\n")
+}
+
+func TestValidationReportFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		report   validationReport
+		expected bool
+	}{
+		{"valid", validationReport{Items: 1, Valid: 1}, false},
+		{"warning", validationReport{Issues: map[string]*validationProblem{"missing_ean": {Count: 1}}}, false},
+		{"issue", validationReport{Issues: map[string]*validationProblem{"duplicate": {Count: 1}}}, true},
+		{"field error", validationReport{FieldErrors: map[string]*validationProblem{"VAT": {Count: 1}}}, true},
+		{"error", validationReport{Error: "test error"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.report.failed())
+		})
+	}
+}
+
+func TestWriteValidationReport(t *testing.T) {
+	report := validationReport{
+		Feed:        "file://testdata/feed.xml",
+		Items:       3,
+		Valid:       1,
+		FieldErrors: map[string]*validationProblem{"VAT": {Count: 1, Examples: []string{"2: Persentage value is incorrect: '300%'"}}},
+		Issues: map[string]*validationProblem{
+			"missing_ean": {Count: 2, Examples: []string{"2", "3"}},
+			"duplicate":   {Count: 1, Examples: []string{"3"}},
+		},
+	}
+	var out bytes.Buffer
+	require.NoError(t, writeValidationReport(&out, report, reportFormatText))
+	assert.Equal(t, `feed: file://testdata/feed.xml
+items: 3
+valid items: 1
+field errors:
+  VAT: 1
+    2: Persentage value is incorrect: '300%'
+issues:
+  duplicate: 1
+    3
+  missing_ean: 2
+    2
+    3
+result: invalid
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeValidationReport(&out, validationReport{Feed: "file://testdata/feed.xml", Items: 1, Valid: 1}, reportFormatJSON))
+	assert.Equal(t, `{"feed":"file://testdata/feed.xml","items":1,"valid":1,"fieldErrors":null,"issues":null}`+"\n", out.String())
+}
+
+func TestParseValidateArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"defaults", []string{"-f", "file://testdata/one_item.xml"}, ""},
+		{"no feed", []string{}, "Unable to parse flags: the required flag `-f, --feedUrl' was not specified"},
+		{"relative feed", []string{"-f", "feed.xml"}, "Feed url 'feed.xml' should be absolute"},
+		{"negative examples", []string{"-f", "file://feed.xml", "--examples", "-1"}, "Number of examples should not be negative"},
+		{"unknown format", []string{"-f", "file://feed.xml", "--format", "yaml"}, "Unknown format of report 'yaml', supported formats are 'text' and 'json'"},
+		{"negative index", []string{"-f", "file://feed.xml", "--duplicateIndexSize", "-1"}, "Duplicate index size should not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseValidateArgs(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "file://testdata/one_item.xml", opts.feed.String())
+			assert.Equal(t, 3, opts.examples)
+			assert.Equal(t, reportFormatText, opts.format)
+			assert.Equal(t, 1000000, opts.duplicateIndexSize)
+		})
+	}
+}
+
+func TestValidateRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	feed := filepath.Join(dir, "feed.xml")
+	require.NoError(t, ioutil.WriteFile(feed, []byte(validateItems), 0644))
+	stdout := os.Stdout
+	report, err := os.Create(filepath.Join(dir, "report.txt"))
+	require.NoError(t, err)
+	defer report.Close()
+	os.Stdout = report
+	defer func() { os.Stdout = stdout }()
+
+	assert.NoError(t, validateRun([]string{"-f", "file://testdata/one_item.xml"}))
+	err = validateRun([]string{"-f", "file://" + feed, "--format", "json"})
+	require.Error(t, err)
+	assert.Equal(t, "Feed 'file://"+feed+"' is not valid", err.Error())
+	assert.Error(t, validateRun([]string{"-f", "file://" + filepath.Join(dir, "absent.xml")}))
+}
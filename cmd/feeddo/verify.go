@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/runlog"
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/kafka"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/jessevdk/go-flags"
+)
+
+// verifyCommand is the first argument which switches app into verification of items delivered by run
+const verifyCommand = "verify"
+
+// verifyOptions define which run is verified and where its items are
+type verifyOptions struct {
+	kafkaURL string
+	security kafka.Security
+	topic    string
+	runID    string
+	// runHistoryFile is run log which number of items parsed by run is taken from
+	runHistoryFile string
+	// expected is number of items of run, it overrides run log. Negative number means that it is unknown
+	expected int
+	// feed is snapshot of feed processed by run, ids of its items are compared with delivered ones. Nil when it is not provided
+	feed     *url.URL
+	format   string
+	examples int
+	timeout  time.Duration
+}
+
+// verifyReport is result of reconciliation of items delivered by run
+type verifyReport struct {
+	Run   string `json:"run"`
+	Topic string `json:"topic"`
+	// Messages is number of messages of run including duplicates and tombstones
+	Messages int `json:"messages"`
+	// Items is number of distinct ids of delivered items
+	Items      int `json:"items"`
+	Tombstones int `json:"tombstones"`
+	// Undecodable is number of messages of run which payload has no id
+	Undecodable int `json:"undecodable"`
+	// Expected is number of items parsed by run, -1 when it is unknown
+	Expected   int               `json:"expected"`
+	Duplicates validationProblem `json:"duplicates"`
+	// Missing are items of feed which were not delivered, Unexpected are delivered items which are not in feed
+	Missing    validationProblem `json:"missing"`
+	Unexpected validationProblem `json:"unexpected"`
+	// Error stopped reading of topic
+	Error string `json:"error,omitempty"`
+}
+
+// failed returns true when delivered items do not match run
+func (r verifyReport) failed() bool {
+	return r.Error != "" || r.Undecodable > 0 || r.Duplicates.Count > 0 || r.Missing.Count > 0 || r.Unexpected.Count > 0 ||
+		(r.Expected >= 0 && r.Items != r.Expected)
+}
+
+// consumeFunc calls fn for every message of topic
+type consumeFunc func(ctx context.Context, fn func(m kafka.ConsumedMessage) error) error
+
+// parseVerifyArgs parses flags of verify command
+func parseVerifyArgs(args []string) (verifyOptions, error) {
+	var opts struct {
+		KafkaURL         string        `short:"k" long:"kafkaUrl" description:"Url to connect to kafka" required:"true" env:"KAFKA_URL"`
+		SecurityProtocol string        `long:"kafkaSecurityProtocol" description:"Protocol used to communicate with brokers: plaintext, ssl, sasl_plaintext, sasl_ssl" env:"KAFKA_SECURITY_PROTOCOL"`
+		SASLMechanism    string        `long:"kafkaSaslMechanism" description:"SASL mechanism: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512" env:"KAFKA_SASL_MECHANISM"`
+		SASLUsername     string        `long:"kafkaSaslUsername" description:"SASL username" env:"KAFKA_SASL_USERNAME"`
+		SASLPassword     string        `long:"kafkaSaslPassword" description:"SASL password" env:"KAFKA_SASL_PASSWORD"`
+		CACert           string        `long:"kafkaCaCert" description:"Path to CA certificate for verifying broker's certificate" env:"KAFKA_CA_CERT"`
+		ClientCert       string        `long:"kafkaClientCert" description:"Path to client's public key (PEM) used for authentication" env:"KAFKA_CLIENT_CERT"`
+		ClientKey        string        `long:"kafkaClientKey" description:"Path to client's private key (PEM) used for authentication" env:"KAFKA_CLIENT_KEY"`
+		Topic            string        `long:"topic" description:"Topic which items of run are read from" default:"shop_items"`
+		RunID            string        `long:"runId" description:"Id of verified run, it is sent in 'feeddo-run-id' header of items" required:"true"`
+		RunHistoryFile   string        `long:"runHistoryFile" description:"Run log which number of items parsed by run is taken from" env:"RUN_HISTORY_FILE"`
+		Expected         int           `long:"expected" description:"Number of items of run, it overrides run log. '-1' means that number is taken from run log" default:"-1"`
+		Feed             string        `short:"f" long:"feedUrl" description:"Snapshot of feed processed by run, e.g. archived one: 'http(s)://' or 'file://' url. Missing and unexpected items are reported when it is provided"`
+		Format           string        `long:"format" description:"Format of report: 'text' or 'json'" default:"text"`
+		Examples         int           `long:"examples" description:"Max number of ids listed per problem" default:"3"`
+		Timeout          time.Duration `long:"timeout" description:"How long topic is read at most" default:"10m"`
+	}
+	parser := flags.NewParser(&opts, flags.PassDoubleDash|flags.IgnoreUnknown)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		return verifyOptions{}, fmt.Errorf("Unable to parse flags: %w", err)
+	}
+	security := kafka.Security{
+		Protocol:      opts.SecurityProtocol,
+		SASLMechanism: opts.SASLMechanism,
+		SASLUsername:  opts.SASLUsername,
+		SASLPassword:  opts.SASLPassword,
+		CALocation:    opts.CACert,
+		CertLocation:  opts.ClientCert,
+		KeyLocation:   opts.ClientKey,
+	}
+	if err := security.Validate(); err != nil {
+		return verifyOptions{}, err
+	}
+	if strings.TrimSpace(opts.RunID) == "" {
+		return verifyOptions{}, fmt.Errorf("Run id should not be empty")
+	}
+	if opts.Expected < -1 {
+		return verifyOptions{}, fmt.Errorf("Expected number of items should not be negative")
+	}
+	if opts.Expected < 0 && opts.RunHistoryFile == "" && opts.Feed == "" {
+		return verifyOptions{}, fmt.Errorf("Expected number of items, run log or feed should be provided")
+	}
+	var feed *url.URL
+	if opts.Feed != "" {
+		feed, err = url.Parse(strings.TrimSpace(opts.Feed))
+		if err != nil {
+			return verifyOptions{}, fmt.Errorf("Unable to parse feed url '%s' because of %w", opts.Feed, err)
+		}
+		if !feed.IsAbs() {
+			return verifyOptions{}, fmt.Errorf("Feed url '%s' should be absolute", opts.Feed)
+		}
+	}
+	if opts.Format != reportFormatText && opts.Format != reportFormatJSON {
+		return verifyOptions{}, fmt.Errorf("Unknown format of report '%s', supported formats are '%s' and '%s'", opts.Format, reportFormatText, reportFormatJSON)
+	}
+	if opts.Examples < 0 {
+		return verifyOptions{}, fmt.Errorf("Number of examples should not be negative")
+	}
+	if opts.Timeout <= 0 {
+		return verifyOptions{}, fmt.Errorf("Timeout should be positive")
+	}
+	return verifyOptions{
+		kafkaURL:       opts.KafkaURL,
+		security:       security,
+		topic:          opts.Topic,
+		runID:          strings.TrimSpace(opts.RunID),
+		runHistoryFile: opts.RunHistoryFile,
+		expected:       opts.Expected,
+		feed:           feed,
+		format:         opts.Format,
+		examples:       opts.Examples,
+		timeout:        opts.Timeout,
+	}, nil
+}
+
+// verifyRun prints reconciliation of items delivered by run into stdout
+func verifyRun(args []string) error {
+	opts, err := parseVerifyArgs(args)
+	if err != nil {
+		return &apperror.ConfigError{Err: err}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+	report, err := verify(ctx, func(ctx context.Context, fn func(m kafka.ConsumedMessage) error) error {
+		return kafka.Consume(ctx, opts.kafkaURL, opts.topic, opts.security, fn)
+	}, opts)
+	if err != nil {
+		return err
+	}
+	if err := writeVerifyReport(os.Stdout, report, opts.format); err != nil {
+		return err
+	}
+	if report.failed() {
+		return fmt.Errorf("Items of run %s do not match run", opts.runID)
+	}
+	return nil
+}
+
+// verify collects expected items of run and reconciles them with items read from topic.
+// Errors of reading of topic are reported in report, returned errors mean that expected items are not known.
+func verify(ctx context.Context, consume consumeFunc, opts verifyOptions) (verifyReport, error) {
+	expected := opts.expected
+	if expected < 0 && opts.runHistoryFile != "" {
+		runs, err := runlog.Load(opts.runHistoryFile)
+		if err != nil {
+			return verifyReport{}, err
+		}
+		found := false
+		for _, r := range runs {
+			if r.ID == opts.runID {
+				expected, found = r.Items, true
+				break
+			}
+		}
+		if !found {
+			return verifyReport{}, fmt.Errorf("Run %s is not in run log '%s'", opts.runID, opts.runHistoryFile)
+		}
+	}
+	var feedIDs map[string]bool
+	if opts.feed != nil {
+		stream, err := provider.CreateStream(ctx, opts.feed)
+		if err != nil {
+			return verifyReport{}, fmt.Errorf("Failed to get stream: %w", err)
+		}
+		defer stream.Close()
+		feedIDs = map[string]bool{}
+		parseErrors, err := readProducts(ctx, stream, opts.feed, func(p product.Product) error {
+			feedIDs[p.ID] = true
+			return nil
+		})
+		if err != nil {
+			return verifyReport{}, err
+		}
+		if parseErrors > 0 {
+			return verifyReport{}, &apperror.ParseError{Err: fmt.Errorf("%d items of feed '%s' could not be parsed", parseErrors, opts.feed)}
+		}
+	}
+	return reconcile(ctx, consume, expected, feedIDs, opts), nil
+}
+
+// reconcile reads messages of run from topic and compares them with expected number of items and ids of feed when they are known
+func reconcile(ctx context.Context, consume consumeFunc, expected int, feedIDs map[string]bool, opts verifyOptions) verifyReport {
+	report := verifyReport{
+		Run:        opts.runID,
+		Topic:      opts.topic,
+		Expected:   expected,
+		Duplicates: validationProblem{Examples: []string{}},
+		Missing:    validationProblem{Examples: []string{}},
+		Unexpected: validationProblem{Examples: []string{}},
+	}
+	delivered := map[string]int{}
+	err := consume(ctx, func(m kafka.ConsumedMessage) error {
+		if messageRunID(m) != opts.runID {
+			return nil
+		}
+		report.Messages++
+		if len(m.Value) == 0 {
+			report.Tombstones++
+			return nil
+		}
+		var payload struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(m.Value, &payload); err != nil || payload.ID == "" {
+			report.Undecodable++
+			return nil
+		}
+		delivered[payload.ID]++
+		if delivered[payload.ID] == 2 {
+			report.Duplicates.add(payload.ID, opts.examples)
+		}
+		return nil
+	})
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Items = len(delivered)
+	if feedIDs == nil {
+		return report
+	}
+	missing := []string{}
+	for id := range feedIDs {
+		if delivered[id] == 0 {
+			missing = append(missing, id)
+		}
+	}
+	unexpected := []string{}
+	for id := range delivered {
+		if !feedIDs[id] {
+			unexpected = append(unexpected, id)
+		}
+	}
+	// examples are the same for repeated verification
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	for _, id := range missing {
+		report.Missing.add(id, opts.examples)
+	}
+	for _, id := range unexpected {
+		report.Unexpected.add(id, opts.examples)
+	}
+	return report
+}
+
+// messageRunID returns id of run which sent message
+func messageRunID(m kafka.ConsumedMessage) string {
+	for _, h := range m.Headers {
+		if h.Key == headerRunID {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// writeVerifyReport writes report in format
+func writeVerifyReport(w io.Writer, report verifyReport, format string) error {
+	var err error
+	if format == reportFormatJSON {
+		err = json.NewEncoder(w).Encode(report)
+	} else {
+		_, err = io.WriteString(w, verifyText(report))
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write report because of %w", err)
+	}
+	return nil
+}
+
+// verifyText formats report as human readable text
+func verifyText(report verifyReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "run: %s\ntopic: %s\n", report.Run, report.Topic)
+	if report.Error != "" {
+		fmt.Fprintf(&b, "error: %s\n", report.Error)
+	}
+	fmt.Fprintf(&b, "messages: %d\nitems: %d\ntombstones: %d\n", report.Messages, report.Items, report.Tombstones)
+	if report.Expected >= 0 {
+		fmt.Fprintf(&b, "expected items: %d\n", report.Expected)
+	}
+	if report.Undecodable > 0 {
+		fmt.Fprintf(&b, "messages without id: %d\n", report.Undecodable)
+	}
+	for _, p := range []struct {
+		name    string
+		problem validationProblem
+	}{{"duplicates", report.Duplicates}, {"missing", report.Missing}, {"unexpected", report.Unexpected}} {
+		if p.problem.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %d\n", p.name, p.problem.Count)
+		for _, id := range p.problem.Examples {
+			fmt.Fprintf(&b, "  %s\n", id)
+		}
+	}
+	if report.failed() {
+		b.WriteString("result: mismatch\n")
+	} else {
+		b.WriteString("result: ok\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/cmd/feeddo/runlog"
+	"github.com/grubastik/feeddo/internal/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testConsume returns messages as if they were read from topic, error is returned after all messages
+func testConsume(messages []kafka.ConsumedMessage, err error) consumeFunc {
+	return func(ctx context.Context, fn func(m kafka.ConsumedMessage) error) error {
+		for _, m := range messages {
+			if errF := fn(m); errF != nil {
+				return errF
+			}
+		}
+		return err
+	}
+}
+
+// testMessage returns message of item sent by run
+func testMessage(runID, id string) kafka.ConsumedMessage {
+	m := kafka.ConsumedMessage{Key: []byte(id), Headers: []kafka.Header{{Key: headerRunID, Value: []byte(runID)}}}
+	if id != "" {
+		m.Value = []byte(`{"id":"` + id + `","name":"test"}`)
+	}
+	return m
+}
+
+func TestReconcile(t *testing.T) {
+	messages := []kafka.ConsumedMessage{
+		testMessage("run-1", "1"),
+		testMessage("run-2", "1"),
+		testMessage("run-1", "2"),
+		testMessage("run-1", "2"),
+		testMessage("run-1", "4"),
+		testMessage("run-1", ""),
+		{Value: []byte(`{"id":"5"}`)},
+		{Value: []byte("{}"), Headers: []kafka.Header{{Key: headerRunID, Value: []byte("run-1")}}},
+	}
+	opts := verifyOptions{runID: "run-1", topic: "shop_items", examples: 3}
+	report := reconcile(context.Background(), testConsume(messages, nil), 3, map[string]bool{"1": true, "2": true, "3": true}, opts)
+	assert.Equal(t, verifyReport{
+		Run:         "run-1",
+		Topic:       "shop_items",
+		Messages:    6,
+		Items:       3,
+		Tombstones:  1,
+		Undecodable: 1,
+		Expected:    3,
+		Duplicates:  validationProblem{Count: 1, Examples: []string{"2"}},
+		Missing:     validationProblem{Count: 1, Examples: []string{"3"}},
+		Unexpected:  validationProblem{Count: 1, Examples: []string{"4"}},
+	}, report)
+	assert.True(t, report.failed())
+
+	report = reconcile(context.Background(), testConsume(messages[:3], nil), 2, nil, opts)
+	assert.Equal(t, 2, report.Items)
+	assert.False(t, report.failed())
+
+	report = reconcile(context.Background(), testConsume(messages[:3], errors.New("test error")), -1, nil, opts)
+	assert.Equal(t, "test error", report.Error)
+	assert.True(t, report.failed())
+}
+
+func TestVerifyReportFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		report   verifyReport
+		expected bool
+	}{
+		{"match", verifyReport{Items: 2, Expected: 2}, false},
+		{"unknown expected", verifyReport{Items: 2, Expected: -1}, false},
+		{"count mismatch", verifyReport{Items: 1, Expected: 2}, true},
+		{"duplicates", verifyReport{Expected: -1, Duplicates: validationProblem{Count: 1}}, true},
+		{"missing", verifyReport{Expected: -1, Missing: validationProblem{Count: 1}}, true},
+		{"unexpected", verifyReport{Expected: -1, Unexpected: validationProblem{Count: 1}}, true},
+		{"undecodable", verifyReport{Expected: -1, Undecodable: 1}, true},
+		{"error", verifyReport{Expected: -1, Error: "test error"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.report.failed())
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "runs.json")
+	data, err := json.Marshal([]runlog.Run{{ID: "run-1", Feed: "file://testdata/one_item.xml", Items: 1}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(file, data, 0644))
+	opts, err := parseVerifyArgs([]string{"-k", "localhost:9092", "--runId", "run-1", "--runHistoryFile", file, "-f", "file://testdata/one_item.xml"})
+	require.NoError(t, err)
+	consume := testConsume([]kafka.ConsumedMessage{testMessage("run-1", "34644")}, nil)
+
+	report, err := verify(context.Background(), consume, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Expected)
+	assert.False(t, report.failed())
+
+	opts.expected = 2
+	report, err = verify(context.Background(), consume, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Expected)
+	assert.True(t, report.failed())
+
+	opts.expected, opts.runID = -1, "run-2"
+	_, err = verify(context.Background(), consume, opts)
+	require.Error(t, err)
+	assert.Equal(t, "Run run-2 is not in run log '"+file+"'", err.Error())
+}
+
+func TestWriteVerifyReport(t *testing.T) {
+	report := verifyReport{
+		Run:        "run-1",
+		Topic:      "shop_items",
+		Messages:   4,
+		Items:      3,
+		Tombstones: 1,
+		Expected:   3,
+		Duplicates: validationProblem{Count: 0, Examples: []string{}},
+		Missing:    validationProblem{Count: 1, Examples: []string{"3"}},
+		Unexpected: validationProblem{Count: 0, Examples: []string{}},
+	}
+	var out bytes.Buffer
+	require.NoError(t, writeVerifyReport(&out, report, reportFormatText))
+	assert.Equal(t, `run: run-1
+topic: shop_items
+messages: 4
+items: 3
+tombstones: 1
+expected items: 3
+missing: 1
+  3
+result: mismatch
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeVerifyReport(&out, verifyReport{Run: "run-1", Topic: "shop_items", Expected: -1}, reportFormatJSON))
+	assert.Equal(t, `{"run":"run-1","topic":"shop_items","messages":0,"items":0,"tombstones":0,"undecodable":0,"expected":-1,`+
+		`"duplicates":{"count":0,"examples":null},"missing":{"count":0,"examples":null},"unexpected":{"count":0,"examples":null}}`+"\n", out.String())
+}
+
+func TestParseVerifyArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"defaults", []string{"-k", "localhost:9092", "--runId", "run-1", "--expected", "10"}, ""},
+		{"no kafka", []string{"--runId", "run-1", "--expected", "10"}, "Unable to parse flags: the required flag `-k, --kafkaUrl' was not specified"},
+		{"empty run", []string{"-k", "localhost:9092", "--runId", " ", "--expected", "10"}, "Run id should not be empty"},
+		{"nothing expected", []string{"-k", "localhost:9092", "--runId", "run-1"}, "Expected number of items, run log or feed should be provided"},
+		{"negative expected", []string{"-k", "localhost:9092", "--runId", "run-1", "--expected", "-2"}, "Expected number of items should not be negative"},
+		{"relative feed", []string{"-k", "localhost:9092", "--runId", "run-1", "-f", "feed.xml"}, "Feed url 'feed.xml' should be absolute"},
+		{"unknown format", []string{"-k", "localhost:9092", "--runId", "run-1", "--expected", "10", "--format", "yaml"}, "Unknown format of report 'yaml', supported formats are 'text' and 'json'"},
+		{"zero timeout", []string{"-k", "localhost:9092", "--runId", "run-1", "--expected", "10", "--timeout", "0s"}, "Timeout should be positive"},
+		{"bad security", []string{"-k", "localhost:9092", "--runId", "run-1", "--expected", "10", "--kafkaSecurityProtocol", "tls"}, "Security protocol 'tls' is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseVerifyArgs(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "localhost:9092", opts.kafkaURL)
+			assert.Equal(t, kafka.TopicShopItems, opts.topic)
+			assert.Equal(t, "run-1", opts.runID)
+			assert.Equal(t, 10, opts.expected)
+			assert.Nil(t, opts.feed)
+			assert.Equal(t, 10*time.Minute, opts.timeout)
+		})
+	}
+}
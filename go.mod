@@ -3,12 +3,25 @@ module github.com/grubastik/feeddo
 go 1.14
 
 require (
+	cloud.google.com/go/pubsub v1.8.3
+	cloud.google.com/go/storage v1.12.0
+	github.com/BurntSushi/toml v0.3.1
+	github.com/aws/aws-sdk-go v1.35.37
 	github.com/confluentinc/confluent-kafka-go v1.4.2 // indirect
+	github.com/getsentry/sentry-go v0.8.0
 	github.com/go-chi/chi v4.1.2+incompatible
 	github.com/jessevdk/go-flags v1.4.0
-	github.com/pkg/errors v0.9.1
+	github.com/klauspost/compress v1.9.8
+	github.com/lib/pq v1.10.2
 	github.com/prometheus/client_golang v1.7.1
+	github.com/segmentio/kafka-go v0.4.20
 	github.com/shopspring/decimal v1.2.0
+	github.com/streadway/amqp v1.0.0
 	github.com/stretchr/testify v1.6.1
+	go.opentelemetry.io/otel v0.14.0
+	go.opentelemetry.io/otel/exporters/otlp v0.14.0
+	go.opentelemetry.io/otel/sdk v0.14.0
+	google.golang.org/grpc v1.33.2
 	gopkg.in/confluentinc/confluent-kafka-go.v1 v1.4.2
+	gopkg.in/yaml.v2 v2.2.8
 )
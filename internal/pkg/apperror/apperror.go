@@ -0,0 +1,142 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// CategoryDownload identifies errors of downloading of feeds
+	CategoryDownload = "download"
+	// CategoryParse identifies errors of parsing of feeds
+	CategoryParse = "parse"
+	// CategoryDelivery identifies errors of delivery of items by sinks
+	CategoryDelivery = "delivery"
+	// CategoryConfig identifies errors of configuration, they could not be fixed without restart
+	CategoryConfig = "config"
+	// CategoryOther identifies errors which are not categorized
+	CategoryOther = "other"
+)
+
+// Categories lists all categories of errors
+var Categories = []string{CategoryDownload, CategoryParse, CategoryDelivery, CategoryConfig, CategoryOther}
+
+// DownloadError is returned when feed could not be downloaded
+type DownloadError struct {
+	Err error
+}
+
+func (e *DownloadError) Error() string { return e.Err.Error() }
+func (e *DownloadError) Unwrap() error { return e.Err }
+
+// ParseError is returned when feed could not be parsed
+type ParseError struct {
+	Err error
+	// Position is nil when error could not be located in feed, e.g. when feed could not be decompressed
+	Position *Position
+}
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// DeliveryError is returned when item could not be delivered
+type DeliveryError struct {
+	Err error
+}
+
+func (e *DeliveryError) Error() string { return e.Err.Error() }
+func (e *DeliveryError) Unwrap() error { return e.Err }
+
+// Position locates parse error in feed, so broken item could be found quickly
+type Position struct {
+	// Line of feed reported by XML decoder, it is zero when it is unknown
+	Line int `json:"line,omitempty"`
+	// Offset is number of bytes of decompressed feed preceding token or item which could not be parsed
+	Offset int64 `json:"offset"`
+	// PreviousItemID is ITEM_ID of the last item parsed before error, it is empty when error precedes the first item
+	PreviousItemID string `json:"previousItemId,omitempty"`
+}
+
+func (p Position) String() string {
+	s := fmt.Sprintf("offset %d", p.Offset)
+	if p.Line > 0 {
+		s = fmt.Sprintf("line %d, %s", p.Line, s)
+	}
+	if p.PreviousItemID != "" {
+		s += fmt.Sprintf(", after item '%s'", p.PreviousItemID)
+	}
+	return s
+}
+
+// ParsePosition returns position of the outermost parse error in chain of err, nil is returned when it is unknown
+func ParsePosition(err error) *Position {
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return pe.Position
+	}
+	return nil
+}
+
+// ConfigError is returned when app is not configured properly
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string { return e.Err.Error() }
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// ContextError attaches feed, run of feed and item, which were processed when error happened, to error
+type ContextError struct {
+	Feed   string
+	RunID  string
+	ItemID string
+	Err    error
+}
+
+func (e *ContextError) Error() string { return e.Err.Error() }
+func (e *ContextError) Unwrap() error { return e.Err }
+
+// Context returns feed and item attached to err, they are empty when err has no context
+func Context(err error) (feed, itemID string) {
+	var ce *ContextError
+	if errors.As(err, &ce) {
+		return ce.Feed, ce.ItemID
+	}
+	return "", ""
+}
+
+// RunID returns id of run of feed attached to err, it is empty when err has no context or it is not related to single run
+func RunID(err error) string {
+	var ce *ContextError
+	if errors.As(err, &ce) {
+		return ce.RunID
+	}
+	return ""
+}
+
+// Category returns category of the outermost typed error in chain of err
+func Category(err error) string {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch err.(type) {
+		case *DownloadError:
+			return CategoryDownload
+		case *ParseError:
+			return CategoryParse
+		case *DeliveryError:
+			return CategoryDelivery
+		case *ConfigError:
+			return CategoryConfig
+		}
+	}
+	return CategoryOther
+}
+
+// Retryable returns true when operation failed with err could succeed if it is repeated later,
+// e.g. during next run of feed. Configuration and not categorized errors are not retryable.
+func Retryable(err error) bool {
+	switch Category(err) {
+	case CategoryDownload, CategoryParse, CategoryDelivery:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategory(t *testing.T) {
+	base := errors.New("test error")
+	tests := []struct {
+		name      string
+		err       error
+		category  string
+		retryable bool
+	}{
+		{"nil", nil, CategoryOther, false},
+		{"not categorized", base, CategoryOther, false},
+		{"download", &DownloadError{Err: base}, CategoryDownload, true},
+		{"parse", &ParseError{Err: base}, CategoryParse, true},
+		{"delivery", &DeliveryError{Err: base}, CategoryDelivery, true},
+		{"config", &ConfigError{Err: base}, CategoryConfig, false},
+		{"wrapped", fmt.Errorf("Failed to get stream: %w", &DownloadError{Err: base}), CategoryDownload, true},
+		{"outermost category", &ConfigError{Err: fmt.Errorf("Failed to check topics: %w", &DeliveryError{Err: base})}, CategoryConfig, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.category, Category(tt.err))
+			assert.Equal(t, tt.retryable, Retryable(tt.err))
+			if tt.err != nil {
+				// message is not changed and original error is available
+				assert.True(t, strings.HasSuffix(tt.err.Error(), "test error"))
+				assert.True(t, errors.Is(tt.err, base))
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	base := &DeliveryError{Err: errors.New("test error")}
+	tests := []struct {
+		name   string
+		err    error
+		feed   string
+		runID  string
+		itemID string
+	}{
+		{"without context", base, "", "", ""},
+		{"feed", &ContextError{Feed: "http://test.org", Err: base}, "http://test.org", "", ""},
+		{"run", &ContextError{Feed: "http://test.org", RunID: "run", Err: base}, "http://test.org", "run", ""},
+		{"wrapped item", fmt.Errorf("Periodic feeds processing failed: %w", &ContextError{Feed: "http://test.org", ItemID: "1", Err: base}), "http://test.org", "", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, itemID := Context(tt.err)
+			assert.Equal(t, tt.feed, feed)
+			assert.Equal(t, tt.itemID, itemID)
+			assert.Equal(t, tt.runID, RunID(tt.err))
+			// context does not hide category
+			assert.Equal(t, CategoryDelivery, Category(tt.err))
+		})
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	pos := &Position{Line: 12, Offset: 3456, PreviousItemID: "34644"}
+	tests := []struct {
+		name     string
+		err      error
+		position *Position
+		text     string
+	}{
+		{"not parse error", &DeliveryError{Err: errors.New("test error")}, nil, ""},
+		{"unknown position", &ParseError{Err: errors.New("test error")}, nil, ""},
+		{"wrapped", &ContextError{Feed: "http://test.org", Err: fmt.Errorf("Failed to process feed: %w", &ParseError{Err: errors.New("test error"), Position: pos})}, pos, "line 12, offset 3456, after item '34644'"},
+		{"before the first item", &ParseError{Err: errors.New("test error"), Position: &Position{Offset: 10}}, &Position{Offset: 10}, "offset 10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ParsePosition(tt.err)
+			assert.Equal(t, tt.position, p)
+			if p != nil {
+				assert.Equal(t, tt.text, p.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Cluster describes additional kafka cluster where all items are produced as well (e.g. during migration)
+type Cluster struct {
+	Name     string
+	Address  string
+	Security Security
+}
+
+// ParseCluster parses cluster in format "<name>@<bootstrap servers>[?<security settings>]".
+// Supported security settings: securityProtocol, saslMechanism, saslUsername, saslPassword, caCert, clientCert, clientKey,
+// e.g. "cloud@broker1:9092,broker2:9092?securityProtocol=sasl_ssl&saslMechanism=PLAIN&saslUsername=user&saslPassword=secret"
+func ParseCluster(s string) (Cluster, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.SplitN(s, "@", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return Cluster{}, fmt.Errorf("Cluster should have format '<name>@<bootstrap servers>'")
+	}
+	c := Cluster{Name: parts[0]}
+	addr := parts[1]
+	if i := strings.Index(addr, "?"); i >= 0 {
+		values, err := url.ParseQuery(addr[i+1:])
+		if err != nil {
+			return Cluster{}, fmt.Errorf("Unable to parse settings of cluster '%s' because of %w", c.Name, err)
+		}
+		for k := range values {
+			switch k {
+			case "securityProtocol":
+				c.Security.Protocol = values.Get(k)
+			case "saslMechanism":
+				c.Security.SASLMechanism = values.Get(k)
+			case "saslUsername":
+				c.Security.SASLUsername = values.Get(k)
+			case "saslPassword":
+				c.Security.SASLPassword = values.Get(k)
+			case "caCert":
+				c.Security.CALocation = values.Get(k)
+			case "clientCert":
+				c.Security.CertLocation = values.Get(k)
+			case "clientKey":
+				c.Security.KeyLocation = values.Get(k)
+			default:
+				return Cluster{}, fmt.Errorf("Setting '%s' of cluster '%s' is not supported", k, c.Name)
+			}
+		}
+		addr = addr[:i]
+	}
+	if addr == "" {
+		return Cluster{}, fmt.Errorf("Bootstrap servers of cluster '%s' were not provided", c.Name)
+	}
+	c.Address = addr
+	err := c.Security.Validate()
+	if err != nil {
+		return Cluster{}, fmt.Errorf("Wrong security settings of cluster '%s': %w", c.Name, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCluster(t *testing.T) {
+	tests := []struct {
+		name     string
+		cluster  string
+		err      string
+		expected Cluster
+	}{
+		{"without name", "broker:9092", "Cluster should have format '<name>@<bootstrap servers>'", Cluster{}},
+		{"empty name", "@broker:9092", "Cluster should have format '<name>@<bootstrap servers>'", Cluster{}},
+		{"without servers", "cloud@?securityProtocol=ssl", "Bootstrap servers of cluster 'cloud' were not provided", Cluster{}},
+		{"unknown setting", "cloud@broker:9092?abc=1", "Setting 'abc' of cluster 'cloud' is not supported", Cluster{}},
+		{"wrong security", "cloud@broker:9092?saslMechanism=PLAIN", "Wrong security settings of cluster 'cloud': SASL mechanism 'PLAIN' requires username and password", Cluster{}},
+		{"plaintext", "onprem@broker1:9092,broker2:9092", "", Cluster{Name: "onprem", Address: "broker1:9092,broker2:9092"}},
+		{
+			"sasl",
+			"cloud@broker:9092?securityProtocol=sasl_ssl&saslMechanism=PLAIN&saslUsername=user&saslPassword=p%26ss",
+			"",
+			Cluster{Name: "cloud", Address: "broker:9092", Security: Security{Protocol: "sasl_ssl", SASLMechanism: "PLAIN", SASLUsername: "user", SASLPassword: "p&ss"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCluster(tt.cluster)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, c)
+			}
+		})
+	}
+}
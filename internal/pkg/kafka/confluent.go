@@ -0,0 +1,279 @@
+//go:build cgo && !nocgo
+// +build cgo,!nocgo
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// confluentProducer adapts librdkafka based producer to ProducerProvider
+type confluentProducer struct {
+	producer       *kafka.Producer
+	events         chan Event
+	partitionCache partitionCache
+	// totals of transmitted messages from the last statistics of librdkafka
+	txMessages int64
+	txBytes    int64
+}
+
+// librdkafkaStats is part of statistics emitted by librdkafka,
+// see https://github.com/edenhill/librdkafka/blob/master/STATISTICS.md
+type librdkafkaStats struct {
+	TxMessages int64 `json:"txmsgs"`
+	TxBytes    int64 `json:"txmsg_bytes"`
+}
+
+// newConfluentProducer creates librdkafka producer. Connection is established in background.
+func newConfluentProducer(addr string, security Security, batch Batch) (*confluentProducer, error) {
+	// all options could be found here https://docs.confluent.io/5.5.0/clients/librdkafka/md_CONFIGURATION.html
+	cm := kafka.ConfigMap{
+		"bootstrap.servers":              addr,
+		"socket.timeout.ms":              timeoutMs,
+		"request.timeout.ms":             timeoutMs,
+		"message.timeout.ms":             timeoutMs,
+		"delivery.timeout.ms":            timeoutMs,
+		"metadata.request.timeout.ms":    timeoutMs,
+		"api.version.request.timeout.ms": timeoutMs,
+		"transaction.timeout.ms":         timeoutMs,
+		"socket.keepalive.enable":        true,
+		// messages are produced asynchronously - give librdkafka chance to batch them
+		"linger.ms": batch.linger(),
+		// statistics are used for metrics of transmitted messages
+		"statistics.interval.ms": statsIntervalMs,
+	}
+	if batch.Size > 0 {
+		cm["batch.num.messages"] = batch.Size
+	}
+	err := security.apply(cm)
+	if err != nil {
+		return nil, err
+	}
+	p, err := kafka.NewProducer(&cm)
+	if err != nil {
+		return nil, err
+	}
+	c := &confluentProducer{producer: p, events: make(chan Event, 1)}
+	go c.forwardEvents()
+	return c, nil
+}
+
+// Produce converts message and produces it. Original message is passed as opaque
+// and is returned in delivery report.
+func (c *confluentProducer) Produce(m *Message, deliveryChan chan Event) error {
+	km := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &m.Topic,
+			Partition: m.Partition,
+		},
+		Key:    m.Key,
+		Value:  m.Value,
+		Opaque: m,
+	}
+	for _, h := range m.Headers {
+		km.Headers = append(km.Headers, kafka.Header{Key: h.Key, Value: h.Value})
+	}
+	var kafkaDeliveryChan chan kafka.Event
+	if deliveryChan != nil {
+		kafkaDeliveryChan = make(chan kafka.Event, 1)
+	}
+	err := c.producer.Produce(km, kafkaDeliveryChan)
+	if err != nil {
+		if ke, ok := err.(kafka.Error); ok && ke.Code() == kafka.ErrQueueFull {
+			return ErrQueueFull
+		}
+		return err
+	}
+	if deliveryChan != nil {
+		go func() {
+			deliveryChan <- convertEvent(<-kafkaDeliveryChan)
+		}()
+	}
+	return nil
+}
+
+// forwardEvents converts producer events until producer will be closed
+func (c *confluentProducer) forwardEvents() {
+	defer close(c.events)
+	for e := range c.producer.Events() {
+		if st, ok := e.(*kafka.Stats); ok {
+			c.updateStats(st.String())
+			continue
+		}
+		if ev := convertEvent(e); ev != nil {
+			c.events <- ev
+		}
+	}
+}
+
+// updateStats stores totals from statistics of librdkafka, invalid statistics are skipped
+func (c *confluentProducer) updateStats(raw string) {
+	var st librdkafkaStats
+	if err := json.Unmarshal([]byte(raw), &st); err != nil {
+		return
+	}
+	atomic.StoreInt64(&c.txMessages, st.TxMessages)
+	atomic.StoreInt64(&c.txBytes, st.TxBytes)
+}
+
+// QueueStats returns number of messages in librdkafka queue and totals from the last statistics
+func (c *confluentProducer) QueueStats() QueueStats {
+	return QueueStats{
+		Messages:   c.producer.Len(),
+		TxMessages: atomic.LoadInt64(&c.txMessages),
+		TxBytes:    atomic.LoadInt64(&c.txBytes),
+	}
+}
+
+// convertEvent returns delivery report or error. Other events are skipped (nil returned).
+func convertEvent(e kafka.Event) Event {
+	switch ev := e.(type) {
+	case *kafka.Message:
+		m, ok := ev.Opaque.(*Message)
+		if !ok {
+			return nil
+		}
+		m.Err = ev.TopicPartition.Error
+		m.Partition = ev.TopicPartition.Partition
+		m.Offset = int64(ev.TopicPartition.Offset)
+		if m.Offset < 0 {
+			// librdkafka uses special negative offsets (e.g. OffsetInvalid)
+			m.Offset = OffsetUnknown
+		}
+		m.Timestamp = ev.Timestamp
+		return m
+	case kafka.Error:
+		return ev
+	}
+	return nil
+}
+
+func (c *confluentProducer) Events() chan Event {
+	return c.events
+}
+
+func (c *confluentProducer) Flush(timeoutMs int) int {
+	return c.producer.Flush(timeoutMs)
+}
+
+func (c *confluentProducer) Close() {
+	c.producer.Close()
+}
+
+// apply sets security options into librdkafka config
+func (s Security) apply(cm kafka.ConfigMap) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	options := map[string]string{
+		"security.protocol":        strings.ToLower(s.Protocol),
+		"sasl.mechanism":           strings.ToUpper(s.SASLMechanism),
+		"sasl.username":            s.SASLUsername,
+		"sasl.password":            s.SASLPassword,
+		"ssl.ca.location":          s.CALocation,
+		"ssl.certificate.location": s.CertLocation,
+		"ssl.key.location":         s.KeyLocation,
+	}
+	for k, v := range options {
+		if v == "" {
+			continue
+		}
+		err := cm.SetKey(k, v)
+		if err != nil {
+			return fmt.Errorf("Unable to set '%s': %w", k, err)
+		}
+	}
+	return nil
+}
+
+func (c *confluentProducer) partitions(topic string) (int, error) {
+	return c.partitionCache.get(topic, func() (int, error) {
+		md, err := c.producer.GetMetadata(&topic, false, timeoutMs)
+		if err != nil {
+			return 0, err
+		}
+		t, ok := md.Topics[topic]
+		if !ok {
+			return 0, fmt.Errorf("Topic %s does not exist", topic)
+		}
+		if t.Error.Code() != kafka.ErrNoError {
+			return 0, t.Error
+		}
+		return len(t.Partitions), nil
+	})
+}
+
+func (c *confluentProducer) missingTopics(ctx context.Context, topics []string) ([]string, error) {
+	admin, err := kafka.NewAdminClientFromProducer(c.producer)
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+	// only existing topics are returned, metadata request for single topic could create it on broker
+	md, err := admin.GetMetadata(nil, true, timeoutMs)
+	if err != nil {
+		return nil, err
+	}
+	missing := []string{}
+	for _, t := range topics {
+		if _, ok := md.Topics[t]; !ok {
+			missing = append(missing, t)
+		}
+	}
+	return missing, nil
+}
+
+func (c *confluentProducer) createTopics(ctx context.Context, topics []string, spec TopicSpec) error {
+	admin, err := kafka.NewAdminClientFromProducer(c.producer)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	specs := make([]kafka.TopicSpecification, 0, len(topics))
+	for _, t := range topics {
+		specs = append(specs, kafka.TopicSpecification{Topic: t, NumPartitions: spec.Partitions, ReplicationFactor: spec.ReplicationFactor})
+	}
+	results, err := admin.CreateTopics(ctx, specs, kafka.SetAdminOperationTimeout(timeoutMs*time.Millisecond))
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		// topic could be created by another instance in the meantime
+		if code := r.Error.Code(); code != kafka.ErrNoError && code != kafka.ErrTopicAlreadyExists {
+			return fmt.Errorf("%s: %w", r.Topic, r.Error)
+		}
+	}
+	return nil
+}
+
+// retriableCodes contains errors which are expected to disappear after some time
+// e.g. during leader election or temporary network problems
+var retriableCodes = map[kafka.ErrorCode]bool{
+	kafka.ErrTransport:                    true,
+	kafka.ErrMsgTimedOut:                  true,
+	kafka.ErrAllBrokersDown:               true,
+	kafka.ErrTimedOut:                     true,
+	kafka.ErrLeaderNotAvailable:           true,
+	kafka.ErrNotLeaderForPartition:        true,
+	kafka.ErrRequestTimedOut:              true,
+	kafka.ErrNetworkException:             true,
+	kafka.ErrNotEnoughReplicas:            true,
+	kafka.ErrNotEnoughReplicasAfterAppend: true,
+}
+
+// confluentRetriable checks error of librdkafka, ok is false for other errors
+func confluentRetriable(err error) (retriable, ok bool) {
+	var ke kafka.Error
+	if !errors.As(err, &ke) {
+		return false, false
+	}
+	return !ke.IsFatal() && (ke.IsRetriable() || retriableCodes[ke.Code()]), true
+}
@@ -0,0 +1,18 @@
+//go:build !cgo || nocgo
+// +build !cgo nocgo
+
+package kafka
+
+import (
+	"fmt"
+)
+
+// newConfluentProducer reports that librdkafka based driver is not available, it requires build with cgo
+func newConfluentProducer(addr string, security Security, batch Batch) (ProducerProvider, error) {
+	return nil, fmt.Errorf("Kafka driver '%s' is not compiled in (it requires cgo), use '%s'", DriverConfluent, DriverKafkaGo)
+}
+
+// confluentRetriable does not recognize any error without librdkafka
+func confluentRetriable(err error) (retriable, ok bool) {
+	return false, false
+}
@@ -0,0 +1,18 @@
+//go:build !cgo || nocgo
+// +build !cgo nocgo
+
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfluentNotCompiled(t *testing.T) {
+	_, err := NewKafkaProducer(context.Background(), Config{Address: "localhost:9092", Driver: DriverConfluent})
+	require.Error(t, err)
+	assert.Equal(t, "Unable to init connection to Kafka: Kafka driver 'confluent' is not compiled in (it requires cgo), use 'kafka-go'", err.Error())
+}
@@ -0,0 +1,87 @@
+//go:build cgo && !nocgo
+// +build cgo,!nocgo
+
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+func TestConfluentRetriable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retriable bool
+		ok        bool
+	}{
+		{"not kafka error", errors.New("test"), false, false},
+		{"retriable code", kafka.NewError(kafka.ErrLeaderNotAvailable, "test", false), true, true},
+		{"wrapped retriable code", fmt.Errorf("wrapped: %w", kafka.NewError(kafka.ErrMsgTimedOut, "test", false)), true, true},
+		{"fatal", kafka.NewError(kafka.ErrLeaderNotAvailable, "test", true), false, true},
+		{"not retriable code", kafka.NewError(kafka.ErrMsgSizeTooLarge, "test", false), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retriable, ok := confluentRetriable(tt.err)
+			assert.Equal(t, tt.retriable, retriable)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.retriable, isRetriable(tt.err))
+		})
+	}
+}
+
+func TestSecurityApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		security Security
+		err      string
+		expected kafka.ConfigMap
+	}{
+		{"empty", Security{}, "", kafka.ConfigMap{}},
+		{"wrong protocol", Security{Protocol: "abc"}, "Security protocol 'abc' is not supported", nil},
+		{"wrong mechanism", Security{SASLMechanism: "abc"}, "SASL mechanism 'abc' is not supported", nil},
+		{"no password", Security{SASLMechanism: "PLAIN", SASLUsername: "user"}, "SASL mechanism 'PLAIN' requires username and password", nil},
+		{"no key", Security{CertLocation: "/cert.pem"}, "Client certificate and key should be provided together", nil},
+		{
+			"sasl ssl",
+			Security{Protocol: "SASL_SSL", SASLMechanism: "scram-sha-512", SASLUsername: "user", SASLPassword: "pass", CALocation: "/ca.pem"},
+			"",
+			kafka.ConfigMap{
+				"security.protocol": "sasl_ssl",
+				"sasl.mechanism":    "SCRAM-SHA-512",
+				"sasl.username":     "user",
+				"sasl.password":     "pass",
+				"ssl.ca.location":   "/ca.pem",
+			},
+		},
+		{
+			"mutual tls",
+			Security{Protocol: "ssl", CertLocation: "/cert.pem", KeyLocation: "/key.pem"},
+			"",
+			kafka.ConfigMap{
+				"security.protocol":        "ssl",
+				"ssl.certificate.location": "/cert.pem",
+				"ssl.key.location":         "/key.pem",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := kafka.ConfigMap{}
+			err := tt.security.apply(cm)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, cm)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// ConsumedMessage is message read from topic
+type ConsumedMessage struct {
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+}
+
+// Consume reads messages which are in topic when it is called, from the oldest ones, by pure go client and calls fn for every of them.
+// Partitions are read one by one without consumer group, so no offsets are committed and topic could be read repeatedly.
+// Reading stops on the first error of fn.
+func Consume(ctx context.Context, addr, topic string, security Security, fn func(m ConsumedMessage) error) error {
+	err := security.Validate()
+	if err != nil {
+		return err
+	}
+	transport := &kafkago.Transport{DialTimeout: timeoutMs * time.Millisecond}
+	dialer := &kafkago.Dialer{Timeout: timeoutMs * time.Millisecond}
+	transport.TLS, err = security.tlsConfig()
+	if err != nil {
+		return err
+	}
+	dialer.TLS = transport.TLS
+	transport.SASL, err = security.saslMechanism()
+	if err != nil {
+		return err
+	}
+	dialer.SASLMechanism = transport.SASL
+	brokers := strings.Split(addr, ",")
+	client := &kafkago.Client{Addr: kafkago.TCP(brokers...), Timeout: timeoutMs * time.Millisecond, Transport: transport}
+	offsets, err := partitionOffsets(ctx, client, topic)
+	if err != nil {
+		return fmt.Errorf("Unable to get offsets of topic '%s' because of %w", topic, err)
+	}
+	for _, po := range offsets {
+		if po.FirstOffset >= po.LastOffset {
+			continue
+		}
+		reader := kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers:   brokers,
+			Topic:     topic,
+			Partition: po.Partition,
+			Dialer:    dialer,
+			MaxWait:   time.Second,
+		})
+		err := consumePartition(ctx, reader, po, fn)
+		if errC := reader.Close(); err == nil && errC != nil {
+			err = fmt.Errorf("Unable to close reader of partition %d because of %w", po.Partition, errC)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionOffsets returns the first and the next to be written offsets of partitions of topic ordered by partition
+func partitionOffsets(ctx context.Context, client *kafkago.Client, topic string) ([]kafkago.PartitionOffsets, error) {
+	md, err := client.Metadata(ctx, &kafkago.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	requests := []kafkago.OffsetRequest{}
+	for _, t := range md.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, t.Error
+		}
+		for _, p := range t.Partitions {
+			requests = append(requests, kafkago.FirstOffsetOf(p.ID), kafkago.LastOffsetOf(p.ID))
+		}
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("Topic has no partitions")
+	}
+	res, err := client.ListOffsets(ctx, &kafkago.ListOffsetsRequest{Topics: map[string][]kafkago.OffsetRequest{topic: requests}})
+	if err != nil {
+		return nil, err
+	}
+	offsets := res.Topics[topic]
+	for _, po := range offsets {
+		if po.Error != nil {
+			return nil, fmt.Errorf("Partition %d: %w", po.Partition, po.Error)
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i].Partition < offsets[j].Partition })
+	return offsets, nil
+}
+
+// consumePartition reads messages of partition until offset which was the last one when reading started
+func consumePartition(ctx context.Context, reader *kafkago.Reader, po kafkago.PartitionOffsets, fn func(m ConsumedMessage) error) error {
+	if err := reader.SetOffset(po.FirstOffset); err != nil {
+		return fmt.Errorf("Unable to set offset of partition %d because of %w", po.Partition, err)
+	}
+	for {
+		m, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("Unable to read partition %d because of %w", po.Partition, err)
+		}
+		headers := make([]Header, 0, len(m.Headers))
+		for _, h := range m.Headers {
+			headers = append(headers, Header{Key: h.Key, Value: h.Value})
+		}
+		err = fn(ConsumedMessage{Partition: m.Partition, Offset: m.Offset, Key: m.Key, Value: m.Value, Headers: headers})
+		if err != nil {
+			return err
+		}
+		if m.Offset+1 >= po.LastOffset {
+			return nil
+		}
+	}
+}
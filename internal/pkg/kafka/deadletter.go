@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	// HeaderError header of dead letter message which contains delivery error
+	HeaderError = "feeddo-error"
+	// HeaderTopic header of dead letter message which contains original topic
+	HeaderTopic = "feeddo-topic"
+	// HeaderContext header of dead letter message which contains item context (feed)
+	HeaderContext = "feeddo-context"
+	// HeaderCluster header of dead letter message which contains cluster where item was not delivered
+	HeaderCluster = "feeddo-cluster"
+)
+
+// DeadLetter stores payloads which were not delivered, so they could be investigated and resent later
+type DeadLetter interface {
+	Put(item Itemer, cluster, topic string, key, payload []byte, err error) error
+	Close() error
+}
+
+// topicDeadLetter sends failed payloads into separate kafka topic of primary cluster with error metadata in headers
+type topicDeadLetter struct {
+	producer *Producer
+	topic    string
+}
+
+func (d topicDeadLetter) Put(item Itemer, cluster, topic string, key, payload []byte, err error) error {
+	headers := []Header{
+		{Key: HeaderError, Value: []byte(err.Error())},
+		{Key: HeaderTopic, Value: []byte(topic)},
+		{Key: HeaderContext, Value: []byte(item.GetContext())},
+		{Key: HeaderCluster, Value: []byte(cluster)},
+	}
+	return d.producer.sendMessageToKafka(d.topic, key, payload, headers)
+}
+
+func (d topicDeadLetter) Close() error { return nil }
+
+// fileRecord is a single line of dead letter file
+type fileRecord struct {
+	Context string          `json:"context"`
+	ID      string          `json:"id"`
+	Cluster string          `json:"cluster"`
+	Topic   string          `json:"topic"`
+	Error   string          `json:"error"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// fileDeadLetter appends failed payloads as json lines into local file
+type fileDeadLetter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// newFileDeadLetter opens file for appending dead letters
+func newFileDeadLetter(path string) (*fileDeadLetter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open dead letter file: %w", err)
+	}
+	return &fileDeadLetter{file: f}, nil
+}
+
+func (d *fileDeadLetter) Put(item Itemer, cluster, topic string, key, payload []byte, err error) error {
+	r := fileRecord{Context: item.GetContext(), ID: item.GetID(), Cluster: cluster, Topic: topic, Error: err.Error()}
+	// payload is not always a valid json (e.g. failed marshaling)
+	if json.Valid(payload) {
+		r.Payload = payload
+	}
+	line, errM := json.Marshal(r)
+	if errM != nil {
+		return fmt.Errorf("Failed to marshal dead letter: %w", errM)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, errW := d.file.Write(append(line, '\n'))
+	if errW != nil {
+		return fmt.Errorf("Failed to write dead letter: %w", errW)
+	}
+	return nil
+}
+
+func (d *fileDeadLetter) Close() error {
+	return d.file.Close()
+}
@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// producerRecorder delivers all messages and keeps them for inspection
+type producerRecorder struct {
+	messages []*Message
+}
+
+func (pp *producerRecorder) Produce(m *Message, c chan Event) error {
+	pp.messages = append(pp.messages, m)
+	go func() {
+		c <- m
+	}()
+	return nil
+}
+func (pp *producerRecorder) Events() chan Event { return nil }
+func (pp *producerRecorder) Flush(int) int      { return 0 }
+func (pp *producerRecorder) Close()             {}
+
+func TestTopicDeadLetter(t *testing.T) {
+	recorder := &producerRecorder{}
+	p := &Producer{kafkaProducer: recorder}
+	p.deadLetter = topicDeadLetter{producer: p, topic: "dlq"}
+	err := p.deadLetter.Put(ItemTest{}, "cloud", "shop_items", []byte("key"), []byte("payload"), errors.New("test error"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(recorder.messages))
+	m := recorder.messages[0]
+	assert.Equal(t, "dlq", m.Topic)
+	assert.Equal(t, []byte("key"), m.Key)
+	assert.Equal(t, []byte("payload"), m.Value)
+	assert.Equal(t, []Header{
+		{Key: HeaderError, Value: []byte("test error")},
+		{Key: HeaderTopic, Value: []byte("shop_items")},
+		{Key: HeaderContext, Value: []byte("testContext")},
+		{Key: HeaderCluster, Value: []byte("cloud")},
+	}, m.Headers)
+}
+
+func TestFileDeadLetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deadletter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dlq.json")
+
+	p := Producer{kafkaProducer: producerError(), chanRes: make(chan Result, 2)}
+	p.deadLetter, err = newFileDeadLetter(path)
+	require.NoError(t, err)
+	// both items fail before they are sent to kafka, so results are reported immediately
+	p.produceItem(ItemTest{})
+	p.produceItem(ItemMarshalErrorTest{})
+	for i := 0; i < 2; i++ {
+		res := <-p.chanRes
+		require.Error(t, res.Err)
+		assert.True(t, res.DeadLettered)
+	}
+	p.Close()
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"context":"testContext","id":"testID","cluster":"primary","topic":"shop_items","error":"Failed to send message to topic shop_items because of: Send message to kafka failed because of test error"}
+{"context":"testContext","id":"testID","cluster":"primary","topic":"shop_items","error":"Failed to marshal json: Test error"}
+`, string(content))
+}
+
+func TestFileDeadLetterOpenError(t *testing.T) {
+	_, err := newFileDeadLetter("/not/existing/dir/dlq.json")
+	require.Error(t, err)
+	assert.Equal(t, "Unable to open dead letter file: open /not/existing/dir/dlq.json: no such file or directory", err.Error())
+}
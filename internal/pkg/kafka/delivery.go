@@ -0,0 +1,118 @@
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// delivery tracks processing of single item which is produced into several topics.
+// It is passed as message opaque and used to correlate delivery reports with items.
+type delivery struct {
+	mu      sync.Mutex
+	res     Result
+	cluster cluster
+	item    Itemer
+	key     []byte
+	message []byte
+	headers []Header
+	// partKey is used for choosing partition of message
+	partKey []byte
+	// reply receives result instead of results of pool when item is sent synchronously
+	reply     chan Result
+	topics    []string
+	remaining int
+	// when item was produced for the first time
+	started time.Time
+	// topics where message was not delivered
+	failed []string
+	// number of retries per topic
+	attempts map[string]int
+	// span of producing, it is ended when item is reported
+	span trace.Span
+	// finished is set when all topics were processed or delivery timed out, later results are ignored
+	finished bool
+	// timer expires delivery, it is nil when delivery is not limited in time
+	timer *time.Timer
+}
+
+// done registers delivery result for topic and returns true when all topics were processed.
+// First error is reported as item error. Results which come after delivery was finished are ignored.
+func (d *delivery) done(tr TopicResult) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.finished {
+		return false
+	}
+	if tr.Err != nil {
+		if d.res.Err == nil {
+			d.res.Err = &apperror.DeliveryError{Err: fmt.Errorf("Failed to send message to topic %s because of: %w", tr.Topic, tr.Err)}
+		}
+		d.failed = append(d.failed, tr.Topic)
+	}
+	tr.Latency = time.Since(d.started)
+	d.res.Topics = append(d.res.Topics, tr)
+	d.remaining--
+	if d.remaining > 0 {
+		return false
+	}
+	d.finished = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	return true
+}
+
+// expireAfter calls fn when delivery is not finished in timeout
+func (d *delivery) expireAfter(timeout time.Duration, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timer = time.AfterFunc(timeout, fn)
+}
+
+// expire completes topics which were not processed yet with error and returns false when delivery is already finished
+func (d *delivery) expire(err error) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.finished {
+		return false
+	}
+	processed := make(map[string]bool, len(d.res.Topics))
+	for _, tr := range d.res.Topics {
+		processed[tr.Topic] = true
+	}
+	for _, topic := range d.topics {
+		if processed[topic] {
+			continue
+		}
+		if d.res.Err == nil {
+			d.res.Err = &apperror.DeliveryError{Err: fmt.Errorf("Failed to send message to topic %s because of: %w", topic, err)}
+		}
+		d.failed = append(d.failed, topic)
+		d.res.Topics = append(d.res.Topics, TopicResult{Topic: topic, Partition: PartitionAny, Offset: OffsetUnknown, Err: err, Latency: time.Since(d.started)})
+	}
+	d.remaining = 0
+	d.finished = true
+	return true
+}
+
+// isFinished returns true when all topics were processed or delivery timed out
+func (d *delivery) isFinished() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.finished
+}
+
+// nextAttempt increments and returns number of retries for topic
+func (d *delivery) nextAttempt(topic string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.attempts == nil {
+		d.attempts = make(map[string]int)
+	}
+	d.attempts[topic]++
+	return d.attempts[topic]
+}
@@ -0,0 +1,883 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/grubastik/feeddo/internal/pkg/tracing"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TopicShopItems default topic for all items
+	TopicShopItems = "shop_items"
+	// TopicShopItemsBidding default topic for items with bidding set and greater than zero
+	TopicShopItemsBidding = "shop_items_bidding"
+)
+
+const (
+	// DriverConfluent librdkafka based client (requires cgo)
+	DriverConfluent = "confluent"
+	// DriverKafkaGo pure go client
+	DriverKafkaGo = "kafka-go"
+	// ClusterPrimary name of the cluster configured by Address and Security of Config
+	ClusterPrimary = "primary"
+
+	// flushTimeoutMs how long to wait for outstanding messages when pool is stopped
+	flushTimeoutMs = 10000
+	// queueFullWaitMs how long to wait for queue to be drained when it is full
+	queueFullWaitMs = 100
+	// sendsBuffer is size of channel of delivery reports of messages sent synchronously
+	sendsBuffer = 16
+	// timeout used for all network operations with kafka
+	timeoutMs = 5000
+	// how long messages are collected into batch before sending by default
+	lingerMs = 5
+	// how often client reports its internal statistics
+	statsIntervalMs = 5000
+)
+
+// SinkName is name under which kafka producer is registered as sink
+const SinkName = "kafka"
+
+func init() {
+	sink.Register(SinkName, func(ctx context.Context, config interface{}) (sink.Sink, error) {
+		c, ok := config.(Config)
+		if !ok {
+			return nil, sink.ConfigTypeError(SinkName, Config{}, config)
+		}
+		p, err := NewKafkaProducer(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	})
+}
+
+// ErrPayloadTooLarge is reported for items which exceed max payload size
+var ErrPayloadTooLarge = errors.New("Payload is too large")
+
+// ErrDeliveryTimeout is reported for messages which delivery was not reported within delivery timeout
+var ErrDeliveryTimeout = errors.New("Delivery to kafka timed out")
+
+// ErrQueueFull should be returned by ProducerProvider when message could not be accepted because local queue is full
+var ErrQueueFull = errors.New("Producer queue is full")
+
+// ErrProducerClosed is returned by ProducerProvider when message is produced after producer started closing
+var ErrProducerClosed = errors.New("Producer is closed")
+
+const (
+	// KeyStrategyNone messages are produced without key
+	KeyStrategyNone KeyStrategy = "none"
+	// KeyStrategyID item id is used as message key
+	KeyStrategyID KeyStrategy = "id"
+	// KeyStrategyFeedID item id prefixed with item context (feed) is used as message key
+	KeyStrategyFeedID KeyStrategy = "feed-id"
+)
+
+const (
+	// OperationEnqueue putting of message into client queue, waiting for free space in queue included
+	OperationEnqueue = "enqueue"
+	// OperationSend synchronous sending of message till its delivery report
+	OperationSend = "send"
+)
+
+// LatencyObserver is notified about duration of produce operation of message into topic of cluster
+type LatencyObserver func(cluster, topic, operation string, latency time.Duration)
+
+// KeyStrategy defines how message key is built from item
+type KeyStrategy string
+
+// ParseKeyStrategy validates provided strategy name
+func ParseKeyStrategy(s string) (KeyStrategy, error) {
+	switch ks := KeyStrategy(s); ks {
+	case KeyStrategyNone, KeyStrategyID, KeyStrategyFeedID:
+		return ks, nil
+	}
+	return "", fmt.Errorf("Key strategy '%s' is not supported", s)
+}
+
+// key returns message key for item. Empty strategy is handled as KeyStrategyID.
+func (ks KeyStrategy) key(item Itemer) []byte {
+	switch ks {
+	case KeyStrategyNone:
+		return nil
+	case KeyStrategyFeedID:
+		return []byte(item.GetContext() + ":" + item.GetID())
+	default:
+		return []byte(item.GetID())
+	}
+}
+
+// Message is kafka message independent from client implementation
+type Message struct {
+	Topic string
+	// Partition of topic, PartitionAny lets client choose it. Zero value is the first partition.
+	Partition int32
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+	// Opaque is not sent to kafka and returned back in delivery report
+	Opaque interface{}
+	// Err is set in delivery report when message was not delivered
+	Err error
+	// Offset and Timestamp are set in delivery report together with Partition where message was written.
+	// OffsetUnknown is set when client was not able to report them.
+	Offset    int64
+	Timestamp time.Time
+}
+
+// Header is kafka message header
+type Header = sink.Header
+
+// Event is either delivery report (*Message) or error which is not related to any message
+type Event interface{}
+
+// ProducerProvider for kafka topics.
+// Delivery report for produced message is sent into provided channel, or into Events channel when it is nil.
+// Flush waits for outstanding messages up to provided timeout in ms and returns number of messages still in flight.
+type ProducerProvider interface {
+	Produce(*Message, chan Event) error
+	Events() chan Event
+	Flush(int) int
+	Close()
+}
+
+// cluster is kafka cluster where items are produced
+type cluster struct {
+	name     string
+	provider ProducerProvider
+	// limits are nil when throughput is not limited
+	limits *rateLimits
+}
+
+// Producer for kafka topics
+type Producer struct {
+	// kafkaProducer produces into primary cluster
+	kafkaProducer ProducerProvider
+	// mirrors are clusters where all items are produced as well
+	mirrors     []cluster
+	ctx         context.Context
+	keyStrategy KeyStrategy
+	partitioner *partitioner
+	deadLetter  DeadLetter
+	retry       Retry
+	// results of delivery are reported here when all topics of item are processed
+	chanRes chan Result
+	// event loops of clusters are started on first use and stopped once
+	eventsOnce sync.Once
+	stopOnce   sync.Once
+	stopEvents chan struct{}
+	eventsWG   sync.WaitGroup
+	// delivery reports of messages sent synchronously are dispatched from sends, it is created on first use
+	sendsOnce sync.Once
+	sends     chan Event
+	stopSends chan struct{}
+	// number of items which were produced but not reported yet
+	inflight      sync.WaitGroup
+	inflightItems int64
+	// limits number of items in flight, nil means no limit
+	slots chan struct{}
+	// total time in ns producers waited for free slot
+	backpressure int64
+	pool         *pool
+	// max size of message value, zero means no limit
+	maxPayload int
+	// limits of throughput into primary cluster
+	limits *rateLimits
+	// total time in ns messages waited because of rate limits
+	throttled int64
+	// deliveryTimeout limits waiting for delivery reports, zero means no limit
+	deliveryTimeout time.Duration
+	// number of items and messages which delivery timed out
+	timeouts int64
+	// observer is nil when latency is not observed
+	observer LatencyObserver
+	// number of producers of pool is scaled between minProducers and maxProducers
+	minProducers int
+	maxProducers int
+}
+
+// Result indicates message processing status, Cluster is name of kafka cluster where item was produced.
+// Partition and offset of TopicResult are PartitionAny and OffsetUnknown when message was not delivered
+// or position was not reported.
+type Result = sink.Result
+
+// TopicResult is result of delivery of item into single topic
+type TopicResult = sink.TopicResult
+
+// Itemer defines interface for processed entities.
+// When Marshal returns nil without error tombstone is produced, so item is removed from compacted topics.
+type Itemer = sink.Item
+
+// Headerer is implemented by items which provide additional message headers
+type Headerer = sink.Headerer
+
+// Config configures kafka producer
+type Config struct {
+	// Address of brokers of primary cluster
+	Address  string
+	Driver   string
+	Security Security
+	// Mirrors are additional clusters where all items are produced as well
+	Mirrors []Cluster
+	// KeyStrategy is KeyStrategyID when it is empty
+	KeyStrategy KeyStrategy
+	// Partitioner is used instead of partitioner of kafka client when it is set
+	Partitioner Partitioner
+	Retry       Retry
+	RateLimit   RateLimit
+	Batch       Batch
+	// MaxPayload is max size of message value in bytes, larger items are not sent. Zero means no limit
+	MaxPayload int
+	// MaxInflight is max number of items which were produced but not reported yet. Zero means no limit
+	MaxInflight int
+	// number of producers of pool is scaled between MinProducers and MaxProducers, MaxProducers is required by pool
+	MinProducers int
+	MaxProducers int
+	// not delivered items are sent into DeadLetterTopic or written into DeadLetterFile
+	DeadLetterTopic string
+	DeadLetterFile  string
+	// LatencyObserver is notified about duration of produce operations when it is set
+	LatencyObserver LatencyObserver
+	// DeliveryTimeout limits how long delivery of item is waited for, retries included. Zero means no limit
+	DeliveryTimeout time.Duration
+}
+
+// NewKafkaProducer returned configured kafka producer. Context is used only for cancellation of producing.
+func NewKafkaProducer(ctx context.Context, c Config) (*Producer, error) {
+	if c.Address == "" {
+		return nil, fmt.Errorf("Kafka address is not configured")
+	}
+	p, err := newProvider(c.Driver, c.Address, c.Security, c.Batch)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to init connection to Kafka: %w", err)
+	}
+	mirrors := []cluster{}
+	for _, cl := range c.Mirrors {
+		m, err := newProvider(c.Driver, cl.Address, cl.Security, c.Batch)
+		if err != nil {
+			p.Close()
+			for _, m := range mirrors {
+				m.provider.Close()
+			}
+			return nil, fmt.Errorf("Unable to init connection to Kafka cluster '%s': %w", cl.Name, err)
+		}
+		mirrors = append(mirrors, cluster{name: cl.Name, provider: m, limits: newRateLimits(c.RateLimit)})
+	}
+	keyStrategy := KeyStrategyID
+	if c.KeyStrategy != "" {
+		keyStrategy = c.KeyStrategy
+	}
+	producer := &Producer{kafkaProducer: p, mirrors: mirrors, ctx: ctx, keyStrategy: keyStrategy, limits: newRateLimits(c.RateLimit),
+		retry: c.Retry, maxPayload: c.MaxPayload, observer: c.LatencyObserver, minProducers: c.MinProducers, maxProducers: c.MaxProducers,
+		deliveryTimeout: c.DeliveryTimeout}
+	if c.Partitioner != "" {
+		producer.partitioner = &partitioner{strategy: c.Partitioner}
+	}
+	if c.MaxInflight > 0 {
+		producer.slots = make(chan struct{}, c.MaxInflight)
+	}
+	if c.DeadLetterTopic != "" {
+		producer.deadLetter = topicDeadLetter{producer: producer, topic: c.DeadLetterTopic}
+	} else if c.DeadLetterFile != "" {
+		producer.deadLetter, err = newFileDeadLetter(c.DeadLetterFile)
+		if err != nil {
+			producer.Close()
+			return nil, err
+		}
+	}
+	return producer, nil
+}
+
+// Batch configures how messages are collected by client into single produce request, so per message overhead is reduced
+type Batch struct {
+	// Size is max number of messages of request, default of client is used when it is zero
+	Size int
+	// Linger is how long messages are collected before request is sent, lingerMs is used when it is zero
+	Linger time.Duration
+}
+
+// linger returns linger of batch in milliseconds, linger shorter than millisecond is rounded up
+func (b Batch) linger() int {
+	switch {
+	case b.Linger <= 0:
+		return lingerMs
+	case b.Linger < time.Millisecond:
+		return 1
+	}
+	return int(b.Linger / time.Millisecond)
+}
+
+// newProvider creates producer provider for selected driver
+func newProvider(driver, addr string, security Security, batch Batch) (ProducerProvider, error) {
+	switch driver {
+	case "", DriverConfluent:
+		return newConfluentProducer(addr, security, batch)
+	case DriverKafkaGo:
+		return newKafkaGoProducer(addr, security, batch)
+	}
+	return nil, fmt.Errorf("Kafka driver '%s' is not supported", driver)
+}
+
+// clusters returns primary cluster followed by mirrors
+func (p *Producer) clusters() []cluster {
+	return append([]cluster{{name: ClusterPrimary, provider: p.kafkaProducer, limits: p.limits}}, p.mirrors...)
+}
+
+// CreateProducersPool creates pool of goroutines which will handle populating items to kafka.
+// Messages are produced asynchronously, delivery reports are drained from producer's events
+// by separate goroutine and reported as Result once item is delivered (or failed) to all its topics.
+// Number of producers is scaled between MinProducers and MaxProducers of Config based on depth of items queue.
+// When number of items in flight reaches limit producers stop to read items until some of them are reported,
+// so slow broker slows down the sender of items.
+// When context is cancelled pool waits for delivery reports of all produced items before exit.
+func (p *Producer) CreateProducersPool(chanItem <-chan Itemer) (<-chan Result, <-chan struct{}) {
+	chanProducersExited := make(chan struct{})
+	p.chanRes = make(chan Result, 1)
+	maxProducers := p.maxProducers
+	if maxProducers < 1 {
+		defer func() {
+			p.chanRes <- Result{Err: &apperror.ConfigError{Err: fmt.Errorf("Max number of producers should be positive")}}
+			// we did not start goroutine yet.
+			// it is required to close both channels here
+			close(p.chanRes)
+			close(chanProducersExited)
+		}()
+		return p.chanRes, chanProducersExited
+	}
+	minProducers := maxProducers
+	if n := p.minProducers; n > 0 && n < maxProducers {
+		minProducers = n
+	}
+	p.pool = &pool{producer: p, items: chanItem, min: minProducers, max: maxProducers}
+	p.startEvents()
+	go func() {
+		defer func() {
+			close(p.chanRes)
+			close(chanProducersExited)
+		}()
+		p.pool.run()
+		// nothing will be produced anymore - wait for reports for all messages in flight
+		for _, c := range p.clusters() {
+			c.provider.Flush(flushTimeoutMs)
+		}
+		p.inflight.Wait()
+		p.stopEventLoops()
+	}()
+	return p.chanRes, chanProducersExited
+}
+
+// Stream implements sink.Streamer by pool of producers
+func (p *Producer) Stream(items <-chan sink.Item) (<-chan sink.Result, <-chan struct{}) {
+	return p.CreateProducersPool(items)
+}
+
+// Send implements sink.Sink: item is produced into all clusters and delivery is awaited.
+// Results of mirrors are merged into result of primary cluster: the first error is reported and
+// topics of all clusters are included.
+func (p *Producer) Send(ctx context.Context, item sink.Item) sink.Result {
+	p.startEvents()
+	n := len(p.clusters())
+	// buffered, so late reports are not blocked when waiting is cancelled
+	reply := make(chan Result, n)
+	p.produce(item, reply)
+	var res Result
+	for i := 0; i < n; i++ {
+		select {
+		case r := <-reply:
+			if i == 0 {
+				res = r
+				continue
+			}
+			if res.Err == nil {
+				res.Err = r.Err
+			}
+			res.DeadLettered = res.DeadLettered || r.DeadLettered
+			res.Topics = append(res.Topics, r.Topics...)
+		case <-ctx.Done():
+			return Result{ItemID: item.GetID(), ItemContext: item.GetContext(), Cluster: ClusterPrimary, Err: ctx.Err()}
+		}
+	}
+	res.Cluster = ClusterPrimary
+	return res
+}
+
+// Publish sends message which is not item (e.g. control message) into topic of primary cluster and waits for its delivery.
+// Message is not retried and not put into dead letter.
+func (p *Producer) Publish(topic string, key, value []byte, headers []Header) error {
+	return p.sendMessageToKafka(topic, key, value, headers)
+}
+
+// PublishPartition sends message which is not item into partition of topic of primary cluster and waits for its delivery
+func (p *Producer) PublishPartition(topic string, partition int32, key, value []byte, headers []Header) error {
+	return p.sendMessageToPartition(topic, partition, key, value, headers)
+}
+
+// Partitions returns number of partitions of topic in primary cluster
+func (p *Producer) Partitions(topic string) (int, error) {
+	pc, ok := p.kafkaProducer.(partitionCounter)
+	if !ok {
+		return 0, fmt.Errorf("Number of partitions of topic %s is not provided by kafka driver", topic)
+	}
+	return pc.partitions(topic)
+}
+
+// startEvents runs event loop of every cluster once
+func (p *Producer) startEvents() {
+	p.eventsOnce.Do(func() {
+		p.stopEvents = make(chan struct{})
+		for _, c := range p.clusters() {
+			p.eventsWG.Add(1)
+			go func(c cluster) {
+				defer p.eventsWG.Done()
+				p.processEvents(c, p.stopEvents)
+			}(c)
+		}
+	})
+}
+
+// stopEventLoops stops event loops and dispatcher of synchronous sends if they were started and waits for event loops
+func (p *Producer) stopEventLoops() {
+	p.eventsOnce.Do(func() {})
+	p.sendsOnce.Do(func() {})
+	p.stopOnce.Do(func() {
+		if p.stopEvents != nil {
+			close(p.stopEvents)
+			p.eventsWG.Wait()
+		}
+		if p.stopSends != nil {
+			close(p.stopSends)
+		}
+	})
+}
+
+// processEvents drains events of cluster's producer and correlates delivery reports with items
+func (p *Producer) processEvents(c cluster, chanStop <-chan struct{}) {
+	events := c.provider.Events()
+	for {
+		select {
+		case e := <-events:
+			switch ev := e.(type) {
+			case *Message:
+				d, ok := ev.Opaque.(*delivery)
+				if !ok {
+					continue
+				}
+				if ev.Err != nil {
+					p.fail(d, ev.Topic, fmt.Errorf("Delivery to kafka failed: %w", ev.Err))
+					continue
+				}
+				p.completeTopic(d, TopicResult{Topic: ev.Topic, Partition: ev.Partition, Offset: ev.Offset, Timestamp: ev.Timestamp})
+			case error:
+				// errors which are not related to particular message, they are reported only by pool
+				if p.chanRes != nil {
+					p.chanRes <- Result{Cluster: c.name, Err: &apperror.DeliveryError{Err: fmt.Errorf("Kafka producer error: %w", ev)}}
+				}
+			}
+		case <-chanStop:
+			return
+		}
+	}
+}
+
+// produceItem marshals item and produces it to all its topics in every cluster without waiting for delivery.
+// Result is reported for every cluster.
+func (p *Producer) produceItem(item Itemer) {
+	p.produce(item, nil)
+}
+
+// produce produces item into every cluster, results are reported into reply channel or into results of pool when it is nil
+func (p *Producer) produce(item Itemer, reply chan Result) {
+	message, errM := item.Marshal()
+	var headers []Header
+	if h, ok := item.(Headerer); ok {
+		headers = h.Headers()
+	}
+	// message key is used for partitioning unless item provides its own partition key
+	partitionKey := p.keyStrategy.key(item)
+	if pk, ok := item.(PartitionKeyer); ok && pk.PartitionKey() != nil {
+		partitionKey = pk.PartitionKey()
+	}
+	for _, c := range p.clusters() {
+		p.acquire()
+		// span lasts till delivery report, trace context is sent in headers so consumers could continue trace
+		ctx, span := tracing.Tracer().Start(tracing.ItemContext(item), "kafka.produce", trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(semconv.MessagingSystemKey.String("kafka"), label.String("feeddo.cluster", c.name),
+				label.Array("feeddo.topics", item.Topics()), label.String("feeddo.item_id", item.GetID())))
+		d := &delivery{
+			res:     Result{ItemID: item.GetID(), ItemContext: item.GetContext(), Cluster: c.name},
+			cluster: c,
+			item:    item,
+			key:     p.keyStrategy.key(item),
+			headers: tracing.Inject(ctx, headers),
+			partKey: partitionKey,
+			reply:   reply,
+			topics:  item.Topics(),
+			started: time.Now(),
+			span:    span,
+		}
+		p.inflight.Add(1)
+		if errM != nil {
+			p.reject(d, fmt.Errorf("Failed to marshal json: %w", errM))
+			continue
+		}
+		d.message = message
+		if p.maxPayload > 0 && len(message) > p.maxPayload {
+			// broker will reject message anyway
+			p.reject(d, fmt.Errorf("%w: %d bytes exceeds limit of %d bytes", ErrPayloadTooLarge, len(message), p.maxPayload))
+			continue
+		}
+		d.res.Tombstone = message == nil
+		d.remaining = len(d.topics)
+		if d.remaining == 0 {
+			p.report(d)
+			continue
+		}
+		if p.deliveryTimeout > 0 {
+			d.expireAfter(p.deliveryTimeout, func() { p.expire(d) })
+		}
+		for _, topic := range d.topics {
+			err := p.produceMessage(topic, d)
+			if err != nil {
+				p.fail(d, topic, fmt.Errorf("Send message to kafka failed because of %w", err))
+			}
+		}
+	}
+}
+
+// reject reports item which could not be sent to any of its topics
+func (p *Producer) reject(d *delivery, err error) {
+	err = &apperror.DeliveryError{Err: err}
+	d.res.Err = err
+	for _, topic := range d.topics {
+		d.res.Topics = append(d.res.Topics, TopicResult{Topic: topic, Partition: PartitionAny, Offset: OffsetUnknown, Err: err})
+	}
+	p.putToDeadLetter(&d.res, d.item, d.topics, d.key, d.message)
+	p.report(d)
+}
+
+// fail schedules another attempt to send message into topic if error is transient
+// and retries are not exhausted. Otherwise topic is completed with error.
+func (p *Producer) fail(d *delivery, topic string, err error) {
+	if !isRetriable(err) {
+		p.complete(d, topic, err)
+		return
+	}
+	attempt := d.nextAttempt(topic)
+	if attempt > p.retry.MaxRetries {
+		p.complete(d, topic, err)
+		return
+	}
+	time.AfterFunc(p.retry.backoff(attempt), func() {
+		if d.isFinished() {
+			return
+		}
+		errP := p.produceMessage(topic, d)
+		if errP != nil {
+			p.fail(d, topic, fmt.Errorf("Send message to kafka failed because of %w", errP))
+		}
+	})
+}
+
+// produceMessage puts message into producer's queue. When queue is full it waits until it will be drained.
+// Throughput into cluster is limited, retries included.
+func (p *Producer) produceMessage(topic string, d *delivery) error {
+	waited := d.cluster.limits.wait(p.ctx, len(d.key)+len(d.message))
+	if waited > 0 {
+		atomic.AddInt64(&p.throttled, int64(waited))
+	}
+	partition, err := p.partitioner.partition(d.cluster.provider, topic, d.partKey)
+	if err != nil {
+		return err
+	}
+	km := &Message{
+		Topic:     topic,
+		Partition: partition,
+		Key:       d.key,
+		Value:     d.message,
+		Headers:   d.headers,
+		Opaque:    d,
+	}
+	started := time.Now()
+	defer p.observe(d.cluster.name, topic, OperationEnqueue, started)
+	for {
+		err := d.cluster.provider.Produce(km, nil)
+		if errors.Is(err, ErrQueueFull) {
+			select {
+			case <-p.ctx.Done():
+				return err
+			default:
+			}
+			d.cluster.provider.Flush(queueFullWaitMs)
+			continue
+		}
+		return err
+	}
+}
+
+// complete registers result of delivery to topic and reports result when item processed for all topics
+func (p *Producer) complete(d *delivery, topic string, err error) {
+	p.completeTopic(d, TopicResult{Topic: topic, Partition: PartitionAny, Offset: OffsetUnknown, Err: err})
+}
+
+// completeTopic registers result of delivery to topic including position of message from delivery report
+func (p *Producer) completeTopic(d *delivery, tr TopicResult) {
+	if !d.done(tr) {
+		return
+	}
+	if d.res.Err != nil {
+		p.putToDeadLetter(&d.res, d.item, d.failed, d.key, d.message)
+	}
+	p.report(d)
+}
+
+// expire finishes item which was not delivered within delivery timeout, late delivery reports of its messages are ignored
+func (p *Producer) expire(d *delivery) {
+	if !d.expire(fmt.Errorf("%w after %s", ErrDeliveryTimeout, p.deliveryTimeout)) {
+		return
+	}
+	atomic.AddInt64(&p.timeouts, 1)
+	p.putToDeadLetter(&d.res, d.item, d.failed, d.key, d.message)
+	p.report(d)
+}
+
+func (p *Producer) report(d *delivery) {
+	if d.span != nil {
+		tracing.End(d.span, d.res.Err)
+	}
+	if d.reply != nil {
+		d.reply <- d.res
+	} else {
+		p.chanRes <- d.res
+	}
+	atomic.AddInt64(&p.inflightItems, -1)
+	p.release()
+	p.inflight.Done()
+}
+
+// acquire waits for free slot when number of items in flight is limited
+func (p *Producer) acquire() {
+	atomic.AddInt64(&p.inflightItems, 1)
+	if p.slots == nil {
+		return
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return
+	default:
+	}
+	// backpressure: producer is saturated and waits until some items are reported
+	started := time.Now()
+	p.slots <- struct{}{}
+	atomic.AddInt64(&p.backpressure, int64(time.Since(started)))
+}
+
+func (p *Producer) release() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+// Inflight returns number of items which were produced but not reported yet
+func (p *Producer) Inflight() int {
+	return int(atomic.LoadInt64(&p.inflightItems))
+}
+
+// Producers returns number of running producers
+func (p *Producer) Producers() int {
+	if p.pool == nil {
+		return 0
+	}
+	return int(atomic.LoadInt32(&p.pool.size))
+}
+
+// BackpressureTime returns total time producers waited because limit of items in flight was reached
+func (p *Producer) BackpressureTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.backpressure))
+}
+
+// ThrottledTime returns total time messages waited because of rate limits
+func (p *Producer) ThrottledTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.throttled))
+}
+
+// DeliveryTimeouts returns number of items and dead letters which delivery was not reported within delivery timeout
+func (p *Producer) DeliveryTimeouts() int64 {
+	return atomic.LoadInt64(&p.timeouts)
+}
+
+// observe reports latency of operation started at provided time
+func (p *Producer) observe(cluster, topic, operation string, started time.Time) {
+	if p.observer != nil {
+		p.observer(cluster, topic, operation, time.Since(started))
+	}
+}
+
+// QueueStats is state of client queue of cluster
+type QueueStats struct {
+	// Messages which wait in client queue or were sent but not acknowledged by broker yet
+	Messages int
+	// TxMessages is total number of messages transmitted to brokers
+	TxMessages int64
+	// TxBytes is total size of messages transmitted to brokers
+	TxBytes int64
+}
+
+// queueStater is implemented by producer providers which report state of their queue
+type queueStater interface {
+	QueueStats() QueueStats
+}
+
+// queueStats returns state of client queue per cluster, clusters which do not report it are skipped
+func (p *Producer) queueStats() map[string]QueueStats {
+	stats := map[string]QueueStats{}
+	for _, c := range p.clusters() {
+		if qs, ok := c.provider.(queueStater); ok {
+			stats[c.name] = qs.QueueStats()
+		}
+	}
+	return stats
+}
+
+// QueueLengths returns number of messages in client queue per cluster
+func (p *Producer) QueueLengths() map[string]int {
+	result := map[string]int{}
+	for name, s := range p.queueStats() {
+		result[name] = s.Messages
+	}
+	return result
+}
+
+// TransmittedMessages returns total number of messages transmitted to brokers per cluster
+func (p *Producer) TransmittedMessages() map[string]int64 {
+	result := map[string]int64{}
+	for name, s := range p.queueStats() {
+		result[name] = s.TxMessages
+	}
+	return result
+}
+
+// TransmittedBytes returns total size of messages transmitted to brokers per cluster
+func (p *Producer) TransmittedBytes() map[string]int64 {
+	result := map[string]int64{}
+	for name, s := range p.queueStats() {
+		result[name] = s.TxBytes
+	}
+	return result
+}
+
+// putToDeadLetter stores failed item for every topic where it was not delivered
+func (p *Producer) putToDeadLetter(res *Result, item Itemer, topics []string, key, message []byte) {
+	if p.deadLetter == nil {
+		return
+	}
+	for _, topic := range topics {
+		err := p.deadLetter.Put(item, res.Cluster, topic, key, message, res.Err)
+		if err != nil {
+			res.Err = fmt.Errorf("%w. Also failed to store dead letter: %v", res.Err, err)
+			return
+		}
+	}
+	res.DeadLettered = true
+}
+
+// pendingSend is opaque of message which is sent synchronously, done is closed by dispatcher of reports only,
+// so sender could stop waiting when delivery times out
+type pendingSend struct {
+	done chan struct{}
+	err  error
+}
+
+// startSends starts dispatching of delivery reports of messages sent synchronously. Reports are delivered into
+// single long lived channel and are correlated with senders by opaque of message, so report which comes late
+// (e.g. after sender stopped waiting) could not be sent into closed channel.
+func (p *Producer) startSends() {
+	p.sendsOnce.Do(func() {
+		p.sends = make(chan Event, sendsBuffer)
+		p.stopSends = make(chan struct{})
+		go p.dispatchSends(p.sends, p.stopSends)
+	})
+}
+
+// dispatchSends completes pending sends by their delivery reports. Channel of reports is never closed,
+// as provider could still report into it, so dispatcher is stopped by chanStop.
+func (p *Producer) dispatchSends(sends <-chan Event, chanStop <-chan struct{}) {
+	for {
+		select {
+		case e := <-sends:
+			km, ok := e.(*Message)
+			if !ok {
+				continue
+			}
+			ps, ok := km.Opaque.(*pendingSend)
+			if !ok {
+				continue
+			}
+			if km.Err != nil {
+				ps.err = fmt.Errorf("Delivery to kafka failed: %w", km.Err)
+			}
+			close(ps.done)
+		case <-chanStop:
+			return
+		}
+	}
+}
+
+// sendMessageToKafka sends message into primary cluster and waits for its delivery.
+// It does not depend on event loops, so it could be called from them, e.g. when item is put into dead letter topic.
+func (p *Producer) sendMessageToKafka(topic string, key, m []byte, headers []Header) error {
+	return p.sendMessageToPartition(topic, PartitionAny, key, m, headers)
+}
+
+// sendMessageToPartition sends message into partition of topic of primary cluster and waits for its delivery
+func (p *Producer) sendMessageToPartition(topic string, partition int32, key, m []byte, headers []Header) error {
+	p.startSends()
+	ps := &pendingSend{done: make(chan struct{})}
+	km := &Message{
+		Topic:     topic,
+		Partition: partition,
+		Key:       key,
+		Value:     m,
+		Headers:   headers,
+		Opaque:    ps,
+	}
+	defer p.observe(ClusterPrimary, topic, OperationSend, time.Now())
+	err := p.kafkaProducer.Produce(km, p.sends)
+	if err != nil {
+		return fmt.Errorf("Send message to kafka failed because of %w", err)
+	}
+	if p.deliveryTimeout <= 0 {
+		<-ps.done
+		return ps.err
+	}
+	timer := time.NewTimer(p.deliveryTimeout)
+	defer timer.Stop()
+	select {
+	case <-ps.done:
+		return ps.err
+	case <-timer.C:
+		atomic.AddInt64(&p.timeouts, 1)
+		return fmt.Errorf("%w after %s", ErrDeliveryTimeout, p.deliveryTimeout)
+	}
+}
+
+// Close wrapper for producer providers of all clusters
+func (p *Producer) Close() error {
+	p.stopEventLoops()
+	if p.deadLetter != nil {
+		p.deadLetter.Close()
+	}
+	for _, c := range p.clusters() {
+		c.provider.Close()
+	}
+	return nil
+}
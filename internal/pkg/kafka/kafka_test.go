@@ -0,0 +1,645 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/oteltest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewKafkaProducerConfig(t *testing.T) {
+	_, err := NewKafkaProducer(context.Background(), Config{})
+	require.Error(t, err)
+	assert.Equal(t, "Kafka address is not configured", err.Error())
+
+	p, err := NewKafkaProducer(context.Background(), Config{Address: "localhost:9092", Driver: DriverKafkaGo, MaxInflight: 5,
+		MinProducers: 1, MaxProducers: 3, MaxPayload: 100, DeadLetterTopic: "dead_letter", DeliveryTimeout: time.Minute})
+	require.NoError(t, err)
+	defer p.Close()
+	assert.Equal(t, KeyStrategyID, p.keyStrategy)
+	assert.Nil(t, p.partitioner)
+	assert.Equal(t, 5, cap(p.slots))
+	assert.Equal(t, 1, p.minProducers)
+	assert.Equal(t, 3, p.maxProducers)
+	assert.Equal(t, 100, p.maxPayload)
+	assert.Equal(t, time.Minute, p.deliveryTimeout)
+	assert.Equal(t, topicDeadLetter{producer: p, topic: "dead_letter"}, p.deadLetter)
+}
+
+// producerMock delivers messages into provided delivery channel or into events channel
+// produceErr is returned from Produce, deliveryErr is set into delivery report
+type producerMock struct {
+	events      chan Event
+	produceErr  error
+	deliveryErr error
+}
+
+func (pp producerMock) Produce(m *Message, c chan Event) error {
+	if pp.produceErr != nil {
+		return pp.produceErr
+	}
+	go func() {
+		m.Err = pp.deliveryErr
+		if c != nil {
+			c <- m
+		} else {
+			pp.events <- m
+		}
+	}()
+	return nil
+}
+func (pp producerMock) Events() chan Event { return pp.events }
+func (pp producerMock) Flush(int) int      { return 0 }
+func (pp producerMock) Close()             {}
+
+func producerSuccess() producerMock {
+	return producerMock{events: make(chan Event)}
+}
+
+func producerError() producerMock {
+	return producerMock{events: make(chan Event), produceErr: errors.New("test error")}
+}
+
+func producerChannelError() producerMock {
+	return producerMock{events: make(chan Event), deliveryErr: errors.New("Test channel error")}
+}
+
+func TestSendMessageToKafka(t *testing.T) {
+	tests := []struct {
+		name     string
+		topic    string
+		message  []byte
+		producer *Producer
+		err      string
+	}{
+		{
+			name:     "Producer failed",
+			topic:    "test",
+			message:  []byte("test"),
+			producer: &Producer{kafkaProducer: producerError()},
+			err:      "Send message to kafka failed because of test error",
+		},
+		{
+			name:     "Producer failed to deliver message to kafka",
+			topic:    "test",
+			message:  []byte("test"),
+			producer: &Producer{kafkaProducer: producerChannelError()},
+			err:      "Delivery to kafka failed: Test channel error",
+		},
+		{
+			name:     "happy path",
+			topic:    "test",
+			message:  []byte("test"),
+			producer: &Producer{kafkaProducer: producerSuccess()},
+			err:      "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.producer.sendMessageToKafka(tt.topic, nil, tt.message, nil)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// channelRecorder delivers messages and records channels which reports were requested into
+type channelRecorder struct {
+	mu       sync.Mutex
+	channels map[chan Event]int
+}
+
+func (cr *channelRecorder) Produce(m *Message, c chan Event) error {
+	cr.mu.Lock()
+	cr.channels[c]++
+	cr.mu.Unlock()
+	go func() {
+		c <- m
+	}()
+	return nil
+}
+func (cr *channelRecorder) Events() chan Event { return nil }
+func (cr *channelRecorder) Flush(int) int      { return 0 }
+func (cr *channelRecorder) Close()             {}
+
+func TestSendMessageToKafkaReusesChannel(t *testing.T) {
+	recorder := &channelRecorder{channels: map[chan Event]int{}}
+	p := &Producer{kafkaProducer: recorder}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, p.sendMessageToKafka("test", nil, []byte("test"), nil))
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, p.Close())
+	// all reports are dispatched from single channel which is not closed, so late reports could not panic
+	assert.Equal(t, map[chan Event]int{p.sends: 100}, recorder.channels)
+	assert.NotPanics(t, func() { p.sends <- &Message{} })
+}
+
+// latencies collects observed operations
+type latencies struct {
+	mu         sync.Mutex
+	operations []string
+}
+
+func (l *latencies) observe(cluster, topic, operation string, latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.operations = append(l.operations, cluster+"/"+topic+"/"+operation)
+}
+
+func TestLatencyObserver(t *testing.T) {
+	l := &latencies{}
+	p := &Producer{kafkaProducer: producerSuccess(), mirrors: []cluster{{name: "cloud", provider: producerSuccess()}},
+		observer: l.observe, ctx: context.Background()}
+	require.NoError(t, p.sendMessageToKafka("dead_letters", nil, []byte("test"), nil))
+	res := p.Send(context.Background(), ItemMultipleTopicsTest{})
+	require.NoError(t, res.Err)
+	assert.ElementsMatch(t, []string{
+		"primary/dead_letters/send",
+		"primary/" + TopicShopItems + "/enqueue", "primary/" + TopicShopItemsBidding + "/enqueue",
+		"cloud/" + TopicShopItems + "/enqueue", "cloud/" + TopicShopItemsBidding + "/enqueue",
+	}, l.operations)
+	p.stopEventLoops()
+}
+
+// queueProducerMock reports state of its queue
+type queueProducerMock struct {
+	producerMock
+	stats QueueStats
+}
+
+func (qp queueProducerMock) QueueStats() QueueStats { return qp.stats }
+
+func TestQueueStats(t *testing.T) {
+	p := &Producer{
+		kafkaProducer: queueProducerMock{producerMock: producerSuccess(), stats: QueueStats{Messages: 3, TxMessages: 10, TxBytes: 100}},
+		// cluster which does not report its queue is skipped
+		mirrors: []cluster{{name: "cloud", provider: producerSuccess()}},
+	}
+	assert.Equal(t, map[string]int{ClusterPrimary: 3}, p.QueueLengths())
+	assert.Equal(t, map[string]int64{ClusterPrimary: 10}, p.TransmittedMessages())
+	assert.Equal(t, map[string]int64{ClusterPrimary: 100}, p.TransmittedBytes())
+}
+
+type ItemTest struct{}
+
+func (i ItemTest) GetContext() string       { return "testContext" }
+func (i ItemTest) GetID() string            { return "testID" }
+func (i ItemTest) Marshal() ([]byte, error) { return []byte("test bytes"), nil }
+func (i ItemTest) Topics() []string         { return []string{TopicShopItems} }
+
+func TestKeyStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		err      string
+		key      []byte
+	}{
+		{"unknown strategy", "abc", "Key strategy 'abc' is not supported", nil},
+		{"none", "none", "", nil},
+		{"id", "id", "", []byte("testID")},
+		{"feed and id", "feed-id", "", []byte("testContext:testID")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := ParseKeyStrategy(tt.strategy)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.key, ks.key(ItemTest{}))
+			}
+		})
+	}
+}
+
+type ItemMarshalErrorTest struct{ ItemTest }
+
+func (i ItemMarshalErrorTest) Marshal() ([]byte, error) { return nil, fmt.Errorf("Test error") }
+
+type ItemMultipleTopicsTest struct{ ItemTest }
+
+func (i ItemMultipleTopicsTest) Topics() []string {
+	return []string{TopicShopItems, TopicShopItemsBidding}
+}
+
+type ItemNoTopicsTest struct{ ItemTest }
+
+func (i ItemNoTopicsTest) Topics() []string { return nil }
+
+func TestCreateProducersPoolMirrors(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	p := &Producer{
+		kafkaProducer: producerSuccess(),
+		mirrors:       []cluster{{name: "cloud", provider: producerChannelError()}},
+		ctx:           ctx,
+		maxProducers:  2,
+	}
+	chanItem := make(chan Itemer)
+	defer close(chanItem)
+	resChan, closeChan := p.CreateProducersPool(chanItem)
+	go func() {
+		chanItem <- ItemTest{}
+		chanItem <- ItemMultipleTopicsTest{}
+	}()
+	errs := map[string]int{}
+	for i := 0; i < 4; i++ {
+		res := <-resChan
+		failed := 0
+		if res.Err != nil {
+			failed = 1
+		}
+		errs[res.Cluster] += failed
+	}
+	// every item is reported for every cluster
+	assert.Equal(t, map[string]int{ClusterPrimary: 0, "cloud": 2}, errs)
+	cancelFunc()
+	<-closeChan
+}
+
+func TestProducerBackpressure(t *testing.T) {
+	events := make(chan Event)
+	p := &Producer{kafkaProducer: producerMock{events: events}, chanRes: make(chan Result, 2), slots: make(chan struct{}, 1)}
+	p.produceItem(ItemTest{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// blocks until first item is reported
+		p.produceItem(ItemTest{})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("item is produced while limit of items in flight is reached")
+	default:
+	}
+	assert.Equal(t, 2, p.Inflight())
+	for i := 0; i < 2; i++ {
+		m := (<-events).(*Message)
+		p.complete(m.Opaque.(*delivery), m.Topic, nil)
+		require.NoError(t, (<-p.chanRes).Err)
+	}
+	<-done
+	assert.Equal(t, 0, p.Inflight())
+	assert.True(t, p.BackpressureTime() >= 10*time.Millisecond)
+}
+
+func TestProduceTooLargePayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deadletter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	p := &Producer{kafkaProducer: producerSuccess(), chanRes: make(chan Result, 1), maxPayload: 5}
+	p.deadLetter, err = newFileDeadLetter(filepath.Join(dir, "dlq.json"))
+	require.NoError(t, err)
+	defer p.Close()
+	p.produceItem(ItemTest{})
+	res := <-p.chanRes
+	require.Error(t, res.Err)
+	assert.True(t, errors.Is(res.Err, ErrPayloadTooLarge))
+	assert.Equal(t, apperror.CategoryDelivery, apperror.Category(res.Err))
+	assert.Equal(t, "Payload is too large: 10 bytes exceeds limit of 5 bytes", res.Err.Error())
+	assert.True(t, res.DeadLettered)
+	assert.Equal(t, []TopicResult{{Topic: TopicShopItems, Partition: PartitionAny, Offset: OffsetUnknown, Err: res.Err}}, res.Topics)
+}
+
+type ItemHeadersTest struct{ ItemTest }
+
+func (i ItemHeadersTest) Headers() []Header { return []Header{{Key: "h", Value: []byte("v")}} }
+
+func TestProduceHeaders(t *testing.T) {
+	events := make(chan Event, 1)
+	p := &Producer{kafkaProducer: producerMock{events: events}, chanRes: make(chan Result, 1)}
+	p.produceItem(ItemHeadersTest{})
+	m := (<-events).(*Message)
+	assert.Equal(t, []Header{{Key: "h", Value: []byte("v")}}, m.Headers)
+	p.complete(m.Opaque.(*delivery), m.Topic, nil)
+	require.NoError(t, (<-p.chanRes).Err)
+}
+
+// ItemTracedTest is produced within trace
+type ItemTracedTest struct {
+	ItemHeadersTest
+	ctx context.Context
+}
+
+func (i ItemTracedTest) TraceContext() context.Context { return i.ctx }
+
+func TestProduceTraced(t *testing.T) {
+	sr := new(oteltest.StandardSpanRecorder)
+	otel.SetTracerProvider(oteltest.NewTracerProvider(oteltest.WithSpanRecorder(sr)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "feed.run")
+	events := make(chan Event, 1)
+	p := &Producer{kafkaProducer: producerMock{events: events}, chanRes: make(chan Result, 1)}
+	p.produceItem(ItemTracedTest{ctx: ctx})
+	m := (<-events).(*Message)
+	require.Equal(t, 2, len(m.Headers))
+	assert.Equal(t, Header{Key: "h", Value: []byte("v")}, m.Headers[0])
+	assert.Equal(t, "traceparent", m.Headers[1].Key)
+	p.complete(m.Opaque.(*delivery), m.Topic, errors.New("test error"))
+	require.Error(t, (<-p.chanRes).Err)
+
+	spans := sr.Completed()
+	require.Equal(t, 1, len(spans))
+	assert.Equal(t, "kafka.produce", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID, spans[0].ParentSpanID())
+	assert.Equal(t, trace.SpanKindProducer, spans[0].SpanKind())
+	assert.Equal(t, codes.Error, spans[0].StatusCode())
+	// consumer continues trace of producing
+	assert.Contains(t, string(m.Headers[1].Value), spans[0].SpanContext().SpanID.String())
+}
+
+func TestProduceRateLimit(t *testing.T) {
+	events := make(chan Event, 1)
+	limits := newRateLimits(RateLimit{MessagesPerSecond: 10})
+	// bucket is drained, so next message waits
+	limits.messages.reserve(10)
+	p := &Producer{kafkaProducer: producerMock{events: events}, chanRes: make(chan Result, 1), ctx: context.Background(), limits: limits}
+	p.produceItem(ItemTest{})
+	m := (<-events).(*Message)
+	p.complete(m.Opaque.(*delivery), m.Topic, nil)
+	require.NoError(t, (<-p.chanRes).Err)
+	assert.True(t, p.ThrottledTime() >= 50*time.Millisecond, p.ThrottledTime())
+}
+
+func TestProduceDeliveryPosition(t *testing.T) {
+	events := make(chan Event, 1)
+	p := &Producer{kafkaProducer: producerMock{events: events}, chanRes: make(chan Result, 1)}
+	p.produceItem(ItemTest{})
+	m := (<-events).(*Message)
+	assert.Equal(t, PartitionAny, m.Partition)
+
+	// delivery report is processed by events loop of the cluster
+	reports := make(chan Event, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.processEvents(cluster{name: ClusterPrimary, provider: producerMock{events: reports}}, stop)
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.Partition, m.Offset, m.Timestamp = 2, 42, ts
+	reports <- m
+	res := <-p.chanRes
+	require.NoError(t, res.Err)
+	require.Equal(t, 1, len(res.Topics))
+	tr := res.Topics[0]
+	assert.Equal(t, "shop_items", tr.Topic)
+	assert.Equal(t, int32(2), tr.Partition)
+	assert.Equal(t, int64(42), tr.Offset)
+	assert.Equal(t, ts, tr.Timestamp)
+
+	// position of not delivered message is unknown
+	p.produceItem(ItemTest{})
+	m = (<-events).(*Message)
+	p.complete(m.Opaque.(*delivery), m.Topic, errors.New("test error"))
+	res = <-p.chanRes
+	require.Error(t, res.Err)
+	assert.Equal(t, PartitionAny, res.Topics[0].Partition)
+	assert.Equal(t, OffsetUnknown, res.Topics[0].Offset)
+}
+
+func TestDeliveryTimeout(t *testing.T) {
+	// reports are not processed, so delivery hangs
+	events := make(chan Event, 2)
+	p := &Producer{kafkaProducer: producerMock{events: events}, chanRes: make(chan Result, 2), deliveryTimeout: 20 * time.Millisecond}
+	p.produceItem(ItemMultipleTopicsTest{})
+	res := <-p.chanRes
+	require.Error(t, res.Err)
+	assert.True(t, errors.Is(res.Err, ErrDeliveryTimeout))
+	assert.Equal(t, "Failed to send message to topic shop_items because of: Delivery to kafka timed out after 20ms", res.Err.Error())
+	require.Equal(t, 2, len(res.Topics))
+	assert.Equal(t, OffsetUnknown, res.Topics[0].Offset)
+	assert.Equal(t, int64(1), p.DeliveryTimeouts())
+
+	// late report is ignored
+	m := (<-events).(*Message)
+	p.completeTopic(m.Opaque.(*delivery), TopicResult{Topic: m.Topic, Offset: 42})
+	select {
+	case res := <-p.chanRes:
+		t.Fatalf("unexpected result %v", res)
+	default:
+	}
+	assert.Equal(t, 0, p.Inflight())
+
+	// synchronous send
+	p = &Producer{kafkaProducer: producerRecorderHung{}, deliveryTimeout: 20 * time.Millisecond}
+	err := p.sendMessageToKafka("dead_letters", nil, []byte("test"), nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrDeliveryTimeout))
+	assert.Equal(t, int64(1), p.DeliveryTimeouts())
+	require.NoError(t, p.Close())
+}
+
+// producerRecorderHung accepts messages, but never reports their delivery
+type producerRecorderHung struct{}
+
+func (producerRecorderHung) Produce(*Message, chan Event) error { return nil }
+func (producerRecorderHung) Events() chan Event                 { return nil }
+func (producerRecorderHung) Flush(int) int                      { return 0 }
+func (producerRecorderHung) Close()                             {}
+
+func TestSend(t *testing.T) {
+	primary := producerSuccess()
+	mirror := producerMock{events: make(chan Event), deliveryErr: errors.New("test error")}
+	p := &Producer{kafkaProducer: primary, mirrors: []cluster{{name: "cloud", provider: mirror}}}
+	// fatal error is not retried, so delivery to mirror fails
+	res := p.Send(context.Background(), ItemTest{})
+	assert.Equal(t, ClusterPrimary, res.Cluster)
+	assert.Equal(t, "testID", res.ItemID)
+	require.Error(t, res.Err)
+	assert.Equal(t, 2, len(res.Topics))
+	require.NoError(t, p.Close())
+
+	// waiting is cancelled by context
+	p = &Producer{kafkaProducer: producerMock{events: make(chan Event)}}
+	p.stopEventLoops()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res = p.Send(ctx, ItemTest{})
+	assert.Equal(t, context.Canceled, res.Err)
+}
+
+func TestPublish(t *testing.T) {
+	recorder := &producerRecorder{}
+	p := &Producer{kafkaProducer: recorder, mirrors: []cluster{{name: "cloud", provider: producerError()}}}
+	require.NoError(t, p.Publish("feeds_runs", []byte("feed"), []byte("{}"), []Header{{Key: "h", Value: []byte("v")}}))
+	// message is sent only into primary cluster
+	require.Equal(t, 1, len(recorder.messages))
+	m := recorder.messages[0]
+	assert.Equal(t, "feeds_runs", m.Topic)
+	assert.Equal(t, []byte("feed"), m.Key)
+	assert.Equal(t, []byte("{}"), m.Value)
+	assert.Equal(t, []Header{{Key: "h", Value: []byte("v")}}, m.Headers)
+
+	p = &Producer{kafkaProducer: producerError()}
+	assert.Error(t, p.Publish("feeds_runs", nil, []byte("{}"), nil))
+}
+
+func TestPublishPartition(t *testing.T) {
+	recorder := &producerRecorder{}
+	p := &Producer{kafkaProducer: recorder}
+	require.NoError(t, p.PublishPartition("shop_items", 2, []byte("key"), []byte("{}"), nil))
+	require.Equal(t, 1, len(recorder.messages))
+	assert.Equal(t, "shop_items", recorder.messages[0].Topic)
+	assert.Equal(t, int32(2), recorder.messages[0].Partition)
+
+	// recorder does not know partitions of topics
+	_, err := p.Partitions("shop_items")
+	assert.Error(t, err)
+	p = &Producer{kafkaProducer: producerPartitionsMock{count: 3}}
+	n, err := p.Partitions("shop_items")
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestSinkRegistered(t *testing.T) {
+	assert.Contains(t, sink.Names(), SinkName)
+	s, err := sink.New(context.Background(), SinkName, Config{Address: "localhost:9092", Driver: DriverKafkaGo})
+	require.NoError(t, err)
+	assert.IsType(t, &Producer{}, s)
+	require.NoError(t, s.Close())
+	_, err = sink.New(context.Background(), SinkName, Config{})
+	require.Error(t, err)
+	_, err = sink.New(context.Background(), SinkName, nil)
+	assert.EqualError(t, err, "Configuration of sink 'kafka' should be kafka.Config instead of <nil>")
+}
+
+type ItemTombstoneTest struct{ ItemTest }
+
+func (i ItemTombstoneTest) Marshal() ([]byte, error) { return nil, nil }
+
+func TestProduceTombstone(t *testing.T) {
+	events := make(chan Event, 1)
+	p := &Producer{kafkaProducer: producerMock{events: events}, chanRes: make(chan Result, 1)}
+	p.produceItem(ItemTombstoneTest{})
+	m := (<-events).(*Message)
+	assert.Nil(t, m.Value)
+	assert.Equal(t, []byte("testID"), m.Key)
+	p.complete(m.Opaque.(*delivery), m.Topic, nil)
+	res := <-p.chanRes
+	require.NoError(t, res.Err)
+	assert.True(t, res.Tombstone)
+}
+
+func TestCreateProducersPool(t *testing.T) {
+	tests := []struct {
+		name         string
+		producer     ProducerProvider
+		maxProducers int
+		item         []Itemer
+		err          string
+	}{
+		{
+			"No producers", producerSuccess(), 0, []Itemer{ItemTest{}}, "Max number of producers should be positive",
+		},
+		{
+			"Item Marshal Error", producerSuccess(), 1, []Itemer{ItemMarshalErrorTest{}}, "Failed to marshal json: Test error",
+		},
+		{
+			"Item Producer Error", producerError(), 1, []Itemer{ItemTest{}},
+			"Failed to send message to topic shop_items because of: Send message to kafka failed because of test error",
+		},
+		{
+			"Item Delivery Error", producerChannelError(), 1, []Itemer{ItemTest{}},
+			"Failed to send message to topic shop_items because of: Delivery to kafka failed: Test channel error",
+		},
+		{
+			"Happy path single item",
+			producerSuccess(),
+			1,
+			[]Itemer{ItemTest{}},
+			"",
+		},
+		{
+			"Happy path item without topics",
+			producerSuccess(),
+			1,
+			[]Itemer{ItemNoTopicsTest{}},
+			"",
+		},
+		{
+			"Happy path multiple items",
+			producerSuccess(),
+			2,
+			[]Itemer{ItemTest{}, ItemMultipleTopicsTest{}, ItemTest{}, ItemTest{}, ItemMultipleTopicsTest{}, ItemTest{}, ItemTest{}, ItemTest{}},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chanItem := make(chan Itemer)
+			defer close(chanItem)
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			producer := &Producer{kafkaProducer: tt.producer, ctx: ctx, maxProducers: tt.maxProducers}
+			resChan, closeChan := producer.CreateProducersPool(chanItem)
+			wg := sync.WaitGroup{}
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if tt.maxProducers == 0 {
+					// pool is not started
+					return
+				}
+				for _, i := range tt.item {
+					chanItem <- i
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				topics := 0
+				reported := 0
+				for _, i := range tt.item {
+					res := <-resChan
+					topics += len(i.Topics())
+					reported += len(res.Topics)
+					if tt.err != "" {
+						require.Error(t, res.Err)
+						assert.Equal(t, tt.err, res.Err.Error())
+						category := apperror.CategoryDelivery
+						if tt.maxProducers == 0 {
+							category = apperror.CategoryConfig
+						}
+						assert.Equal(t, category, apperror.Category(res.Err))
+						continue
+					}
+					assert.NoError(t, res.Err)
+					if res.Err == nil {
+						assert.Equal(t, i.GetContext(), res.ItemContext)
+						assert.Equal(t, i.GetID(), res.ItemID)
+					}
+				}
+				if tt.maxProducers != 0 {
+					// every topic of every item is reported
+					assert.Equal(t, topics, reported)
+				}
+			}()
+			wg.Wait()
+			cancelFunc()
+			<-closeChan
+			_, ok := <-resChan
+			assert.False(t, ok)
+		})
+	}
+}
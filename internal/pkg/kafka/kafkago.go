@@ -0,0 +1,249 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// kafkaGoProducer adapts pure go kafka writer to ProducerProvider.
+// Writer blocks until batch is written, so every message is written in its own goroutine
+// and concurrent messages are collected into batches by writer.
+type kafkaGoProducer struct {
+	writer   *kafkago.Writer
+	events   chan Event
+	inflight int64
+	wg       sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+	// partitionCache is used when partition is chosen by Producer
+	partitionCache partitionCache
+	// written are messages being written. Writer doesn't carry opaque, so messages reported
+	// in completion are matched by topic and identity of key and value which reference original slices.
+	mu      sync.Mutex
+	written map[writtenKey]*Message
+	// closed is set under mu when Close started, so no new write is added while writes are awaited
+	closed bool
+	// totals of messages written to brokers
+	txMessages int64
+	txBytes    int64
+}
+
+type writtenKey struct {
+	topic string
+	key   *byte
+	value *byte
+}
+
+func newWrittenKey(topic string, key, value []byte) writtenKey {
+	wk := writtenKey{topic: topic}
+	if cap(key) > 0 {
+		wk.key = &key[:1][0]
+	}
+	if cap(value) > 0 {
+		wk.value = &value[:1][0]
+	}
+	return wk
+}
+
+// newKafkaGoProducer creates pure go producer. Connection is established on first write.
+func newKafkaGoProducer(addr string, security Security, batch Batch) (*kafkaGoProducer, error) {
+	err := security.Validate()
+	if err != nil {
+		return nil, err
+	}
+	transport := &kafkago.Transport{
+		DialTimeout: timeoutMs * time.Millisecond,
+	}
+	transport.TLS, err = security.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.SASL, err = security.saslMechanism()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	k := &kafkaGoProducer{
+		writer: &kafkago.Writer{
+			Addr:         kafkago.TCP(strings.Split(addr, ",")...),
+			RequiredAcks: kafkago.RequireAll,
+			BatchSize:    batch.Size,
+			BatchTimeout: time.Duration(batch.linger()) * time.Millisecond,
+			ReadTimeout:  timeoutMs * time.Millisecond,
+			WriteTimeout: timeoutMs * time.Millisecond,
+			Transport:    transport,
+			Balancer:     explicitBalancer{fallback: &kafkago.RoundRobin{}},
+		},
+		events:  make(chan Event, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+		written: map[writtenKey]*Message{},
+	}
+	k.writer.Completion = k.completed
+	return k, nil
+}
+
+// completed sets position of written messages. It is called before WriteMessages returns.
+func (k *kafkaGoProducer) completed(messages []kafkago.Message, err error) {
+	if err != nil {
+		return
+	}
+	atomic.AddInt64(&k.txMessages, int64(len(messages)))
+	for _, km := range messages {
+		atomic.AddInt64(&k.txBytes, int64(len(km.Key)+len(km.Value)))
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, km := range messages {
+		if m, ok := k.written[newWrittenKey(km.Topic, km.Key, km.Value)]; ok {
+			m.Partition = int32(km.Partition)
+			m.Offset = km.Offset
+			m.Timestamp = km.Time
+		}
+	}
+}
+
+func (k *kafkaGoProducer) Produce(m *Message, deliveryChan chan Event) error {
+	km := kafkago.Message{Topic: m.Topic, Partition: int(m.Partition), Key: m.Key, Value: m.Value}
+	for _, h := range m.Headers {
+		km.Headers = append(km.Headers, kafkago.Header{Key: h.Key, Value: h.Value})
+	}
+	m.Offset = OffsetUnknown
+	wk := newWrittenKey(m.Topic, m.Key, m.Value)
+	k.mu.Lock()
+	if k.closed {
+		k.mu.Unlock()
+		return ErrProducerClosed
+	}
+	// position of message which could not be matched unambiguously is not reported
+	_, tracked := k.written[wk]
+	if !tracked {
+		k.written[wk] = m
+	}
+	atomic.AddInt64(&k.inflight, 1)
+	k.wg.Add(1)
+	k.mu.Unlock()
+	go func() {
+		defer k.wg.Done()
+		err := k.writer.WriteMessages(k.ctx, km)
+		if !tracked {
+			k.mu.Lock()
+			delete(k.written, wk)
+			k.mu.Unlock()
+		}
+		// single message is written - report its own error
+		if we, ok := err.(kafkago.WriteErrors); ok && len(we) == 1 {
+			err = we[0]
+		}
+		m.Err = err
+		atomic.AddInt64(&k.inflight, -1)
+		if deliveryChan != nil {
+			deliveryChan <- m
+		} else {
+			k.events <- m
+		}
+	}()
+	return nil
+}
+
+// QueueStats returns number of messages being written and totals of written messages
+func (k *kafkaGoProducer) QueueStats() QueueStats {
+	return QueueStats{
+		Messages:   int(atomic.LoadInt64(&k.inflight)),
+		TxMessages: atomic.LoadInt64(&k.txMessages),
+		TxBytes:    atomic.LoadInt64(&k.txBytes),
+	}
+}
+
+func (k *kafkaGoProducer) Events() chan Event {
+	return k.events
+}
+
+func (k *kafkaGoProducer) Flush(timeoutMs int) int {
+	deadline := time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if atomic.LoadInt64(&k.inflight) == 0 {
+			return 0
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return int(atomic.LoadInt64(&k.inflight))
+}
+
+// Close aborts writes in progress. Messages produced after Close started are rejected with ErrProducerClosed,
+// so events are closed only when no write could report into them anymore.
+func (k *kafkaGoProducer) Close() {
+	k.mu.Lock()
+	if k.closed {
+		k.mu.Unlock()
+		return
+	}
+	k.closed = true
+	k.mu.Unlock()
+	// abort writes which are still in progress
+	k.cancel()
+	k.writer.Close()
+	// reports could not be delivered anymore
+	go func() {
+		for range k.events {
+		}
+	}()
+	k.wg.Wait()
+	close(k.events)
+}
+
+// tlsConfig returns tls configuration for protocols which require encryption
+func (s Security) tlsConfig() (*tls.Config, error) {
+	protocol := strings.ToLower(s.Protocol)
+	if protocol != "ssl" && protocol != "sasl_ssl" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if s.CALocation != "" {
+		ca, err := ioutil.ReadFile(s.CALocation)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA certificate: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("CA certificate '%s' does not contain valid PEM certificates", s.CALocation)
+		}
+	}
+	if s.CertLocation != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertLocation, s.KeyLocation)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// saslMechanism returns authentication mechanism for protocols which require it
+func (s Security) saslMechanism() (sasl.Mechanism, error) {
+	protocol := strings.ToLower(s.Protocol)
+	if protocol != "sasl_plaintext" && protocol != "sasl_ssl" {
+		return nil, nil
+	}
+	switch strings.ToUpper(s.SASLMechanism) {
+	case "PLAIN":
+		return plain.Mechanism{Username: s.SASLUsername, Password: s.SASLPassword}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, s.SASLUsername, s.SASLPassword)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, s.SASLUsername, s.SASLPassword)
+	}
+	return nil, fmt.Errorf("SASL mechanism should be provided for protocol '%s'", s.Protocol)
+}
@@ -0,0 +1,151 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKafkaProducerDriver(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		err    string
+	}{
+		{"unknown driver", "abc", "Unable to init connection to Kafka: Kafka driver 'abc' is not supported"},
+		{"pure go driver", DriverKafkaGo, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewKafkaProducer(context.Background(), Config{Address: "localhost:9092", Driver: tt.driver})
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.IsType(t, &kafkaGoProducer{}, p.kafkaProducer)
+				p.Close()
+			}
+		})
+	}
+}
+
+func TestBatchKafkaGo(t *testing.T) {
+	tests := []struct {
+		name    string
+		batch   Batch
+		size    int
+		timeout time.Duration
+	}{
+		{"default", Batch{}, 0, lingerMs * time.Millisecond},
+		{"configured", Batch{Size: 500, Linger: 50 * time.Millisecond}, 500, 50 * time.Millisecond},
+		{"rounded up", Batch{Linger: time.Microsecond}, 0, time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := newKafkaGoProducer("localhost:9092", Security{}, tt.batch)
+			require.NoError(t, err)
+			defer k.Close()
+			assert.Equal(t, tt.size, k.writer.BatchSize)
+			assert.Equal(t, tt.timeout, k.writer.BatchTimeout)
+		})
+	}
+}
+
+func TestCloseKafkaGo(t *testing.T) {
+	k, err := newKafkaGoProducer("127.0.0.1:1", Security{}, Batch{})
+	require.NoError(t, err)
+	// messages are produced while producer is closed, e.g. by retries
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := k.Produce(&Message{Topic: "shop_items", Value: []byte{byte(i)}}, nil); err != nil {
+				assert.Equal(t, ErrProducerClosed, err)
+			}
+		}(i)
+	}
+	k.Close()
+	wg.Wait()
+	assert.Equal(t, ErrProducerClosed, k.Produce(&Message{Topic: "shop_items", Value: []byte("late")}, nil))
+	// producer could be closed more than once
+	k.Close()
+}
+
+func TestSecurityKafkaGo(t *testing.T) {
+	tests := []struct {
+		name      string
+		security  Security
+		err       string
+		tls       bool
+		mechanism string
+	}{
+		{"plaintext", Security{}, "", false, ""},
+		{"ssl", Security{Protocol: "SSL"}, "", true, ""},
+		{"missing CA", Security{Protocol: "ssl", CALocation: "/not/existing/ca.pem"}, "Unable to read CA certificate: open /not/existing/ca.pem: no such file or directory", false, ""},
+		{"missing client certificate", Security{Protocol: "ssl", CertLocation: "/cert.pem", KeyLocation: "/key.pem"}, "Unable to load client certificate: open /cert.pem: no such file or directory", false, ""},
+		{"sasl without mechanism", Security{Protocol: "sasl_plaintext"}, "SASL mechanism should be provided for protocol 'sasl_plaintext'", false, ""},
+		{"sasl plain", Security{Protocol: "sasl_plaintext", SASLMechanism: "PLAIN", SASLUsername: "u", SASLPassword: "p"}, "", false, "PLAIN"},
+		{"sasl scram over ssl", Security{Protocol: "sasl_ssl", SASLMechanism: "SCRAM-SHA-512", SASLUsername: "u", SASLPassword: "p"}, "", true, "SCRAM-SHA-512"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := tt.security.tlsConfig()
+			if err == nil {
+				mechanism, errM := tt.security.saslMechanism()
+				err = errM
+				if err == nil {
+					if tt.mechanism == "" {
+						assert.Nil(t, mechanism)
+					} else {
+						assert.Equal(t, tt.mechanism, mechanism.Name())
+					}
+				}
+			}
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.tls, tlsConfig != nil)
+		})
+	}
+	m, err := Security{Protocol: "sasl_plaintext", SASLMechanism: "plain", SASLUsername: "u", SASLPassword: "p"}.saslMechanism()
+	require.NoError(t, err)
+	assert.Equal(t, plain.Mechanism{Username: "u", Password: "p"}, m)
+}
+
+func TestKafkaGoCompleted(t *testing.T) {
+	k := &kafkaGoProducer{written: map[writtenKey]*Message{}}
+	key, value := []byte("id"), []byte("payload")
+	m := &Message{Topic: "items", Partition: PartitionAny, Key: key, Value: value, Offset: OffsetUnknown}
+	other := &Message{Topic: "bidding", Partition: PartitionAny, Key: key, Value: value, Offset: OffsetUnknown}
+	k.written[newWrittenKey(m.Topic, m.Key, m.Value)] = m
+	k.written[newWrittenKey(other.Topic, other.Key, other.Value)] = other
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// failed batch doesn't report position
+	k.completed([]kafkago.Message{{Topic: "items", Partition: 1, Offset: 7, Key: key, Value: value}}, errors.New("test error"))
+	assert.Equal(t, OffsetUnknown, m.Offset)
+
+	// messages are matched by topic and identity of key and value, copy of value is not matched
+	k.completed([]kafkago.Message{
+		{Topic: "items", Partition: 1, Offset: 7, Time: ts, Key: key, Value: value},
+		{Topic: "bidding", Partition: 0, Offset: 3, Time: ts, Key: key, Value: []byte("payload")},
+	}, nil)
+	assert.Equal(t, int32(1), m.Partition)
+	assert.Equal(t, int64(7), m.Offset)
+	assert.Equal(t, ts, m.Timestamp)
+	assert.Equal(t, OffsetUnknown, other.Offset)
+	// only written messages are counted as transmitted
+	assert.Equal(t, QueueStats{TxMessages: 2, TxBytes: 18}, k.QueueStats())
+}
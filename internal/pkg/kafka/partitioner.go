@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const (
+	// PartitionAny lets client choose partition of message by its own default partitioner
+	PartitionAny int32 = -1
+	// OffsetUnknown is reported when offset of message is not known
+	OffsetUnknown int64 = -1
+)
+
+const (
+	// PartitionerDefault partition is chosen by default partitioner of kafka client
+	PartitionerDefault Partitioner = "default"
+	// PartitionerConsistent CRC32 hash of partition key, compatible with librdkafka consistent partitioner
+	PartitionerConsistent Partitioner = "consistent"
+	// PartitionerMurmur2 murmur2 hash of partition key, compatible with default partitioner of java client
+	PartitionerMurmur2 Partitioner = "murmur2"
+	// PartitionerRoundRobin messages are spread evenly over partitions regardless of key
+	PartitionerRoundRobin Partitioner = "round-robin"
+
+	// partitionsTTL how long number of topic partitions is cached
+	partitionsTTL = time.Minute
+)
+
+// Partitioner defines how partition of message is chosen
+type Partitioner string
+
+// ParsePartitioner validates provided partitioner name
+func ParsePartitioner(s string) (Partitioner, error) {
+	switch pt := Partitioner(s); pt {
+	case PartitionerDefault, PartitionerConsistent, PartitionerMurmur2, PartitionerRoundRobin:
+		return pt, nil
+	}
+	return "", fmt.Errorf("Partitioner '%s' is not supported", s)
+}
+
+// PartitionKeyer is implemented by items which are partitioned by other value than message key,
+// e.g. manufacturer, so all items of the same manufacturer are kept in order. When nil is returned message key is used.
+type PartitionKeyer interface {
+	PartitionKey() []byte
+}
+
+// partitionCounter is implemented by providers which are able to return number of topic partitions
+type partitionCounter interface {
+	partitions(topic string) (int, error)
+}
+
+// partitioner chooses partitions of messages
+type partitioner struct {
+	strategy Partitioner
+	next     uint32
+}
+
+// partition returns partition of message with provided partition key produced by provider into topic.
+// Messages without partition key are spread by round robin.
+// PartitionAny is returned for default partitioner and for providers which are not able to count partitions.
+func (pt *partitioner) partition(provider ProducerProvider, topic string, key []byte) (int32, error) {
+	if pt == nil || pt.strategy == "" || pt.strategy == PartitionerDefault {
+		return PartitionAny, nil
+	}
+	pc, ok := provider.(partitionCounter)
+	if !ok {
+		return PartitionAny, nil
+	}
+	n, err := pc.partitions(topic)
+	if err != nil {
+		return PartitionAny, fmt.Errorf("Unable to get number of partitions of topic %s because of %w", topic, err)
+	}
+	if n <= 0 {
+		return PartitionAny, fmt.Errorf("Topic %s has no partitions", topic)
+	}
+	return int32(pt.choose(key, uint32(n))), nil
+}
+
+func (pt *partitioner) choose(key []byte, n uint32) uint32 {
+	if pt.strategy == PartitionerRoundRobin || len(key) == 0 {
+		return (atomic.AddUint32(&pt.next, 1) - 1) % n
+	}
+	if pt.strategy == PartitionerMurmur2 {
+		return (murmur2(key) & 0x7fffffff) % n
+	}
+	return crc32.ChecksumIEEE(key) % n
+}
+
+// murmur2 is hash used by java client for partitioning
+func murmur2(data []byte) uint32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+// partitionCache keeps number of topic partitions, so metadata is not requested for every message
+type partitionCache struct {
+	mu     sync.Mutex
+	counts map[string]partitionCount
+}
+
+type partitionCount struct {
+	n       int
+	expires time.Time
+}
+
+// get returns cached number of partitions or loads it
+func (c *partitionCache) get(topic string, load func() (int, error)) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pc, ok := c.counts[topic]; ok && time.Now().Before(pc.expires) {
+		return pc.n, nil
+	}
+	n, err := load()
+	if err != nil {
+		return 0, err
+	}
+	if c.counts == nil {
+		c.counts = map[string]partitionCount{}
+	}
+	c.counts[topic] = partitionCount{n: n, expires: time.Now().Add(partitionsTTL)}
+	return n, nil
+}
+
+func (k *kafkaGoProducer) partitions(topic string) (int, error) {
+	return k.partitionCache.get(topic, func() (int, error) {
+		ctx, cancel := context.WithTimeout(k.ctx, timeoutMs*time.Millisecond)
+		defer cancel()
+		md, err := k.client().Metadata(ctx, &kafkago.MetadataRequest{Topics: []string{topic}})
+		if err != nil {
+			return 0, err
+		}
+		for _, t := range md.Topics {
+			if t.Name == topic {
+				if t.Error != nil {
+					return 0, t.Error
+				}
+				return len(t.Partitions), nil
+			}
+		}
+		return 0, fmt.Errorf("Topic %s does not exist", topic)
+	})
+}
+
+// explicitBalancer writes messages into partition chosen by Producer, other messages are balanced by fallback
+type explicitBalancer struct {
+	fallback kafkago.Balancer
+}
+
+func (b explicitBalancer) Balance(msg kafkago.Message, partitions ...int) int {
+	for _, p := range partitions {
+		if p == msg.Partition {
+			return p
+		}
+	}
+	return b.fallback.Balance(msg, partitions...)
+}
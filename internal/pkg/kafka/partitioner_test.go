@@ -0,0 +1,140 @@
+package kafka
+
+import (
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// producerPartitionsMock is producer which knows number of topic partitions
+type producerPartitionsMock struct {
+	producerMock
+	count int
+	err   error
+}
+
+func (pp producerPartitionsMock) partitions(topic string) (int, error) { return pp.count, pp.err }
+
+func TestParsePartitioner(t *testing.T) {
+	for _, s := range []string{"default", "consistent", "murmur2", "round-robin"} {
+		pt, err := ParsePartitioner(s)
+		require.NoError(t, err)
+		assert.Equal(t, Partitioner(s), pt)
+	}
+	_, err := ParsePartitioner("random")
+	require.Error(t, err)
+	assert.Equal(t, "Partitioner 'random' is not supported", err.Error())
+}
+
+func TestMurmur2(t *testing.T) {
+	// values are taken from tests of java client
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, expected := range tests {
+		assert.Equal(t, expected, int32(murmur2([]byte(key))), key)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	provider := producerPartitionsMock{count: 3}
+	tests := []struct {
+		name     string
+		pt       *partitioner
+		provider ProducerProvider
+		keys     []string
+		expected []int32
+	}{
+		{name: "no partitioner", pt: nil, provider: provider, keys: []string{"a"}, expected: []int32{PartitionAny}},
+		{name: "default", pt: &partitioner{strategy: PartitionerDefault}, provider: provider, keys: []string{"a"}, expected: []int32{PartitionAny}},
+		{name: "provider without partitions", pt: &partitioner{strategy: PartitionerMurmur2}, provider: producerMock{}, keys: []string{"a"}, expected: []int32{PartitionAny}},
+		{
+			name:     "consistent",
+			pt:       &partitioner{strategy: PartitionerConsistent},
+			provider: provider,
+			keys:     []string{"abc", "abc"},
+			expected: []int32{int32(crc32.ChecksumIEEE([]byte("abc")) % 3), int32(crc32.ChecksumIEEE([]byte("abc")) % 3)},
+		},
+		{name: "murmur2", pt: &partitioner{strategy: PartitionerMurmur2}, provider: provider, keys: []string{"abc", "21"}, expected: []int32{479470107 % 3, (-973932308 & 0x7fffffff) % 3}},
+		{name: "round robin", pt: &partitioner{strategy: PartitionerRoundRobin}, provider: provider, keys: []string{"a", "a", "a", "a"}, expected: []int32{0, 1, 2, 0}},
+		{name: "no key", pt: &partitioner{strategy: PartitionerMurmur2}, provider: provider, keys: []string{"", ""}, expected: []int32{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partitions := []int32{}
+			for _, k := range tt.keys {
+				p, err := tt.pt.partition(tt.provider, "items", []byte(k))
+				require.NoError(t, err)
+				partitions = append(partitions, p)
+			}
+			assert.Equal(t, tt.expected, partitions)
+		})
+	}
+}
+
+func TestPartitionErrors(t *testing.T) {
+	pt := &partitioner{strategy: PartitionerConsistent}
+	_, err := pt.partition(producerPartitionsMock{err: errors.New("test error")}, "items", []byte("a"))
+	require.Error(t, err)
+	assert.Equal(t, "Unable to get number of partitions of topic items because of test error", err.Error())
+	_, err = pt.partition(producerPartitionsMock{}, "items", []byte("a"))
+	require.Error(t, err)
+	assert.Equal(t, "Topic items has no partitions", err.Error())
+}
+
+func TestPartitionCache(t *testing.T) {
+	c := partitionCache{}
+	loads := 0
+	load := func() (int, error) {
+		loads++
+		return 5, nil
+	}
+	for i := 0; i < 2; i++ {
+		n, err := c.get("items", load)
+		require.NoError(t, err)
+		assert.Equal(t, 5, n)
+	}
+	assert.Equal(t, 1, loads)
+	// errors are not cached
+	_, err := c.get("bidding", func() (int, error) { return 0, errors.New("test error") })
+	require.Error(t, err)
+	n, err := c.get("bidding", load)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+}
+
+func TestExplicitBalancer(t *testing.T) {
+	b := explicitBalancer{fallback: &kafkago.RoundRobin{}}
+	assert.Equal(t, 2, b.Balance(kafkago.Message{Partition: 2}, 0, 1, 2))
+	assert.Equal(t, 0, b.Balance(kafkago.Message{Partition: int(PartitionAny)}, 0, 1, 2))
+	assert.Equal(t, 1, b.Balance(kafkago.Message{Partition: int(PartitionAny)}, 0, 1, 2))
+}
+
+type ItemPartitionKeyTest struct{ ItemTest }
+
+func (i ItemPartitionKeyTest) PartitionKey() []byte { return []byte("abc") }
+
+func TestProducePartitionKey(t *testing.T) {
+	events := make(chan Event, 1)
+	p := &Producer{
+		kafkaProducer: producerPartitionsMock{producerMock: producerMock{events: events}, count: 4},
+		chanRes:       make(chan Result, 1),
+		partitioner:   &partitioner{strategy: PartitionerMurmur2},
+	}
+	p.produceItem(ItemPartitionKeyTest{})
+	m := (<-events).(*Message)
+	// partition is chosen by partition key while message key is not changed
+	assert.Equal(t, int32(479470107%4), m.Partition)
+	assert.Equal(t, []byte("testID"), m.Key)
+	p.complete(m.Opaque.(*delivery), m.Topic, nil)
+	require.NoError(t, (<-p.chanRes).Err)
+}
@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scaleInterval how often queue depth is checked when pool is auto scaled
+const scaleInterval = 100 * time.Millisecond
+
+// pool runs producers reading items from queue.
+// Number of producers is kept between min and max: producer is added when queue is filled at least by half
+// and removed when queue is empty. Auto scaling works only for buffered queue.
+type pool struct {
+	producer *Producer
+	items    <-chan Itemer
+	min      int
+	max      int
+	wg       sync.WaitGroup
+	// one channel per running producer, closing it stops producer
+	stops []chan struct{}
+	size  int32
+}
+
+// run starts min producers and scales them until context is cancelled. Returns when all producers exited.
+func (pl *pool) run() {
+	for i := 0; i < pl.min; i++ {
+		pl.grow()
+	}
+	if pl.min < pl.max && cap(pl.items) > 0 {
+		t := time.NewTicker(scaleInterval)
+		defer t.Stop()
+		continueLoop := true
+		for continueLoop {
+			select {
+			case <-t.C:
+				pl.scale()
+			case <-pl.producer.ctx.Done():
+				continueLoop = false
+			}
+		}
+	}
+	pl.wg.Wait()
+}
+
+func (pl *pool) scale() {
+	running := len(pl.stops)
+	switch pl.desired(len(pl.items), running) - running {
+	case 1:
+		pl.grow()
+	case -1:
+		pl.shrink()
+	}
+}
+
+// desired returns number of producers for current depth of queue. Pool is scaled by one producer at a time.
+func (pl *pool) desired(depth, running int) int {
+	switch {
+	case depth*2 >= cap(pl.items) && running < pl.max:
+		return running + 1
+	case depth == 0 && running > pl.min:
+		return running - 1
+	}
+	return running
+}
+
+func (pl *pool) grow() {
+	stop := make(chan struct{})
+	pl.stops = append(pl.stops, stop)
+	atomic.AddInt32(&pl.size, 1)
+	pl.wg.Add(1)
+	go func() {
+		defer pl.wg.Done()
+		defer atomic.AddInt32(&pl.size, -1)
+		continueLoop := true
+		for continueLoop {
+			select {
+			// if this channel will be closed - we will go here with default value for item
+			case item := <-pl.items:
+				// all items should belong to some context
+				if item != nil && item.GetContext() != "" {
+					pl.producer.produceItem(item)
+				}
+			case <-stop:
+				continueLoop = false
+			case <-pl.producer.ctx.Done():
+				continueLoop = false
+			}
+		}
+	}()
+}
+
+func (pl *pool) shrink() {
+	last := len(pl.stops) - 1
+	close(pl.stops[last])
+	pl.stops = pl.stops[:last]
+}
@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolDesired(t *testing.T) {
+	tests := []struct {
+		name     string
+		depth    int
+		running  int
+		expected int
+	}{
+		{"queue is filled by half", 2, 2, 3},
+		{"queue is full but max reached", 4, 4, 4},
+		{"queue is used", 1, 3, 3},
+		{"queue is empty", 0, 3, 2},
+		{"queue is empty but min reached", 0, 1, 1},
+	}
+	pl := &pool{items: make(chan Itemer, 4), min: 1, max: 4}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, pl.desired(tt.depth, tt.running))
+		})
+	}
+}
+
+func TestPoolScaling(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	p := &Producer{kafkaProducer: producerSuccess(), ctx: ctx, minProducers: 1, maxProducers: 3}
+	chanItem := make(chan Itemer, 2)
+	_, closeChan := p.CreateProducersPool(chanItem)
+	require.Eventually(t, func() bool { return p.Producers() == 1 }, time.Second, time.Millisecond)
+	cancelFunc()
+	<-closeChan
+	assert.Equal(t, 0, p.Producers())
+}
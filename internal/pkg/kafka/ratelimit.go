@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit limits produce throughput into every cluster. Zero means no limit
+type RateLimit struct {
+	MessagesPerSecond int
+	BytesPerSecond    int
+}
+
+// limiter is token bucket refilled by rate tokens per second with capacity of one second.
+// Tokens could be borrowed: caller waits until debt is paid, so requests larger than capacity are allowed.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate int) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// reserve takes n tokens and returns how long caller should wait before using them
+func (l *limiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// rateLimits limits number of messages and bytes produced into cluster
+type rateLimits struct {
+	messages *limiter
+	bytes    *limiter
+}
+
+func newRateLimits(rl RateLimit) *rateLimits {
+	if rl.MessagesPerSecond <= 0 && rl.BytesPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimits{messages: newLimiter(rl.MessagesPerSecond), bytes: newLimiter(rl.BytesPerSecond)}
+}
+
+// wait blocks until message of provided size could be produced or context is done. Returns time spent waiting.
+func (r *rateLimits) wait(ctx context.Context, size int) time.Duration {
+	if r == nil {
+		return 0
+	}
+	var delay time.Duration
+	if r.messages != nil {
+		delay = r.messages.reserve(1)
+	}
+	if r.bytes != nil {
+		if d := r.bytes.reserve(size); d > delay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return 0
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	started := time.Now()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+	return time.Since(started)
+}
@@ -0,0 +1,39 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterReserve(t *testing.T) {
+	l := newLimiter(10)
+	// capacity of one second is available immediately
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, time.Duration(0), l.reserve(1))
+	}
+	assert.InDelta(t, float64(100*time.Millisecond), float64(l.reserve(1)), float64(5*time.Millisecond))
+	// request larger than capacity is allowed but caller waits for it
+	assert.InDelta(t, float64(2100*time.Millisecond), float64(l.reserve(20)), float64(5*time.Millisecond))
+	assert.Nil(t, newLimiter(0))
+}
+
+func TestRateLimitsWait(t *testing.T) {
+	assert.Nil(t, newRateLimits(RateLimit{}))
+	var r *rateLimits
+	assert.Equal(t, time.Duration(0), r.wait(context.Background(), 100))
+
+	r = newRateLimits(RateLimit{BytesPerSecond: 1000})
+	assert.Equal(t, time.Duration(0), r.wait(context.Background(), 1000))
+	waited := r.wait(context.Background(), 20)
+	assert.True(t, waited >= 15*time.Millisecond, waited)
+
+	// waiting is interrupted when context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r = newRateLimits(RateLimit{MessagesPerSecond: 1})
+	r.wait(ctx, 0)
+	assert.True(t, r.wait(ctx, 0) < 100*time.Millisecond)
+}
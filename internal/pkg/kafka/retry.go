@@ -0,0 +1,43 @@
+package kafka
+
+import (
+	"errors"
+	"time"
+)
+
+// Retry defines how transient delivery errors are retried.
+// Delay before each next attempt is doubled starting from InitialBackoff up to MaxBackoff.
+type Retry struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// temporary is implemented by errors of pure go driver and network errors
+type temporary interface {
+	Temporary() bool
+}
+
+// isRetriable checks whether error could be fixed by another attempt
+func isRetriable(err error) bool {
+	if retriable, ok := confluentRetriable(err); ok {
+		return retriable
+	}
+	var te temporary
+	if errors.As(err, &te) {
+		return te.Temporary()
+	}
+	return false
+}
+
+// backoff returns delay before provided attempt (starting from 1)
+func (r Retry) backoff(attempt int) time.Duration {
+	d := r.InitialBackoff
+	for i := 1; i < attempt && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	if r.MaxBackoff > 0 && d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	return d
+}
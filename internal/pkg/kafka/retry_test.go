@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetriable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"not kafka error", errors.New("test"), false},
+		{"temporary error of pure go driver", kafkago.LeaderNotAvailable, true},
+		{"wrapped temporary error", fmt.Errorf("wrapped: %w", kafkago.RequestTimedOut), true},
+		{"not temporary error of pure go driver", kafkago.MessageSizeTooLarge, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isRetriable(tt.err))
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := Retry{MaxRetries: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, r.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, r.backoff(2))
+	assert.Equal(t, 800*time.Millisecond, r.backoff(4))
+	assert.Equal(t, time.Second, r.backoff(5))
+	assert.Equal(t, time.Second, r.backoff(50))
+}
+
+// temporaryError is retriable error which does not depend on driver
+type temporaryError string
+
+func (e temporaryError) Error() string   { return string(e) }
+func (e temporaryError) Temporary() bool { return true }
+
+// producerFlaky reports retriable delivery error for first failures messages
+type producerFlaky struct {
+	producerMock
+	failures int32
+}
+
+func (pp *producerFlaky) Produce(m *Message, c chan Event) error {
+	go func() {
+		m.Err = nil
+		if atomic.AddInt32(&pp.failures, -1) >= 0 {
+			m.Err = temporaryError("leader election")
+		}
+		pp.events <- m
+	}()
+	return nil
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		retries  int
+		err      string
+	}{
+		{"recovered", 2, 2, ""},
+		{"retries exceeded", 3, 2, "Failed to send message to topic shop_items because of: Delivery to kafka failed: leader election"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			pp := &producerFlaky{producerMock: producerSuccess(), failures: tt.failures}
+			producer := &Producer{kafkaProducer: pp, ctx: ctx, maxProducers: 1, retry: Retry{MaxRetries: tt.retries, InitialBackoff: time.Millisecond}}
+			chanItem := make(chan Itemer)
+			defer close(chanItem)
+			resChan, _ := producer.CreateProducersPool(chanItem)
+			chanItem <- ItemTest{}
+			res := <-resChan
+			if tt.err != "" {
+				require.Error(t, res.Err)
+				assert.Equal(t, tt.err, res.Err.Error())
+			} else {
+				require.NoError(t, res.Err)
+			}
+		})
+	}
+}
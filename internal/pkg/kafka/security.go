@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Security holds settings for encryption and authentication of connection to kafka.
+// Empty values are not passed to librdkafka, so its defaults are used.
+type Security struct {
+	// Protocol one of plaintext, ssl, sasl_plaintext, sasl_ssl
+	Protocol string
+	// SASLMechanism one of PLAIN, SCRAM-SHA-256, SCRAM-SHA-512
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+	// CALocation path to CA certificate used to verify broker's certificate
+	CALocation string
+	// CertLocation and KeyLocation paths to client's certificate and private key
+	CertLocation string
+	KeyLocation  string
+}
+
+// Validate checks that provided settings are consistent
+func (s Security) Validate() error {
+	switch strings.ToLower(s.Protocol) {
+	case "", "plaintext", "ssl", "sasl_plaintext", "sasl_ssl":
+	default:
+		return fmt.Errorf("Security protocol '%s' is not supported", s.Protocol)
+	}
+	switch strings.ToUpper(s.SASLMechanism) {
+	case "":
+	case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		if s.SASLUsername == "" || s.SASLPassword == "" {
+			return fmt.Errorf("SASL mechanism '%s' requires username and password", s.SASLMechanism)
+		}
+	default:
+		return fmt.Errorf("SASL mechanism '%s' is not supported", s.SASLMechanism)
+	}
+	if (s.CertLocation == "") != (s.KeyLocation == "") {
+		return fmt.Errorf("Client certificate and key should be provided together")
+	}
+	return nil
+}
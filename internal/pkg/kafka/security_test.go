@@ -0,0 +1,34 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		security Security
+		err      string
+	}{
+		{"empty", Security{}, ""},
+		{"wrong protocol", Security{Protocol: "abc"}, "Security protocol 'abc' is not supported"},
+		{"wrong mechanism", Security{SASLMechanism: "abc"}, "SASL mechanism 'abc' is not supported"},
+		{"no password", Security{SASLMechanism: "PLAIN", SASLUsername: "user"}, "SASL mechanism 'PLAIN' requires username and password"},
+		{"no key", Security{CertLocation: "/cert.pem"}, "Client certificate and key should be provided together"},
+		{"sasl ssl", Security{Protocol: "SASL_SSL", SASLMechanism: "scram-sha-512", SASLUsername: "user", SASLPassword: "pass"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.security.Validate()
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// TopicSpec describes how missing topics are created
+type TopicSpec struct {
+	Partitions        int
+	ReplicationFactor int
+}
+
+// topicAdmin is implemented by producer providers which are able to check and create topics
+type topicAdmin interface {
+	missingTopics(ctx context.Context, topics []string) ([]string, error)
+	createTopics(ctx context.Context, topics []string, spec TopicSpec) error
+}
+
+// EnsureTopics verifies that all topics exist in every cluster. When create is set missing topics are created according to spec,
+// otherwise error with list of missing topics is returned.
+func (p *Producer) EnsureTopics(topics []string, create bool, spec TopicSpec) error {
+	for _, c := range p.clusters() {
+		err := p.ensureTopics(c.provider, topics, create, spec)
+		if err != nil {
+			return fmt.Errorf("Cluster '%s': %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+func (p *Producer) ensureTopics(provider ProducerProvider, topics []string, create bool, spec TopicSpec) error {
+	admin, ok := provider.(topicAdmin)
+	if !ok {
+		return fmt.Errorf("Kafka driver does not support check of topics")
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, timeoutMs*time.Millisecond)
+	defer cancel()
+	missing, err := admin.missingTopics(ctx, topics)
+	if err != nil {
+		return fmt.Errorf("Unable to get topics metadata because of %w", err)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	if !create {
+		return fmt.Errorf("Topics do not exist: %s", strings.Join(missing, ", "))
+	}
+	err = admin.createTopics(ctx, missing, spec)
+	if err != nil {
+		return fmt.Errorf("Unable to create topics %s because of %w", strings.Join(missing, ", "), err)
+	}
+	return nil
+}
+
+func (k *kafkaGoProducer) client() *kafkago.Client {
+	return &kafkago.Client{Addr: k.writer.Addr, Timeout: timeoutMs * time.Millisecond, Transport: k.writer.Transport}
+}
+
+func (k *kafkaGoProducer) missingTopics(ctx context.Context, topics []string) ([]string, error) {
+	md, err := k.client().Metadata(ctx, &kafkago.MetadataRequest{})
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]struct{}{}
+	for _, t := range md.Topics {
+		if t.Error == nil {
+			existing[t.Name] = struct{}{}
+		}
+	}
+	missing := []string{}
+	for _, t := range topics {
+		if _, ok := existing[t]; !ok {
+			missing = append(missing, t)
+		}
+	}
+	return missing, nil
+}
+
+func (k *kafkaGoProducer) createTopics(ctx context.Context, topics []string, spec TopicSpec) error {
+	req := &kafkago.CreateTopicsRequest{}
+	for _, t := range topics {
+		req.Topics = append(req.Topics, kafkago.TopicConfig{Topic: t, NumPartitions: spec.Partitions, ReplicationFactor: spec.ReplicationFactor})
+	}
+	res, err := k.client().CreateTopics(ctx, req)
+	if err != nil {
+		return err
+	}
+	for _, t := range topics {
+		// topic could be created by another instance in the meantime
+		if err := res.Errors[t]; err != nil && !errors.Is(err, kafkago.TopicAlreadyExists) {
+			return fmt.Errorf("%s: %w", t, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type producerAdminMock struct {
+	producerMock
+	existing    []string
+	metadataErr error
+	createErr   error
+	created     []string
+}
+
+func (pp *producerAdminMock) missingTopics(ctx context.Context, topics []string) ([]string, error) {
+	if pp.metadataErr != nil {
+		return nil, pp.metadataErr
+	}
+	missing := []string{}
+	for _, t := range topics {
+		found := false
+		for _, e := range pp.existing {
+			found = found || e == t
+		}
+		if !found {
+			missing = append(missing, t)
+		}
+	}
+	return missing, nil
+}
+
+func (pp *producerAdminMock) createTopics(ctx context.Context, topics []string, spec TopicSpec) error {
+	pp.created = append(pp.created, topics...)
+	return pp.createErr
+}
+
+func TestEnsureTopics(t *testing.T) {
+	tests := []struct {
+		name     string
+		producer ProducerProvider
+		create   bool
+		err      string
+		created  []string
+	}{
+		{"driver without admin", producerSuccess(), false, "Cluster 'primary': Kafka driver does not support check of topics", nil},
+		{"metadata error", &producerAdminMock{metadataErr: errors.New("test error")}, false, "Cluster 'primary': Unable to get topics metadata because of test error", nil},
+		{"all topics exist", &producerAdminMock{existing: []string{"a", "b", "c"}}, false, "", nil},
+		{"missing topics", &producerAdminMock{existing: []string{"b"}}, false, "Cluster 'primary': Topics do not exist: a, c", nil},
+		{"create missing topics", &producerAdminMock{existing: []string{"b"}}, true, "", []string{"a", "c"}},
+		{"create error", &producerAdminMock{createErr: errors.New("test error")}, true, "Cluster 'primary': Unable to create topics a, b, c because of test error", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Producer{kafkaProducer: tt.producer, ctx: context.Background()}
+			err := p.EnsureTopics([]string{"c", "b", "a"}, tt.create, TopicSpec{Partitions: 1, ReplicationFactor: 1})
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+			if admin, ok := tt.producer.(*producerAdminMock); ok {
+				assert.Equal(t, tt.created, admin.created)
+			}
+		})
+	}
+}
+
+func TestEnsureTopicsMirrors(t *testing.T) {
+	mirror := &producerAdminMock{existing: []string{"a"}}
+	p := &Producer{
+		kafkaProducer: &producerAdminMock{existing: []string{"a", "b"}},
+		mirrors:       []cluster{{name: "cloud", provider: mirror}},
+		ctx:           context.Background(),
+	}
+	err := p.EnsureTopics([]string{"a", "b"}, false, TopicSpec{})
+	require.Error(t, err)
+	assert.Equal(t, "Cluster 'cloud': Topics do not exist: b", err.Error())
+	require.NoError(t, p.EnsureTopics([]string{"a", "b"}, true, TopicSpec{Partitions: 1, ReplicationFactor: 1}))
+	assert.Equal(t, []string{"b"}, mirror.created)
+}
@@ -0,0 +1,283 @@
+package parser
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/heureka"
+)
+
+// Decoder implements xml decode interface
+type Decoder interface {
+	Token() (xml.Token, error)
+	DecodeElement(v interface{}, start *xml.StartElement) error
+}
+
+// DefaultItemElement is name of element of items in heureka feed
+const DefaultItemElement = "SHOPITEM"
+
+// Options adapt parser to quirks of feed
+type Options struct {
+	// ItemElement is name of element of items, DefaultItemElement is used when it is empty
+	ItemElement string
+	// Charset overrides encoding declared by feed, e.g. when feed declares UTF-8 but is encoded in windows-1250.
+	// Encoding declared by feed is used when it is empty.
+	Charset string
+	// Stats are collected while feed is parsed when they are set
+	Stats *Stats
+	// Metadata of feed is collected when it is set
+	Metadata *Metadata
+}
+
+// Stats of parsing of feed. They could be read while feed is parsed.
+type Stats struct {
+	// decoding is time in nanoseconds spent by decoding of items, reading of stream included.
+	// It is the first field, so it is aligned for atomic operations on 32 bit platforms.
+	decoding int64
+}
+
+// Decoding returns time spent by decoding of items, time of reading of stream is included
+func (s *Stats) Decoding() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.decoding))
+}
+
+// maximum number of fields of metadata and maximum length of their values in characters, longer values are truncated
+const (
+	maxMetadataFields = 32
+	maxMetadataValue  = 256
+)
+
+// Metadata of feed, e.g. time when feed was generated or id of shop. Metadata is read from attributes of root element
+// and from text elements of root which precede the first item, e.g. <SHOP generated="..."><SHOP_ID>1</SHOP_ID><SHOPITEM>.
+// Fields are named by attributes and elements. Metadata is complete once the first item is sent or parsing is finished.
+type Metadata struct {
+	mu     sync.Mutex
+	fields map[string]string
+}
+
+// Fields returns copy of fields of metadata, nil is returned when feed has no metadata
+func (m *Metadata) Fields() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.fields) == 0 {
+		return nil
+	}
+	fields := make(map[string]string, len(m.fields))
+	for k, v := range m.fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+func (m *Metadata) set(name, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if r := []rune(value); len(r) > maxMetadataValue {
+		value = string(r[:maxMetadataValue])
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fields == nil {
+		m.fields = map[string]string{}
+	}
+	if _, ok := m.fields[name]; !ok && len(m.fields) >= maxMetadataFields {
+		return
+	}
+	m.fields[name] = value
+}
+
+// ProcessFeed loop through the channel and retrieve item from it.
+// Parsing is stopped and channels are closed when ctx is done, error of ctx is reported unless other error is pending.
+func ProcessFeed(ctx context.Context, readCloser io.ReadCloser) (<-chan heureka.Item, <-chan error) {
+	return ProcessFeedWithOptions(ctx, readCloser, Options{})
+}
+
+// ProcessFeedWithOptions is ProcessFeed with items parsed according to options
+func ProcessFeedWithOptions(ctx context.Context, readCloser io.ReadCloser, opts Options) (<-chan heureka.Item, <-chan error) {
+	element := opts.ItemElement
+	if element == "" {
+		element = DefaultItemElement
+	}
+	// try to unmarshal stream.
+	// If this stream is not represent expected schema - result will be empty.
+	chanItemProducer := make(chan heureka.Item)
+	chanItemError := make(chan error, 1)
+	go func() {
+		defer func() {
+			close(chanItemProducer)
+			close(chanItemError)
+		}()
+		d, err := newDecoder(readCloser, opts.Charset)
+		if err != nil {
+			chanItemError <- &apperror.ParseError{Err: err}
+			return
+		}
+		sc := &scanner{d: d, element: element, metadata: opts.Metadata}
+		for {
+			if ctx.Err() != nil {
+				stopped(ctx, chanItemError)
+				return
+			}
+			start := time.Now()
+			item, err := sc.next()
+			if opts.Stats != nil {
+				atomic.AddInt64(&opts.Stats.decoding, int64(time.Since(start)))
+			}
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				} else {
+					// in case of error - skip this item
+					chanItemError <- &apperror.ParseError{Err: fmt.Errorf("Failed to get item from stream: %w", err), Position: sc.position(err)}
+					err = d.Skip()
+					if err != nil {
+						chanItemError <- &apperror.ParseError{Err: fmt.Errorf("Failed to skip bad part: %w", err), Position: sc.position(err)}
+						break
+					}
+				}
+			}
+			if item != nil {
+				select {
+				case chanItemProducer <- *item:
+				case <-ctx.Done():
+					stopped(ctx, chanItemError)
+					return
+				}
+			}
+		}
+	}()
+	return chanItemProducer, chanItemError
+}
+
+// stopped reports that parsing was stopped because ctx is done, reader of errors could be gone already so it is not waited for
+func stopped(ctx context.Context, chanItemError chan<- error) {
+	select {
+	case chanItemError <- &apperror.ParseError{Err: fmt.Errorf("Parsing was stopped because of %w", ctx.Err())}:
+	default:
+	}
+}
+
+// newDecoder returns decoder of feed. Feed is decoded from charset when it is set, so encoding declared by feed is ignored.
+func newDecoder(r io.Reader, charset string) (*xml.Decoder, error) {
+	if charset == "" {
+		d := xml.NewDecoder(r)
+		d.CharsetReader = charsetReader
+		return d, nil
+	}
+	decoded, err := charsetReader(charset, r)
+	if err != nil {
+		return nil, err
+	}
+	d := xml.NewDecoder(decoded)
+	// input is already decoded
+	d.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	return d, nil
+}
+
+// getItemFromStream retrieves next item from xml
+// item can be nil if start tag of next element in feed will be not recognized
+// in this case error not provided and also will be nil
+func getItemFromStream(d Decoder, element string) (*heureka.Item, error) {
+	return (&scanner{d: d, element: element}).next()
+}
+
+// scanner reads items of feed token by token and collects metadata of feed until the first item is read
+type scanner struct {
+	d        Decoder
+	element  string
+	metadata *Metadata
+	// depth of current element, root element has depth 1
+	depth int
+	// items is set once the first item was read
+	items bool
+	// field is name of element of root which could be field of metadata, text is its content
+	field string
+	text  strings.Builder
+	// offset of the last read token or item and ITEM_ID of the last parsed item locate parse errors
+	offset int64
+	lastID string
+}
+
+// inputOffset returns current offset of decoder, it is zero when decoder does not track it
+func (s *scanner) inputOffset() int64 {
+	if o, ok := s.d.(interface{ InputOffset() int64 }); ok {
+		return o.InputOffset()
+	}
+	return 0
+}
+
+// position returns position of err in feed
+func (s *scanner) position(err error) *apperror.Position {
+	p := &apperror.Position{Offset: s.offset, PreviousItemID: s.lastID}
+	var se *xml.SyntaxError
+	if errors.As(err, &se) {
+		p.Line = se.Line
+	}
+	return p
+}
+
+// next reads next token of feed and returns item when the token starts item
+func (s *scanner) next() (*heureka.Item, error) {
+	s.offset = s.inputOffset()
+	token, err := s.d.Token()
+	if err != nil {
+		// malformed token is located where decoder stopped
+		s.offset = s.inputOffset()
+		return nil, fmt.Errorf("Failed to read node element: %w", err)
+	}
+	switch t := token.(type) {
+	case xml.StartElement:
+		if t.Name.Local == s.element {
+			s.items, s.field = true, ""
+			item := &heureka.Item{}
+			// item is decoded as heureka item whatever its element is named
+			t.Name.Local = DefaultItemElement
+			err = s.d.DecodeElement(item, &t)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to unmarshal xml node: %w", err)
+			}
+			s.lastID = string(item.ID)
+			return item, nil
+		}
+		s.depth++
+		if s.metadata == nil || s.items {
+			return nil, nil
+		}
+		switch s.depth {
+		case 1:
+			for _, a := range t.Attr {
+				// namespaces and attributes of schemas are not metadata of feed
+				if a.Name.Space == "" && a.Name.Local != "xmlns" {
+					s.metadata.set(a.Name.Local, a.Value)
+				}
+			}
+		case 2:
+			s.field = t.Name.Local
+			s.text.Reset()
+		default:
+			// element with children is not field of metadata
+			s.field = ""
+		}
+	case xml.CharData:
+		if s.field != "" && s.depth == 2 {
+			s.text.Write(t)
+		}
+	case xml.EndElement:
+		if s.field != "" && s.depth == 2 {
+			s.metadata.set(s.field, s.text.String())
+			s.field = ""
+		}
+		s.depth--
+	}
+	return nil, nil
+}
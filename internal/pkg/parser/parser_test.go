@@ -0,0 +1,327 @@
+package parser
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/grubastik/feeddo/internal/pkg/heureka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decoderTokenErr struct{}
+
+func (t decoderTokenErr) Token() (xml.Token, error) {
+	return nil, errors.New("Test token")
+}
+
+func (t decoderTokenErr) DecodeElement(v interface{}, start *xml.StartElement) error {
+	return nil
+}
+
+type decoderDecodeErr struct{}
+
+func (t decoderDecodeErr) Token() (xml.Token, error) {
+	return xml.StartElement{Name: xml.Name{Local: "SHOPITEM"}}, nil
+}
+
+func (t decoderDecodeErr) DecodeElement(v interface{}, start *xml.StartElement) error {
+	return errors.New("Test decode error")
+}
+
+type decoderDecodeWrongElement struct{}
+
+func (t decoderDecodeWrongElement) Token() (xml.Token, error) {
+	return xml.EndElement{Name: xml.Name{Local: "SHOPITEM"}}, nil
+}
+
+func (t decoderDecodeWrongElement) DecodeElement(v interface{}, start *xml.StartElement) error {
+	return errors.New("Test decode error")
+}
+
+type decoderDecodeWrongTagName struct{}
+
+func (t decoderDecodeWrongTagName) Token() (xml.Token, error) {
+	return xml.EndElement{Name: xml.Name{Local: "SOMEITEM"}}, nil
+}
+
+func (t decoderDecodeWrongTagName) DecodeElement(v interface{}, start *xml.StartElement) error {
+	return errors.New("Test decode error")
+}
+
+type decoderHappyPath struct{}
+
+func (t decoderHappyPath) Token() (xml.Token, error) {
+	return xml.StartElement{Name: xml.Name{Local: "SHOPITEM"}}, nil
+}
+
+func (t decoderHappyPath) DecodeElement(v interface{}, start *xml.StartElement) error {
+	ir, ok := v.(*heureka.Item)
+	if !ok {
+		return errors.New("Can not cast interface to heureka item")
+	}
+	*ir = heureka.Item{Product: "Test", ProductName: "TestName"}
+	return nil
+}
+
+func TestGetItemFromStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		decoder Decoder
+		err     string
+		item    *heureka.Item
+	}{
+		{
+			name:    "token error",
+			decoder: decoderTokenErr{},
+			err:     "Failed to read node element: Test token",
+			item:    nil,
+		},
+		{
+			name:    "decoding error",
+			decoder: decoderDecodeErr{},
+			err:     "Failed to unmarshal xml node: Test decode error",
+			item:    nil,
+		},
+		{
+			name:    "decoding without results",
+			decoder: decoderDecodeWrongElement{},
+			err:     "",
+			item:    nil,
+		},
+		{
+			name:    "decoding with wrong tag name",
+			decoder: decoderDecodeWrongTagName{},
+			err:     "",
+			item:    nil,
+		},
+		{
+			name:    "happy path",
+			decoder: decoderHappyPath{},
+			err:     "",
+			item:    &heureka.Item{Product: "Test", ProductName: "TestName"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, err := getItemFromStream(tt.decoder, DefaultItemElement)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.item, i)
+			}
+		})
+	}
+}
+
+func TestProcessFeed(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		err  string
+		item heureka.Item
+	}{
+		{"Xml with error", "<SHOPITEM><ITEM_ID>123abc</ITEM_ID><PRODUCT></SHOPITEM>", "Failed to get item from stream: Failed to unmarshal xml node: XML syntax error on line 1: element <PRODUCT> closed by </SHOPITEM>", heureka.Item{}},
+		{"Xml with error", "<SHOPITEM><ITEM_ID>123abc</ITEM_ID></SHOPITEM>", "", heureka.Item{ID: "123abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stringReader := strings.NewReader(tt.xml)
+			stringReadCloser := ioutil.NopCloser(stringReader)
+			chanItem, chanError := ProcessFeed(context.Background(), stringReadCloser)
+			if tt.err != "" {
+				err := <-chanError //only one error possible here before close
+				<-chanError        //on close channel should be unblocked
+				<-chanItem         // wait for close
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				assert.Equal(t, apperror.CategoryParse, apperror.Category(err))
+			} else {
+				item := <-chanItem //we have only one item in stream
+				err := <-chanError //wait for close
+				<-chanError        //on close channel should be unblocked
+				<-chanItem         //we have only one item in stream
+				require.NoError(t, err)
+				require.NotEmpty(t, item)
+				assert.Equal(t, tt.item.ID, item.ID)
+			}
+		})
+	}
+}
+
+func TestProcessFeedErrorPosition(t *testing.T) {
+	feed := "<SHOP>\n<SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM>\n<SHOPITEM><ITEM_ID>2</ITEM_ID><PRODUCT></SHOPITEM>\n</SHOP>"
+	tests := []struct {
+		name     string
+		xml      string
+		position *apperror.Position
+	}{
+		{"broken item", feed, &apperror.Position{Line: 3, Offset: int64(strings.Index(feed, "<SHOPITEM><ITEM_ID>2")), PreviousItemID: "1"}},
+		{"broken token before items", "<SHOP>\n<SHOP_ID>1</SHOP_ID>\n<</SHOP>", &apperror.Position{Line: 3, Offset: 29}},
+		{"invalid item", "<SHOP><SHOPITEM><ITEM_ID></ITEM_ID></SHOPITEM></SHOP>", &apperror.Position{Offset: 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chanItem, chanError := ProcessFeed(context.Background(), ioutil.NopCloser(strings.NewReader(tt.xml)))
+			go func() {
+				for range chanItem {
+				}
+			}()
+			err := <-chanError
+			require.Error(t, err)
+			assert.Equal(t, tt.position, apperror.ParsePosition(err))
+			for range chanError {
+			}
+		})
+	}
+}
+
+func TestProcessFeedCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	xml := strings.Repeat("<SHOPITEM><ITEM_ID>123abc</ITEM_ID></SHOPITEM>", 10)
+	chanItem, chanError := ProcessFeed(ctx, ioutil.NopCloser(strings.NewReader(xml)))
+	item := <-chanItem
+	assert.Equal(t, heureka.ID("123abc"), item.ID)
+	// parser is not blocked by items which are not read anymore
+	cancel()
+	err := <-chanError
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, apperror.CategoryParse, apperror.Category(err))
+	for range chanItem {
+	}
+}
+
+func TestProcessFeedWithOptions(t *testing.T) {
+	// "Žluťoučký kůň" encoded in windows-1250
+	name := "\x8elu\x9dou\xe8k\xfd k\xf9\xf2"
+	tests := []struct {
+		name string
+		xml  string
+		opts Options
+		err  string
+		item heureka.Item
+	}{
+		{"declared charset", `<?xml version="1.0" encoding="windows-1250"?><SHOP><SHOPITEM><ITEM_ID>1</ITEM_ID><PRODUCTNAME>` + name + `</PRODUCTNAME></SHOPITEM></SHOP>`, Options{}, "", heureka.Item{ID: "1", ProductName: "Žluťoučký kůň"}},
+		{"overridden charset", `<?xml version="1.0" encoding="UTF-8"?><SHOP><SHOPITEM><ITEM_ID>1</ITEM_ID><PRODUCTNAME>` + name + `</PRODUCTNAME></SHOPITEM></SHOP>`, Options{Charset: "windows-1250"}, "", heureka.Item{ID: "1", ProductName: "Žluťoučký kůň"}},
+		{"unknown declared charset", `<?xml version="1.0" encoding="koi8-r"?><SHOP></SHOP>`, Options{}, "Charset 'koi8-r' is not supported", heureka.Item{}},
+		{"item element", `<SHOP><ITEM><ITEM_ID>1</ITEM_ID><PRODUCTNAME>a</PRODUCTNAME></ITEM><SHOPITEM><ITEM_ID>2</ITEM_ID></SHOPITEM></SHOP>`, Options{ItemElement: "ITEM"}, "", heureka.Item{ID: "1", ProductName: "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chanItem, chanError := ProcessFeedWithOptions(context.Background(), ioutil.NopCloser(strings.NewReader(tt.xml)), tt.opts)
+			items := []heureka.Item{}
+			errs := []error{}
+			for chanItem != nil || chanError != nil {
+				select {
+				case item, ok := <-chanItem:
+					if !ok {
+						chanItem = nil
+						continue
+					}
+					items = append(items, item)
+				case err, ok := <-chanError:
+					if !ok {
+						chanError = nil
+						continue
+					}
+					errs = append(errs, err)
+				}
+			}
+			if tt.err != "" {
+				require.NotEmpty(t, errs)
+				assert.Contains(t, errs[0].Error(), tt.err)
+				return
+			}
+			require.Empty(t, errs)
+			require.Len(t, items, 1)
+			assert.Equal(t, tt.item.ID, items[0].ID)
+			assert.Equal(t, tt.item.ProductName, items[0].ProductName)
+		})
+	}
+}
+
+// slowReader delays every read, like stream of slow server
+type slowReader struct {
+	io.Reader
+	delay time.Duration
+}
+
+func (r slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	return r.Reader.Read(p)
+}
+
+func TestProcessFeedStats(t *testing.T) {
+	stats := &Stats{}
+	r := slowReader{Reader: strings.NewReader(`<SHOP><SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM></SHOP>`), delay: 10 * time.Millisecond}
+	chanItem, chanError := ProcessFeedWithOptions(context.Background(), ioutil.NopCloser(r), Options{Stats: stats})
+	for range chanItem {
+	}
+	require.NoError(t, <-chanError)
+	// reading of stream is part of decoding
+	assert.True(t, stats.Decoding() >= 10*time.Millisecond, "decoding took %v", stats.Decoding())
+}
+
+func TestProcessFeedMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		feed     string
+		expected map[string]string
+	}{
+		{"none", `<SHOP><SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM></SHOP>`, nil},
+		{"attributes", `<SHOP xmlns="http://www.zbozi.cz/ns/offer/1.0" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="x" generated="2020-01-02T03:04:05Z" shop=" 1 "><SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM></SHOP>`,
+			map[string]string{"generated": "2020-01-02T03:04:05Z", "shop": "1"}},
+		{"elements", `<SHOP><GENERATED>2020-01-02</GENERATED><INFO><NAME>x</NAME></INFO><EMPTY/><SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM><TOTAL>1</TOTAL></SHOP>`,
+			map[string]string{"GENERATED": "2020-01-02"}},
+		{"wrapped items", `<SHOP><SHOP_ID>7</SHOP_ID><ITEMS><SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM></ITEMS></SHOP>`, map[string]string{"SHOP_ID": "7"}},
+		{"long value", `<SHOP note="` + strings.Repeat("á", 300) + `"><SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM></SHOP>`, map[string]string{"note": strings.Repeat("á", maxMetadataValue)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := &Metadata{}
+			chanItem, chanError := ProcessFeedWithOptions(context.Background(), ioutil.NopCloser(strings.NewReader(tt.feed)), Options{Metadata: metadata})
+			items := 0
+			for item := range chanItem {
+				// metadata preceding items is complete when the first item is received
+				if items == 0 {
+					assert.Equal(t, tt.expected, metadata.Fields())
+				}
+				assert.Equal(t, "1", string(item.ID))
+				items++
+			}
+			require.NoError(t, <-chanError)
+			assert.Equal(t, 1, items)
+			assert.Equal(t, tt.expected, metadata.Fields())
+		})
+	}
+}
+
+func TestCharsetReader(t *testing.T) {
+	require.NoError(t, ValidateCharset("Windows-1250"))
+	require.NoError(t, ValidateCharset("utf-8"))
+	assert.Contains(t, ValidateCharset("ebcdic").Error(), "Charset 'ebcdic' is not supported, use one of cp1250")
+
+	r, err := charsetReader("iso-8859-2", strings.NewReader("\xa9koda \xbe"))
+	require.NoError(t, err)
+	// buffer shorter than encoded rune does not lose bytes
+	var out []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	assert.Equal(t, "Škoda ž", string(out))
+}
@@ -0,0 +1,129 @@
+package payload
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+const (
+	// CloudEventsStructured payload is wrapped into CloudEvents envelope
+	CloudEventsStructured = "structured"
+	// CloudEventsBinary payload is sent as it is and attributes of event are sent in headers
+	CloudEventsBinary = "binary"
+	// CloudEventsSpecVersion is version of CloudEvents specification
+	CloudEventsSpecVersion = "1.0"
+
+	contentTypeJSON        = "application/json"
+	contentTypeCloudEvents = "application/cloudevents+json; charset=UTF-8"
+	// headerContentType and prefix of headers of attributes are defined by Kafka protocol binding of CloudEvents
+	headerContentType = "content-type"
+	headerPrefix      = "ce_"
+)
+
+// CloudEvents configures which attributes items are sent with as CloudEvents 1.0 events
+type CloudEvents struct {
+	// Mode is CloudEventsStructured or CloudEventsBinary
+	Mode string
+	// Type is type of all events
+	Type string
+}
+
+// CloudEvent is item described by CloudEvents attributes
+type CloudEvent struct {
+	CloudEvents
+	// Source is url of feed and ID is id of item
+	Source string
+	ID     string
+	Time   time.Time
+	// Expires is sent as extension attribute 'expiresat' unless it is zero
+	Expires time.Time
+}
+
+// envelope is event in structured mode. Data which is not valid json (e.g. invalid offloaded payload) is sent as base64.
+type envelope struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	Time            string          `json:"time"`
+	ExpiresAt       string          `json:"expiresat,omitempty"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+// NewCloudEvents validates settings of events
+func NewCloudEvents(mode, eventType string) (CloudEvents, error) {
+	if mode != CloudEventsStructured && mode != CloudEventsBinary {
+		return CloudEvents{}, fmt.Errorf("CloudEvents mode '%s' is not supported, use '%s' or '%s'", mode, CloudEventsStructured, CloudEventsBinary)
+	}
+	if eventType == "" {
+		return CloudEvents{}, fmt.Errorf("Type of CloudEvents should not be empty")
+	}
+	return CloudEvents{Mode: mode, Type: eventType}, nil
+}
+
+// Event returns event of item of feed
+func (c CloudEvents) Event(source, id string, t time.Time) CloudEvent {
+	return CloudEvent{CloudEvents: c, Source: source, ID: id, Time: t}
+}
+
+// Wrap returns payload of event. Payload is wrapped into envelope in structured mode only, tombstone (nil payload) is kept as it is.
+func (e CloudEvent) Wrap(data []byte) ([]byte, error) {
+	if e.Mode != CloudEventsStructured || data == nil {
+		return data, nil
+	}
+	env := envelope{
+		SpecVersion:     CloudEventsSpecVersion,
+		Type:            e.Type,
+		Source:          e.Source,
+		ID:              e.ID,
+		Time:            e.time(),
+		ExpiresAt:       e.expiresAt(),
+		DataContentType: contentTypeJSON,
+	}
+	if json.Valid(data) {
+		env.Data = data
+	} else {
+		env.DataBase64 = data
+	}
+	wrapped, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to wrap payload into CloudEvent: %w", err)
+	}
+	return wrapped, nil
+}
+
+// Headers returns headers of event: attributes in binary mode and content type in structured mode
+func (e CloudEvent) Headers() []sink.Header {
+	if e.Mode == CloudEventsStructured {
+		return []sink.Header{{Key: headerContentType, Value: []byte(contentTypeCloudEvents)}}
+	}
+	headers := []sink.Header{
+		{Key: headerPrefix + "specversion", Value: []byte(CloudEventsSpecVersion)},
+		{Key: headerPrefix + "type", Value: []byte(e.Type)},
+		{Key: headerPrefix + "source", Value: []byte(e.Source)},
+		{Key: headerPrefix + "id", Value: []byte(e.ID)},
+		{Key: headerPrefix + "time", Value: []byte(e.time())},
+	}
+	if expires := e.expiresAt(); expires != "" {
+		headers = append(headers, sink.Header{Key: headerPrefix + "expiresat", Value: []byte(expires)})
+	}
+	return append(headers, sink.Header{Key: headerContentType, Value: []byte(contentTypeJSON)})
+}
+
+// time formats time of event as RFC 3339
+func (e CloudEvent) time() string {
+	return e.Time.UTC().Format(time.RFC3339Nano)
+}
+
+// expiresAt formats expiry of event as RFC 3339, it is empty when event does not expire
+func (e CloudEvent) expiresAt() string {
+	if e.Expires.IsZero() {
+		return ""
+	}
+	return e.Expires.UTC().Format(time.RFC3339)
+}
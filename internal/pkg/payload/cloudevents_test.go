@@ -0,0 +1,95 @@
+package payload
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCloudEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		eventType string
+		err       string
+	}{
+		{"structured", CloudEventsStructured, "feeddo.item", ""},
+		{"binary", CloudEventsBinary, "feeddo.item", ""},
+		{"unknown mode", "batch", "feeddo.item", "CloudEvents mode 'batch' is not supported, use 'structured' or 'binary'"},
+		{"empty type", CloudEventsBinary, "", "Type of CloudEvents should not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCloudEvents(tt.mode, tt.eventType)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, CloudEvents{Mode: tt.mode, Type: tt.eventType}, c)
+			}
+		})
+	}
+}
+
+func TestCloudEventWrap(t *testing.T) {
+	at := time.Date(2020, 5, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+	structured := CloudEvents{Mode: CloudEventsStructured, Type: "feeddo.item"}.Event("http://example.com/feed.xml", "1", at)
+	binary := CloudEvents{Mode: CloudEventsBinary, Type: "feeddo.item"}.Event("http://example.com/feed.xml", "1", at)
+	tests := []struct {
+		name     string
+		event    CloudEvent
+		data     []byte
+		expected string
+	}{
+		{"structured", structured, []byte(`{"id":"1"}`), `{"specversion":"1.0","type":"feeddo.item","source":"http://example.com/feed.xml","id":"1","time":"2020-05-01T10:30:00Z","datacontenttype":"application/json","data":{"id":"1"}}`},
+		{"structured not json", structured, []byte("ref"), `{"specversion":"1.0","type":"feeddo.item","source":"http://example.com/feed.xml","id":"1","time":"2020-05-01T10:30:00Z","datacontenttype":"application/json","data_base64":"cmVm"}`},
+		{"binary", binary, []byte(`{"id":"1"}`), `{"id":"1"}`},
+		{"disabled", CloudEvent{}, []byte(`{"id":"1"}`), `{"id":"1"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.event.Wrap(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(data))
+			if tt.event.Mode == CloudEventsStructured {
+				assert.True(t, json.Valid(data))
+			}
+		})
+	}
+
+	expiring := structured
+	expiring.Expires = at.Add(48 * time.Hour)
+	data, err := expiring.Wrap([]byte(`{"id":"1"}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"time":"2020-05-01T10:30:00Z","expiresat":"2020-05-03T10:30:00Z"`)
+
+	// tombstone is not wrapped, so item is still removed from compacted topics
+	data, err = structured.Wrap(nil)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+func TestCloudEventHeaders(t *testing.T) {
+	at := time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)
+	structured := CloudEvents{Mode: CloudEventsStructured, Type: "feeddo.item"}.Event("http://example.com/feed.xml", "1", at)
+	assert.Equal(t, []sink.Header{{Key: "content-type", Value: []byte("application/cloudevents+json; charset=UTF-8")}}, structured.Headers())
+
+	binary := CloudEvents{Mode: CloudEventsBinary, Type: "feeddo.item"}.Event("http://example.com/feed.xml", "1", at)
+	assert.Equal(t, []sink.Header{
+		{Key: "ce_specversion", Value: []byte("1.0")},
+		{Key: "ce_type", Value: []byte("feeddo.item")},
+		{Key: "ce_source", Value: []byte("http://example.com/feed.xml")},
+		{Key: "ce_id", Value: []byte("1")},
+		{Key: "ce_time", Value: []byte("2020-05-01T10:30:00Z")},
+		{Key: "content-type", Value: []byte("application/json")},
+	}, binary.Headers())
+
+	binary.Expires = at.Add(time.Hour)
+	headers := binary.Headers()
+	assert.Equal(t, sink.Header{Key: "ce_expiresat", Value: []byte("2020-05-01T11:30:00Z")}, headers[len(headers)-2])
+}
@@ -0,0 +1,364 @@
+package payload
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+)
+
+// hexDigits are digits of escaped characters
+const hexDigits = "0123456789abcdef"
+
+// buffers are reused between items, so buffer does not grow again for every item.
+// Only buffers up to maxPooledBuffer bytes are returned into pool, so single huge item does not stay in memory.
+var buffers = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// maxPooledBuffer is max capacity of buffer which is reused
+const maxPooledBuffer = 1 << 20
+
+// xmlNameMember starts payload with member which was sent before product model, when heureka items were marshalled
+// as they were parsed. It is kept, so payload stays the same for existing consumers.
+const xmlNameMember = `{"XMLName":{"Space":"","Local":"SHOPITEM"},`
+
+// formats of prices and other decimals in payload
+const (
+	// DecimalString decimal is quoted, e.g. "269.9", so precision is never lost
+	DecimalString = "string"
+	// DecimalNumber decimal is json number, e.g. 269.9
+	DecimalNumber = "number"
+	// DecimalObject decimal is object with amount and currency, e.g. {"amount":"269.9","currency":"CZK"}
+	DecimalObject = "object"
+)
+
+// formats of urls in payload
+const (
+	// URLString url is sent as string, e.g. "https://www.czc.cz/produkt"
+	URLString URLFormat = "string"
+	// URLObject url is sent as object with parts of url.URL, e.g. {"Scheme":"https","Host":"www.czc.cz",...}.
+	// It is shape of urls sent before items were converted into product model, so existing consumers keep working.
+	URLObject URLFormat = "object"
+)
+
+// URLFormat configures how urls of product are encoded. Zero value encodes urls as strings like product marshals itself.
+type URLFormat string
+
+// ParseURLFormat validates format of urls
+func ParseURLFormat(format string) (URLFormat, error) {
+	switch u := URLFormat(format); u {
+	case URLString, URLObject:
+		return u, nil
+	}
+	return "", fmt.Errorf("URL format '%s' is not supported, use '%s' or '%s'", format, URLObject, URLString)
+}
+
+// DecimalFormat configures how prices and other decimals of product are encoded.
+// Zero value encodes decimals like decimal.Decimal marshals itself.
+type DecimalFormat struct {
+	Format   string
+	Currency string
+}
+
+// NewDecimalFormat validates format of decimals, currency is required only by DecimalObject
+func NewDecimalFormat(format, currency string) (DecimalFormat, error) {
+	switch format {
+	case DecimalString, DecimalNumber:
+		if currency != "" {
+			return DecimalFormat{}, fmt.Errorf("Currency is sent only by '%s' format", DecimalObject)
+		}
+	case DecimalObject:
+		if currency == "" {
+			return DecimalFormat{}, fmt.Errorf("Currency should be provided for '%s' format", DecimalObject)
+		}
+	default:
+		return DecimalFormat{}, fmt.Errorf("Decimal format '%s' is not supported, use '%s', '%s' or '%s'", format, DecimalString, DecimalNumber, DecimalObject)
+	}
+	return DecimalFormat{Format: format, Currency: currency}, nil
+}
+
+// Encode returns json of product. It is the same as json.Marshal returns for zero formats, except XMLName member payload starts with,
+// but product is encoded without encoding/json into reused buffer. Only the returned payload, formatting of decimals (a few allocations per decimal) and parsing of urls
+// in URLObject format (one allocation per url) allocate.
+func Encode(p product.Product, f DecimalFormat, u URLFormat) []byte {
+	bp := buffers.Get().(*[]byte)
+	buf := AppendJSON((*bp)[:0], p, f, u)
+	data := make([]byte, len(buf))
+	copy(data, buf)
+	if cap(buf) <= maxPooledBuffer {
+		*bp = buf
+		buffers.Put(bp)
+	}
+	return data
+}
+
+// AppendJSON appends json of product with decimals in format f and urls in format u to dst and returns extended buffer
+func AppendJSON(dst []byte, p product.Product, f DecimalFormat, u URLFormat) []byte {
+	dst = append(dst, xmlNameMember...)
+	dst = append(dst, `"id":`...)
+	dst = appendString(dst, p.ID)
+	dst = append(dst, `,"name":`...)
+	dst = appendString(dst, p.Name)
+	dst = append(dst, `,"product":`...)
+	dst = appendString(dst, p.Title)
+	dst = append(dst, `,"description":`...)
+	dst = appendString(dst, p.Description)
+	dst = append(dst, `,"url":`...)
+	dst = u.appendURL(dst, p.URL)
+	dst = append(dst, `,"imageUrl":`...)
+	dst = u.appendURL(dst, p.ImageURL)
+	dst = append(dst, `,"imageUrlsAlternate":`...)
+	dst = u.appendURLs(dst, p.AlternativeImageURLs)
+	dst = append(dst, `,"videoUrl":`...)
+	dst = u.appendURL(dst, p.VideoURL)
+	dst = append(dst, `,"priceWithVat":`...)
+	dst = f.appendDecimal(dst, p.PriceVAT)
+	dst = append(dst, `,"vat":`...)
+	dst = appendString(dst, p.VAT)
+	dst = append(dst, `,"type":`...)
+	dst = appendString(dst, p.Type)
+	dst = append(dst, `,"cpc":`...)
+	dst = f.appendDecimal(dst, p.CPC)
+	dst = append(dst, `,"manufacterer":`...)
+	dst = appendString(dst, p.Manufacturer)
+	dst = append(dst, `,"category":`...)
+	dst = appendString(dst, p.Category)
+	dst = append(dst, `,"ean":`...)
+	dst = appendString(dst, p.EAN)
+	dst = append(dst, `,"isbn":`...)
+	dst = appendString(dst, p.ISBN)
+	dst = append(dst, `,"parameters":`...)
+	if p.Parameters == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, param := range p.Parameters {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `{"name":`...)
+			dst = appendString(dst, param.Name)
+			dst = append(dst, `,"value":`...)
+			dst = appendString(dst, param.Value)
+			dst = append(dst, '}')
+		}
+		dst = append(dst, ']')
+	}
+	dst = append(dst, `,"deliveryDay":`...)
+	dst = appendString(dst, p.DeliveryDate)
+	dst = append(dst, `,"deliveries":`...)
+	if p.Deliveries == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, d := range p.Deliveries {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `{"id":`...)
+			dst = appendString(dst, d.ID)
+			dst = append(dst, `,"price":`...)
+			dst = f.appendDecimal(dst, d.Price)
+			dst = append(dst, `,"priceCod":`...)
+			dst = f.appendDecimal(dst, d.PriceCOD)
+			dst = append(dst, '}')
+		}
+		dst = append(dst, ']')
+	}
+	dst = append(dst, `,"groupId":`...)
+	dst = appendString(dst, p.GroupID)
+	dst = append(dst, `,"accessories":`...)
+	dst = appendStrings(dst, p.Accessories)
+	dst = append(dst, `,"dues":`...)
+	dst = f.appendDecimal(dst, p.Dues)
+	dst = append(dst, `,"gifts":`...)
+	if p.Gifts == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, g := range p.Gifts {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `{"name":`...)
+			dst = appendString(dst, g.Name)
+			dst = append(dst, `,"id":`...)
+			dst = appendString(dst, g.ID)
+			dst = append(dst, '}')
+		}
+		dst = append(dst, ']')
+	}
+	return append(dst, '}')
+}
+
+// appendDecimal appends decimal in format, zero format appends it like its MarshalJSON does. Formatting of decimal allocates,
+// so zero which is common e.g. for CPC or dues is appended directly.
+func (f DecimalFormat) appendDecimal(dst []byte, d decimal.Decimal) []byte {
+	s := "0"
+	if !d.IsZero() {
+		s = d.String()
+	}
+	switch f.Format {
+	case DecimalNumber:
+		return append(dst, s...)
+	case DecimalObject:
+		dst = append(dst, `{"amount":"`...)
+		dst = append(dst, s...)
+		dst = append(dst, `","currency":`...)
+		dst = appendString(dst, f.Currency)
+		return append(dst, '}')
+	case "":
+		if decimal.MarshalJSONWithoutQuotes {
+			return append(dst, s...)
+		}
+	}
+	dst = append(dst, '"')
+	dst = append(dst, s...)
+	return append(dst, '"')
+}
+
+// urlField is exported field of url.URL with its json key
+type urlField struct {
+	index int
+	key   string
+	kind  reflect.Kind
+}
+
+// urlFields are exported fields of url.URL in order of declaration, which is order encoding/json marshals them in.
+// Fields and their order differ between versions of go (e.g. RawFragment and OmitHost were added later),
+// so they are read from type once and values are accessed by index without allocation.
+var urlFields = func() []urlField {
+	t := reflect.TypeOf(url.URL{})
+	fields := make([]urlField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.PkgPath == "" {
+			fields = append(fields, urlField{index: i, key: `"` + f.Name + `":`, kind: f.Type.Kind()})
+		}
+	}
+	return fields
+}()
+
+// appendURL appends url in format u. Object has the same fields as url.URL marshalled by encoding/json,
+// which is shape consumers received before. Url which could not be parsed is sent as path, empty url is object with empty fields.
+func (u URLFormat) appendURL(dst []byte, s string) []byte {
+	if u != URLObject {
+		return appendString(dst, s)
+	}
+	parsed, err := url.Parse(s)
+	if err != nil {
+		parsed = &url.URL{Path: s}
+	}
+	v := reflect.ValueOf(parsed).Elem()
+	dst = append(dst, '{')
+	for i, f := range urlFields {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, f.key...)
+		value := v.Field(f.index)
+		switch f.kind {
+		case reflect.String:
+			dst = appendString(dst, value.String())
+		case reflect.Bool:
+			dst = strconv.AppendBool(dst, value.Bool())
+		case reflect.Ptr:
+			// User is the only pointer, Userinfo has no exported fields, so it is empty object like in encoding/json
+			if value.IsNil() {
+				dst = append(dst, "null"...)
+			} else {
+				dst = append(dst, "{}"...)
+			}
+		default:
+			// field of other kind is not in any version of url.URL, test against encoding/json reports it
+			dst = append(dst, "null"...)
+		}
+	}
+	return append(dst, '}')
+}
+
+// appendURLs appends list of urls in format u, nil list is null like in encoding/json
+func (u URLFormat) appendURLs(dst []byte, list []string) []byte {
+	if list == nil {
+		return append(dst, "null"...)
+	}
+	dst = append(dst, '[')
+	for i, s := range list {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = u.appendURL(dst, s)
+	}
+	return append(dst, ']')
+}
+
+// appendStrings appends list of strings, nil list is null like in encoding/json
+func appendStrings(dst []byte, list []string) []byte {
+	if list == nil {
+		return append(dst, "null"...)
+	}
+	dst = append(dst, '[')
+	for i, s := range list {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendString(dst, s)
+	}
+	return append(dst, ']')
+}
+
+// appendString appends quoted string escaped like encoding/json does: HTML characters, U+2028 and U+2029
+// are escaped, so payload could be embedded into HTML, and invalid utf8 is replaced by U+FFFD
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= ' ' && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,149 @@
+package payload
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+const (
+	// HeaderEncryption is algorithm payload is encrypted by
+	HeaderEncryption = "feeddo-encryption"
+	// HeaderKeyID identifies key payload is encrypted by, so consumers could pick the key when keys are rotated
+	HeaderKeyID = "feeddo-key-id"
+	// AlgorithmAESGCM encrypted payload is random nonce followed by AES-GCM ciphertext with tag
+	AlgorithmAESGCM = "AES-GCM"
+)
+
+// Key is data key payloads are encrypted by. Secret is 16, 24 or 32 bytes long for AES-128, AES-192 or AES-256.
+type Key struct {
+	ID     string `json:"id"`
+	Secret []byte `json:"key"`
+}
+
+// KeySource provides data key, e.g. key is decrypted by KMS
+type KeySource interface {
+	Key(ctx context.Context) (Key, error)
+}
+
+// StaticKey is key which is configured directly, e.g. by environment variable. Secret is base64 encoded.
+type StaticKey struct {
+	ID     string
+	Secret string
+}
+
+// Key decodes secret of key
+func (s StaticKey) Key(ctx context.Context) (Key, error) {
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s.Secret))
+	if err != nil {
+		return Key{}, fmt.Errorf("Unable to decode encryption key because of %w", err)
+	}
+	return Key{ID: s.ID, Secret: secret}, nil
+}
+
+// CommandKey runs command which prints key as json '{"id": "<key id>", "key": "<base64 encoded secret>"}' into stdout.
+// It is a hook for KMS: command could e.g. decrypt data key by cloud KMS, so secret is not stored in configuration.
+type CommandKey struct {
+	Command string
+}
+
+// Key runs command by shell and parses its output
+func (c CommandKey) Key(ctx context.Context) (Key, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return Key{}, fmt.Errorf("Unable to get encryption key by command because of %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var k Key
+	if err := json.Unmarshal(stdout.Bytes(), &k); err != nil {
+		return Key{}, fmt.Errorf("Unable to parse encryption key printed by command because of %w", err)
+	}
+	return k, nil
+}
+
+// Encrypter encrypts payloads by AES-GCM. Nil encrypter keeps payloads as they are.
+type Encrypter struct {
+	keyID string
+	aead  cipher.AEAD
+	// random is source of nonces
+	random io.Reader
+}
+
+// NewEncrypter gets key from source and validates it
+func NewEncrypter(ctx context.Context, src KeySource) (*Encrypter, error) {
+	k, err := src.Key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if k.ID == "" {
+		return nil, fmt.Errorf("ID of encryption key should not be empty")
+	}
+	block, err := aes.NewCipher(k.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("Encryption key should be 16, 24 or 32 bytes long, got %d bytes", len(k.Secret))
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create AES-GCM cipher because of %w", err)
+	}
+	return &Encrypter{keyID: k.ID, aead: aead, random: rand.Reader}, nil
+}
+
+// KeyID returns id of key payloads are encrypted by
+func (e *Encrypter) KeyID() string {
+	if e == nil {
+		return ""
+	}
+	return e.keyID
+}
+
+// Encrypt returns nonce followed by encrypted payload. Tombstone (nil payload) is kept as it is.
+// Additional data binds payload to id of item, so payload could not be swapped between items unnoticed.
+func (e *Encrypter) Encrypt(data []byte, id string) ([]byte, error) {
+	if e == nil || data == nil {
+		return data, nil
+	}
+	nonce := make([]byte, e.aead.NonceSize(), e.aead.NonceSize()+len(data)+e.aead.Overhead())
+	if _, err := io.ReadFull(e.random, nonce); err != nil {
+		return nil, fmt.Errorf("Unable to generate nonce because of %w", err)
+	}
+	return e.aead.Seal(nonce, nonce, data, []byte(id)), nil
+}
+
+// Decrypt returns payload encrypted by Encrypt
+func (e *Encrypter) Decrypt(data []byte, id string) ([]byte, error) {
+	if e == nil || data == nil {
+		return data, nil
+	}
+	n := e.aead.NonceSize()
+	if len(data) < n {
+		return nil, fmt.Errorf("Encrypted payload is shorter than nonce")
+	}
+	plain, err := e.aead.Open(nil, data[:n], data[n:], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt payload because of %w", err)
+	}
+	return plain, nil
+}
+
+// Headers describe algorithm and key of encrypted payload
+func (e *Encrypter) Headers() []sink.Header {
+	if e == nil {
+		return nil
+	}
+	return []sink.Header{
+		{Key: HeaderEncryption, Value: []byte(AlgorithmAESGCM)},
+		{Key: HeaderKeyID, Value: []byte(e.keyID)},
+	}
+}
@@ -0,0 +1,76 @@
+package payload
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// secret16 is base64 of 16 bytes long key
+const secret16 = "MDEyMzQ1Njc4OWFiY2RlZg=="
+
+func TestNewEncrypter(t *testing.T) {
+	tests := []struct {
+		name string
+		src  KeySource
+		err  string
+	}{
+		{"static key", StaticKey{ID: "k1", Secret: secret16}, ""},
+		{"command key", CommandKey{Command: `echo '{"id":"k2","key":"` + secret16 + `"}'`}, ""},
+		{"not base64", StaticKey{ID: "k1", Secret: "%%%"}, "Unable to decode encryption key because of illegal base64 data at input byte 0"},
+		{"wrong size", StaticKey{ID: "k1", Secret: base64.StdEncoding.EncodeToString([]byte("short"))}, "Encryption key should be 16, 24 or 32 bytes long, got 5 bytes"},
+		{"missing id", StaticKey{Secret: secret16}, "ID of encryption key should not be empty"},
+		{"failed command", CommandKey{Command: "echo denied >&2; exit 1"}, "Unable to get encryption key by command because of exit status 1: denied"},
+		{"wrong output", CommandKey{Command: "echo key"}, "Unable to parse encryption key printed by command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEncrypter(context.Background(), tt.src)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+			} else {
+				require.NoError(t, err)
+				assert.NotEmpty(t, e.KeyID())
+			}
+		})
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	e, err := NewEncrypter(context.Background(), StaticKey{ID: "k1", Secret: secret16})
+	require.NoError(t, err)
+	data := []byte(`{"id":"1","priceWithVat":"10"}`)
+
+	encrypted, err := e.Encrypt(data, "1")
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "priceWithVat")
+	again, err := e.Encrypt(data, "1")
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again, "nonce should be random")
+
+	plain, err := e.Decrypt(encrypted, "1")
+	require.NoError(t, err)
+	assert.Equal(t, data, plain)
+	// payload is bound to item
+	_, err = e.Decrypt(encrypted, "2")
+	assert.Error(t, err)
+
+	// tombstone is not encrypted
+	encrypted, err = e.Encrypt(nil, "1")
+	require.NoError(t, err)
+	assert.Nil(t, encrypted)
+
+	assert.Equal(t, []sink.Header{{Key: HeaderEncryption, Value: []byte("AES-GCM")}, {Key: HeaderKeyID, Value: []byte("k1")}}, e.Headers())
+
+	// nil encrypter keeps payload as it is
+	var disabled *Encrypter
+	encrypted, err = disabled.Encrypt(data, "1")
+	require.NoError(t, err)
+	assert.Equal(t, data, encrypted)
+	assert.Nil(t, disabled.Headers())
+}
@@ -0,0 +1,125 @@
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+)
+
+const (
+	// StrategyDrop oversized item is not sent and is stored into dead letter
+	StrategyDrop = "drop"
+	// StrategyTruncate description of oversized item is truncated to fit the limit
+	StrategyTruncate = "truncate"
+	// StrategyOffload oversized item is stored into offload directory and reference to it is sent instead
+	StrategyOffload = "offload"
+)
+
+// Reference is sent instead of item which was offloaded
+type Reference struct {
+	ID          string `json:"id"`
+	PayloadRef  string `json:"payloadRef"`
+	PayloadSize int    `json:"payloadSize"`
+}
+
+// Guard keeps size of items payload within limit
+type Guard struct {
+	// MaxBytes max size of payload. Zero means no limit
+	MaxBytes int
+	Strategy string
+	// OffloadDir directory where oversized items are stored, e.g. mounted bucket of object storage
+	OffloadDir string
+	// Redaction is applied before product is encoded, so redacted values are not stored even into offload directory
+	Redaction Redaction
+	// Decimals is format of prices and other decimals of payload
+	Decimals DecimalFormat
+	// URLs is format of urls of payload
+	URLs URLFormat
+}
+
+// NewGuard validates settings of guard
+func NewGuard(maxBytes int, strategy, offloadDir string) (Guard, error) {
+	if maxBytes < 0 {
+		return Guard{}, fmt.Errorf("Max payload size should not be negative")
+	}
+	switch strategy {
+	case StrategyDrop, StrategyTruncate:
+	case StrategyOffload:
+		if offloadDir == "" {
+			return Guard{}, fmt.Errorf("Offload directory should be provided for strategy '%s'", strategy)
+		}
+		dir, err := filepath.Abs(offloadDir)
+		if err != nil {
+			return Guard{}, fmt.Errorf("Unable to resolve offload directory because of %w", err)
+		}
+		offloadDir = dir
+	default:
+		return Guard{}, fmt.Errorf("Oversized payload strategy '%s' is not supported", strategy)
+	}
+	return Guard{MaxBytes: maxBytes, Strategy: strategy, OffloadDir: offloadDir}, nil
+}
+
+// Marshal redacts and encodes product and applies strategy when payload exceeds limit.
+// Payload which still exceeds limit is returned as is, so it could be rejected by producer.
+func (g Guard) Marshal(p product.Product) ([]byte, error) {
+	p = g.Redaction.Apply(p)
+	data := Encode(p, g.Decimals, g.URLs)
+	if g.MaxBytes == 0 || len(data) <= g.MaxBytes {
+		return data, nil
+	}
+	switch g.Strategy {
+	case StrategyTruncate:
+		return g.truncate(p, data)
+	case StrategyOffload:
+		return g.offload(p.ID, data)
+	}
+	return data, nil
+}
+
+// truncate cuts description until payload fits the limit. Escaping in json makes payload longer than description,
+// so several attempts could be required.
+func (g Guard) truncate(p product.Product, data []byte) ([]byte, error) {
+	for len(data) > g.MaxBytes && p.Description != "" {
+		cut := len(data) - g.MaxBytes
+		if cut >= len(p.Description) {
+			p.Description = ""
+		} else {
+			p.Description = validPrefix(p.Description[:len(p.Description)-cut])
+		}
+		data = Encode(p, g.Decimals, g.URLs)
+	}
+	return data, nil
+}
+
+// validPrefix drops incomplete utf8 sequence at the end of string
+func validPrefix(s string) string {
+	for len(s) > 0 {
+		r, size := utf8.DecodeLastRuneInString(s)
+		if r != utf8.RuneError || size != 1 {
+			break
+		}
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// offload stores payload into file named by its hash and returns reference to it
+func (g Guard) offload(id string, data []byte) ([]byte, error) {
+	h := sha256.Sum256(data)
+	path := filepath.Join(g.OffloadDir, hex.EncodeToString(h[:])+".json")
+	// the same content is stored only once
+	if _, err := os.Stat(path); err != nil {
+		err = ioutil.WriteFile(path, data, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to offload payload because of %w", err)
+		}
+	}
+	return json.Marshal(Reference{ID: id, PayloadRef: "file://" + filepath.ToSlash(path), PayloadSize: len(data)})
+}
@@ -0,0 +1,99 @@
+package payload
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGuard(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		strategy string
+		dir      string
+		err      string
+	}{
+		{"negative size", -1, StrategyDrop, "", "Max payload size should not be negative"},
+		{"unknown strategy", 10, "abc", "", "Oversized payload strategy 'abc' is not supported"},
+		{"offload without directory", 10, StrategyOffload, "", "Offload directory should be provided for strategy 'offload'"},
+		{"drop", 10, StrategyDrop, "", ""},
+		{"offload", 10, StrategyOffload, "/tmp", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGuard(tt.maxBytes, tt.strategy, tt.dir)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	p := product.Product{ID: "1", Description: strings.Repeat("žluťoučký kůň ", 100)}
+	full := Encode(p, DecimalFormat{}, "")
+
+	t.Run("no limit", func(t *testing.T) {
+		data, err := Guard{Strategy: StrategyTruncate}.Marshal(p)
+		require.NoError(t, err)
+		assert.Equal(t, full, data)
+	})
+	t.Run("drop", func(t *testing.T) {
+		data, err := Guard{MaxBytes: 500, Strategy: StrategyDrop}.Marshal(p)
+		require.NoError(t, err)
+		assert.Equal(t, full, data)
+	})
+	t.Run("truncate", func(t *testing.T) {
+		data, err := Guard{MaxBytes: 500, Strategy: StrategyTruncate}.Marshal(p)
+		require.NoError(t, err)
+		assert.True(t, len(data) <= 500)
+		decoded := product.Product{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.NotEmpty(t, decoded.Description)
+		assert.True(t, strings.HasPrefix(p.Description, decoded.Description))
+	})
+	t.Run("truncate is not enough", func(t *testing.T) {
+		data, err := Guard{MaxBytes: 10, Strategy: StrategyTruncate}.Marshal(p)
+		require.NoError(t, err)
+		decoded := product.Product{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Empty(t, decoded.Description)
+	})
+	t.Run("offload", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "offload")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		g, err := NewGuard(500, StrategyOffload, dir)
+		require.NoError(t, err)
+		data, err := g.Marshal(p)
+		require.NoError(t, err)
+		ref := Reference{}
+		require.NoError(t, json.Unmarshal(data, &ref))
+		assert.Equal(t, "1", ref.ID)
+		assert.Equal(t, len(full), ref.PayloadSize)
+		require.True(t, strings.HasPrefix(ref.PayloadRef, "file://"))
+		stored, err := ioutil.ReadFile(strings.TrimPrefix(ref.PayloadRef, "file://"))
+		require.NoError(t, err)
+		assert.Equal(t, full, stored)
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, files, 1)
+		assert.Equal(t, ".json", filepath.Ext(files[0].Name()))
+	})
+	t.Run("offload error", func(t *testing.T) {
+		_, err := Guard{MaxBytes: 500, Strategy: StrategyOffload, OffloadDir: "/not/existing"}.Marshal(p)
+		require.Error(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "Unable to offload payload because of open /not/existing/"))
+	})
+}
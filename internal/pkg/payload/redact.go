@@ -0,0 +1,162 @@
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// RedactDrop value of field is removed from payload
+	RedactDrop = "drop"
+	// RedactHash value of field is replaced by its sha256, so consumers could still match equal values
+	RedactHash = "hash"
+)
+
+// redactedField changes field of product. Hash is nil for fields which could be only dropped, e.g. numbers.
+type redactedField struct {
+	drop func(p *product.Product)
+	hash func(p *product.Product, h func(string) string)
+}
+
+// redactedFields are fields which could be redacted named by elements of heureka feed.
+// ITEM_ID is not listed, as items are identified by it.
+var redactedFields = map[string]redactedField{
+	"PRODUCTNAME": {func(p *product.Product) { p.Name = "" }, func(p *product.Product, h func(string) string) { p.Name = h(p.Name) }},
+	"PRODUCT":     {func(p *product.Product) { p.Title = "" }, func(p *product.Product, h func(string) string) { p.Title = h(p.Title) }},
+	"DESCRIPTION": {func(p *product.Product) { p.Description = "" }, func(p *product.Product, h func(string) string) { p.Description = h(p.Description) }},
+	"URL":         {func(p *product.Product) { p.URL = "" }, func(p *product.Product, h func(string) string) { p.URL = h(p.URL) }},
+	"IMGURL":      {func(p *product.Product) { p.ImageURL = "" }, func(p *product.Product, h func(string) string) { p.ImageURL = h(p.ImageURL) }},
+	"IMGURL_ALTERNATIVE": {func(p *product.Product) { p.AlternativeImageURLs = nil }, func(p *product.Product, h func(string) string) {
+		p.AlternativeImageURLs = hashStrings(p.AlternativeImageURLs, h)
+	}},
+	"VIDEO_URL":     {func(p *product.Product) { p.VideoURL = "" }, func(p *product.Product, h func(string) string) { p.VideoURL = h(p.VideoURL) }},
+	"PRICE_VAT":     {func(p *product.Product) { p.PriceVAT = decimal.Zero }, nil},
+	"HEUREKA_CPC":   {func(p *product.Product) { p.CPC = decimal.Zero }, nil},
+	"DUES":          {func(p *product.Product) { p.Dues = decimal.Zero }, nil},
+	"MANUFACTURER":  {func(p *product.Product) { p.Manufacturer = "" }, func(p *product.Product, h func(string) string) { p.Manufacturer = h(p.Manufacturer) }},
+	"CATEGORYTEXT":  {func(p *product.Product) { p.Category = "" }, func(p *product.Product, h func(string) string) { p.Category = h(p.Category) }},
+	"EAN":           {func(p *product.Product) { p.EAN = "" }, func(p *product.Product, h func(string) string) { p.EAN = h(p.EAN) }},
+	"ISBN":          {func(p *product.Product) { p.ISBN = "" }, func(p *product.Product, h func(string) string) { p.ISBN = h(p.ISBN) }},
+	"ITEMGROUP_ID":  {func(p *product.Product) { p.GroupID = "" }, func(p *product.Product, h func(string) string) { p.GroupID = h(p.GroupID) }},
+	"DELIVERY_DATE": {func(p *product.Product) { p.DeliveryDate = "" }, nil},
+	"DELIVERY":      {func(p *product.Product) { p.Deliveries = nil }, nil},
+	"ACCESSORY": {func(p *product.Product) { p.Accessories = nil }, func(p *product.Product, h func(string) string) {
+		p.Accessories = hashStrings(p.Accessories, h)
+	}},
+	// values of parameters and names of gifts are hashed, names of parameters and ids of gifts are kept
+	"PARAM": {func(p *product.Product) { p.Parameters = nil }, func(p *product.Product, h func(string) string) {
+		if p.Parameters == nil {
+			return
+		}
+		params := make([]product.Parameter, len(p.Parameters))
+		for i, param := range p.Parameters {
+			params[i] = product.Parameter{Name: param.Name, Value: h(param.Value)}
+		}
+		p.Parameters = params
+	}},
+	"GIFT": {func(p *product.Product) { p.Gifts = nil }, func(p *product.Product, h func(string) string) {
+		if p.Gifts == nil {
+			return
+		}
+		gifts := make([]product.Gift, len(p.Gifts))
+		for i, g := range p.Gifts {
+			gifts[i] = product.Gift{ID: g.ID, Name: h(g.Name)}
+		}
+		p.Gifts = gifts
+	}},
+}
+
+// redactRule is field and how it is redacted
+type redactRule struct {
+	field  string
+	action string
+}
+
+// Redaction drops or hashes fields of items before they are serialized, so sensitive data never leaves the app
+type Redaction struct {
+	rules []redactRule
+}
+
+// ParseRedaction parses rules in format "<field>[=<drop|hash>]", field is dropped when action is omitted.
+// Every value could contain several comma separated rules. Field names are case insensitive.
+func ParseRedaction(values []string) (Redaction, error) {
+	r := Redaction{}
+	seen := map[string]bool{}
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			rule := redactRule{field: s, action: RedactDrop}
+			if i := strings.Index(s, "="); i >= 0 {
+				rule = redactRule{field: strings.TrimSpace(s[:i]), action: strings.TrimSpace(s[i+1:])}
+			}
+			rule.field = strings.ToUpper(rule.field)
+			f, ok := redactedFields[rule.field]
+			if !ok {
+				names := make([]string, 0, len(redactedFields))
+				for n := range redactedFields {
+					names = append(names, n)
+				}
+				sort.Strings(names)
+				return Redaction{}, fmt.Errorf("Field '%s' could not be redacted, use one of %s", rule.field, strings.Join(names, ", "))
+			}
+			switch rule.action {
+			case RedactDrop:
+			case RedactHash:
+				if f.hash == nil {
+					return Redaction{}, fmt.Errorf("Field '%s' could not be hashed, use '%s'", rule.field, RedactDrop)
+				}
+			default:
+				return Redaction{}, fmt.Errorf("Redaction '%s' of field '%s' is not supported, use '%s' or '%s'", rule.action, rule.field, RedactDrop, RedactHash)
+			}
+			if seen[rule.field] {
+				return Redaction{}, fmt.Errorf("Field '%s' is redacted more than once", rule.field)
+			}
+			seen[rule.field] = true
+			r.rules = append(r.rules, rule)
+		}
+	}
+	return r, nil
+}
+
+// Apply returns product with redacted fields. Lists of product are copied before they are changed.
+func (r Redaction) Apply(p product.Product) product.Product {
+	for _, rule := range r.rules {
+		f := redactedFields[rule.field]
+		if rule.action == RedactHash {
+			f.hash(&p, hashValue)
+		} else {
+			f.drop(&p)
+		}
+	}
+	return p
+}
+
+// hashValue returns hex encoded sha256 of value, empty value is kept empty
+func hashValue(s string) string {
+	if s == "" {
+		return s
+	}
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// hashStrings returns copy of list with hashed values
+func hashStrings(list []string, h func(string) string) []string {
+	if list == nil {
+		return nil
+	}
+	hashed := make([]string, len(list))
+	for i, s := range list {
+		hashed[i] = h(s)
+	}
+	return hashed
+}
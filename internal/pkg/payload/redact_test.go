@@ -0,0 +1,78 @@
+package payload
+
+import (
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRedaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		err      string
+		expected []redactRule
+	}{
+		{"empty", nil, "", nil},
+		{"default action", []string{"url"}, "", []redactRule{{"URL", RedactDrop}}},
+		{"comma separated", []string{"URL=drop, EAN = hash", "PARAM=hash"}, "", []redactRule{{"URL", RedactDrop}, {"EAN", RedactHash}, {"PARAM", RedactHash}}},
+		{"unknown field", []string{"ITEM_ID"}, "Field 'ITEM_ID' could not be redacted, use one of", nil},
+		{"unknown action", []string{"URL=mask"}, "Redaction 'mask' of field 'URL' is not supported, use 'drop' or 'hash'", nil},
+		{"number hashed", []string{"PRICE_VAT=hash"}, "Field 'PRICE_VAT' could not be hashed, use 'drop'", nil},
+		{"duplicate", []string{"URL", "url=hash"}, "Field 'URL' is redacted more than once", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRedaction(tt.values)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, r.rules)
+			}
+		})
+	}
+}
+
+func TestRedactionApply(t *testing.T) {
+	p := product.Product{
+		ID:                   "1",
+		URL:                  "https://supplier.internal/1",
+		EAN:                  "123",
+		PriceVAT:             decimal.NewFromInt(10),
+		AlternativeImageURLs: []string{"https://supplier.internal/1.jpg"},
+		Parameters:           []product.Parameter{{Name: "color", Value: "red"}},
+		Deliveries:           []product.Delivery{{ID: "DPD"}},
+	}
+	r, err := ParseRedaction([]string{"URL,EAN=hash,PRICE_VAT,IMGURL_ALTERNATIVE=hash,PARAM=hash,DELIVERY,ISBN=hash"})
+	require.NoError(t, err)
+
+	redacted := r.Apply(p)
+	assert.Equal(t, product.Product{
+		ID:                   "1",
+		EAN:                  "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3",
+		PriceVAT:             decimal.Zero,
+		AlternativeImageURLs: []string{hashValue("https://supplier.internal/1.jpg")},
+		Parameters:           []product.Parameter{{Name: "color", Value: hashValue("red")}},
+	}, redacted)
+	// original item is not changed, so e.g. content hash of state is computed from it
+	assert.Equal(t, "https://supplier.internal/1.jpg", p.AlternativeImageURLs[0])
+	assert.Equal(t, "red", p.Parameters[0].Value)
+
+	assert.Equal(t, p, Redaction{}.Apply(p))
+}
+
+func TestGuardMarshalRedacted(t *testing.T) {
+	g, err := NewGuard(0, StrategyDrop, "")
+	require.NoError(t, err)
+	g.Redaction, err = ParseRedaction([]string{"URL"})
+	require.NoError(t, err)
+	data, err := g.Marshal(product.Product{ID: "1", URL: "https://supplier.internal/1"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "supplier.internal")
+	assert.Contains(t, string(data), `"url":""`)
+}
@@ -0,0 +1,51 @@
+package product
+
+import (
+	"github.com/grubastik/feeddo/internal/pkg/heureka"
+)
+
+// FromHeureka converts item of heureka feed into product
+func FromHeureka(item heureka.Item) Product {
+	p := Product{
+		ID:           string(item.ID),
+		Name:         item.ProductName,
+		Title:        item.Product,
+		Description:  item.Description,
+		URL:          heurekaURL(item.URL),
+		ImageURL:     heurekaURL(item.ImgURL),
+		VideoURL:     heurekaURL(item.VideoURL),
+		PriceVAT:     item.PriceVAT.Decimal,
+		VAT:          string(item.VAT),
+		Type:         item.Type,
+		CPC:          item.HeurekaCPC.Decimal,
+		Manufacturer: item.Manufacturer,
+		Category:     item.CategoryText,
+		EAN:          item.EAN,
+		ISBN:         item.ISBN,
+		DeliveryDate: item.DeliveryDate,
+		GroupID:      item.GroupID,
+		Accessories:  item.Accessories,
+		Dues:         item.Dues.Decimal,
+	}
+	for _, u := range item.ImgURLAlternative {
+		p.AlternativeImageURLs = append(p.AlternativeImageURLs, heurekaURL(u))
+	}
+	for _, param := range item.Parameters {
+		p.Parameters = append(p.Parameters, Parameter{Name: param.Name, Value: param.Value})
+	}
+	for _, d := range item.Deliveries {
+		p.Deliveries = append(p.Deliveries, Delivery{ID: d.ID, Price: d.Price.Decimal, PriceCOD: d.PriceCOD.Decimal})
+	}
+	for _, g := range item.Gifts {
+		p.Gifts = append(p.Gifts, Gift{ID: string(g.ID), Name: g.Name})
+	}
+	return p
+}
+
+// heurekaURL returns empty string for urls which were not provided in feed
+func heurekaURL(u heureka.URL) string {
+	if u == (heureka.URL{}) {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,64 @@
+package product
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/heureka"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromHeureka(t *testing.T) {
+	tests := []struct {
+		name     string
+		xml      string
+		expected Product
+	}{
+		{"empty item", "<SHOPITEM></SHOPITEM>", Product{}},
+		{
+			"item with all nested elements",
+			`<SHOPITEM>
+				<ITEM_ID>abc</ITEM_ID>
+				<PRODUCTNAME>Name</PRODUCTNAME>
+				<URL>http://test.com/abc</URL>
+				<IMGURL_ALTERNATIVE>http://test.com/1.jpg</IMGURL_ALTERNATIVE>
+				<PRICE_VAT>1 000,50</PRICE_VAT>
+				<HEUREKA_CPC>1,5</HEUREKA_CPC>
+				<VAT>21%</VAT>
+				<PARAM><PARAM_NAME>color</PARAM_NAME><VAL>red</VAL></PARAM>
+				<DELIVERY><DELIVERY_ID>PPL</DELIVERY_ID><DELIVERY_PRICE>100</DELIVERY_PRICE><DELIVERY_PRICE_COD>120</DELIVERY_PRICE_COD></DELIVERY>
+				<GIFT ID="g1">Gift</GIFT>
+				<ACCESSORY>def</ACCESSORY>
+			</SHOPITEM>`,
+			Product{
+				ID:                   "abc",
+				Name:                 "Name",
+				URL:                  "http://test.com/abc",
+				AlternativeImageURLs: []string{"http://test.com/1.jpg"},
+				PriceVAT:             decimal.New(100050, -2),
+				CPC:                  decimal.New(15, -1),
+				VAT:                  "21%",
+				Parameters:           []Parameter{{Name: "color", Value: "red"}},
+				Deliveries:           []Delivery{{ID: "PPL", Price: decimal.New(100, 0), PriceCOD: decimal.New(120, 0)}},
+				Gifts:                []Gift{{ID: "g1", Name: "Gift"}},
+				Accessories:          []string{"def"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := heureka.Item{}
+			require.NoError(t, xml.Unmarshal([]byte(tt.xml), &item))
+			p := FromHeureka(item)
+			// decimals should be compared by value
+			expectedJSON, err := json.Marshal(tt.expected)
+			require.NoError(t, err)
+			actualJSON, err := json.Marshal(p)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJSON), string(actualJSON))
+		})
+	}
+}
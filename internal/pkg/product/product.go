@@ -0,0 +1,52 @@
+package product
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Product - format neutral representation of shop item used inside app and sent to consumers.
+// Every supported feed format should provide converter into this model.
+type Product struct {
+	ID                   string          `json:"id"`
+	Name                 string          `json:"name"`
+	Title                string          `json:"product"`
+	Description          string          `json:"description"`
+	URL                  string          `json:"url"`
+	ImageURL             string          `json:"imageUrl"`
+	AlternativeImageURLs []string        `json:"imageUrlsAlternate"`
+	VideoURL             string          `json:"videoUrl"`
+	PriceVAT             decimal.Decimal `json:"priceWithVat"`
+	VAT                  string          `json:"vat"`
+	Type                 string          `json:"type"`
+	CPC                  decimal.Decimal `json:"cpc"`
+	Manufacturer         string          `json:"manufacterer"`
+	Category             string          `json:"category"`
+	EAN                  string          `json:"ean"`
+	ISBN                 string          `json:"isbn"`
+	Parameters           []Parameter     `json:"parameters"`
+	DeliveryDate         string          `json:"deliveryDay"`
+	Deliveries           []Delivery      `json:"deliveries"`
+	GroupID              string          `json:"groupId"`
+	Accessories          []string        `json:"accessories"`
+	Dues                 decimal.Decimal `json:"dues"`
+	Gifts                []Gift          `json:"gifts"`
+}
+
+// Parameter - describes product parameter
+type Parameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Delivery - describes delivery option
+type Delivery struct {
+	ID       string          `json:"id"`
+	Price    decimal.Decimal `json:"price"`
+	PriceCOD decimal.Decimal `json:"priceCod"`
+}
+
+// Gift - describes item which will be added to the order as free item
+type Gift struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compression formats of feeds which are detected by magic bytes
+const (
+	compressionGzip = "gzip"
+	compressionZip  = "zip"
+	compressionZstd = "zstd"
+)
+
+// magicSize is number of bytes of feed which are needed to detect compression
+const magicSize = 4
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// compression returns format of compressed feed, empty string is returned for uncompressed feed.
+// Extension of file and headers of response are not trusted, as suppliers often misconfigure them.
+func compression(start []byte) string {
+	switch {
+	case bytes.HasPrefix(start, gzipMagic):
+		return compressionGzip
+	case bytes.HasPrefix(start, zipMagic):
+		return compressionZip
+	case bytes.HasPrefix(start, zstdMagic):
+		return compressionZstd
+	}
+	return ""
+}
+
+// decompressedReadCloser reads decompressed feed, Closer is compressed stream and close releases decompressor after it is closed
+type decompressedReadCloser struct {
+	io.Reader
+	close func()
+	io.Closer
+}
+
+func (d *decompressedReadCloser) Close() error {
+	err := d.Closer.Close()
+	if d.close != nil {
+		d.close()
+	}
+	return err
+}
+
+// decompress returns stream of decompressed feed, c closes compressed stream r when returned stream is closed or error is returned
+func decompress(format string, r io.Reader, c io.Closer) (io.ReadCloser, error) {
+	switch format {
+	case compressionGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		return &decompressedReadCloser{Reader: gz, Closer: c}, nil
+	case compressionZstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		return &decompressedReadCloser{Reader: zr, close: zr.Close, Closer: c}, nil
+	case compressionZip:
+		return unzip(r, c)
+	}
+	c.Close()
+	return nil, fmt.Errorf("compression '%s' is not supported", format)
+}
+
+// unzip returns stream of XML file of zip archive. Archive could not be read as stream, so it is stored
+// into temporary file which is removed when returned stream is closed.
+func unzip(r io.Reader, c io.Closer) (io.ReadCloser, error) {
+	defer c.Close()
+	tmp, err := ioutil.TempFile("", "feeddo-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	remove := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	file, err := zipFeed(zr.File)
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	rc, err := file.Open()
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	return &decompressedReadCloser{Reader: rc, close: remove, Closer: rc}, nil
+}
+
+// zipFeed returns the only file of archive or its only XML file
+func zipFeed(files []*zip.File) (*zip.File, error) {
+	var all, xml []*zip.File
+	for _, f := range files {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		all = append(all, f)
+		if strings.EqualFold(path.Ext(f.Name), ".xml") {
+			xml = append(xml, f)
+		}
+	}
+	switch {
+	case len(all) == 1:
+		return all[0], nil
+	case len(xml) == 1:
+		return xml[0], nil
+	}
+	return nil, fmt.Errorf("zip archive should contain single XML file, got %d files", len(all))
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const compressedFeed = "<?xml version=\"1.0\"?>\n<SHOP><SHOPITEM><ITEM_ID>1</ITEM_ID></SHOPITEM></SHOP>"
+
+func gzipped(t *testing.T, data string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func zstdCompressed(t *testing.T, data string) string {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func zipped(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestCreateStreamCompressed(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"gzip", gzipped(t, compressedFeed), ""},
+		{"zstd", zstdCompressed(t, compressedFeed), ""},
+		{"zip", zipped(t, map[string]string{"feed.bin": compressedFeed}), ""},
+		{"zip with readme", zipped(t, map[string]string{"README.txt": "feed", "export/feed.XML": compressedFeed}), ""},
+		{"zip with feeds", zipped(t, map[string]string{"a.xml": compressedFeed, "b.xml": compressedFeed}), "zip archive should contain single XML file, got 2 files"},
+		{"broken zip", "PK\x03\x04broken", "Unable to decompress zip feed"},
+		{"gzip of html", gzipped(t, "<html></html>"), "expected XML, got HTML"},
+		{"compressed twice", gzipped(t, gzipped(t, compressedFeed)), "expected XML, got gzip compressed data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// content type does not tell that feed is compressed
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/xml")
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+			u, err := url.Parse(ts.URL + "/feed.xml")
+			require.NoError(t, err)
+
+			stream, err := CreateStream(context.Background(), u)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				assert.Equal(t, apperror.CategoryParse, apperror.Category(err))
+				return
+			}
+			require.NoError(t, err)
+			data, err := ioutil.ReadAll(stream)
+			require.NoError(t, err)
+			assert.Equal(t, compressedFeed, string(data))
+			require.NoError(t, stream.Close())
+		})
+	}
+}
+
+func TestCreateStreamCompressedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "decompress")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// temporary copy of zip archive is removed when stream is closed
+	tmp, err := ioutil.TempDir("", "decompress-tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmp)
+
+	for name, body := range map[string]string{"feed.xml.gz": gzipped(t, compressedFeed), "feed.xml": zipped(t, map[string]string{"feed.xml": compressedFeed})} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(body), 0644))
+		u, err := url.Parse("file://" + path)
+		require.NoError(t, err)
+		stream, err := CreateStream(context.Background(), u)
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(stream)
+		require.NoError(t, err)
+		assert.Equal(t, compressedFeed, string(data))
+		require.NoError(t, stream.Close())
+	}
+	files, err := ioutil.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+)
+
+// ErrNotModified is returned by conditional download when feed was not changed since provided validators
+var ErrNotModified = errors.New("feed was not modified")
+
+// Validators identify version of downloaded feed
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// Request customizes HTTP request of feed, e.g. for API which generates export on POST with JSON body.
+// Feed is downloaded by GET without body when request is empty.
+type Request struct {
+	// Method is GET when it is empty
+	Method      string
+	Body        string
+	ContentType string
+}
+
+// CreateStream generate stream from provided url. Download and reading of stream are aborted when ctx is done.
+func CreateStream(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	readCloser, _, err := CreateConditionalStream(ctx, u, Validators{})
+	return readCloser, err
+}
+
+// CreateConditionalStream generate stream from provided url if it was changed since version identified by validators.
+// Error is returned early when server fails or feed does not look like XML, e.g. server returned HTML error page.
+// ErrNotModified is returned when feed was not changed. Validators of downloaded feed are returned, they are empty for files.
+// Download and reading of stream are aborted when ctx is done.
+func CreateConditionalStream(ctx context.Context, u *url.URL, v Validators) (io.ReadCloser, Validators, error) {
+	return CreateConditionalStreamWithRequest(ctx, u, v, Request{})
+}
+
+// CreateConditionalStreamWithRequest is CreateConditionalStream with custom HTTP request, request is ignored for files.
+// Validators are sent only by GET, as other methods usually generate feed on every request.
+func CreateConditionalStreamWithRequest(ctx context.Context, u *url.URL, v Validators, r Request) (io.ReadCloser, Validators, error) {
+	if u.Scheme == "file" {
+		if err := ctx.Err(); err != nil {
+			return nil, Validators{}, &apperror.DownloadError{Err: fmt.Errorf("Unable to read file `%v` because of %w", u, err)}
+		}
+		readCloser, err := os.Open(u.Hostname() + u.EscapedPath())
+		if err != nil {
+			return nil, Validators{}, &apperror.DownloadError{Err: fmt.Errorf("Unable to read file `%v` because of %w", u, err)}
+		}
+		stream, err := sniff(u, &ctxReadCloser{ctx: ctx, ReadCloser: readCloser}, 0, response{})
+		return stream, Validators{}, err
+	}
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if r.Body != "" {
+		body = strings.NewReader(r.Body)
+	}
+	// body of response is closed by client when ctx is done
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, Validators{}, &apperror.DownloadError{Err: fmt.Errorf("Unable to download file `%v` because of %w", u, err)}
+	}
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+	if method == http.MethodGet {
+		if v.ETag != "" {
+			req.Header.Set("If-None-Match", v.ETag)
+		}
+		if v.LastModified != "" {
+			req.Header.Set("If-Modified-Since", v.LastModified)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, Validators{}, &apperror.DownloadError{Err: fmt.Errorf("Unable to download file `%v` because of %w", u, err)}
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return nil, v, ErrNotModified
+	}
+	stream, err := sniff(u, resp.Body, resp.StatusCode, response{status: resp.Status, contentType: resp.Header.Get("Content-Type")})
+	if err != nil {
+		return nil, Validators{}, err
+	}
+	return stream, Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, nil
+}
+
+// ctxReadCloser fails reading when ctx is done, so reading of large file is aborted in the same way as download
+type ctxReadCloser struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r *ctxReadCloser) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
+}
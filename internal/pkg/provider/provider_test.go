@@ -0,0 +1,256 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		URL       string
+		err       string
+		isFile    bool
+		runServer bool
+	}{
+		{
+			name:      "non existing file",
+			URL:       "file:///test.xml",
+			err:       "Unable to read file `file:///test.xml` because of open /test.xml: no such file or directory",
+			isFile:    true,
+			runServer: false,
+		},
+		{
+			name:      "file success",
+			URL:       "file://testdata/one_item.xml",
+			err:       "",
+			isFile:    true,
+			runServer: false,
+		},
+		{
+			name:      "wrong url",
+			URL:       "http://localhost:8945",
+			err:       "connect: connection refused",
+			isFile:    false,
+			runServer: false,
+		},
+		{
+			name:      "success download",
+			URL:       "",
+			err:       "",
+			isFile:    false,
+			runServer: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.runServer {
+				ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintln(w, "<SHOP></SHOP>")
+				}))
+				defer ts.Close()
+
+				tt.URL = ts.URL
+			}
+
+			u, err := url.Parse(tt.URL)
+			require.NoError(t, err)
+			stream, err := CreateStream(context.Background(), u)
+			if stream != nil {
+				defer stream.Close()
+			}
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				assert.Equal(t, apperror.CategoryDownload, apperror.Category(err))
+			} else {
+				require.NoError(t, err)
+				assert.NotNil(t, stream)
+				if tt.isFile {
+					// file is read through wrapper aborting reading when ctx is done
+					require.IsType(t, &sniffedReadCloser{}, stream)
+					c := stream.(*sniffedReadCloser).Closer
+					require.IsType(t, &ctxReadCloser{}, c)
+					assert.IsType(t, &os.File{}, c.(*ctxReadCloser).ReadCloser)
+				} else {
+					_, ok := stream.(io.ReadCloser)
+					assert.True(t, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateConditionalStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Thu, 02 Jan 2020 03:04:05 GMT")
+		fmt.Fprintln(w, "<SHOP></SHOP>")
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	stream, v, err := CreateConditionalStream(context.Background(), u, Validators{})
+	require.NoError(t, err)
+	stream.Close()
+	assert.Equal(t, Validators{ETag: `"v1"`, LastModified: "Thu, 02 Jan 2020 03:04:05 GMT"}, v)
+
+	stream, v2, err := CreateConditionalStream(context.Background(), u, v)
+	assert.Equal(t, ErrNotModified, err)
+	assert.Nil(t, stream)
+	assert.Equal(t, v, v2)
+
+	u, err = url.Parse("file://testdata/one_item.xml")
+	require.NoError(t, err)
+	stream, v, err = CreateConditionalStream(context.Background(), u, Validators{ETag: `"v1"`})
+	require.NoError(t, err)
+	stream.Close()
+	assert.Equal(t, Validators{}, v)
+}
+
+func TestCreateConditionalStreamWithRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"export":"full"}` || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// validators are not sent by POST, so export is downloaded on every run
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintln(w, "<SHOP></SHOP>")
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	r := Request{Method: http.MethodPost, Body: `{"export":"full"}`, ContentType: "application/json"}
+	stream, v, err := CreateConditionalStreamWithRequest(context.Background(), u, Validators{ETag: `"v1"`}, r)
+	require.NoError(t, err)
+	stream.Close()
+	assert.Equal(t, Validators{ETag: `"v1"`}, v)
+
+	_, _, err = CreateConditionalStreamWithRequest(context.Background(), u, Validators{}, Request{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 400 Bad Request")
+}
+
+func TestCreateStreamCancelled(t *testing.T) {
+	// server sends headers and then stalls body till request is cancelled
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "<SHOP>")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := CreateStream(ctx, u)
+	require.NoError(t, err)
+	defer stream.Close()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err = ioutil.ReadAll(stream)
+	assert.True(t, errors.Is(err, context.Canceled), err)
+
+	// reading of file is aborted in the same way
+	u, err = url.Parse("file://testdata/one_item.xml")
+	require.NoError(t, err)
+	ctx, cancel = context.WithCancel(context.Background())
+	stream, err = CreateStream(ctx, u)
+	require.NoError(t, err)
+	defer stream.Close()
+	cancel()
+	_, err = ioutil.ReadAll(stream)
+	assert.Equal(t, context.Canceled, err)
+	_, err = CreateStream(ctx, u)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, apperror.CategoryDownload, apperror.Category(err))
+}
+
+func TestCreateStreamNotXML(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		err         string
+		category    string
+	}{
+		{"xml", http.StatusOK, "application/xml", "\xef\xbb\xbf  <?xml version=\"1.0\"?>\n<SHOP></SHOP>", "", ""},
+		{"empty", http.StatusOK, "application/xml", "", "", ""},
+		{"html", http.StatusOK, "text/html", "<!DOCTYPE html>\n<html><body>Maintenance</body></html>", "expected XML, got HTML (status 200 OK, content type \"text/html\", starts with \"<!DOCTYPE html>\")", apperror.CategoryParse},
+		{"json", http.StatusOK, "application/json", `{"error":"unauthorized"}`, "expected XML, got JSON (status 200 OK, content type \"application/json\", starts with \"{\\\"error\\\":\\\"unauthorized\\\"}\")", apperror.CategoryParse},
+		{"binary", http.StatusOK, "application/octet-stream", "\x00\x01\x02\x03", "expected XML, got binary data", apperror.CategoryParse},
+		{"broken gzip", http.StatusOK, "application/xml", "\x1f\x8b\x08\x00", "Unable to decompress gzip feed", apperror.CategoryParse},
+		{"server error", http.StatusInternalServerError, "", "<SHOP></SHOP>", "server responded (status 500 Internal Server Error, content type \"text/plain; charset=utf-8\", starts with \"<SHOP></SHOP>\")", apperror.CategoryDownload},
+		{"not found", http.StatusNotFound, "text/html", "", "server responded (status 404 Not Found, content type \"text/html\", empty body)", apperror.CategoryDownload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer ts.Close()
+			u, err := url.Parse(ts.URL)
+			require.NoError(t, err)
+
+			stream, err := CreateStream(context.Background(), u)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Nil(t, stream)
+				assert.Contains(t, err.Error(), tt.err)
+				assert.Equal(t, tt.category, apperror.Category(err))
+				return
+			}
+			require.NoError(t, err)
+			defer stream.Close()
+			// sniffed bytes are not lost
+			data, err := ioutil.ReadAll(stream)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(data))
+		})
+	}
+}
+
+func TestSniffFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sniff")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := dir + "/feed.xml"
+	require.NoError(t, ioutil.WriteFile(file, []byte("<html><head><title>Page with very long title which is cut in error</title></head></html>"), 0644))
+	u, err := url.Parse("file://" + file)
+	require.NoError(t, err)
+	_, err = CreateStream(context.Background(), u)
+	require.Error(t, err)
+	assert.Equal(t, "Feed `file://"+file+"` is not valid: expected XML, got HTML (starts with \"<html><head><title>Page with very long t\"...)", err.Error())
+	assert.True(t, errors.Is(err, ErrNotXML))
+}
@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/apperror"
+)
+
+const (
+	// sniffSize is number of bytes of feed which are inspected before feed is handed to parser
+	sniffSize = 512
+	// quotedStart is max number of characters of feed start which are quoted in errors
+	quotedStart = 40
+)
+
+// ErrNotXML is returned when feed does not start like XML, e.g. server returned HTML error page
+var ErrNotXML = errors.New("expected XML")
+
+// utf8BOM is byte order mark which could precede XML declaration
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// response describes where feed came from, status and content type are empty for files
+type response struct {
+	status      string
+	contentType string
+}
+
+// sniffedReadCloser returns peeked bytes before the rest of stream
+type sniffedReadCloser struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// sniff peeks the beginning of feed and fails early with description of content when feed is not XML or server failed,
+// so users do not see cryptic errors of XML tokens. Feed compressed by gzip, zip or zstd is decompressed.
+// Stream is closed when error is returned.
+func sniff(u fmt.Stringer, r io.ReadCloser, statusCode int, resp response) (io.ReadCloser, error) {
+	br := bufio.NewReaderSize(r, sniffSize)
+	start, err := peek(br)
+	if err != nil {
+		r.Close()
+		return nil, &apperror.DownloadError{Err: fmt.Errorf("Unable to read feed `%v` because of %w", u, err)}
+	}
+	if statusCode >= http.StatusBadRequest {
+		r.Close()
+		return nil, &apperror.DownloadError{Err: fmt.Errorf("Unable to download feed `%v`, server responded %s", u, resp.describe(start))}
+	}
+	if format := compression(start); format != "" {
+		r, err = decompress(format, br, r)
+		if err != nil {
+			return nil, &apperror.ParseError{Err: fmt.Errorf("Unable to decompress %s feed `%v` because of %w", format, u, err)}
+		}
+		br = bufio.NewReaderSize(r, sniffSize)
+		start, err = peek(br)
+		if err != nil {
+			r.Close()
+			return nil, &apperror.ParseError{Err: fmt.Errorf("Unable to decompress %s feed `%v` because of %w", format, u, err)}
+		}
+	}
+	if kind := contentKind(start); kind != "" {
+		r.Close()
+		return nil, &apperror.ParseError{Err: fmt.Errorf("Feed `%v` is not valid: %w, got %s %s", u, ErrNotXML, kind, resp.describe(start))}
+	}
+	return &sniffedReadCloser{Reader: br, Closer: r}, nil
+}
+
+// peek returns the first chunk of stream, only the first chunk is inspected, so slow stream is not waited for
+// until sniffSize bytes arrive. Error is not returned for empty or short stream.
+func peek(br *bufio.Reader) ([]byte, error) {
+	_, err := br.Peek(magicSize)
+	start, _ := br.Peek(br.Buffered())
+	if err != nil && !errors.Is(err, io.EOF) {
+		return start, err
+	}
+	return start, nil
+}
+
+// contentKind returns kind of content which is not XML, empty string is returned for XML and empty feed
+func contentKind(start []byte) string {
+	s := bytes.TrimLeft(bytes.TrimPrefix(start, utf8BOM), " \t\r\n")
+	if len(s) == 0 {
+		return ""
+	}
+	lower := strings.ToLower(string(s[:min(len(s), 32)]))
+	switch {
+	case strings.HasPrefix(lower, "<!doctype html") || strings.HasPrefix(lower, "<html"):
+		return "HTML"
+	case s[0] == '<':
+		return ""
+	case s[0] == '{' || s[0] == '[':
+		return "JSON"
+	case len(s) > 1 && s[0] == 0x1f && s[1] == 0x8b:
+		return "gzip compressed data"
+	}
+	for _, b := range s {
+		if b < ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return "binary data"
+		}
+	}
+	return "text"
+}
+
+// describe returns status, content type and start of feed, e.g. `(status 200 OK, content type "text/html", starts with "<!DOCTYPE html>")`
+func (resp response) describe(start []byte) string {
+	parts := []string{}
+	if resp.status != "" {
+		parts = append(parts, "status "+resp.status)
+	}
+	if resp.contentType != "" {
+		parts = append(parts, fmt.Sprintf("content type %q", resp.contentType))
+	}
+	s := bytes.TrimLeft(bytes.TrimPrefix(start, utf8BOM), " \t\r\n")
+	if i := bytes.IndexAny(s, "\r\n"); i >= 0 {
+		s = s[:i]
+	}
+	if len(s) == 0 {
+		parts = append(parts, "empty body")
+	} else {
+		r := []rune(string(s))
+		if len(r) > quotedStart {
+			parts = append(parts, fmt.Sprintf("starts with %q...", string(r[:quotedStart])))
+		} else {
+			parts = append(parts, fmt.Sprintf("starts with %q", string(r)))
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
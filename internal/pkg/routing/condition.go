@@ -0,0 +1,189 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+)
+
+// operators of conditions, longer operators are listed first, so they are matched before their prefixes
+var operators = []string{"==", "!=", ">=", "<=", "^=", ">", "<"}
+
+// conditionFields supported fields named by elements of heureka feed
+var conditionFields = map[string]func(product.Product) string{
+	"ITEM_ID":       func(p product.Product) string { return p.ID },
+	"ITEMGROUP_ID":  func(p product.Product) string { return p.GroupID },
+	"PRODUCTNAME":   func(p product.Product) string { return p.Name },
+	"MANUFACTURER":  func(p product.Product) string { return p.Manufacturer },
+	"CATEGORYTEXT":  func(p product.Product) string { return p.Category },
+	"EAN":           func(p product.Product) string { return p.EAN },
+	"ISBN":          func(p product.Product) string { return p.ISBN },
+	"ITEM_TYPE":     func(p product.Product) string { return p.Type },
+	"VAT":           func(p product.Product) string { return p.VAT },
+	"DELIVERY_DATE": func(p product.Product) string { return p.DeliveryDate },
+}
+
+// numberFields are compared without formatting, as bidding rule is checked for every item
+var numberFields = map[string]func(product.Product) decimal.Decimal{
+	"PRICE_VAT":   func(p product.Product) decimal.Decimal { return p.PriceVAT },
+	"HEUREKA_CPC": func(p product.Product) decimal.Decimal { return p.CPC },
+	"DUES":        func(p product.Product) decimal.Decimal { return p.Dues },
+}
+
+// Condition compares field of item with value. Operators '>', '>=', '<' and '<=' compare numbers,
+// '^=' matches prefix and '==' with '!=' compare text.
+type Condition struct {
+	Field    string
+	Operator string
+	Value    string
+	// number is parsed value of numeric comparison
+	number decimal.Decimal
+}
+
+// ConditionalRule sends items which match all conditions into its topics in addition to items topic
+type ConditionalRule struct {
+	Conditions []Condition
+	// Topics templates
+	Topics []string
+}
+
+// Filter selects items which match all its conditions, empty filter selects all items
+type Filter []Condition
+
+// ParseFilter parses conditions in format "<field><operator><value>[&<field><operator><value>...]", e.g. "PRICE_VAT>0&CATEGORYTEXT^=Elektronika"
+func ParseFilter(s string) (Filter, error) {
+	f := Filter{}
+	for _, c := range strings.Split(s, "&") {
+		cond, err := parseCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		f = append(f, cond)
+	}
+	return f, nil
+}
+
+// Matches returns true when item satisfies all conditions of filter
+func (f Filter) Matches(p product.Product) bool {
+	for _, c := range f {
+		if !c.Matches(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// biddingRule sends items with bidding into bidding topic
+func biddingRule(topic string) ConditionalRule {
+	c, _ := parseCondition("HEUREKA_CPC>0")
+	return ConditionalRule{Conditions: []Condition{c}, Topics: []string{topic}}
+}
+
+// ParseConditionalRule parses rule in format "<field><operator><value>[&<field><operator><value>...]=><topic template>[,<topic template>...]",
+// e.g. "DELIVERY_DATE==0&PRICE_VAT<100=>cheap_available_{feed}". Field names are case insensitive.
+func ParseConditionalRule(s string) (ConditionalRule, error) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndex(s, "=>")
+	if i < 0 {
+		return ConditionalRule{}, fmt.Errorf("Rule '%s' should have format '<conditions>=><topics>'", s)
+	}
+	conditions, err := ParseFilter(s[:i])
+	if err != nil {
+		return ConditionalRule{}, fmt.Errorf("Rule '%s' is not valid because of %w", s, err)
+	}
+	r := ConditionalRule{Conditions: conditions}
+	for _, t := range strings.Split(s[i+2:], ",") {
+		t = strings.TrimSpace(t)
+		if err := validateTemplate(t); err != nil {
+			return ConditionalRule{}, err
+		}
+		r.Topics = append(r.Topics, t)
+	}
+	return r, nil
+}
+
+func parseCondition(s string) (Condition, error) {
+	s = strings.TrimSpace(s)
+	at, op := -1, ""
+	for _, o := range operators {
+		if i := strings.Index(s, o); i > 0 && (at < 0 || i < at) {
+			at, op = i, o
+		}
+	}
+	if at < 0 {
+		return Condition{}, fmt.Errorf("Condition '%s' should have format '<field><operator><value>', supported operators are %s", s, strings.Join(operators, " "))
+	}
+	c := Condition{Field: strings.ToUpper(strings.TrimSpace(s[:at])), Operator: op, Value: strings.TrimSpace(s[at+len(op):])}
+	_, text := conditionFields[c.Field]
+	_, number := numberFields[c.Field]
+	if !text && !number {
+		names := make([]string, 0, len(conditionFields)+len(numberFields))
+		for n := range conditionFields {
+			names = append(names, n)
+		}
+		for n := range numberFields {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Condition{}, fmt.Errorf("Field '%s' is not supported, use one of %s", c.Field, strings.Join(names, ", "))
+	}
+	switch op {
+	case ">", ">=", "<", "<=":
+		n, err := decimal.NewFromString(c.Value)
+		if err != nil {
+			return Condition{}, fmt.Errorf("Value of condition '%s' should be number", s)
+		}
+		c.number = n
+	}
+	return c, nil
+}
+
+// Matches returns true when item satisfies condition. Field which is not a number never matches numeric comparison.
+func (c Condition) Matches(p product.Product) bool {
+	getNumber, number := numberFields[c.Field]
+	switch c.Operator {
+	case "==", "!=", "^=":
+		var v string
+		if number {
+			v = getNumber(p).String()
+		} else {
+			v = conditionFields[c.Field](p)
+		}
+		switch c.Operator {
+		case "==":
+			return v == c.Value
+		case "!=":
+			return v != c.Value
+		default:
+			return strings.HasPrefix(v, c.Value)
+		}
+	}
+	var n decimal.Decimal
+	if number {
+		n = getNumber(p)
+	} else {
+		var err error
+		n, err = decimal.NewFromString(conditionFields[c.Field](p))
+		if err != nil {
+			return false
+		}
+	}
+	switch c.Operator {
+	case ">":
+		return n.GreaterThan(c.number)
+	case ">=":
+		return n.GreaterThanOrEqual(c.number)
+	case "<":
+		return n.LessThan(c.number)
+	default:
+		return n.LessThanOrEqual(c.number)
+	}
+}
+
+// Matches returns true when item satisfies all conditions of rule
+func (r ConditionalRule) Matches(p product.Product) bool {
+	return Filter(r.Conditions).Matches(p)
+}
@@ -0,0 +1,111 @@
+package routing
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConditionalRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		err      string
+		expected ConditionalRule
+	}{
+		{"no topics", "DELIVERY_DATE==0", "Rule 'DELIVERY_DATE==0' should have format '<conditions>=><topics>'", ConditionalRule{}},
+		{"no operator", "DELIVERY_DATE=>available", "Rule 'DELIVERY_DATE=>available' is not valid because of Condition 'DELIVERY_DATE' should have format '<field><operator><value>', supported operators are == != >= <= ^= > <", ConditionalRule{}},
+		{"not a number", "PRICE_VAT>cheap=>expensive", "Rule 'PRICE_VAT>cheap=>expensive' is not valid because of Value of condition 'PRICE_VAT>cheap' should be number", ConditionalRule{}},
+		{"wrong topic", "EAN!==>a b", "Topic 'a b' contains not allowed characters or has wrong length", ConditionalRule{}},
+		{"several conditions and topics", " delivery_date==0 & CATEGORYTEXT^=Books | Sci-fi=>available, books_{feed}", "", ConditionalRule{
+			Conditions: []Condition{{Field: "DELIVERY_DATE", Operator: "==", Value: "0"}, {Field: "CATEGORYTEXT", Operator: "^=", Value: "Books | Sci-fi"}},
+			Topics:     []string{"available", "books_{feed}"},
+		}},
+		{"value with operator", "PRODUCTNAME==a=>b=>names", "", ConditionalRule{
+			Conditions: []Condition{{Field: "PRODUCTNAME", Operator: "==", Value: "a=>b"}},
+			Topics:     []string{"names"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseConditionalRule(tt.rule)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, r)
+			}
+		})
+	}
+}
+
+func TestConditionMatches(t *testing.T) {
+	p := product.Product{PriceVAT: decimal.New(150, 0), DeliveryDate: "3", Category: "Books | Sci-fi", EAN: ""}
+	tests := []struct {
+		condition string
+		expected  bool
+	}{
+		{"PRICE_VAT>100", true},
+		{"PRICE_VAT>150", false},
+		{"PRICE_VAT>=150", true},
+		{"PRICE_VAT<150.5", true},
+		{"PRICE_VAT<=149", false},
+		{"PRICE_VAT==150", true},
+		{"HEUREKA_CPC>0", false},
+		{"DELIVERY_DATE<=3", true},
+		{"DELIVERY_DATE==0", false},
+		{"CATEGORYTEXT^=Books", true},
+		{"CATEGORYTEXT!=Books", true},
+		{"EAN!=", false},
+		// field which is not a number does not match numeric comparison
+		{"CATEGORYTEXT<1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			c, err := parseCondition(tt.condition)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, c.Matches(p))
+		})
+	}
+}
+
+func TestTopicsConditionalRules(t *testing.T) {
+	feed, err := url.Parse("http://test.org/feed.xml")
+	require.NoError(t, err)
+	router, err := NewRouter("items", "bidding", nil)
+	require.NoError(t, err)
+	available, err := ParseConditionalRule("DELIVERY_DATE==0=>available_{feedhost},items")
+	require.NoError(t, err)
+	cheap, err := ParseConditionalRule("PRICE_VAT<100&HEUREKA_CPC>0=>cheap")
+	require.NoError(t, err)
+	router = router.WithConditionalRules([]ConditionalRule{available, cheap})
+
+	assert.Equal(t, []string{"items"}, router.Topics(feed, product.Product{DeliveryDate: "2", PriceVAT: decimal.New(150, 0)}))
+	// item is not sent into the same topic twice
+	assert.Equal(t, []string{"items", "available_test_org"}, router.Topics(feed, product.Product{DeliveryDate: "0"}))
+	assert.Equal(t, []string{"items", "bidding", "cheap"}, router.Topics(feed, product.Product{PriceVAT: decimal.New(50, 0), CPC: decimal.New(1, 0)}))
+	assert.Equal(t, []string{"available_test_org", "bidding", "cheap", "items"}, router.KnownTopics([]*url.URL{feed}))
+
+	// rules are replaced, bidding rule is kept
+	router = router.WithConditionalRules(nil)
+	assert.Equal(t, []string{"items", "bidding"}, router.Topics(feed, product.Product{DeliveryDate: "0", CPC: decimal.New(1, 0)}))
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter("PRICE_VAT>=100 & CATEGORYTEXT^=Books")
+	require.NoError(t, err)
+	assert.Len(t, f, 2)
+	assert.True(t, f.Matches(product.Product{PriceVAT: decimal.New(100, 0), Category: "Books | Sci-fi"}))
+	assert.False(t, f.Matches(product.Product{PriceVAT: decimal.New(99, 0), Category: "Books | Sci-fi"}))
+	assert.False(t, f.Matches(product.Product{PriceVAT: decimal.New(100, 0), Category: "Toys"}))
+	assert.True(t, Filter(nil).Matches(product.Product{}))
+
+	_, err = ParseFilter("PRICE>=100")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Field 'PRICE' is not supported")
+}
@@ -0,0 +1,37 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+)
+
+// PartitionField returns value of item field used as partition key,
+// so all items with the same value are produced into the same partition and kept in order
+type PartitionField func(product.Product) string
+
+// partitionFields supported fields named by elements of heureka feed
+var partitionFields = map[string]PartitionField{
+	"ITEM_ID":      func(p product.Product) string { return p.ID },
+	"ITEMGROUP_ID": func(p product.Product) string { return p.GroupID },
+	"MANUFACTURER": func(p product.Product) string { return p.Manufacturer },
+	"CATEGORYTEXT": func(p product.Product) string { return p.Category },
+	"EAN":          func(p product.Product) string { return p.EAN },
+	"ISBN":         func(p product.Product) string { return p.ISBN },
+}
+
+// ParsePartitionField returns getter of field with provided name. Name is case insensitive.
+func ParsePartitionField(name string) (PartitionField, error) {
+	f, ok := partitionFields[strings.ToUpper(name)]
+	if !ok {
+		names := make([]string, 0, len(partitionFields))
+		for n := range partitionFields {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("Partition field '%s' is not supported, use one of %s", name, strings.Join(names, ", "))
+	}
+	return f, nil
+}
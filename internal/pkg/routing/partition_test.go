@@ -0,0 +1,35 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePartitionField(t *testing.T) {
+	p := product.Product{ID: "1", GroupID: "g", Manufacturer: "acme", Category: "Shoes", EAN: "123", ISBN: "978"}
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "ITEM_ID", expected: "1"},
+		{name: "ITEMGROUP_ID", expected: "g"},
+		{name: "MANUFACTURER", expected: "acme"},
+		{name: "manufacturer", expected: "acme"},
+		{name: "CATEGORYTEXT", expected: "Shoes"},
+		{name: "EAN", expected: "123"},
+		{name: "ISBN", expected: "978"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParsePartitionField(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, f(p))
+		})
+	}
+	_, err := ParsePartitionField("PRICE_VAT")
+	require.Error(t, err)
+	assert.Equal(t, "Partition field 'PRICE_VAT' is not supported, use one of CATEGORYTEXT, EAN, ISBN, ITEMGROUP_ID, ITEM_ID, MANUFACTURER", err.Error())
+}
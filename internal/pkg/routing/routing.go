@@ -0,0 +1,245 @@
+package routing
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+)
+
+const (
+	// PlaceholderFeedHost is replaced in topic template by host of the feed with not allowed characters replaced by underscore
+	PlaceholderFeedHost = "{feedhost}"
+	// PlaceholderFeed is replaced in topic template by alias of the feed, feed without alias is replaced by its host as PlaceholderFeedHost
+	PlaceholderFeed = "{feed}"
+
+	matchFeed     = "feed"
+	matchCategory = "category"
+)
+
+// modes of sending items with bidding (HEUREKA_CPC set)
+const (
+	// BiddingBoth items with bidding are sent into their topic and into bidding topic
+	BiddingBoth = "both"
+	// BiddingOnly items with bidding are sent only into bidding topic instead of their topic
+	BiddingOnly = "only"
+	// BiddingOff bidding topic is not used, items with bidding are sent only into their topic
+	BiddingOff = "off"
+)
+
+var (
+	reTopic        = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+	reNotTopicChar = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+)
+
+// Rule overrides topic for all items for matched feed or category
+type Rule struct {
+	// Feed prefix of feed url or alias of feed. Empty value matches any feed
+	Feed string
+	// Category prefix of item category. Empty value matches any category
+	Category string
+	// Topic template
+	Topic string
+}
+
+// Router selects topics for items
+type Router struct {
+	itemsTopic string
+	rules      []Rule
+	// conditional rules add topics, the first one is rule of bidding topic
+	conditional []ConditionalRule
+	// biddingMode is one of bidding modes, empty mode is BiddingBoth
+	biddingMode string
+	// aliases of feeds by feed url
+	aliases map[string]string
+	// feedTopics override topic of all items of feed by feed url
+	feedTopics map[string]string
+}
+
+// NewRouter validates topic templates and creates router.
+// Items are sent to itemsTopic unless one of rules matched - first matched rule defines topic.
+// Items with bidding are additionally sent to biddingTopic, other topics could be added by conditional rules.
+func NewRouter(itemsTopic, biddingTopic string, rules []Rule) (Router, error) {
+	for _, t := range []string{itemsTopic, biddingTopic} {
+		if err := validateTemplate(t); err != nil {
+			return Router{}, err
+		}
+	}
+	for _, r := range rules {
+		if err := validateTemplate(r.Topic); err != nil {
+			return Router{}, err
+		}
+	}
+	return Router{itemsTopic: itemsTopic, rules: rules, conditional: []ConditionalRule{biddingRule(biddingTopic)}}, nil
+}
+
+// WithConditionalRules returns router which additionally sends items matching conditional rules into their topics.
+// Rules should be created by ParseConditionalRule, so their topics are valid.
+func (r Router) WithConditionalRules(rules []ConditionalRule) Router {
+	r.conditional = append(r.conditional[:1:1], rules...)
+	return r
+}
+
+// WithBiddingMode returns router which sends items with bidding according to mode
+func (r Router) WithBiddingMode(mode string) (Router, error) {
+	switch mode {
+	case BiddingBoth, BiddingOnly, BiddingOff:
+		r.biddingMode = mode
+		return r, nil
+	}
+	return Router{}, fmt.Errorf("Bidding mode '%s' is not supported, use '%s', '%s' or '%s'", mode, BiddingBoth, BiddingOnly, BiddingOff)
+}
+
+// WithAliases returns router which uses aliases of feeds (mapped by feed url) in topic templates and rules
+func (r Router) WithAliases(aliases map[string]string) Router {
+	r.aliases = aliases
+	return r
+}
+
+// WithFeedTopics returns router which sends all items of feeds into their own topics instead of items topic and rules.
+// Topics are templates mapped by feed url.
+func (r Router) WithFeedTopics(topics map[string]string) (Router, error) {
+	for _, t := range topics {
+		if err := validateTemplate(t); err != nil {
+			return Router{}, err
+		}
+	}
+	r.feedTopics = topics
+	return r, nil
+}
+
+// ParseRule parses rule in format "feed:<url prefix>=<topic template>" or "category:<category prefix>=<topic template>"
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	// topic names could not contain "=" but urls could
+	i := strings.LastIndex(s, "=")
+	if i < 0 {
+		return Rule{}, fmt.Errorf("Rule '%s' should have format '<match>=<topic>'", s)
+	}
+	match, topic := s[:i], s[i+1:]
+	parts := strings.SplitN(match, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Rule{}, fmt.Errorf("Rule '%s' should match '%s:<prefix>' or '%s:<prefix>'", s, matchFeed, matchCategory)
+	}
+	r := Rule{Topic: topic}
+	switch parts[0] {
+	case matchFeed:
+		r.Feed = parts[1]
+	case matchCategory:
+		r.Category = parts[1]
+	default:
+		return Rule{}, fmt.Errorf("Rule '%s' should match '%s:<prefix>' or '%s:<prefix>'", s, matchFeed, matchCategory)
+	}
+	if err := validateTemplate(r.Topic); err != nil {
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+// Topics returns list of topics where item from feed should be sent
+func (r Router) Topics(feed *url.URL, p product.Product) []string {
+	topics := []string{}
+	bidding := r.BiddingTopic(feed) != "" && r.conditional[0].Matches(p)
+	if !bidding || r.biddingMode != BiddingOnly {
+		topics = append(topics, r.ItemsTopic(feed, p))
+	}
+	for i, rule := range r.conditional {
+		if (i == 0 && !bidding) || !rule.Matches(p) {
+			continue
+		}
+		for _, t := range rule.Topics {
+			topics = appendTopic(topics, r.render(t, feed))
+		}
+	}
+	return topics
+}
+
+// ItemsTopic returns topic where item from feed is sent regardless of bidding and conditional rules
+func (r Router) ItemsTopic(feed *url.URL, p product.Product) string {
+	if t, ok := r.feedTopics[feed.String()]; ok {
+		return r.render(t, feed)
+	}
+	for _, rule := range r.rules {
+		if r.matchesFeed(rule, feed) && strings.HasPrefix(p.Category, rule.Category) {
+			return r.render(rule.Topic, feed)
+		}
+	}
+	return r.render(r.itemsTopic, feed)
+}
+
+// BiddingTopic returns topic where items of feed with bidding are sent, it is empty when bidding topic is not used
+func (r Router) BiddingTopic(feed *url.URL) string {
+	if len(r.conditional) == 0 || r.biddingMode == BiddingOff {
+		return ""
+	}
+	return r.render(r.conditional[0].Topics[0], feed)
+}
+
+// appendTopic appends topic unless it is already in the list, so item is not sent into the same topic twice
+func appendTopic(topics []string, topic string) []string {
+	for _, t := range topics {
+		if t == topic {
+			return topics
+		}
+	}
+	return append(topics, topic)
+}
+
+// KnownTopics returns sorted list of all topics where items of provided feeds could be sent
+func (r Router) KnownTopics(feeds []*url.URL) []string {
+	unique := map[string]struct{}{}
+	for _, feed := range feeds {
+		templates := []string{}
+		if t, ok := r.feedTopics[feed.String()]; ok {
+			templates = append(templates, t)
+		} else {
+			templates = append(templates, r.itemsTopic)
+			for _, rule := range r.rules {
+				if r.matchesFeed(rule, feed) {
+					templates = append(templates, rule.Topic)
+				}
+			}
+		}
+		for i, rule := range r.conditional {
+			if i == 0 && r.biddingMode == BiddingOff {
+				continue
+			}
+			templates = append(templates, rule.Topics...)
+		}
+		for _, t := range templates {
+			unique[r.render(t, feed)] = struct{}{}
+		}
+	}
+	topics := make([]string, 0, len(unique))
+	for t := range unique {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func (r Router) matchesFeed(rule Rule, feed *url.URL) bool {
+	if alias, ok := r.aliases[feed.String()]; ok && alias == rule.Feed {
+		return true
+	}
+	return strings.HasPrefix(feed.String(), rule.Feed)
+}
+
+func (r Router) render(template string, feed *url.URL) string {
+	host := reNotTopicChar.ReplaceAllString(feed.Host, "_")
+	name := host
+	if alias, ok := r.aliases[feed.String()]; ok {
+		name = reNotTopicChar.ReplaceAllString(alias, "_")
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(template, PlaceholderFeedHost, host), PlaceholderFeed, name)
+}
+
+func validateTemplate(template string) error {
+	if !reTopic.MatchString(strings.ReplaceAll(strings.ReplaceAll(template, PlaceholderFeedHost, "x"), PlaceholderFeed, "x")) {
+		return fmt.Errorf("Topic '%s' contains not allowed characters or has wrong length", template)
+	}
+	return nil
+}
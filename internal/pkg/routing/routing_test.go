@@ -0,0 +1,169 @@
+package routing
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		err      string
+		expected Rule
+	}{
+		{"no topic", "feed:http://test.com", "Rule 'feed:http://test.com' should have format '<match>=<topic>'", Rule{}},
+		{"no prefix", "feed:=abc", "Rule 'feed:=abc' should match 'feed:<prefix>' or 'category:<prefix>'", Rule{}},
+		{"unknown match", "host:test.com=abc", "Rule 'host:test.com=abc' should match 'feed:<prefix>' or 'category:<prefix>'", Rule{}},
+		{"wrong topic", "feed:http://test.com=a b", "Topic 'a b' contains not allowed characters or has wrong length", Rule{}},
+		{"feed with query", "feed:http://test.com/?a=b=items_{feedhost}", "", Rule{Feed: "http://test.com/?a=b", Topic: "items_{feedhost}"}},
+		{"category", "category:Heureka.cz | Elektronika=electronics", "", Rule{Category: "Heureka.cz | Elektronika", Topic: "electronics"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRule(tt.rule)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, r)
+			}
+		})
+	}
+}
+
+func TestNewRouter(t *testing.T) {
+	_, err := NewRouter("items", "bidding", []Rule{{Topic: "a/b"}})
+	require.Error(t, err)
+	assert.Equal(t, "Topic 'a/b' contains not allowed characters or has wrong length", err.Error())
+	_, err = NewRouter("", "bidding", nil)
+	require.Error(t, err)
+}
+
+func TestTopics(t *testing.T) {
+	feed, err := url.Parse("http://test.example.com:8080/feed.xml")
+	require.NoError(t, err)
+	router, err := NewRouter("items_{feedhost}", "bidding", []Rule{
+		{Feed: "http://other.com", Topic: "other"},
+		{Category: "Books", Topic: "books"},
+		{Feed: "http://test.example.com", Category: "Books", Topic: "never"},
+	})
+	require.NoError(t, err)
+	tests := []struct {
+		name     string
+		product  product.Product
+		expected []string
+	}{
+		{"default topic", product.Product{Category: "Toys"}, []string{"items_test_example_com_8080"}},
+		{"bidding", product.Product{CPC: decimal.New(1, 0)}, []string{"items_test_example_com_8080", "bidding"}},
+		{"first matched rule", product.Product{Category: "Books | Sci-fi"}, []string{"books"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, router.Topics(feed, tt.product))
+		})
+	}
+}
+
+func TestKnownTopics(t *testing.T) {
+	feed, err := url.Parse("http://test.example.com/feed.xml")
+	require.NoError(t, err)
+	other, err := url.Parse("http://other.com/feed.xml")
+	require.NoError(t, err)
+	router, err := NewRouter("items_{feedhost}", "bidding", []Rule{
+		{Feed: "http://other.com", Topic: "other"},
+		{Category: "Books", Topic: "books"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bidding", "books", "items_test_example_com"}, router.KnownTopics([]*url.URL{feed}))
+	assert.Equal(t, []string{"bidding", "books", "items_other_com", "items_test_example_com", "other"}, router.KnownTopics([]*url.URL{feed, other}))
+}
+
+func TestBiddingMode(t *testing.T) {
+	feed, err := url.Parse("http://test.example.com/feed.xml")
+	require.NoError(t, err)
+	router, err := NewRouter("items", "bidding", []Rule{{Category: "Books", Topic: "books"}})
+	require.NoError(t, err)
+	cheap, err := ParseConditionalRule("PRICE_VAT<100=>cheap,bidding")
+	require.NoError(t, err)
+	router = router.WithConditionalRules([]ConditionalRule{cheap})
+	_, err = router.WithBiddingMode("single")
+	require.Error(t, err)
+	assert.Equal(t, "Bidding mode 'single' is not supported, use 'both', 'only' or 'off'", err.Error())
+
+	bidding := product.Product{Category: "Books", CPC: decimal.New(1, 0), PriceVAT: decimal.New(500, 0)}
+	cheapBidding := product.Product{CPC: decimal.New(1, 0), PriceVAT: decimal.New(50, 0)}
+	tests := []struct {
+		mode         string
+		bidding      []string
+		cheapBidding []string
+		biddingTopic string
+		known        []string
+	}{
+		{BiddingBoth, []string{"books", "bidding"}, []string{"items", "bidding", "cheap"}, "bidding", []string{"bidding", "books", "cheap", "items"}},
+		{BiddingOnly, []string{"bidding"}, []string{"bidding", "cheap"}, "bidding", []string{"bidding", "books", "cheap", "items"}},
+		// conditional rule could still send items into topic named as bidding topic
+		{BiddingOff, []string{"books"}, []string{"items", "cheap", "bidding"}, "", []string{"bidding", "books", "cheap", "items"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			r, err := router.WithBiddingMode(tt.mode)
+			require.NoError(t, err)
+			assert.Equal(t, tt.bidding, r.Topics(feed, bidding))
+			assert.Equal(t, tt.cheapBidding, r.Topics(feed, cheapBidding))
+			// items without bidding are not affected
+			assert.Equal(t, []string{"books"}, r.Topics(feed, product.Product{Category: "Books", PriceVAT: decimal.New(500, 0)}))
+			assert.Equal(t, "books", r.ItemsTopic(feed, bidding))
+			assert.Equal(t, tt.biddingTopic, r.BiddingTopic(feed))
+			assert.Equal(t, tt.known, r.KnownTopics([]*url.URL{feed}))
+		})
+	}
+	router, err = NewRouter("items", "bidding", nil)
+	require.NoError(t, err)
+	router, err = router.WithBiddingMode(BiddingOff)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"items"}, router.KnownTopics([]*url.URL{feed}))
+}
+
+func TestTopicsAliases(t *testing.T) {
+	feed, err := url.Parse("http://test.example.com/feed.xml?signature=abc")
+	require.NoError(t, err)
+	other, err := url.Parse("http://other.com/feed.xml")
+	require.NoError(t, err)
+	router, err := NewRouter("items_{feed}", "bidding_{feedhost}", []Rule{
+		{Feed: "catalog", Category: "Books", Topic: "catalog_books"},
+	})
+	require.NoError(t, err)
+	router = router.WithAliases(map[string]string{feed.String(): "catalog"})
+	assert.Equal(t, []string{"items_catalog", "bidding_test_example_com"}, router.Topics(feed, product.Product{CPC: decimal.New(1, 0)}))
+	assert.Equal(t, []string{"catalog_books"}, router.Topics(feed, product.Product{Category: "Books"}))
+	// feed without alias is named by its host
+	assert.Equal(t, []string{"items_other_com"}, router.Topics(other, product.Product{Category: "Books"}))
+	assert.Equal(t, []string{"bidding_other_com", "bidding_test_example_com", "catalog_books", "items_catalog", "items_other_com"}, router.KnownTopics([]*url.URL{feed, other}))
+	assert.Equal(t, "bidding_test_example_com", router.BiddingTopic(feed))
+	assert.Equal(t, "", Router{}.BiddingTopic(feed))
+}
+
+func TestTopicsFeedTopics(t *testing.T) {
+	feed, err := url.Parse("http://test.example.com/feed.xml")
+	require.NoError(t, err)
+	other, err := url.Parse("http://test.example.com/feed.xml2")
+	require.NoError(t, err)
+	router, err := NewRouter("items", "bidding", []Rule{{Category: "Books", Topic: "books"}})
+	require.NoError(t, err)
+	_, err = router.WithFeedTopics(map[string]string{feed.String(): "a/b"})
+	require.Error(t, err)
+	router, err = router.WithFeedTopics(map[string]string{feed.String(): "supplier_{feedhost}"})
+	require.NoError(t, err)
+	// feed topic overrides rules, conditional rules still add topics
+	assert.Equal(t, []string{"supplier_test_example_com", "bidding"}, router.Topics(feed, product.Product{Category: "Books", CPC: decimal.New(1, 0)}))
+	// feed is matched exactly, not by prefix
+	assert.Equal(t, []string{"books"}, router.Topics(other, product.Product{Category: "Books"}))
+	assert.Equal(t, []string{"bidding", "supplier_test_example_com"}, router.KnownTopics([]*url.URL{feed}))
+}
@@ -0,0 +1,101 @@
+package amqpsink
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type itemTest struct {
+	payload []byte
+	err     error
+}
+
+func (i itemTest) GetContext() string       { return "http://test.org" }
+func (i itemTest) GetID() string            { return "1" }
+func (i itemTest) Marshal() ([]byte, error) { return i.payload, i.err }
+func (i itemTest) Topics() []string         { return []string{"shop_items", "shop_items_bidding"} }
+func (i itemTest) Headers() []sink.Header   { return []sink.Header{{Key: "h", Value: []byte("v")}} }
+
+type published struct {
+	exchange string
+	key      string
+	msg      amqp.Publishing
+}
+
+// publisherMock fails messages routed with key failKey
+type publisherMock struct {
+	messages []published
+	failKey  string
+}
+
+func (p *publisherMock) publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	p.messages = append(p.messages, published{exchange: exchange, key: key, msg: msg})
+	if key == p.failKey {
+		return errors.New("Message was rejected by broker")
+	}
+	return nil
+}
+func (p *publisherMock) Close() error { return nil }
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name       string
+		exchange   string
+		routingKey string
+		expected   [][2]string
+	}{
+		{name: "default exchange", expected: [][2]string{{"", "shop_items"}, {"", "shop_items_bidding"}}},
+		{name: "templates", exchange: "feeddo", routingKey: "items.{topic}", expected: [][2]string{{"feeddo", "items.shop_items"}, {"feeddo", "items.shop_items_bidding"}}},
+		{name: "exchange per topic", exchange: "{topic}", routingKey: "all", expected: [][2]string{{"shop_items", "all"}, {"shop_items_bidding", "all"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &publisherMock{}
+			s := newSink(p, tt.exchange, tt.routingKey)
+			res := s.Send(context.Background(), itemTest{payload: []byte(`{"id":"1"}`)})
+			require.NoError(t, res.Err)
+			assert.Equal(t, SinkName, res.Cluster)
+			assert.Equal(t, 2, len(res.Topics))
+			routes := [][2]string{}
+			for _, m := range p.messages {
+				routes = append(routes, [2]string{m.exchange, m.key})
+			}
+			assert.Equal(t, tt.expected, routes)
+			msg := p.messages[0].msg
+			assert.Equal(t, []byte(`{"id":"1"}`), msg.Body)
+			assert.Equal(t, "1", msg.MessageId)
+			assert.Equal(t, amqp.Persistent, msg.DeliveryMode)
+			assert.Equal(t, amqp.Table{HeaderFeed: "http://test.org", "h": "v"}, msg.Headers)
+		})
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	p := &publisherMock{failKey: "shop_items_bidding"}
+	s := newSink(p, "", "")
+	res := s.Send(context.Background(), itemTest{})
+	require.Error(t, res.Err)
+	assert.Equal(t, "Failed to publish message for topic shop_items_bidding because of Message was rejected by broker", res.Err.Error())
+	assert.True(t, res.Tombstone)
+	assert.Equal(t, true, p.messages[0].msg.Headers[HeaderTombstone])
+	assert.NoError(t, res.Topics[0].Err)
+	assert.Error(t, res.Topics[1].Err)
+
+	p = &publisherMock{}
+	s = newSink(p, "", "")
+	res = s.Send(context.Background(), itemTest{err: errors.New("test error")})
+	require.Error(t, res.Err)
+	assert.Equal(t, "Failed to marshal json: test error", res.Err.Error())
+	assert.Equal(t, 2, len(res.Topics))
+	assert.Equal(t, 0, len(p.messages))
+
+	_, err := New("", "", "")
+	require.Error(t, err)
+	assert.Equal(t, "AMQP url was not provided", err.Error())
+}
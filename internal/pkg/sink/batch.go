@@ -0,0 +1,119 @@
+package sink
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultFlushInterval is used when flush interval of batching is not set
+const DefaultFlushInterval = time.Second
+
+// Batching configures how items are grouped before they are delivered by bulk API of sink
+type Batching struct {
+	// Size is max number of items of batch
+	Size int
+	// FlushInterval is time after which not full batch is delivered, so items of slow feeds are not delayed.
+	// DefaultFlushInterval is used when it is not set
+	FlushInterval time.Duration
+	// Concurrency is number of batches delivered at the same time, batches are delivered in order when it is 1
+	Concurrency int
+}
+
+// Batcher is implemented by sinks which deliver items by bulk API, e.g. transaction or single request.
+// Run groups items into batches for them.
+type Batcher interface {
+	Batching() Batching
+	// SendBatch delivers items and returns result for every item in the same order
+	SendBatch(ctx context.Context, items []Item) []Result
+}
+
+// Batches groups items from channel into batches in order they were received. Batch is emitted when it is full
+// or after flush interval. Channel of batches is closed when items channel is closed or when ctx is done,
+// items which are already buffered are still emitted in that case. Nil items and items without context are skipped.
+func Batches(ctx context.Context, items <-chan Item, size int, flushInterval time.Duration) <-chan []Item {
+	if size < 1 {
+		size = 1
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	batches := make(chan []Item)
+	go func() {
+		defer close(batches)
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		batch := make([]Item, 0, size)
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			batches <- batch
+			batch = make([]Item, 0, size)
+		}
+		defer flush()
+		add := func(item Item) {
+			if item == nil || item.GetContext() == "" {
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= size {
+				flush()
+			}
+		}
+		for {
+			select {
+			case item, ok := <-items:
+				if !ok {
+					return
+				}
+				add(item)
+			case <-ticker.C:
+				flush()
+			case <-ctx.Done():
+				for {
+					select {
+					case item, ok := <-items:
+						if !ok {
+							return
+						}
+						add(item)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return batches
+}
+
+// runBatches delivers batches of items by batcher. Batches are delivered even after ctx is done,
+// so collected items are not lost, sink limits time of delivery itself.
+func runBatches(ctx context.Context, b Batcher, items <-chan Item) (<-chan Result, <-chan struct{}) {
+	batching := b.Batching()
+	if batching.Concurrency < 1 {
+		batching.Concurrency = 1
+	}
+	chanRes := make(chan Result, 1)
+	chanExited := make(chan struct{})
+	batches := Batches(ctx, items, batching.Size, batching.FlushInterval)
+	wg := sync.WaitGroup{}
+	for i := 0; i < batching.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				for _, res := range b.SendBatch(context.Background(), batch) {
+					chanRes <- res
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(chanRes)
+		close(chanExited)
+	}()
+	return chanRes, chanExited
+}
@@ -0,0 +1,98 @@
+package sink
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// batcherMock records sizes of delivered batches
+type batcherMock struct {
+	sinkMock
+	batching Batching
+	mu       sync.Mutex
+	batches  [][]string
+}
+
+func (b *batcherMock) Batching() Batching { return b.batching }
+
+func (b *batcherMock) SendBatch(ctx context.Context, items []Item) []Result {
+	ids := make([]string, len(items))
+	results := make([]Result, len(items))
+	for i, item := range items {
+		ids[i] = item.GetID()
+		results[i] = Result{ItemContext: item.GetContext(), ItemID: item.GetID(), Cluster: "batch"}
+	}
+	b.mu.Lock()
+	b.batches = append(b.batches, ids)
+	b.mu.Unlock()
+	return results
+}
+
+// itemWithoutContext is skipped by batching
+type itemWithoutContext struct{ itemTest }
+
+func (i itemWithoutContext) GetContext() string { return "" }
+
+func TestBatches(t *testing.T) {
+	items := make(chan Item, 10)
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		items <- itemTest{id: id}
+	}
+	items <- nil
+	items <- itemWithoutContext{itemTest{id: "6"}}
+	close(items)
+	batches := [][]string{}
+	for batch := range Batches(context.Background(), items, 2, time.Hour) {
+		ids := []string{}
+		for _, item := range batch {
+			ids = append(ids, item.GetID())
+		}
+		batches = append(batches, ids)
+	}
+	assert.Equal(t, [][]string{{"1", "2"}, {"3", "4"}, {"5"}}, batches)
+}
+
+func TestBatchesFlushInterval(t *testing.T) {
+	items := make(chan Item)
+	batches := Batches(context.Background(), items, 10, 10*time.Millisecond)
+	items <- itemTest{id: "1"}
+	// not full batch is emitted after flush interval
+	select {
+	case batch := <-batches:
+		assert.Equal(t, 1, len(batch))
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed")
+	}
+	close(items)
+	_, ok := <-batches
+	assert.False(t, ok)
+}
+
+func TestRunBatcher(t *testing.T) {
+	b := &batcherMock{batching: Batching{Size: 3, FlushInterval: time.Hour}}
+	ctx, cancel := context.WithCancel(context.Background())
+	items := make(chan Item, 10)
+	chanRes, chanExited := Run(ctx, b, 4, items)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		items <- itemTest{id: id}
+	}
+	ids := []string{}
+	for i := 0; i < 3; i++ {
+		res := <-chanRes
+		assert.Equal(t, "batch", res.Cluster)
+		ids = append(ids, res.ItemID)
+	}
+	// not full batch is delivered when sink is stopped
+	cancel()
+	for res := range chanRes {
+		ids = append(ids, res.ItemID)
+	}
+	<-chanExited
+	assert.Equal(t, []string{"1", "2", "3", "4"}, ids)
+	assert.Equal(t, [][]string{{"1", "2", "3"}, {"4"}}, b.batches)
+	assert.Empty(t, b.sent)
+}
@@ -0,0 +1,96 @@
+package drysink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+const (
+	// SinkName is name under which dry run sink is registered
+	SinkName = "dry-run"
+)
+
+// Config is configuration of sink created by name
+type Config struct {
+	// Print is number of the first items which are printed to stdout
+	Print int
+}
+
+func init() {
+	sink.Register(SinkName, func(ctx context.Context, config interface{}) (sink.Sink, error) {
+		c, ok := config.(Config)
+		if !ok {
+			return nil, sink.ConfigTypeError(SinkName, Config{}, config)
+		}
+		return New(os.Stdout, c.Print), nil
+	})
+}
+
+// Sink validates items without delivering them anywhere. The first items are printed as json lines
+// in format of file sink, so messages which would be produced could be checked.
+type Sink struct {
+	mu      sync.Mutex
+	out     io.Writer
+	limit   int
+	valid   int
+	invalid int
+}
+
+// New creates sink which prints up to limit first valid items into out
+func New(out io.Writer, limit int) *Sink {
+	return &Sink{out: out, limit: limit}
+}
+
+// Send validates item, it is reported as delivered when it could be marshalled
+func (s *Sink) Send(ctx context.Context, item sink.Item) sink.Result {
+	started := time.Now()
+	res := sink.Result{ItemContext: item.GetContext(), ItemID: item.GetID(), Cluster: SinkName}
+	r, err := sink.NewRecord(item)
+	if err == nil {
+		res.Tombstone = r.Tombstone
+		err = s.count(r)
+	} else {
+		s.mu.Lock()
+		s.invalid++
+		s.mu.Unlock()
+	}
+	res.Err = err
+	for _, topic := range item.Topics() {
+		res.Topics = append(res.Topics, sink.TopicResult{Topic: topic, Partition: -1, Offset: -1, Err: err, Latency: time.Since(started)})
+	}
+	return res
+}
+
+// count counts valid item and prints it when it is one of the first ones
+func (s *Sink) count(r sink.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.valid++
+	if s.valid > s.limit {
+		return nil
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal record: %w", err)
+	}
+	if _, err := s.out.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Failed to print item: %w", err)
+	}
+	return nil
+}
+
+// Close reports number of items which would be delivered
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Dry run: %d items would be delivered, %d items are not valid", s.valid, s.invalid)
+	return nil
+}
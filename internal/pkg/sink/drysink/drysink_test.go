@@ -0,0 +1,72 @@
+package drysink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type itemTest struct {
+	id      string
+	payload []byte
+	err     error
+}
+
+func (i itemTest) GetContext() string       { return "http://test.org" }
+func (i itemTest) GetID() string            { return i.id }
+func (i itemTest) Marshal() ([]byte, error) { return i.payload, i.err }
+func (i itemTest) Topics() []string         { return []string{"items", "bidding"} }
+
+func TestSend(t *testing.T) {
+	out := &bytes.Buffer{}
+	s := New(out, 2)
+	tests := []struct {
+		name      string
+		item      itemTest
+		err       string
+		tombstone bool
+	}{
+		{name: "item", item: itemTest{id: "1", payload: []byte(`{"id":"1"}`)}},
+		{name: "tombstone", item: itemTest{id: "2"}, tombstone: true},
+		{name: "marshal error", item: itemTest{id: "3", err: errors.New("test error")}, err: "Failed to marshal json: test error"},
+		{name: "not printed", item: itemTest{id: "4", payload: []byte(`{"id":"4"}`)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := s.Send(context.Background(), tt.item)
+			assert.Equal(t, tt.item.id, res.ItemID)
+			assert.Equal(t, SinkName, res.Cluster)
+			assert.Equal(t, tt.tombstone, res.Tombstone)
+			require.Equal(t, 2, len(res.Topics))
+			if tt.err != "" {
+				require.Error(t, res.Err)
+				assert.Equal(t, tt.err, res.Err.Error())
+			} else {
+				require.NoError(t, res.Err)
+			}
+		})
+	}
+	// only the first valid items are printed
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, []string{
+		`{"context":"http://test.org","id":"1","topics":["items","bidding"],"payload":{"id":"1"}}`,
+		`{"context":"http://test.org","id":"2","topics":["items","bidding"],"tombstone":true,"payload":null}`,
+	}, lines)
+	assert.Equal(t, 3, s.valid)
+	assert.Equal(t, 1, s.invalid)
+	assert.NoError(t, s.Close())
+}
+
+func TestRegistered(t *testing.T) {
+	s, err := sink.New(context.Background(), SinkName, Config{Print: 5})
+	require.NoError(t, err)
+	assert.Equal(t, 5, s.(*Sink).limit)
+	_, err = sink.New(context.Background(), SinkName, 5)
+	assert.EqualError(t, err, "Configuration of sink 'dry-run' should be drysink.Config instead of int")
+}
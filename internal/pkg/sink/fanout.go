@@ -0,0 +1,149 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FailurePolicy defines how failure of secondary sink affects item
+type FailurePolicy string
+
+const (
+	// FailurePolicyFail item fails when it was not delivered by any sink
+	FailurePolicyFail FailurePolicy = "fail"
+	// FailurePolicyLog failures of secondary sinks are only logged, result of item is defined by primary sink
+	FailurePolicyLog FailurePolicy = "log"
+)
+
+// ParseFailurePolicy converts name of policy, empty name means FailurePolicyFail
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	switch p := FailurePolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return FailurePolicyFail, nil
+	case FailurePolicyFail, FailurePolicyLog:
+		return p, nil
+	}
+	return "", fmt.Errorf("Sink failure policy '%s' is not supported, use one of fail, log", s)
+}
+
+// Fanout delivers every item into several sinks. The first sink is primary, others are secondary.
+// Every sink reports its own results which are marked by name of sink, so errors are accounted per sink.
+type Fanout struct {
+	names []string
+	sinks []Sink
+}
+
+// NewFanout combines sinks, names are used to mark results of sinks
+func NewFanout(names []string, sinks []Sink) *Fanout {
+	return &Fanout{names: names, sinks: sinks}
+}
+
+// Sinks returns combined sinks
+func (f *Fanout) Sinks() []Sink {
+	return f.sinks
+}
+
+// Send delivers item into all sinks concurrently and returns result of primary sink.
+// When primary sink succeeded the first error of secondary sinks is reported.
+func (f *Fanout) Send(ctx context.Context, item Item) Result {
+	results := make([]Result, len(f.sinks))
+	wg := sync.WaitGroup{}
+	for i, s := range f.sinks {
+		wg.Add(1)
+		go func(i int, s Sink) {
+			defer wg.Done()
+			results[i] = s.Send(ctx, item)
+		}(i, s)
+	}
+	wg.Wait()
+	res := results[0]
+	res.Sink = f.names[0]
+	for i, r := range results[1:] {
+		if res.Err == nil && r.Err != nil {
+			res.Err = fmt.Errorf("Sink '%s' failed: %w", f.names[i+1], r.Err)
+		}
+	}
+	return res
+}
+
+// run delivers items by every sink and merges their results. Every sink gets all items,
+// so the slowest sink slows down delivery of items into other sinks.
+func (f *Fanout) run(ctx context.Context, workers int, items <-chan Item) (<-chan Result, <-chan struct{}) {
+	chanRes := make(chan Result, 1)
+	chanExited := make(chan struct{})
+	chans := make([]chan Item, len(f.sinks))
+	exited := make([]<-chan struct{}, len(f.sinks))
+	wg := sync.WaitGroup{}
+	for i, s := range f.sinks {
+		chans[i] = make(chan Item, 1)
+		var results <-chan Result
+		// sinks stop when their channels are closed, so items buffered on cancellation are delivered into all sinks
+		results, exited[i] = Run(context.Background(), s, workers, chans[i])
+		wg.Add(1)
+		go func(i int, results <-chan Result) {
+			defer wg.Done()
+			for res := range results {
+				res.Sink = f.names[i]
+				res.Secondary = i > 0
+				chanRes <- res
+			}
+		}(i, results)
+	}
+	go func() {
+		wg.Wait()
+		close(chanRes)
+		close(chanExited)
+	}()
+	go func() {
+		defer func() {
+			for _, ch := range chans {
+				close(ch)
+			}
+		}()
+		forward := func(item Item) {
+			for i, ch := range chans {
+				// sink which already exited could not get item anymore
+				select {
+				case ch <- item:
+				case <-exited[i]:
+				}
+			}
+		}
+		for {
+			select {
+			case item, ok := <-items:
+				if !ok {
+					return
+				}
+				forward(item)
+			case <-ctx.Done():
+				// items which are already buffered are still delivered
+				for {
+					select {
+					case item, ok := <-items:
+						if !ok {
+							return
+						}
+						forward(item)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return chanRes, chanExited
+}
+
+// Close closes all sinks and returns the first error
+func (f *Fanout) Close() error {
+	var result error
+	for i, s := range f.sinks {
+		if err := s.Close(); err != nil && result == nil {
+			result = fmt.Errorf("Unable to close sink '%s' because of %w", f.names[i], err)
+		}
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type closeErrorSink struct{ sinkMock }
+
+func (s *closeErrorSink) Close() error { return errors.New("test error") }
+
+func TestParseFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected FailurePolicy
+		err      string
+	}{
+		{name: "empty", expected: FailurePolicyFail},
+		{name: "fail", value: "fail", expected: FailurePolicyFail},
+		{name: "log", value: " LOG ", expected: FailurePolicyLog},
+		{name: "wrong", value: "ignore", err: "Sink failure policy 'ignore' is not supported, use one of fail, log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseFailurePolicy(tt.value)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, p)
+		})
+	}
+}
+
+func TestFanoutSend(t *testing.T) {
+	primary, secondary := &sinkMock{}, &sinkMock{}
+	f := NewFanout([]string{"primary", "secondary"}, []Sink{primary, secondary})
+	res := f.Send(context.Background(), itemTest{id: "1"})
+	require.NoError(t, res.Err)
+	assert.Equal(t, "primary", res.Sink)
+	res = f.Send(context.Background(), itemTest{id: "fail"})
+	require.Error(t, res.Err)
+	assert.Equal(t, []string{"1", "fail"}, primary.sent)
+	assert.Equal(t, []string{"1", "fail"}, secondary.sent)
+
+	f = NewFanout([]string{"primary", "secondary"}, []Sink{primary, &closeErrorSink{}})
+	err := f.Close()
+	require.Error(t, err)
+	assert.Equal(t, "Unable to close sink 'secondary' because of test error", err.Error())
+}
+
+func TestFanoutRun(t *testing.T) {
+	primary, secondary := &streamerMock{}, &sinkMock{}
+	f := NewFanout([]string{"kafka", "file"}, []Sink{primary, secondary})
+	items := make(chan Item, 3)
+	items <- itemTest{id: "1"}
+	items <- itemTest{id: "fail"}
+	close(items)
+	chanRes, chanExited := Run(context.Background(), f, 2, items)
+	results := map[string][]Result{}
+	for res := range chanRes {
+		results[res.Sink] = append(results[res.Sink], res)
+	}
+	<-chanExited
+	require.Equal(t, 2, len(results["kafka"]))
+	require.Equal(t, 2, len(results["file"]))
+	for _, res := range results["kafka"] {
+		assert.False(t, res.Secondary)
+		assert.Equal(t, "stream", res.Cluster)
+		assert.NoError(t, res.Err)
+	}
+	failed := 0
+	for _, res := range results["file"] {
+		assert.True(t, res.Secondary)
+		if res.Err != nil {
+			failed++
+		}
+	}
+	assert.Equal(t, 1, failed)
+}
+
+func TestFanoutRunCancelled(t *testing.T) {
+	primary, secondary := &sinkMock{}, &sinkMock{}
+	f := NewFanout([]string{"primary", "secondary"}, []Sink{primary, secondary})
+	ctx, cancel := context.WithCancel(context.Background())
+	items := make(chan Item, 2)
+	chanRes, chanExited := Run(ctx, f, 1, items)
+	items <- itemTest{id: "1"}
+	cancel()
+	for range chanRes {
+	}
+	<-chanExited
+	// item is delivered by both sinks or by none of them
+	assert.Equal(t, len(primary.sent), len(secondary.sent))
+}
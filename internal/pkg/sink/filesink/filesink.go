@@ -0,0 +1,158 @@
+package filesink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+const (
+	// SinkName is name under which file sink is registered
+	SinkName = "file"
+	// Stdout is path which writes items to standard output
+	Stdout = "-"
+)
+
+// Config is configuration of sink created by name
+type Config struct {
+	// Path of file where items are written, Stdout or empty value means standard output
+	Path string
+	// MaxSize is size of file in bytes after which it is rotated, zero means no rotation
+	MaxSize int64
+	// MaxBackups is number of rotated files which are kept
+	MaxBackups int
+}
+
+func init() {
+	sink.Register(SinkName, func(ctx context.Context, config interface{}) (sink.Sink, error) {
+		c, ok := config.(Config)
+		if !ok {
+			return nil, sink.ConfigTypeError(SinkName, Config{}, config)
+		}
+		s, err := New(c.Path, c.MaxSize, c.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+// Sink writes items as json lines (NDJSON) into standard output or into file which is rotated by size
+type Sink struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	out        io.Writer
+	file       *os.File
+	size       int64
+}
+
+// New opens file for appending items. Items are written into standard output when path is empty or Stdout.
+func New(path string, maxSize int64, maxBackups int) (*Sink, error) {
+	if path == "" || path == Stdout {
+		return &Sink{path: Stdout, out: os.Stdout}, nil
+	}
+	if maxSize < 0 || maxBackups < 0 {
+		return nil, fmt.Errorf("Max size and number of backups of file sink should not be negative")
+	}
+	s := &Sink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sink) open() error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to open sink file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("Unable to open sink file: %w", err)
+	}
+	s.file, s.out, s.size = f, f, fi.Size()
+	return nil
+}
+
+// Send writes item as single line. Payload which is not valid json is reported as error.
+func (s *Sink) Send(ctx context.Context, item sink.Item) sink.Result {
+	started := time.Now()
+	res := sink.Result{ItemContext: item.GetContext(), ItemID: item.GetID(), Cluster: SinkName}
+	line, err := sink.NewRecord(item)
+	if err == nil {
+		res.Tombstone = line.Tombstone
+		err = s.write(line)
+	}
+	res.Err = err
+	for _, topic := range item.Topics() {
+		res.Topics = append(res.Topics, sink.TopicResult{Topic: topic, Partition: -1, Offset: -1, Err: err, Latency: time.Since(started)})
+	}
+	return res
+}
+
+func (s *Sink) write(r sink.Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal record: %w", err)
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file != nil && s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.out.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("Failed to write item: %w", err)
+	}
+	return nil
+}
+
+// rotate renames current file to <path>.1 shifting older backups, the oldest one is removed
+func (s *Sink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("Unable to rotate sink file: %w", err)
+	}
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("Unable to rotate sink file: %w", err)
+		}
+		return s.open()
+	}
+	for i := s.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(backup(s.path, i), backup(s.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Unable to rotate sink file: %w", err)
+		}
+	}
+	if err := os.Rename(s.path, backup(s.path, 1)); err != nil {
+		return fmt.Errorf("Unable to rotate sink file: %w", err)
+	}
+	return s.open()
+}
+
+func backup(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// Close closes file, standard output is left open
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,114 @@
+package filesink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type itemTest struct {
+	id      string
+	payload []byte
+	err     error
+	headers []sink.Header
+}
+
+func (i itemTest) GetContext() string       { return "http://test.org" }
+func (i itemTest) GetID() string            { return i.id }
+func (i itemTest) Marshal() ([]byte, error) { return i.payload, i.err }
+func (i itemTest) Topics() []string         { return []string{"items", "bidding"} }
+func (i itemTest) Headers() []sink.Header   { return i.headers }
+
+func TestSend(t *testing.T) {
+	out := &bytes.Buffer{}
+	s := &Sink{path: Stdout, out: out}
+	tests := []struct {
+		name      string
+		item      itemTest
+		err       string
+		tombstone bool
+	}{
+		{name: "item", item: itemTest{id: "1", payload: []byte(`{"id":"1"}`), headers: []sink.Header{{Key: "h", Value: []byte("v")}}}},
+		{name: "tombstone", item: itemTest{id: "2"}, tombstone: true},
+		{name: "marshal error", item: itemTest{id: "3", err: errors.New("test error")}, err: "Failed to marshal json: test error"},
+		{name: "invalid json", item: itemTest{id: "4", payload: []byte("abc")}, err: "Payload of item 4 is not valid json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := s.Send(context.Background(), tt.item)
+			assert.Equal(t, tt.item.id, res.ItemID)
+			assert.Equal(t, "http://test.org", res.ItemContext)
+			assert.Equal(t, SinkName, res.Cluster)
+			assert.Equal(t, tt.tombstone, res.Tombstone)
+			require.Equal(t, 2, len(res.Topics))
+			if tt.err != "" {
+				require.Error(t, res.Err)
+				assert.Equal(t, tt.err, res.Err.Error())
+				assert.Equal(t, res.Err, res.Topics[1].Err)
+			} else {
+				require.NoError(t, res.Err)
+			}
+		})
+	}
+	assert.Equal(t, `{"context":"http://test.org","id":"1","topics":["items","bidding"],"headers":{"h":"v"},"payload":{"id":"1"}}
+{"context":"http://test.org","id":"2","topics":["items","bidding"],"tombstone":true,"payload":null}
+`, out.String())
+	require.NoError(t, s.Close())
+}
+
+func TestRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "items.json")
+
+	// every line is longer than half of max size, so file is rotated after every item
+	s, err := New(path, 150, 2)
+	require.NoError(t, err)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, s.Send(context.Background(), itemTest{id: id, payload: []byte(`{"id":"` + id + `"}`)}).Err)
+	}
+	require.NoError(t, s.Close())
+	for file, id := range map[string]string{path: "4", path + ".1": "3", path + ".2": "2"} {
+		content, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `"id":"`+id+`"`, file)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// file is appended after restart
+	s, err = New(path, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), itemTest{id: "5", payload: []byte(`{}`)}).Err)
+	require.NoError(t, s.Close())
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(content, []byte("\n")))
+}
+
+func TestNew(t *testing.T) {
+	s, err := New("", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, os.Stdout, s.out)
+	require.NoError(t, s.Close())
+
+	_, err = New("/not/existing/dir/items.json", 0, 0)
+	require.Error(t, err)
+	assert.Equal(t, "Unable to open sink file: open /not/existing/dir/items.json: no such file or directory", err.Error())
+
+	_, err = New("items.json", -1, 0)
+	require.Error(t, err)
+
+	registered, err := sink.New(context.Background(), SinkName, Config{Path: Stdout})
+	require.NoError(t, err)
+	assert.IsType(t, &Sink{}, registered)
+}
@@ -0,0 +1,136 @@
+package kinesissink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+const (
+	// SinkName is name under which Kinesis sink is registered
+	SinkName = "kinesis"
+	// PlaceholderTopic is replaced in stream template by topic of item
+	PlaceholderTopic = "{topic}"
+
+	// DefaultStream puts items into streams named as topics of item (shop_items and shop_items_bidding)
+	DefaultStream = PlaceholderTopic
+)
+
+// Config is configuration of sink created by name
+type Config struct {
+	// Region is AWS region, empty value means region from AWS environment
+	Region string
+	// Stream is template of stream name
+	Stream string
+}
+
+func init() {
+	sink.Register(SinkName, func(ctx context.Context, config interface{}) (sink.Sink, error) {
+		c, ok := config.(Config)
+		if !ok {
+			return nil, sink.ConfigTypeError(SinkName, Config{}, config)
+		}
+		s, err := New(c.Region, c.Stream)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+// putter puts record into stream
+type putter interface {
+	put(ctx context.Context, stream, partitionKey string, data []byte) error
+}
+
+// clientPutter puts records by Kinesis client
+type clientPutter struct {
+	client *kinesis.Kinesis
+}
+
+func (p clientPutter) put(ctx context.Context, stream, partitionKey string, data []byte) error {
+	_, err := p.client.PutRecordWithContext(ctx, &kinesis.PutRecordInput{
+		StreamName:   aws.String(stream),
+		PartitionKey: aws.String(partitionKey),
+		Data:         data,
+	})
+	return err
+}
+
+// Sink puts items into Kinesis streams rendered from template for every topic of item.
+// ITEM_ID is used as partition key, so changes of item are kept in order.
+// Records contain only payload, tombstones are records with empty data.
+// Credentials are resolved by AWS default credential chain.
+type Sink struct {
+	putter putter
+	stream string
+}
+
+// New creates Kinesis client
+func New(region, stream string) (*Sink, error) {
+	config := aws.NewConfig()
+	if region != "" {
+		config = config.WithRegion(region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{Config: *config, SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create AWS session because of %w", err)
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		return nil, fmt.Errorf("Kinesis region was not provided")
+	}
+	return newSink(clientPutter{client: kinesis.New(sess)}, stream), nil
+}
+
+func newSink(p putter, stream string) *Sink {
+	if stream == "" {
+		stream = DefaultStream
+	}
+	return &Sink{putter: p, stream: stream}
+}
+
+// Send puts item into stream for every its topic. The first error is reported as item error.
+func (s *Sink) Send(ctx context.Context, item sink.Item) sink.Result {
+	started := time.Now()
+	res := sink.Result{ItemContext: item.GetContext(), ItemID: item.GetID(), Cluster: SinkName}
+	payload, err := item.Marshal()
+	if err != nil {
+		res.Err = fmt.Errorf("Failed to marshal json: %w", err)
+	} else if item.GetID() == "" {
+		// partition key is required by Kinesis
+		res.Err = fmt.Errorf("Item without id could not be put into Kinesis")
+	}
+	if res.Err != nil {
+		for _, topic := range item.Topics() {
+			res.Topics = append(res.Topics, sink.TopicResult{Topic: topic, Partition: -1, Offset: -1, Err: res.Err})
+		}
+		return res
+	}
+	res.Tombstone = payload == nil
+	if res.Tombstone {
+		// data is required by Kinesis, but it could be empty
+		payload = []byte{}
+	}
+	for _, topic := range item.Topics() {
+		err := s.putter.put(ctx, strings.ReplaceAll(s.stream, PlaceholderTopic, topic), item.GetID(), payload)
+		if err != nil {
+			err = fmt.Errorf("Failed to put record for topic %s because of %w", topic, err)
+			if res.Err == nil {
+				res.Err = err
+			}
+		}
+		res.Topics = append(res.Topics, sink.TopicResult{Topic: topic, Partition: -1, Offset: -1, Err: err, Latency: time.Since(started)})
+	}
+	return res
+}
+
+// Close does nothing, records are put synchronously
+func (s *Sink) Close() error {
+	return nil
+}
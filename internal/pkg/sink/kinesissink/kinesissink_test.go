@@ -0,0 +1,102 @@
+package kinesissink
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type itemTest struct {
+	id      string
+	payload []byte
+	err     error
+}
+
+func (i itemTest) GetContext() string       { return "http://test.org" }
+func (i itemTest) GetID() string            { return i.id }
+func (i itemTest) Marshal() ([]byte, error) { return i.payload, i.err }
+func (i itemTest) Topics() []string         { return []string{"shop_items", "shop_items_bidding"} }
+
+type record struct {
+	stream       string
+	partitionKey string
+	data         []byte
+}
+
+// putterMock fails records put into failStream
+type putterMock struct {
+	records    []record
+	failStream string
+}
+
+func (p *putterMock) put(ctx context.Context, stream, partitionKey string, data []byte) error {
+	p.records = append(p.records, record{stream: stream, partitionKey: partitionKey, data: data})
+	if stream == p.failStream {
+		return errors.New("ResourceNotFoundException")
+	}
+	return nil
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   string
+		expected []string
+	}{
+		{name: "default stream", expected: []string{"shop_items", "shop_items_bidding"}},
+		{name: "template", stream: "feeddo-{topic}", expected: []string{"feeddo-shop_items", "feeddo-shop_items_bidding"}},
+		{name: "single stream", stream: "items", expected: []string{"items", "items"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &putterMock{}
+			s := newSink(p, tt.stream)
+			res := s.Send(context.Background(), itemTest{id: "1", payload: []byte(`{"id":"1"}`)})
+			require.NoError(t, res.Err)
+			assert.Equal(t, SinkName, res.Cluster)
+			assert.Equal(t, 2, len(res.Topics))
+			streams := []string{}
+			for _, r := range p.records {
+				streams = append(streams, r.stream)
+				assert.Equal(t, "1", r.partitionKey)
+				assert.Equal(t, []byte(`{"id":"1"}`), r.data)
+			}
+			assert.Equal(t, tt.expected, streams)
+		})
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	p := &putterMock{failStream: "shop_items_bidding"}
+	s := newSink(p, "")
+	res := s.Send(context.Background(), itemTest{id: "1"})
+	require.Error(t, res.Err)
+	assert.Equal(t, "Failed to put record for topic shop_items_bidding because of ResourceNotFoundException", res.Err.Error())
+	assert.True(t, res.Tombstone)
+	assert.Equal(t, []byte{}, p.records[0].data)
+	assert.NoError(t, res.Topics[0].Err)
+	assert.Error(t, res.Topics[1].Err)
+
+	tests := []struct {
+		name string
+		item itemTest
+		err  string
+	}{
+		{name: "marshal error", item: itemTest{id: "1", err: errors.New("test error")}, err: "Failed to marshal json: test error"},
+		{name: "empty id", item: itemTest{payload: []byte(`{}`)}, err: "Item without id could not be put into Kinesis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &putterMock{}
+			s := newSink(p, "")
+			res := s.Send(context.Background(), tt.item)
+			require.Error(t, res.Err)
+			assert.Equal(t, tt.err, res.Err.Error())
+			assert.Equal(t, 2, len(res.Topics))
+			assert.Equal(t, 0, len(p.records))
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package pgsink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	// registers postgres driver for database/sql
+	_ "github.com/lib/pq"
+)
+
+const (
+	// SinkName is name under which postgres sink is registered
+	SinkName = "postgres"
+	// DefaultTable is table where items are stored by default
+	DefaultTable = "products"
+	// DefaultBatchSize is number of items written in single transaction by default
+	DefaultBatchSize = 100
+
+	// flushInterval not full batch is written after this interval
+	flushInterval = time.Second
+	// timeout of single transaction
+	writeTimeout = 30 * time.Second
+)
+
+var reIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Config is configuration of sink created by name
+type Config struct {
+	// DSN is connection string of database
+	DSN string
+	// Table where items are stored, could be qualified by schema
+	Table string
+	// Columns are names of table columns, DefaultColumns when it is empty
+	Columns Columns
+	// BatchSize is max number of items written in single transaction
+	BatchSize int
+}
+
+func init() {
+	sink.Register(SinkName, func(ctx context.Context, config interface{}) (sink.Sink, error) {
+		c, ok := config.(Config)
+		if !ok {
+			return nil, sink.ConfigTypeError(SinkName, Config{}, config)
+		}
+		if c.Columns == (Columns{}) {
+			c.Columns = DefaultColumns
+		}
+		s, err := New(ctx, c.DSN, c.Table, c.Columns, c.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+// Columns are names of table columns. Table should have unique constraint on (Feed, ID).
+type Columns struct {
+	ID   string
+	Feed string
+	// Payload is json or jsonb column with item
+	Payload string
+	// Updated is timestamp column set on every upsert, empty name means that column is not used
+	Updated string
+}
+
+// DefaultColumns are used when mapping is not configured
+var DefaultColumns = Columns{ID: "id", Feed: "feed", Payload: "payload", Updated: "updated_at"}
+
+// ParseColumns overrides default column names by mapping in format "id=item_id,payload=data,updated="
+func ParseColumns(s string) (Columns, error) {
+	c := DefaultColumns
+	if strings.TrimSpace(s) == "" {
+		return c, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return Columns{}, fmt.Errorf("Column mapping '%s' should have format '<column>=<name>'", pair)
+		}
+		name := strings.TrimSpace(kv[1])
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "id":
+			c.ID = name
+		case "feed":
+			c.Feed = name
+		case "payload":
+			c.Payload = name
+		case "updated":
+			c.Updated = name
+		default:
+			return Columns{}, fmt.Errorf("Column '%s' is not supported, use one of id, feed, payload, updated", kv[0])
+		}
+	}
+	return c, c.Validate()
+}
+
+// Validate checks that all required columns are set and names are valid identifiers
+func (c Columns) Validate() error {
+	for _, name := range []string{c.ID, c.Feed, c.Payload} {
+		if !reIdentifier.MatchString(name) {
+			return fmt.Errorf("Column name '%s' is not valid", name)
+		}
+	}
+	if c.Updated != "" && !reIdentifier.MatchString(c.Updated) {
+		return fmt.Errorf("Column name '%s' is not valid", c.Updated)
+	}
+	return nil
+}
+
+// statement is query with its arguments
+type statement struct {
+	query string
+	args  []interface{}
+}
+
+// executor executes statements in single transaction
+type executor interface {
+	exec(ctx context.Context, statements []statement) error
+	Close() error
+}
+
+// sqlExecutor executes statements by database/sql
+type sqlExecutor struct {
+	db *sql.DB
+}
+
+func (e sqlExecutor) exec(ctx context.Context, statements []statement) error {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, st := range statements {
+		if _, err = tx.ExecContext(ctx, st.query, st.args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (e sqlExecutor) Close() error {
+	return e.db.Close()
+}
+
+// Sink upserts items into table, tombstones delete rows. Items are written in batches, one transaction per batch.
+type Sink struct {
+	db        executor
+	table     string
+	columns   Columns
+	batchSize int
+	// mu serializes batches, so the latest version of item wins
+	mu sync.Mutex
+}
+
+// New connects to database and checks connection
+func New(ctx context.Context, dsn, table string, columns Columns, batchSize int) (*Sink, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("Postgres connection string was not provided")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open postgres connection because of %w", err)
+	}
+	ctxPing, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	if err = db.PingContext(ctxPing); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Unable to connect to postgres because of %w", err)
+	}
+	s, err := newSink(sqlExecutor{db: db}, table, columns, batchSize)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSink(db executor, table string, columns Columns, batchSize int) (*Sink, error) {
+	if table == "" {
+		table = DefaultTable
+	}
+	parts := strings.Split(table, ".")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("Table name '%s' is not valid", table)
+	}
+	for i, p := range parts {
+		if !reIdentifier.MatchString(p) {
+			return nil, fmt.Errorf("Table name '%s' is not valid", table)
+		}
+		parts[i] = quote(p)
+	}
+	if err := columns.Validate(); err != nil {
+		return nil, err
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Sink{db: db, table: strings.Join(parts, "."), columns: columns, batchSize: batchSize}, nil
+}
+
+func quote(identifier string) string {
+	return `"` + identifier + `"`
+}
+
+// Send writes single item
+func (s *Sink) Send(ctx context.Context, item sink.Item) sink.Result {
+	return s.write(ctx, []sink.Item{item})[0]
+}
+
+// Batching implements sink.Batcher: batches are written one by one, so the latest version of item wins
+func (s *Sink) Batching() sink.Batching {
+	return sink.Batching{Size: s.batchSize, FlushInterval: flushInterval, Concurrency: 1}
+}
+
+// SendBatch writes items in single transaction. Write is limited by write timeout only, so batch collected
+// before sink was stopped is still written.
+func (s *Sink) SendBatch(ctx context.Context, items []sink.Item) []sink.Result {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	return s.write(ctx, items)
+}
+
+// write writes items in single transaction and returns result for every item
+func (s *Sink) write(ctx context.Context, items []sink.Item) []sink.Result {
+	started := time.Now()
+	results := make([]sink.Result, len(items))
+	statements := []statement{}
+	// rows are upserted by single statement which could not affect the same row twice - the last version wins
+	upserts := map[rowKey]int{}
+	order := []rowKey{}
+	payloads := make([][]byte, len(items))
+	for i, item := range items {
+		results[i] = sink.Result{ItemContext: item.GetContext(), ItemID: item.GetID(), Cluster: SinkName}
+		payload, err := item.Marshal()
+		if err != nil {
+			results[i].Err = fmt.Errorf("Failed to marshal json: %w", err)
+			continue
+		}
+		key := rowKey{feed: item.GetContext(), id: item.GetID()}
+		if payload == nil {
+			results[i].Tombstone = true
+			delete(upserts, key)
+			statements = append(statements, s.deleteStatement(key))
+			continue
+		}
+		payloads[i] = payload
+		if _, ok := upserts[key]; !ok {
+			order = append(order, key)
+		}
+		upserts[key] = i
+	}
+	rows := [][]interface{}{}
+	for _, key := range order {
+		if i, ok := upserts[key]; ok {
+			rows = append(rows, []interface{}{key.id, key.feed, string(payloads[i])})
+		}
+	}
+	if len(rows) > 0 {
+		statements = append(statements, s.upsertStatement(rows))
+	}
+	var err error
+	if len(statements) > 0 {
+		s.mu.Lock()
+		err = s.db.exec(ctx, statements)
+		s.mu.Unlock()
+		if err != nil {
+			err = fmt.Errorf("Failed to write items into postgres because of %w", err)
+		}
+	}
+	for i, item := range items {
+		if results[i].Err == nil {
+			results[i].Err = err
+		}
+		for _, topic := range item.Topics() {
+			results[i].Topics = append(results[i].Topics, sink.TopicResult{Topic: topic, Partition: -1, Offset: -1, Err: results[i].Err, Latency: time.Since(started)})
+		}
+	}
+	return results
+}
+
+type rowKey struct {
+	feed string
+	id   string
+}
+
+func (s *Sink) upsertStatement(rows [][]interface{}) statement {
+	c := s.columns
+	columns := []string{quote(c.ID), quote(c.Feed), quote(c.Payload)}
+	update := []string{fmt.Sprintf("%s = EXCLUDED.%s", quote(c.Payload), quote(c.Payload))}
+	if c.Updated != "" {
+		columns = append(columns, quote(c.Updated))
+		update = append(update, fmt.Sprintf("%s = EXCLUDED.%s", quote(c.Updated), quote(c.Updated)))
+	}
+	values := make([]string, 0, len(rows))
+	args := make([]interface{}, 0, len(rows)*3)
+	for _, row := range rows {
+		n := len(args)
+		v := fmt.Sprintf("($%d, $%d, $%d", n+1, n+2, n+3)
+		if c.Updated != "" {
+			v += ", now()"
+		}
+		values = append(values, v+")")
+		args = append(args, row...)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s ON CONFLICT (%s, %s) DO UPDATE SET %s",
+		s.table, strings.Join(columns, ", "), strings.Join(values, ", "), quote(c.Feed), quote(c.ID), strings.Join(update, ", "))
+	return statement{query: query, args: args}
+}
+
+func (s *Sink) deleteStatement(key rowKey) statement {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND %s = $2", s.table, quote(s.columns.Feed), quote(s.columns.ID))
+	return statement{query: query, args: []interface{}{key.feed, key.id}}
+}
+
+// Close closes connection to database
+func (s *Sink) Close() error {
+	return s.db.Close()
+}
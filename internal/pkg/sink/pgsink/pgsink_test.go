@@ -0,0 +1,153 @@
+package pgsink
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type itemTest struct {
+	id      string
+	payload []byte
+	err     error
+}
+
+func (i itemTest) GetContext() string       { return "http://test.org" }
+func (i itemTest) GetID() string            { return i.id }
+func (i itemTest) Marshal() ([]byte, error) { return i.payload, i.err }
+func (i itemTest) Topics() []string         { return []string{"items"} }
+
+// executorMock records statements of every transaction
+type executorMock struct {
+	transactions [][]statement
+	err          error
+}
+
+func (e *executorMock) exec(ctx context.Context, statements []statement) error {
+	e.transactions = append(e.transactions, statements)
+	return e.err
+}
+func (e *executorMock) Close() error { return nil }
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapping  string
+		expected Columns
+		err      string
+	}{
+		{name: "default", mapping: "", expected: DefaultColumns},
+		{name: "override", mapping: "id=item_id, payload=data,updated=", expected: Columns{ID: "item_id", Feed: "feed", Payload: "data"}},
+		{name: "wrong format", mapping: "id", err: "Column mapping 'id' should have format '<column>=<name>'"},
+		{name: "unknown column", mapping: "price=p", err: "Column 'price' is not supported, use one of id, feed, payload, updated"},
+		{name: "invalid name", mapping: "id=a;drop", err: "Column name 'a;drop' is not valid"},
+		{name: "missing required", mapping: "feed=", err: "Column name '' is not valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseColumns(tt.mapping)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, c)
+		})
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	s, err := newSink(&executorMock{}, "", DefaultColumns, 0)
+	require.NoError(t, err)
+	assert.Equal(t, `"products"`, s.table)
+	assert.Equal(t, DefaultBatchSize, s.batchSize)
+	s, err = newSink(&executorMock{}, "shop.items", DefaultColumns, 10)
+	require.NoError(t, err)
+	assert.Equal(t, `"shop"."items"`, s.table)
+	_, err = newSink(&executorMock{}, "items; drop table x", DefaultColumns, 10)
+	require.Error(t, err)
+	assert.Equal(t, "Table name 'items; drop table x' is not valid", err.Error())
+	_, err = New(context.Background(), "", "", DefaultColumns, 0)
+	require.Error(t, err)
+	assert.Equal(t, "Postgres connection string was not provided", err.Error())
+}
+
+func TestWrite(t *testing.T) {
+	db := &executorMock{}
+	s, err := newSink(db, "products", DefaultColumns, 10)
+	require.NoError(t, err)
+	results := s.write(context.Background(), []sink.Item{
+		itemTest{id: "1", payload: []byte(`{"v":1}`)},
+		itemTest{id: "2", payload: []byte(`{"v":1}`)},
+		// the last version of item wins
+		itemTest{id: "1", payload: []byte(`{"v":2}`)},
+		itemTest{id: "3"},
+		itemTest{id: "4", err: errors.New("test error")},
+	})
+	require.Equal(t, 5, len(results))
+	for i := 0; i < 4; i++ {
+		require.NoError(t, results[i].Err)
+		assert.Equal(t, SinkName, results[i].Cluster)
+		assert.Equal(t, 1, len(results[i].Topics))
+	}
+	assert.True(t, results[3].Tombstone)
+	require.Error(t, results[4].Err)
+	assert.Equal(t, "Failed to marshal json: test error", results[4].Err.Error())
+
+	require.Equal(t, 1, len(db.transactions))
+	assert.Equal(t, []statement{
+		{
+			query: `DELETE FROM "products" WHERE "feed" = $1 AND "id" = $2`,
+			args:  []interface{}{"http://test.org", "3"},
+		},
+		{
+			query: `INSERT INTO "products" ("id", "feed", "payload", "updated_at") VALUES ($1, $2, $3, now()), ($4, $5, $6, now()) ` +
+				`ON CONFLICT ("feed", "id") DO UPDATE SET "payload" = EXCLUDED."payload", "updated_at" = EXCLUDED."updated_at"`,
+			args: []interface{}{"1", "http://test.org", `{"v":2}`, "2", "http://test.org", `{"v":1}`},
+		},
+	}, db.transactions[0])
+
+	// transaction error fails all items of batch
+	db.err = errors.New("connection refused")
+	res := s.Send(context.Background(), itemTest{id: "1", payload: []byte(`{}`)})
+	require.Error(t, res.Err)
+	assert.Equal(t, "Failed to write items into postgres because of connection refused", res.Err.Error())
+	assert.Equal(t, res.Err, res.Topics[0].Err)
+}
+
+func TestWriteWithoutUpdatedColumn(t *testing.T) {
+	db := &executorMock{}
+	s, err := newSink(db, "products", Columns{ID: "id", Feed: "feed", Payload: "data"}, 10)
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), itemTest{id: "1", payload: []byte(`{}`)}).Err)
+	assert.Equal(t, `INSERT INTO "products" ("id", "feed", "data") VALUES ($1, $2, $3) ON CONFLICT ("feed", "id") DO UPDATE SET "data" = EXCLUDED."data"`,
+		db.transactions[0][0].query)
+}
+
+func TestRunBatches(t *testing.T) {
+	db := &executorMock{}
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := newSink(db, "products", DefaultColumns, 2)
+	require.NoError(t, err)
+	items := make(chan sink.Item, 3)
+	chanRes, chanExited := sink.Run(ctx, s, 4, items)
+	for _, id := range []string{"1", "2", "3"} {
+		items <- itemTest{id: id, payload: []byte(`{}`)}
+	}
+	// full batch is written immediately
+	for i := 0; i < 2; i++ {
+		require.NoError(t, (<-chanRes).Err)
+	}
+	// not full batch is written when sink is stopped
+	cancel()
+	res := <-chanRes
+	require.NoError(t, res.Err)
+	assert.Equal(t, "3", res.ItemID)
+	<-chanExited
+	assert.Equal(t, 2, len(db.transactions))
+}
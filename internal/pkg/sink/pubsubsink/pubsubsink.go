@@ -0,0 +1,154 @@
+package pubsubsink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+const (
+	// SinkName is name under which Pub/Sub sink is registered
+	SinkName = "pubsub"
+	// PlaceholderTopic is replaced in topic template by topic of item
+	PlaceholderTopic = "{topic}"
+	// AttributeTombstone is set for messages of deleted items which have empty data
+	AttributeTombstone = "feeddo-tombstone"
+	// AttributeFeed contains feed url of item
+	AttributeFeed = "feeddo-feed"
+	// AttributeID contains id of item
+	AttributeID = "feeddo-id"
+
+	// DefaultTopic publishes items into Pub/Sub topics named as topics of item (shop_items and shop_items_bidding)
+	DefaultTopic = PlaceholderTopic
+)
+
+// Config is configuration of sink created by name
+type Config struct {
+	// Project is id of GCP project
+	Project string
+	// Topic is template of Pub/Sub topic id
+	Topic string
+}
+
+func init() {
+	sink.Register(SinkName, func(ctx context.Context, config interface{}) (sink.Sink, error) {
+		c, ok := config.(Config)
+		if !ok {
+			return nil, sink.ConfigTypeError(SinkName, Config{}, config)
+		}
+		s, err := New(ctx, c.Project, c.Topic)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+// publisher publishes message into topic and waits till it is accepted by server
+type publisher interface {
+	publish(ctx context.Context, topic string, msg *pubsub.Message) error
+	Close() error
+}
+
+// clientPublisher publishes by Pub/Sub client. Topic handlers are created on first use and batch messages in background.
+type clientPublisher struct {
+	client *pubsub.Client
+	mu     sync.Mutex
+	topics map[string]*pubsub.Topic
+}
+
+func (p *clientPublisher) publish(ctx context.Context, topic string, msg *pubsub.Message) error {
+	p.mu.Lock()
+	t, ok := p.topics[topic]
+	if !ok {
+		t = p.client.Topic(topic)
+		p.topics[topic] = t
+	}
+	p.mu.Unlock()
+	_, err := t.Publish(ctx, msg).Get(ctx)
+	return err
+}
+
+func (p *clientPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// messages which are still batched are published
+	for _, t := range p.topics {
+		t.Stop()
+	}
+	return p.client.Close()
+}
+
+// Sink publishes items into Pub/Sub topics rendered from template for every topic of item.
+// Credentials are resolved by Google application default credentials.
+type Sink struct {
+	publisher publisher
+	topic     string
+}
+
+// New creates Pub/Sub client for project
+func New(ctx context.Context, project, topic string) (*Sink, error) {
+	if project == "" {
+		return nil, fmt.Errorf("Pub/Sub project was not provided")
+	}
+	// client lives till sink is closed, so it should not be bound to context of creation
+	client, err := pubsub.NewClient(context.Background(), project)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create Pub/Sub client because of %w", err)
+	}
+	return newSink(&clientPublisher{client: client, topics: map[string]*pubsub.Topic{}}, topic), nil
+}
+
+func newSink(p publisher, topic string) *Sink {
+	if topic == "" {
+		topic = DefaultTopic
+	}
+	return &Sink{publisher: p, topic: topic}
+}
+
+// Send publishes item for every its topic. The first error is reported as item error.
+func (s *Sink) Send(ctx context.Context, item sink.Item) sink.Result {
+	started := time.Now()
+	res := sink.Result{ItemContext: item.GetContext(), ItemID: item.GetID(), Cluster: SinkName}
+	payload, err := item.Marshal()
+	if err != nil {
+		res.Err = fmt.Errorf("Failed to marshal json: %w", err)
+		for _, topic := range item.Topics() {
+			res.Topics = append(res.Topics, sink.TopicResult{Topic: topic, Partition: -1, Offset: -1, Err: res.Err})
+		}
+		return res
+	}
+	res.Tombstone = payload == nil
+	attributes := map[string]string{AttributeFeed: item.GetContext(), AttributeID: item.GetID()}
+	if res.Tombstone {
+		attributes[AttributeTombstone] = "true"
+	}
+	if h, ok := item.(sink.Headerer); ok {
+		for _, header := range h.Headers() {
+			attributes[header.Key] = string(header.Value)
+		}
+	}
+	for _, topic := range item.Topics() {
+		// every publish gets its own message because client sets id and publish time of published message
+		msg := &pubsub.Message{Data: payload, Attributes: attributes}
+		err := s.publisher.publish(ctx, strings.ReplaceAll(s.topic, PlaceholderTopic, topic), msg)
+		if err != nil {
+			err = fmt.Errorf("Failed to publish message for topic %s because of %w", topic, err)
+			if res.Err == nil {
+				res.Err = err
+			}
+		}
+		res.Topics = append(res.Topics, sink.TopicResult{Topic: topic, Partition: -1, Offset: -1, Err: err, Latency: time.Since(started)})
+	}
+	return res
+}
+
+// Close publishes batched messages and closes client
+func (s *Sink) Close() error {
+	return s.publisher.Close()
+}
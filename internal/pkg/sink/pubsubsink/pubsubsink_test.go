@@ -0,0 +1,97 @@
+package pubsubsink
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type itemTest struct {
+	payload []byte
+	err     error
+}
+
+func (i itemTest) GetContext() string       { return "http://test.org" }
+func (i itemTest) GetID() string            { return "1" }
+func (i itemTest) Marshal() ([]byte, error) { return i.payload, i.err }
+func (i itemTest) Topics() []string         { return []string{"shop_items", "shop_items_bidding"} }
+func (i itemTest) Headers() []sink.Header   { return []sink.Header{{Key: "h", Value: []byte("v")}} }
+
+type published struct {
+	topic string
+	msg   *pubsub.Message
+}
+
+// publisherMock fails messages published into failTopic
+type publisherMock struct {
+	messages  []published
+	failTopic string
+}
+
+func (p *publisherMock) publish(ctx context.Context, topic string, msg *pubsub.Message) error {
+	p.messages = append(p.messages, published{topic: topic, msg: msg})
+	if topic == p.failTopic {
+		return errors.New("topic not found")
+	}
+	return nil
+}
+func (p *publisherMock) Close() error { return nil }
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		topic    string
+		expected []string
+	}{
+		{name: "default topic", expected: []string{"shop_items", "shop_items_bidding"}},
+		{name: "template", topic: "feeddo-{topic}", expected: []string{"feeddo-shop_items", "feeddo-shop_items_bidding"}},
+		{name: "single topic", topic: "items", expected: []string{"items", "items"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &publisherMock{}
+			s := newSink(p, tt.topic)
+			res := s.Send(context.Background(), itemTest{payload: []byte(`{"id":"1"}`)})
+			require.NoError(t, res.Err)
+			assert.Equal(t, SinkName, res.Cluster)
+			assert.Equal(t, 2, len(res.Topics))
+			topics := []string{}
+			for _, m := range p.messages {
+				topics = append(topics, m.topic)
+			}
+			assert.Equal(t, tt.expected, topics)
+			msg := p.messages[0].msg
+			assert.Equal(t, []byte(`{"id":"1"}`), msg.Data)
+			assert.Equal(t, map[string]string{AttributeFeed: "http://test.org", AttributeID: "1", "h": "v"}, msg.Attributes)
+		})
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	p := &publisherMock{failTopic: "shop_items_bidding"}
+	s := newSink(p, "")
+	res := s.Send(context.Background(), itemTest{})
+	require.Error(t, res.Err)
+	assert.Equal(t, "Failed to publish message for topic shop_items_bidding because of topic not found", res.Err.Error())
+	assert.True(t, res.Tombstone)
+	assert.Equal(t, "true", p.messages[0].msg.Attributes[AttributeTombstone])
+	assert.NoError(t, res.Topics[0].Err)
+	assert.Error(t, res.Topics[1].Err)
+
+	p = &publisherMock{}
+	s = newSink(p, "")
+	res = s.Send(context.Background(), itemTest{err: errors.New("test error")})
+	require.Error(t, res.Err)
+	assert.Equal(t, "Failed to marshal json: test error", res.Err.Error())
+	assert.Equal(t, 2, len(res.Topics))
+	assert.Equal(t, 0, len(p.messages))
+
+	_, err := New(context.Background(), "", "")
+	require.Error(t, err)
+	assert.Equal(t, "Pub/Sub project was not provided", err.Error())
+}
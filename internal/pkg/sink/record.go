@@ -0,0 +1,41 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Record is json representation of item with its metadata. It is used by sinks which write items as json,
+// e.g. lines of NDJSON file, so items could be read back and delivered again.
+type Record struct {
+	Context   string            `json:"context"`
+	ID        string            `json:"id"`
+	Topics    []string          `json:"topics"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Tombstone bool              `json:"tombstone,omitempty"`
+	Payload   json.RawMessage   `json:"payload"`
+}
+
+// NewRecord marshals item. Payload which is not valid json is reported as error.
+func NewRecord(item Item) (Record, error) {
+	payload, err := item.Marshal()
+	if err != nil {
+		return Record{}, fmt.Errorf("Failed to marshal json: %w", err)
+	}
+	r := Record{Context: item.GetContext(), ID: item.GetID(), Topics: item.Topics(), Tombstone: payload == nil}
+	if payload != nil {
+		if !json.Valid(payload) {
+			return Record{}, fmt.Errorf("Payload of item %s is not valid json", item.GetID())
+		}
+		r.Payload = payload
+	}
+	if h, ok := item.(Headerer); ok {
+		for _, header := range h.Headers() {
+			if r.Headers == nil {
+				r.Headers = map[string]string{}
+			}
+			r.Headers[header.Key] = string(header.Value)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,49 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordItem struct {
+	payload []byte
+	err     error
+}
+
+func (i recordItem) GetContext() string       { return "http://test.org" }
+func (i recordItem) GetID() string            { return "1" }
+func (i recordItem) Marshal() ([]byte, error) { return i.payload, i.err }
+func (i recordItem) Topics() []string         { return []string{"items"} }
+func (i recordItem) Headers() []Header        { return []Header{{Key: "h", Value: []byte("v")}} }
+
+func TestNewRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     recordItem
+		expected Record
+		err      string
+	}{
+		{name: "item", item: recordItem{payload: []byte(`{"id":"1"}`)},
+			expected: Record{Context: "http://test.org", ID: "1", Topics: []string{"items"}, Headers: map[string]string{"h": "v"}, Payload: json.RawMessage(`{"id":"1"}`)}},
+		{name: "tombstone", item: recordItem{},
+			expected: Record{Context: "http://test.org", ID: "1", Topics: []string{"items"}, Headers: map[string]string{"h": "v"}, Tombstone: true}},
+		{name: "marshal error", item: recordItem{err: errors.New("test error")}, err: "Failed to marshal json: test error"},
+		{name: "invalid json", item: recordItem{payload: []byte(`{`)}, err: "Payload of item 1 is not valid json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRecord(tt.item)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, r)
+		})
+	}
+}
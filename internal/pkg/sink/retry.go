@@ -0,0 +1,281 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// retryKey identifies item in retry queue
+type retryKey struct {
+	context string
+	id      string
+}
+
+// retryEntry is item delivered by retry queue with the last result of its delivery
+type retryEntry struct {
+	item    Item
+	retries int
+	last    Result
+}
+
+// RetryQueue delivers items which failed to be delivered by primary sink again. Delay before each next attempt
+// is doubled starting from backoff up to max backoff. Items which were not delivered after all attempts,
+// items which did not fit into queue and items waiting for retry when delivery is stopped are reported with their last error.
+// Items waiting for retry when delivery is stopped are appended into file (when it is set) as records, so they could be replayed later.
+type RetryQueue struct {
+	max        int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	size       int
+	file       string
+
+	mu sync.Mutex
+	// items delivered by sink which are waiting for result
+	sent map[retryKey][]*retryEntry
+	// items waiting for the next attempt
+	waiting map[*retryEntry]struct{}
+	stopped bool
+	// number of items per context which were not delivered
+	failed map[string]int
+	// idle is signalled when no item is sent or waiting
+	idle chan struct{}
+}
+
+// NewRetryQueue creates queue retrying delivery up to max times. Size limits number of items waiting for retry, zero means no limit.
+func NewRetryQueue(max int, backoff, maxBackoff time.Duration, size int, file string) *RetryQueue {
+	return &RetryQueue{
+		max:        max,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		size:       size,
+		file:       file,
+		sent:       map[retryKey][]*retryEntry{},
+		waiting:    map[*retryEntry]struct{}{},
+		failed:     map[string]int{},
+		idle:       make(chan struct{}, 1),
+	}
+}
+
+// Failed returns number of not delivered items per item context
+func (q *RetryQueue) Failed() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	failed := make(map[string]int, len(q.failed))
+	for k, v := range q.failed {
+		failed[k] = v
+	}
+	return failed
+}
+
+// Run delivers items by sink like Run does and retries failed items till context is done.
+// Only final result is reported for every item. When items channel is closed, channels are closed after items waiting for retry are delivered.
+func (q *RetryQueue) Run(ctx context.Context, s Sink, workers int, items <-chan Item) (<-chan Result, <-chan struct{}) {
+	in := make(chan Item, 1)
+	chanRetry := make(chan *retryEntry)
+	stop := make(chan struct{})
+	innerRes, innerExited := Run(ctx, s, workers, in)
+	go func() {
+		defer close(in)
+		q.forward(ctx, items, chanRetry, in, innerExited)
+		close(stop)
+	}()
+	chanRes := make(chan Result, 1)
+	chanExited := make(chan struct{})
+	go func() {
+		defer close(chanExited)
+		defer close(chanRes)
+		for r := range innerRes {
+			if r.ItemContext == "" || r.Secondary {
+				chanRes <- r
+				continue
+			}
+			e, retry := q.settle(r)
+			if retry {
+				time.AfterFunc(q.delay(e.retries), func() {
+					select {
+					case chanRetry <- e:
+					case <-stop:
+					}
+				})
+				continue
+			}
+			if e != nil {
+				r.Redeliveries = e.retries
+			}
+			chanRes <- r
+		}
+		for _, r := range q.giveUp() {
+			chanRes <- r
+		}
+	}()
+	return chanRes, chanExited
+}
+
+// forward sends new items and items due for retry into sink till items channel is closed and queue is idle or context is done
+func (q *RetryQueue) forward(ctx context.Context, items <-chan Item, chanRetry <-chan *retryEntry, in chan<- Item, innerExited <-chan struct{}) {
+	for {
+		var e *retryEntry
+		select {
+		case item, ok := <-items:
+			if !ok {
+				items = nil
+				if q.pending() == 0 {
+					return
+				}
+				continue
+			}
+			e = &retryEntry{item: item}
+		case e = <-chanRetry:
+		case <-q.idle:
+			if items == nil && q.pending() == 0 {
+				return
+			}
+		case <-ctx.Done():
+			q.stop()
+			// items which are already buffered are still delivered
+			for {
+				select {
+				case item, ok := <-items:
+					if !ok {
+						return
+					}
+					q.track(&retryEntry{item: item})
+					select {
+					case in <- item:
+					case <-innerExited:
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+		if e == nil {
+			continue
+		}
+		q.track(e)
+		select {
+		case in <- e.item:
+		case <-innerExited:
+			return
+		}
+	}
+}
+
+// track registers item sent into sink, item due for retry is removed from waiting items
+func (q *RetryQueue) track(e *retryEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.waiting, e)
+	k := retryKey{context: e.item.GetContext(), id: e.item.GetID()}
+	q.sent[k] = append(q.sent[k], e)
+}
+
+// settle removes item of result from sent items. True is returned when item is put into queue for the next attempt.
+func (q *RetryQueue) settle(r Result) (*retryEntry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	k := retryKey{context: r.ItemContext, id: r.ItemID}
+	entries := q.sent[k]
+	if len(entries) == 0 {
+		return nil, false
+	}
+	e := entries[0]
+	if len(entries) == 1 {
+		delete(q.sent, k)
+	} else {
+		q.sent[k] = entries[1:]
+	}
+	// dead lettered item is already stored, so it is not delivered again
+	if r.Err != nil && !r.DeadLettered && !q.stopped && e.retries < q.max && (q.size == 0 || len(q.waiting) < q.size) {
+		e.retries++
+		e.last = r
+		q.waiting[e] = struct{}{}
+		return e, true
+	}
+	if r.Err != nil {
+		q.failed[r.ItemContext]++
+	}
+	if len(q.sent) == 0 && len(q.waiting) == 0 {
+		select {
+		case q.idle <- struct{}{}:
+		default:
+		}
+	}
+	return e, false
+}
+
+// pending returns number of items which are sent or waiting for retry
+func (q *RetryQueue) pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.sent) + len(q.waiting)
+}
+
+// stop prevents next retries
+func (q *RetryQueue) stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.stopped = true
+}
+
+// giveUp returns results of items left waiting for retry and stores items into file
+func (q *RetryQueue) giveUp() []Result {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	results := make([]Result, 0, len(q.waiting))
+	items := make([]Item, 0, len(q.waiting))
+	for e := range q.waiting {
+		r := e.last
+		r.Redeliveries = e.retries
+		results = append(results, r)
+		items = append(items, e.item)
+		q.failed[r.ItemContext]++
+	}
+	q.waiting = map[*retryEntry]struct{}{}
+	if q.file != "" && len(items) != 0 {
+		if err := appendRecords(q.file, items); err != nil {
+			results = append(results, Result{Err: err})
+		}
+	}
+	return results
+}
+
+// delay returns delay before provided attempt (starting from 1)
+func (q *RetryQueue) delay(attempt int) time.Duration {
+	d := q.backoff
+	for i := 1; i < attempt && d < q.maxBackoff; i++ {
+		d *= 2
+	}
+	if q.maxBackoff > 0 && d > q.maxBackoff {
+		d = q.maxBackoff
+	}
+	return d
+}
+
+// appendRecords appends items into file as json lines in format of file sink
+func appendRecords(file string, items []Item) error {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to open retry queue file '%s' because of %w", file, err)
+	}
+	enc := json.NewEncoder(f)
+	for _, item := range items {
+		r, err := NewRecord(item)
+		if err == nil {
+			err = enc.Encode(r)
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("Unable to save item %s into retry queue file '%s' because of %w", item.GetID(), file, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Unable to close retry queue file '%s' because of %w", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakySink fails items till they were sent given number of times, items with id "fail" are always failed
+type flakySink struct {
+	sinkMock
+	failures map[string]int
+}
+
+func (s *flakySink) Send(ctx context.Context, item Item) Result {
+	res := s.sinkMock.Send(ctx, item)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := 0
+	for _, id := range s.sent {
+		if id == item.GetID() {
+			sent++
+		}
+	}
+	if sent <= s.failures[item.GetID()] {
+		res.Err = errors.New("test error")
+	}
+	return res
+}
+
+// collect reads results till delivery is finished
+func collect(chanRes <-chan Result, chanExited <-chan struct{}) map[string]Result {
+	results := map[string]Result{}
+	for {
+		select {
+		case r, ok := <-chanRes:
+			if ok {
+				results[r.ItemID] = r
+			}
+		case <-chanExited:
+			return results
+		}
+	}
+}
+
+func TestRetryQueueRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		backoff      time.Duration
+		size         int
+		redeliveries map[string]int
+		failed       []string
+	}{
+		{"retried", time.Millisecond, 0, map[string]int{"1": 2, "2": 0, "fail": 3}, []string{"fail"}},
+		{"queue full", 50 * time.Millisecond, 1, map[string]int{"1": 2, "2": 0, "fail": 0}, []string{"fail"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewRetryQueue(3, tt.backoff, 2*tt.backoff, tt.size, "")
+			s := &flakySink{failures: map[string]int{"1": 2}}
+			items := make(chan Item, 3)
+			// "fail" is delivered when "1" is already waiting for retry
+			items <- itemTest{id: "1"}
+			items <- itemTest{id: "2"}
+			items <- itemTest{id: "fail"}
+			close(items)
+			results := collect(q.Run(context.Background(), s, 1, items))
+			require.Len(t, results, 3)
+			for id, n := range tt.redeliveries {
+				assert.Equal(t, n, results[id].Redeliveries, id)
+			}
+			for _, id := range []string{"1", "2"} {
+				assert.NoError(t, results[id].Err, id)
+			}
+			for _, id := range tt.failed {
+				assert.Error(t, results[id].Err, id)
+			}
+			assert.Equal(t, map[string]int{"testContext": len(tt.failed)}, q.Failed())
+		})
+	}
+}
+
+func TestRetryQueueDelay(t *testing.T) {
+	q := NewRetryQueue(5, time.Second, 5*time.Second, 0, "")
+	delays := []time.Duration{}
+	for attempt := 1; attempt <= 5; attempt++ {
+		delays = append(delays, q.delay(attempt))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+}
+
+func TestRetryQueueCancelled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retry")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "retry.ndjson")
+	q := NewRetryQueue(3, time.Hour, time.Hour, 0, file)
+	ctx, cancel := context.WithCancel(context.Background())
+	items := make(chan Item, 1)
+	defer close(items)
+	items <- itemTest{id: "1"}
+	chanRes, chanExited := q.Run(ctx, &flakySink{failures: map[string]int{"1": 1}}, 1, items)
+	// item waits for retry when delivery is stopped
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	results := collect(chanRes, chanExited)
+	require.Contains(t, results, "1")
+	assert.Error(t, results["1"].Err)
+	assert.Equal(t, 1, results["1"].Redeliveries)
+	assert.Equal(t, map[string]int{"testContext": 1}, q.Failed())
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `{"context":"testContext","id":"1","topics":["items"],"payload":1}`+"\n", string(data))
+}
@@ -0,0 +1,197 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Item defines interface for entities delivered by sinks.
+// When Marshal returns nil without error item is deleted (e.g. tombstone is produced into kafka).
+type Item interface {
+	GetContext() string
+	GetID() string
+	Marshal() ([]byte, error)
+	// Topics where item is delivered. Sinks without topics could use them e.g. as tables or directories
+	Topics() []string
+}
+
+// Header is additional metadata of item, e.g. kafka message header
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Headerer is implemented by items which provide additional headers
+type Headerer interface {
+	Headers() []Header
+}
+
+// Result indicates item processing status
+// on success - err will be nil
+// on error - err will contain corresponding error
+type Result struct {
+	ItemContext string
+	ItemID      string
+	// Cluster is name of destination where item was delivered, e.g. kafka cluster
+	Cluster string
+	Err     error
+	// DeadLettered is set when not delivered item was stored into dead letter
+	DeadLettered bool
+	// Tombstone is set when item was delivered as deletion (e.g. message with null value)
+	Tombstone bool
+	// Topics contains result of delivery for every topic of item
+	Topics []TopicResult
+	// Sink is name of sink which reported result when items are delivered by several sinks (see Fanout)
+	Sink string
+	// Secondary is set for results of sinks other than primary one
+	Secondary bool
+	// Redeliveries is number of times item was delivered again by retry queue
+	Redeliveries int
+}
+
+// TopicResult is result of delivery of item into single topic
+type TopicResult struct {
+	Topic string
+	// Partition, Offset and Timestamp of delivered message when sink reports them.
+	// Negative values mean that position is not known.
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+	Err       error
+	// Latency from the first attempt to deliver item till it was confirmed, retries included
+	Latency time.Duration
+}
+
+// Sink delivers items to some output
+type Sink interface {
+	// Send delivers item and waits for result. Could be called concurrently.
+	Send(ctx context.Context, item Item) Result
+	Close() error
+}
+
+// Streamer is implemented by sinks which deliver items asynchronously without waiting for previous ones,
+// e.g. kafka producer. Result is reported for every item. Both channels are closed when items channel
+// is closed and all items are reported.
+type Streamer interface {
+	Stream(items <-chan Item) (<-chan Result, <-chan struct{})
+}
+
+// Factory creates sink from its configuration. Configuration is typed, usually Config struct of package implementing sink,
+// and factory returns ConfigTypeError for configuration of other type. Context only cancels connecting to output.
+type Factory func(ctx context.Context, config interface{}) (Sink, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes sink available by name. It is usually called from init of package implementing sink.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("Sink '%s' is already registered", name))
+	}
+	factories[name] = f
+}
+
+// Names returns sorted names of registered sinks
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return namesLocked()
+}
+
+// Validate checks that sink with provided name is registered
+func Validate(name string) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	if _, ok := factories[name]; !ok {
+		return fmt.Errorf("Sink '%s' is not supported, use one of %s", name, strings.Join(namesLocked(), ", "))
+	}
+	return nil
+}
+
+func namesLocked() []string {
+	names := make([]string, 0, len(factories))
+	for n := range factories {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates registered sink from its configuration
+func New(ctx context.Context, name string, config interface{}) (Sink, error) {
+	mu.RLock()
+	f, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Sink '%s' is not registered", name)
+	}
+	return f(ctx, config)
+}
+
+// ConfigTypeError is returned by factory of sink when its configuration is not of expected type
+func ConfigTypeError(name string, expected, config interface{}) error {
+	return fmt.Errorf("Configuration of sink '%s' should be %T instead of %T", name, expected, config)
+}
+
+// Run delivers items from channel by sink until channel is closed or context is done. Streamer sinks stream items themselves,
+// items of Batcher sinks are grouped into batches and other sinks are called by provided number of workers.
+// Both returned channels are closed when all items are reported.
+func Run(ctx context.Context, s Sink, workers int, items <-chan Item) (<-chan Result, <-chan struct{}) {
+	if f, ok := s.(*Fanout); ok {
+		return f.run(ctx, workers, items)
+	}
+	if b, ok := s.(Batcher); ok {
+		return runBatches(ctx, b, items)
+	}
+	if st, ok := s.(Streamer); ok {
+		return st.Stream(items)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	chanRes := make(chan Result, 1)
+	chanExited := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case item, ok := <-items:
+					if !ok {
+						return
+					}
+					chanRes <- s.Send(ctx, item)
+				case <-ctx.Done():
+					// items which are already buffered are still delivered
+					for {
+						select {
+						case item, ok := <-items:
+							if !ok {
+								return
+							}
+							chanRes <- s.Send(ctx, item)
+						default:
+							return
+						}
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(chanRes)
+		close(chanExited)
+	}()
+	return chanRes, chanExited
+}
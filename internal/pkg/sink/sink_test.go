@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type itemTest struct{ id string }
+
+func (i itemTest) GetContext() string       { return "testContext" }
+func (i itemTest) GetID() string            { return i.id }
+func (i itemTest) Marshal() ([]byte, error) { return []byte(i.id), nil }
+func (i itemTest) Topics() []string         { return []string{"items"} }
+
+// sinkMock fails items with id "fail"
+type sinkMock struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (s *sinkMock) Send(ctx context.Context, item Item) Result {
+	s.mu.Lock()
+	s.sent = append(s.sent, item.GetID())
+	s.mu.Unlock()
+	res := Result{ItemContext: item.GetContext(), ItemID: item.GetID()}
+	if item.GetID() == "fail" {
+		res.Err = errors.New("test error")
+	}
+	return res
+}
+func (s *sinkMock) Close() error { return nil }
+
+// streamerMock reports all items as delivered by itself
+type streamerMock struct{ sinkMock }
+
+func (s *streamerMock) Stream(items <-chan Item) (<-chan Result, <-chan struct{}) {
+	chanRes := make(chan Result)
+	chanExited := make(chan struct{})
+	go func() {
+		defer close(chanExited)
+		defer close(chanRes)
+		for item := range items {
+			chanRes <- Result{ItemContext: item.GetContext(), ItemID: item.GetID(), Cluster: "stream"}
+		}
+	}()
+	return chanRes, chanExited
+}
+
+func TestRegistry(t *testing.T) {
+	Register("test-mock", func(ctx context.Context, config interface{}) (Sink, error) {
+		if _, ok := config.(string); !ok {
+			return nil, ConfigTypeError("test-mock", "", config)
+		}
+		return &sinkMock{}, nil
+	})
+	Register("test-error", func(ctx context.Context, config interface{}) (Sink, error) { return nil, errors.New("test error") })
+	assert.Panics(t, func() { Register("test-mock", nil) })
+	assert.Contains(t, Names(), "test-mock")
+	assert.True(t, sort.StringsAreSorted(Names()))
+
+	require.NoError(t, Validate("test-mock"))
+	err := Validate("abc")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Sink 'abc' is not supported, use one of ")
+
+	s, err := New(context.Background(), "test-mock", "config")
+	require.NoError(t, err)
+	assert.IsType(t, &sinkMock{}, s)
+	_, err = New(context.Background(), "test-mock", 1)
+	assert.EqualError(t, err, "Configuration of sink 'test-mock' should be string instead of int")
+	_, err = New(context.Background(), "test-error", nil)
+	require.Error(t, err)
+	_, err = New(context.Background(), "abc", nil)
+	require.Error(t, err)
+	assert.Equal(t, "Sink 'abc' is not registered", err.Error())
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		sink    Sink
+		cluster string
+	}{
+		{name: "send by workers", sink: &sinkMock{}},
+		{name: "streamer", sink: &streamerMock{}, cluster: "stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make(chan Item, 3)
+			items <- itemTest{id: "1"}
+			items <- itemTest{id: "fail"}
+			items <- itemTest{id: "2"}
+			close(items)
+			chanRes, chanExited := Run(context.Background(), tt.sink, 2, items)
+			ids := []string{}
+			failed := 0
+			for res := range chanRes {
+				ids = append(ids, res.ItemID)
+				assert.Equal(t, tt.cluster, res.Cluster)
+				if res.Err != nil {
+					failed++
+				}
+			}
+			<-chanExited
+			sort.Strings(ids)
+			assert.Equal(t, []string{"1", "2", "fail"}, ids)
+			if tt.cluster == "" {
+				assert.Equal(t, 1, failed)
+			}
+		})
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	items := make(chan Item, 2)
+	items <- itemTest{id: "1"}
+	items <- itemTest{id: "2"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// channel is not closed, workers stop because of context but buffered items are delivered
+	chanRes, chanExited := Run(ctx, &sinkMock{}, 1, items)
+	ids := []string{}
+	for res := range chanRes {
+		ids = append(ids, res.ItemID)
+	}
+	<-chanExited
+	assert.Equal(t, []string{"1", "2"}, ids)
+}
@@ -0,0 +1,241 @@
+package webhooksink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+)
+
+const (
+	// SinkName is name under which webhook sink is registered
+	SinkName = "webhook"
+	// DefaultBatchSize is number of items posted in single request by default
+	DefaultBatchSize = 100
+	// DefaultConcurrency is number of requests in flight by default
+	DefaultConcurrency = 4
+	// DefaultRetries is number of retries of failed request by default
+	DefaultRetries = 3
+
+	// flushInterval not full batch is posted after this interval
+	flushInterval = time.Second
+	// timeout of single request
+	requestTimeout = 30 * time.Second
+	// timeout of posting batch including retries
+	drainTimeout = time.Minute
+	// retryBackoff is doubled for every retry up to retryMaxBackoff
+	retryBackoff    = 500 * time.Millisecond
+	retryMaxBackoff = 10 * time.Second
+	// maxErrorBody is number of bytes of response body included into error
+	maxErrorBody = 512
+)
+
+// Config is configuration of sink created by name
+type Config struct {
+	// URL of endpoint
+	URL string
+	// Headers are additional request headers, e.g. authorization
+	Headers http.Header
+	// BatchSize is max number of items posted in single request
+	BatchSize int
+	// Concurrency is max number of requests in flight
+	Concurrency int
+	// Retries is number of retries of request which failed with transient error
+	Retries int
+}
+
+func init() {
+	sink.Register(SinkName, func(ctx context.Context, config interface{}) (sink.Sink, error) {
+		c, ok := config.(Config)
+		if !ok {
+			return nil, sink.ConfigTypeError(SinkName, Config{}, config)
+		}
+		s, err := New(c.URL, c.Headers, c.BatchSize, c.Concurrency, c.Retries)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+// ParseHeaders parses headers in format "Name: value"
+func ParseHeaders(values []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, v := range values {
+		kv := strings.SplitN(v, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Header '%s' should have format '<name>: <value>'", v)
+		}
+		headers.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return headers, nil
+}
+
+// Sink posts batches of items as json array to http endpoint.
+// Requests which failed because of network error, status 429 or 5xx are retried with exponential backoff.
+type Sink struct {
+	client      *http.Client
+	url         string
+	headers     http.Header
+	batchSize   int
+	concurrency int
+	retries     int
+	backoff     time.Duration
+}
+
+// New creates sink posting to url
+func New(url string, headers http.Header, batchSize, concurrency, retries int) (*Sink, error) {
+	if url == "" {
+		return nil, fmt.Errorf("Webhook url was not provided")
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("Webhook url '%s' should use http or https scheme", url)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return &Sink{
+		client:      &http.Client{Timeout: requestTimeout},
+		url:         url,
+		headers:     headers,
+		batchSize:   batchSize,
+		concurrency: concurrency,
+		retries:     retries,
+		backoff:     retryBackoff,
+	}, nil
+}
+
+// Send posts single item
+func (s *Sink) Send(ctx context.Context, item sink.Item) sink.Result {
+	return s.post(ctx, []sink.Item{item})[0]
+}
+
+// Batching implements sink.Batcher: batches are posted by limited number of concurrent requests
+func (s *Sink) Batching() sink.Batching {
+	return sink.Batching{Size: s.batchSize, FlushInterval: flushInterval, Concurrency: s.concurrency}
+}
+
+// SendBatch posts items in single request. Posting is limited by drain timeout only, so batch collected
+// before sink was stopped is still posted.
+func (s *Sink) SendBatch(ctx context.Context, items []sink.Item) []sink.Result {
+	ctx, cancel := context.WithTimeout(ctx, drainTimeout)
+	defer cancel()
+	return s.post(ctx, items)
+}
+
+// post posts items in single request and returns result for every item
+func (s *Sink) post(ctx context.Context, items []sink.Item) []sink.Result {
+	started := time.Now()
+	results := make([]sink.Result, len(items))
+	records := make([]sink.Record, 0, len(items))
+	for i, item := range items {
+		results[i] = sink.Result{ItemContext: item.GetContext(), ItemID: item.GetID(), Cluster: SinkName}
+		r, err := sink.NewRecord(item)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Tombstone = r.Tombstone
+		records = append(records, r)
+	}
+	var err error
+	if len(records) > 0 {
+		var body []byte
+		body, err = json.Marshal(records)
+		if err == nil {
+			err = s.postWithRetries(ctx, body)
+		}
+		if err != nil {
+			err = fmt.Errorf("Failed to post items to webhook because of %w", err)
+		}
+	}
+	for i, item := range items {
+		if results[i].Err == nil {
+			results[i].Err = err
+		}
+		for _, topic := range item.Topics() {
+			results[i].Topics = append(results[i].Topics, sink.TopicResult{Topic: topic, Partition: -1, Offset: -1, Err: results[i].Err, Latency: time.Since(started)})
+		}
+	}
+	return results
+}
+
+func (s *Sink) postWithRetries(ctx context.Context, body []byte) error {
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		wait, err := s.postOnce(ctx, body)
+		if err == nil || wait < 0 || attempt >= s.retries {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff
+		}
+		backoff *= 2
+		if backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// postOnce sends single request. Returned wait is negative when error is not transient,
+// zero when request should be retried after backoff or duration requested by server in Retry-After header.
+func (s *Sink) postOnce(ctx context.Context, body []byte) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req = req.WithContext(ctx)
+	for name, values := range s.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// connection could be reused only when body is read
+		io.Copy(ioutil.Discard, resp.Body)
+		return 0, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	err = fmt.Errorf("Webhook responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return -1, err
+	}
+	if seconds, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && seconds > 0 {
+		wait := time.Duration(seconds) * time.Second
+		if wait > retryMaxBackoff {
+			wait = retryMaxBackoff
+		}
+		return wait, err
+	}
+	return 0, err
+}
+
+// Close does nothing, batches are posted till items channel is closed
+func (s *Sink) Close() error {
+	return nil
+}
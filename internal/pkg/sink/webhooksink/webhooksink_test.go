@@ -0,0 +1,181 @@
+package webhooksink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type itemTest struct {
+	id      string
+	payload []byte
+	err     error
+}
+
+func (i itemTest) GetContext() string       { return "http://test.org" }
+func (i itemTest) GetID() string            { return i.id }
+func (i itemTest) Marshal() ([]byte, error) { return i.payload, i.err }
+func (i itemTest) Topics() []string         { return []string{"shop_items"} }
+
+// endpoint records posted batches and responds with statuses in order, the last status is repeated
+type endpoint struct {
+	mu       sync.Mutex
+	statuses []int
+	requests int
+	batches  [][]sink.Record
+	headers  []http.Header
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := http.StatusOK
+	if len(e.statuses) > 0 {
+		status = e.statuses[0]
+		if len(e.statuses) > 1 {
+			e.statuses = e.statuses[1:]
+		}
+	}
+	e.requests++
+	e.headers = append(e.headers, r.Header)
+	if status != http.StatusOK {
+		http.Error(w, "test error", status)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	batch := []sink.Record{}
+	json.Unmarshal(body, &batch)
+	e.batches = append(e.batches, batch)
+}
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected http.Header
+		err      string
+	}{
+		{name: "empty", expected: http.Header{}},
+		{name: "headers", values: []string{"Authorization: Bearer abc:def", "x-api-key:123"}, expected: http.Header{"Authorization": {"Bearer abc:def"}, "X-Api-Key": {"123"}}},
+		{name: "without value", values: []string{"Authorization"}, err: "Header 'Authorization' should have format '<name>: <value>'"},
+		{name: "without name", values: []string{": abc"}, err: "Header ': abc' should have format '<name>: <value>'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseHeaders(tt.values)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, h)
+		})
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		err  string
+	}{
+		{name: "empty url", err: "Webhook url was not provided"},
+		{name: "wrong scheme", url: "ftp://test.org", err: "Webhook url 'ftp://test.org' should use http or https scheme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.url, nil, 0, 0, 0)
+			require.Error(t, err)
+			assert.Equal(t, tt.err, err.Error())
+		})
+	}
+	s, err := New("http://test.org", nil, 0, 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultBatchSize, s.batchSize)
+	assert.Equal(t, DefaultConcurrency, s.concurrency)
+	assert.Equal(t, 0, s.retries)
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		requests int
+		err      string
+	}{
+		{name: "success", requests: 1},
+		{name: "retried server error", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, retries: 3, requests: 3},
+		{name: "retries exhausted", statuses: []int{http.StatusBadGateway}, retries: 2, requests: 3,
+			err: "Failed to post items to webhook because of Webhook responded with status 502: test error"},
+		{name: "client error is not retried", statuses: []int{http.StatusUnauthorized}, retries: 3, requests: 1,
+			err: "Failed to post items to webhook because of Webhook responded with status 401: test error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &endpoint{statuses: tt.statuses}
+			ts := httptest.NewServer(e)
+			defer ts.Close()
+			s, err := New(ts.URL, http.Header{"Authorization": {"Bearer abc"}}, 0, 0, tt.retries)
+			require.NoError(t, err)
+			s.backoff = time.Millisecond
+			res := s.Send(context.Background(), itemTest{id: "1", payload: []byte(`{"id":"1"}`)})
+			assert.Equal(t, tt.requests, e.requests)
+			assert.Equal(t, 1, len(res.Topics))
+			if tt.err != "" {
+				require.Error(t, res.Err)
+				assert.Equal(t, tt.err, res.Err.Error())
+				return
+			}
+			require.NoError(t, res.Err)
+			assert.Equal(t, SinkName, res.Cluster)
+			assert.Equal(t, "Bearer abc", e.headers[0].Get("Authorization"))
+			assert.Equal(t, "application/json", e.headers[0].Get("Content-Type"))
+			assert.Equal(t, [][]sink.Record{{{Context: "http://test.org", ID: "1", Topics: []string{"shop_items"}, Payload: json.RawMessage(`{"id":"1"}`)}}}, e.batches)
+		})
+	}
+}
+
+func TestRunBatches(t *testing.T) {
+	e := &endpoint{}
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := New(ts.URL, nil, 2, 2, 0)
+	require.NoError(t, err)
+	items := make(chan sink.Item, 10)
+	chanRes, chanExited := sink.Run(ctx, s, 1, items)
+	for i := 0; i < 5; i++ {
+		items <- itemTest{id: fmt.Sprint(i), payload: []byte(`{}`)}
+	}
+	items <- itemTest{id: "5"}
+	items <- itemTest{id: "6", err: errors.New("test error")}
+	cancel()
+	results := map[string]sink.Result{}
+	for res := range chanRes {
+		results[res.ItemID] = res
+	}
+	<-chanExited
+	assert.Equal(t, 7, len(results))
+	assert.True(t, results["5"].Tombstone)
+	require.Error(t, results["6"].Err)
+	assert.Equal(t, "Failed to marshal json: test error", results["6"].Err.Error())
+	posted := 0
+	for _, b := range e.batches {
+		assert.True(t, len(b) <= 2)
+		posted += len(b)
+	}
+	assert.Equal(t, 6, posted)
+}
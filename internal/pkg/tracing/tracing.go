@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// ServiceName is name of service in exported spans
+	ServiceName = "feeddo"
+	// instrumentationName identifies tracer of the app
+	instrumentationName = "github.com/grubastik/feeddo"
+)
+
+// propagator puts trace context into message headers by W3C Trace Context (traceparent header)
+var propagator = propagation.TraceContext{}
+
+// Start configures global tracer provider which exports spans by OTLP (gRPC) to collector at endpoint (host:port).
+// Ratio of traces is sampled, spans of sampled parents are always sampled.
+// Returned function exports remaining spans and stops exporter.
+func Start(endpoint string, insecure bool, ratio float64) (func(context.Context) error, error) {
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("Trace sample ratio should be between 0 and 1")
+	}
+	opts := []otlp.ExporterOption{otlp.WithAddress(endpoint)}
+	if insecure {
+		opts = append(opts, otlp.WithInsecure())
+	} else {
+		opts = append(opts, otlp.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")))
+	}
+	exporter, err := otlp.NewExporter(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create OTLP exporter because of %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))}),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(ServiceName))),
+		sdktrace.WithBatcher(exporter),
+	)
+	otel.SetTracerProvider(tp)
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if errE := exporter.Shutdown(ctx); err == nil {
+			err = errE
+		}
+		return err
+	}, nil
+}
+
+// Tracer returns tracer of the app. Spans are not recorded unless Start was called.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// End records error of operation and ends span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Traced is implemented by items which are processed within trace
+type Traced interface {
+	TraceContext() context.Context
+}
+
+// ItemContext returns context of trace of item. Background context is returned for items which are not traced.
+func ItemContext(item interface{}) context.Context {
+	if t, ok := item.(Traced); ok && t.TraceContext() != nil {
+		return t.TraceContext()
+	}
+	return context.Background()
+}
+
+// Inject returns copy of headers with trace context of ctx, so consumers could continue trace.
+// Headers are returned unchanged when ctx does not contain span, e.g. when tracing is disabled.
+func Inject(ctx context.Context, headers []sink.Header) []sink.Header {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return headers
+	}
+	carrier := headerCarrier{headers: append([]sink.Header{}, headers...)}
+	propagator.Inject(ctx, &carrier)
+	return carrier.headers
+}
+
+// Extract returns context with remote span context from headers
+func Extract(ctx context.Context, headers []sink.Header) context.Context {
+	return propagator.Extract(ctx, &headerCarrier{headers: headers})
+}
+
+// headerCarrier adapts message headers to propagation.TextMapCarrier
+type headerCarrier struct {
+	headers []sink.Header
+}
+
+func (c *headerCarrier) Get(key string) string {
+	for _, h := range c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c *headerCarrier) Set(key, value string) {
+	for i, h := range c.headers {
+		if h.Key == key {
+			c.headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.headers = append(c.headers, sink.Header{Key: key, Value: []byte(value)})
+}
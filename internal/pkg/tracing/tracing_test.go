@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/sink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/oteltest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedItem struct {
+	ctx context.Context
+}
+
+func (i tracedItem) TraceContext() context.Context { return i.ctx }
+
+func TestStart(t *testing.T) {
+	_, err := Start("localhost:4317", true, 2)
+	require.Error(t, err)
+	assert.Equal(t, "Trace sample ratio should be between 0 and 1", err.Error())
+}
+
+func TestInjectExtract(t *testing.T) {
+	headers := []sink.Header{{Key: "h", Value: []byte("v")}}
+	// without span headers are not changed
+	assert.Equal(t, headers, Inject(context.Background(), headers))
+
+	ctx, span := oteltest.DefaultTracer().Start(context.Background(), "test")
+	injected := Inject(ctx, headers)
+	require.Equal(t, 2, len(injected))
+	assert.Equal(t, "traceparent", injected[1].Key)
+	// original headers are not modified
+	assert.Equal(t, 1, len(headers))
+
+	remote := trace.RemoteSpanContextFromContext(Extract(context.Background(), injected))
+	assert.Equal(t, span.SpanContext().TraceID, remote.TraceID)
+	assert.Equal(t, span.SpanContext().SpanID, remote.SpanID)
+}
+
+func TestItemContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "key", "value")
+	assert.Equal(t, ctx, ItemContext(tracedItem{ctx: ctx}))
+	assert.Equal(t, context.Background(), ItemContext(tracedItem{}))
+	assert.Equal(t, context.Background(), ItemContext("not traced"))
+}
+
+func TestEnd(t *testing.T) {
+	sr := new(oteltest.StandardSpanRecorder)
+	otel.SetTracerProvider(oteltest.NewTracerProvider(oteltest.WithSpanRecorder(sr)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	_, span := Tracer().Start(context.Background(), "success")
+	End(span, nil)
+	_, span = Tracer().Start(context.Background(), "failure")
+	End(span, errors.New("test error"))
+	spans := sr.Completed()
+	require.Equal(t, 2, len(spans))
+	assert.Equal(t, codes.Unset, spans[0].StatusCode())
+	assert.Equal(t, codes.Error, spans[1].StatusCode())
+	assert.Equal(t, "test error", spans[1].StatusMessage())
+}
@@ -0,0 +1,450 @@
+// Package feeddo is library API for embedding of feed ingestion into other services.
+// Pipeline fetches feed, parses it into products and delivers them by sink. Scheduler runs pipeline
+// for several feeds periodically. Every step could be replaced by own implementation of its interface.
+package feeddo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/grubastik/feeddo/internal/pkg/kafka"
+	"github.com/grubastik/feeddo/internal/pkg/parser"
+	"github.com/grubastik/feeddo/internal/pkg/payload"
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/grubastik/feeddo/internal/pkg/provider"
+	"github.com/grubastik/feeddo/internal/pkg/routing"
+)
+
+// Item is entity delivered by sink
+type Item interface {
+	// GetContext returns url of feed of item
+	GetContext() string
+	GetID() string
+	// Marshal returns payload of item
+	Marshal() ([]byte, error)
+	// Topics where item is delivered
+	Topics() []string
+}
+
+// Result is result of delivery of item, Err is nil when item was delivered
+type Result struct {
+	ItemContext string
+	ItemID      string
+	Err         error
+}
+
+// Sink delivers items to some output, e.g. kafka
+type Sink interface {
+	// Send delivers item and waits for result. Could be called concurrently.
+	Send(ctx context.Context, item Item) Result
+	Close() error
+}
+
+// KafkaSecurity holds settings for encryption and authentication of connection to kafka, empty values use defaults of client
+type KafkaSecurity struct {
+	// Protocol one of plaintext, ssl, sasl_plaintext, sasl_ssl
+	Protocol string
+	// SASLMechanism one of PLAIN, SCRAM-SHA-256, SCRAM-SHA-512
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+	// CALocation path to CA certificate used to verify broker's certificate
+	CALocation string
+	// CertLocation and KeyLocation paths to client's certificate and private key
+	CertLocation string
+	KeyLocation  string
+}
+
+// KafkaConfig is configuration of kafka sink
+type KafkaConfig struct {
+	// Address is comma separated list of brokers
+	Address string
+	// Driver is "confluent" (librdkafka, requires cgo) or "kafka-go" (pure go), confluent is used when it is empty
+	Driver   string
+	Security KafkaSecurity
+	// MaxRetries is number of attempts to deliver item again after transient error,
+	// delay before each next attempt is doubled starting from RetryBackoff up to MaxRetryBackoff
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// MaxPayload is max size of message in bytes, larger items are not sent. Zero means no limit
+	MaxPayload int
+	// DeliveryTimeout limits how long delivery of item is waited for, retries included. Zero means no limit
+	DeliveryTimeout time.Duration
+}
+
+// Rule overrides topic of items of matched feed or category
+type Rule struct {
+	// Feed is prefix of feed url, empty value matches any feed
+	Feed string
+	// Category is prefix of category of item, empty value matches any category
+	Category string
+	// Topic template, it could contain '{feedhost}' placeholder
+	Topic string
+}
+
+// Fetcher opens stream of feed
+type Fetcher interface {
+	Fetch(ctx context.Context, u *url.URL) (io.ReadCloser, error)
+}
+
+// FetcherFunc is function implementing Fetcher
+type FetcherFunc func(ctx context.Context, u *url.URL) (io.ReadCloser, error)
+
+// Fetch calls f
+func (f FetcherFunc) Fetch(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	return f(ctx, u)
+}
+
+// Parser reads products from stream of feed. Both channels are closed when stream is parsed or ctx is done.
+// Errors of single items are reported without stopping of parsing.
+type Parser interface {
+	Parse(ctx context.Context, r io.Reader) (<-chan Product, <-chan error)
+}
+
+// ParserFunc is function implementing Parser
+type ParserFunc func(ctx context.Context, r io.Reader) (<-chan Product, <-chan error)
+
+// Parse calls f
+func (f ParserFunc) Parse(ctx context.Context, r io.Reader) (<-chan Product, <-chan error) {
+	return f(ctx, r)
+}
+
+var (
+	// DefaultFetcher downloads feed by http(s) or reads local file by file:// url
+	DefaultFetcher Fetcher = FetcherFunc(provider.CreateStream)
+	// DefaultParser parses feed in heureka xml format
+	DefaultParser Parser = ParserFunc(parseHeureka)
+)
+
+// parseHeureka parses heureka xml feed and converts its items into products
+func parseHeureka(ctx context.Context, r io.Reader) (<-chan Product, <-chan error) {
+	items, errs := parser.ProcessFeed(ctx, ioutil.NopCloser(r))
+	products := make(chan Product)
+	go func() {
+		defer close(products)
+		for item := range items {
+			select {
+			case products <- fromProduct(product.FromHeureka(item)):
+			case <-ctx.Done():
+				// parser stops as soon as it notices that ctx is done
+				for range items {
+				}
+				return
+			}
+		}
+	}()
+	return products, errs
+}
+
+// Router selects topics of products, its Topics method could be used as topics of pipeline
+type Router struct {
+	router routing.Router
+}
+
+// NewRouter creates router which sends items into itemsTopic unless one of rules matched and items with bidding additionally into biddingTopic.
+// Topic templates could contain '{feedhost}' placeholder. The first matched rule is used.
+func NewRouter(itemsTopic, biddingTopic string, rules []Rule) (Router, error) {
+	routingRules := make([]routing.Rule, 0, len(rules))
+	for _, r := range rules {
+		routingRules = append(routingRules, routing.Rule{Feed: r.Feed, Category: r.Category, Topic: r.Topic})
+	}
+	r, err := routing.NewRouter(itemsTopic, biddingTopic, routingRules)
+	if err != nil {
+		return Router{}, err
+	}
+	return Router{router: r}, nil
+}
+
+// Topics returns topics of product of feed
+func (r Router) Topics(feed *url.URL, p Product) []string {
+	return r.router.Topics(feed, p.product())
+}
+
+// DefaultTopics sends all products into "shop_items" topic and products with bidding (positive HEUREKA_CPC)
+// additionally into "shop_items_bidding"
+func DefaultTopics(feed *url.URL, p Product) []string {
+	topics := []string{kafka.TopicShopItems}
+	if p.CPC.IsPositive() {
+		topics = append(topics, kafka.TopicShopItemsBidding)
+	}
+	return topics
+}
+
+// NewKafkaSink creates sink producing items into kafka
+func NewKafkaSink(ctx context.Context, c KafkaConfig) (Sink, error) {
+	p, err := kafka.NewKafkaProducer(ctx, c.kafkaConfig())
+	if err != nil {
+		return nil, err
+	}
+	return kafkaSink{producer: p}, nil
+}
+
+// kafkaConfig converts configuration into configuration of kafka producer
+func (c KafkaConfig) kafkaConfig() kafka.Config {
+	return kafka.Config{
+		Address: c.Address,
+		Driver:  c.Driver,
+		Security: kafka.Security{
+			Protocol:      c.Security.Protocol,
+			SASLMechanism: c.Security.SASLMechanism,
+			SASLUsername:  c.Security.SASLUsername,
+			SASLPassword:  c.Security.SASLPassword,
+			CALocation:    c.Security.CALocation,
+			CertLocation:  c.Security.CertLocation,
+			KeyLocation:   c.Security.KeyLocation,
+		},
+		Retry:           kafka.Retry{MaxRetries: c.MaxRetries, InitialBackoff: c.RetryBackoff, MaxBackoff: c.MaxRetryBackoff},
+		MaxPayload:      c.MaxPayload,
+		DeliveryTimeout: c.DeliveryTimeout,
+	}
+}
+
+// kafkaSink adapts kafka producer to Sink
+type kafkaSink struct {
+	producer *kafka.Producer
+}
+
+func (s kafkaSink) Send(ctx context.Context, item Item) Result {
+	r := s.producer.Send(ctx, item)
+	return Result{ItemContext: r.ItemContext, ItemID: r.ItemID, Err: r.Err}
+}
+
+func (s kafkaSink) Close() error {
+	return s.producer.Close()
+}
+
+// Stats is summary of single run of pipeline
+type Stats struct {
+	// Items is number of parsed products
+	Items int
+	// Delivered is number of products delivered by sink
+	Delivered int
+	// Failed is number of products which were not delivered
+	Failed int
+	// ParseErrors is number of items of feed which could not be parsed
+	ParseErrors int
+	// Malformed is number of parsed products skipped because they could not be identified (no ITEM_ID),
+	// they do not fail run like in the app
+	Malformed int
+}
+
+// Pipeline delivers products of feed by sink. Sink is required, other fields have defaults.
+type Pipeline struct {
+	// Fetcher is DefaultFetcher when nil
+	Fetcher Fetcher
+	// Parser is DefaultParser when nil
+	Parser Parser
+	Sink   Sink
+	// Topics returns topics where product of feed is delivered, DefaultTopics when nil
+	Topics func(feed *url.URL, p Product) []string
+	// Workers is number of products delivered concurrently, 1 when not positive
+	Workers int
+	// PriceFormat is format of prices in payload: "string" - quoted, e.g. "269.9", "number" - JSON number,
+	// "object" - object with amount and PriceCurrency. "string" is used when it is empty, the same as by the app
+	PriceFormat   string
+	PriceCurrency string
+	// URLFormat is format of urls in payload: "object" - parts of url.URL, "string" - url as string.
+	// "object" is used when it is empty, the same as by the app
+	URLFormat string
+}
+
+// Run fetches feed, parses it and delivers its products. Stats are returned even when run failed.
+// Error is returned when feed could not be fetched, some items could not be parsed or delivered - the first of errors is returned.
+func (p *Pipeline) Run(ctx context.Context, u *url.URL) (Stats, error) {
+	if p.Sink == nil {
+		return Stats{}, errors.New("Sink of pipeline is not configured")
+	}
+	fetcher, prs, topics, workers := p.Fetcher, p.Parser, p.Topics, p.Workers
+	if fetcher == nil {
+		fetcher = DefaultFetcher
+	}
+	if prs == nil {
+		prs = DefaultParser
+	}
+	if topics == nil {
+		topics = DefaultTopics
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	priceFormat, urlFormat := p.PriceFormat, p.URLFormat
+	if priceFormat == "" {
+		priceFormat = payload.DecimalString
+	}
+	if urlFormat == "" {
+		urlFormat = string(payload.URLObject)
+	}
+	decimals, err := payload.NewDecimalFormat(priceFormat, p.PriceCurrency)
+	if err != nil {
+		return Stats{}, fmt.Errorf("Wrong price format of pipeline: %w", err)
+	}
+	urls, err := payload.ParseURLFormat(urlFormat)
+	if err != nil {
+		return Stats{}, fmt.Errorf("Wrong url format of pipeline: %w", err)
+	}
+	stream, err := fetcher.Fetch(ctx, u)
+	if err != nil {
+		return Stats{}, fmt.Errorf("Unable to fetch feed '%s' because of %w", u, err)
+	}
+	defer stream.Close()
+
+	var stats Stats
+	var parseErr error
+	items := make(chan Item)
+	go func() {
+		defer close(items)
+		products, errs := prs.Parse(ctx, stream)
+		for products != nil || errs != nil {
+			select {
+			case pr, ok := <-products:
+				if !ok {
+					products = nil
+					continue
+				}
+				if pr.ID == "" {
+					// product without id could not be keyed, so it is not delivered
+					stats.Malformed++
+					continue
+				}
+				stats.Items++
+				items <- pipelineItem{feed: u, product: pr, topics: topics(u, pr), decimals: decimals, urls: urls}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				stats.ParseErrors++
+				if parseErr == nil {
+					parseErr = fmt.Errorf("Unable to parse feed '%s' because of %w", u, err)
+				}
+			}
+		}
+	}()
+
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				results <- p.Sink.Send(ctx, item)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	var deliveryErr error
+	for r := range results {
+		if r.Err != nil {
+			stats.Failed++
+			if deliveryErr == nil {
+				deliveryErr = fmt.Errorf("Unable to deliver item %s of feed '%s' because of %w", r.ItemID, u, r.Err)
+			}
+			continue
+		}
+		stats.Delivered++
+	}
+	// parsing is finished when all results are received
+	if parseErr != nil {
+		return stats, parseErr
+	}
+	return stats, deliveryErr
+}
+
+// pipelineItem is product of feed delivered as json in the same shape as the app sends it
+type pipelineItem struct {
+	feed     *url.URL
+	product  Product
+	topics   []string
+	decimals payload.DecimalFormat
+	urls     payload.URLFormat
+}
+
+func (i pipelineItem) GetContext() string {
+	return i.feed.String()
+}
+
+func (i pipelineItem) GetID() string {
+	return i.product.ID
+}
+
+func (i pipelineItem) Marshal() ([]byte, error) {
+	return payload.Encode(i.product.product(), i.decimals, i.urls), nil
+}
+
+func (i pipelineItem) Topics() []string {
+	return i.topics
+}
+
+// Feed is feed processed by scheduler. Zero interval means that feed is processed only once.
+type Feed struct {
+	URL      *url.URL
+	Interval time.Duration
+}
+
+// Scheduler runs pipeline for every feed periodically. Runs of the same feed never overlap.
+type Scheduler struct {
+	Pipeline *Pipeline
+	Feeds    []Feed
+	// OnRun is called (concurrently for different feeds) after every run when it is set
+	OnRun func(feed *url.URL, s Stats, err error)
+}
+
+// Run processes feeds till ctx is done or every feed without interval is processed
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.Pipeline == nil {
+		return errors.New("Pipeline of scheduler is not configured")
+	}
+	for _, f := range s.Feeds {
+		if f.URL == nil {
+			return errors.New("Feed url of scheduler is not configured")
+		}
+		if f.Interval < 0 {
+			return fmt.Errorf("Interval of feed '%s' should not be negative", f.URL)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, f := range s.Feeds {
+		wg.Add(1)
+		go func(f Feed) {
+			defer wg.Done()
+			s.runFeed(ctx, f)
+		}(f)
+	}
+	wg.Wait()
+	return nil
+}
+
+// runFeed runs pipeline for feed once and then on every tick of its interval
+func (s *Scheduler) runFeed(ctx context.Context, f Feed) {
+	run := func() {
+		stats, err := s.Pipeline.Run(ctx, f.URL)
+		if s.OnRun != nil {
+			s.OnRun(f.URL, stats, err)
+		}
+	}
+	run()
+	if f.Interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			run()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package feeddo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sinkMock records delivered items, items with ids from fail are not delivered
+type sinkMock struct {
+	mu       sync.Mutex
+	fail     map[string]bool
+	topics   map[string][]string
+	payloads map[string]string
+}
+
+func (s *sinkMock) Send(ctx context.Context, item Item) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := Result{ItemContext: item.GetContext(), ItemID: item.GetID()}
+	if s.fail[item.GetID()] {
+		r.Err = errors.New("test error")
+		return r
+	}
+	data, err := item.Marshal()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if s.topics == nil {
+		s.topics = map[string][]string{}
+		s.payloads = map[string]string{}
+	}
+	s.topics[item.GetID()] = item.Topics()
+	s.payloads[item.GetID()] = string(data)
+	return r
+}
+
+func (s *sinkMock) Close() error {
+	return nil
+}
+
+func TestPipelineRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		feed   string
+		fail   map[string]bool
+		stats  Stats
+		topics map[string][]string
+		err    string
+	}{
+		{"delivered", "file://testdata/feed.xml", nil, Stats{Items: 2, Delivered: 2},
+			map[string][]string{"34644": {"shop_items", "shop_items_bidding"}, "34645": {"shop_items"}}, ""},
+		{"not delivered", "file://testdata/feed.xml", map[string]bool{"34644": true}, Stats{Items: 2, Delivered: 1, Failed: 1},
+			map[string][]string{"34645": {"shop_items"}}, "Unable to deliver item 34644 of feed 'file://testdata/feed.xml' because of test error"},
+		{"without id", "file://testdata/no_id.xml", nil, Stats{Items: 1, Delivered: 1, Malformed: 1},
+			map[string][]string{"34645": {"shop_items"}}, ""},
+		{"not parsed", "file://testdata/bad.xml", nil, Stats{ParseErrors: 2},
+			nil, "Unable to parse feed 'file://testdata/bad.xml' because of Failed to get item from stream"},
+		{"not fetched", "file://testdata/missing.xml", nil, Stats{},
+			nil, "Unable to fetch feed 'file://testdata/missing.xml'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.feed)
+			require.NoError(t, err)
+			s := &sinkMock{fail: tt.fail}
+			p := Pipeline{Sink: s, Workers: 2}
+			stats, err := p.Run(context.Background(), u)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+			}
+			assert.Equal(t, tt.stats, stats)
+			assert.Equal(t, tt.topics, s.topics)
+		})
+	}
+}
+
+func TestPipelineRunCustom(t *testing.T) {
+	u, err := url.Parse("http://example.com/feed.csv")
+	require.NoError(t, err)
+	fetched := ""
+	p := Pipeline{
+		Fetcher: FetcherFunc(func(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+			fetched = u.String()
+			return DefaultFetcher.Fetch(ctx, &url.URL{Scheme: "file", Host: "testdata", Path: "/feed.xml"})
+		}),
+		Parser: ParserFunc(func(ctx context.Context, r io.Reader) (<-chan Product, <-chan error) {
+			products := make(chan Product, 1)
+			errs := make(chan error)
+			products <- Product{ID: "custom"}
+			close(products)
+			close(errs)
+			return products, errs
+		}),
+		Topics: func(feed *url.URL, p Product) []string {
+			return []string{feed.Host + "." + p.ID}
+		},
+		Sink: &sinkMock{},
+	}
+	stats, err := p.Run(context.Background(), u)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Items: 1, Delivered: 1}, stats)
+	assert.Equal(t, "http://example.com/feed.csv", fetched)
+	assert.Equal(t, map[string][]string{"custom": {"example.com.custom"}}, p.Sink.(*sinkMock).topics)
+}
+
+func TestPipelineRunPayload(t *testing.T) {
+	u, err := url.Parse("file://testdata/feed.xml")
+	require.NoError(t, err)
+	tests := []struct {
+		name     string
+		pipeline Pipeline
+		contains []string
+		err      string
+	}{
+		{"defaults of app", Pipeline{}, []string{`"url":{"Scheme":"http",`, `"priceWithVat":"269"`}, ""},
+		{"formats", Pipeline{PriceFormat: "object", PriceCurrency: "CZK", URLFormat: "string"},
+			[]string{`"url":"http://www.czc.cz/`, `"priceWithVat":{"amount":"269","currency":"CZK"}`}, ""},
+		{"wrong price format", Pipeline{PriceFormat: "float"}, nil,
+			"Wrong price format of pipeline: Decimal format 'float' is not supported, use 'string', 'number' or 'object'"},
+		{"wrong url format", Pipeline{URLFormat: "text"}, nil,
+			"Wrong url format of pipeline: URL format 'text' is not supported, use 'object' or 'string'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &sinkMock{}
+			tt.pipeline.Sink = s
+			_, err := tt.pipeline.Run(context.Background(), u)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			for _, c := range tt.contains {
+				assert.Contains(t, s.payloads["34644"], c)
+			}
+		})
+	}
+}
+
+func TestPipelineRunWithoutSink(t *testing.T) {
+	_, err := (&Pipeline{}).Run(context.Background(), &url.URL{})
+	assert.EqualError(t, err, "Sink of pipeline is not configured")
+}
+
+func TestSchedulerRun(t *testing.T) {
+	once, err := url.Parse("file://testdata/feed.xml")
+	require.NoError(t, err)
+	periodic, err := url.Parse("file://testdata/bad.xml")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	runs := map[string]int{}
+	s := Scheduler{
+		Pipeline: &Pipeline{Sink: &sinkMock{}},
+		Feeds:    []Feed{{URL: once}, {URL: periodic, Interval: 10 * time.Millisecond}},
+		OnRun: func(feed *url.URL, stats Stats, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			runs[feed.String()]++
+			if feed == periodic && runs[feed.String()] == 3 {
+				cancel()
+			}
+		},
+	}
+	require.NoError(t, s.Run(ctx))
+	assert.Equal(t, map[string]int{once.String(): 1, periodic.String(): 3}, runs)
+}
+
+func TestSchedulerRunErrors(t *testing.T) {
+	u, err := url.Parse("file://testdata/feed.xml")
+	require.NoError(t, err)
+	tests := []struct {
+		name string
+		s    Scheduler
+		err  string
+	}{
+		{"no pipeline", Scheduler{Feeds: []Feed{{URL: u}}}, "Pipeline of scheduler is not configured"},
+		{"no url", Scheduler{Pipeline: &Pipeline{}, Feeds: []Feed{{}}}, "Feed url of scheduler is not configured"},
+		{"negative interval", Scheduler{Pipeline: &Pipeline{}, Feeds: []Feed{{URL: u, Interval: -time.Second}}}, "Interval of feed 'file://testdata/feed.xml' should not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, tt.s.Run(context.Background()), tt.err)
+		})
+	}
+}
+
+func TestNewRouter(t *testing.T) {
+	r, err := NewRouter("items", "bidding", []Rule{{Category: "Heureka.cz | Elektronika", Topic: "electronics"}})
+	require.NoError(t, err)
+	u, err := url.Parse("file://testdata/feed.xml")
+	require.NoError(t, err)
+	s := &sinkMock{}
+	_, err = (&Pipeline{Sink: s, Topics: r.Topics}).Run(context.Background(), u)
+	require.NoError(t, err)
+	ids := []string{}
+	for id := range s.topics {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	assert.Equal(t, []string{"34644", "34645"}, ids)
+	assert.Equal(t, []string{"electronics", "bidding"}, s.topics["34644"])
+	assert.Equal(t, []string{"items"}, s.topics["34645"])
+}
+
+func TestDefaultTopics(t *testing.T) {
+	u, err := url.Parse("file://testdata/feed.xml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shop_items", "shop_items_bidding"}, DefaultTopics(u, Product{ID: "1", CPC: decimal.NewFromFloat(1.5)}))
+	assert.Equal(t, []string{"shop_items"}, DefaultTopics(u, Product{ID: "2"}))
+	// the same as bidding rule HEUREKA_CPC>0 of router
+	assert.Equal(t, []string{"shop_items"}, DefaultTopics(u, Product{ID: "3", CPC: decimal.NewFromInt(-1)}))
+}
+
+func TestNewKafkaSink(t *testing.T) {
+	c := KafkaConfig{
+		Address:         "localhost:9092",
+		Driver:          "kafka-go",
+		Security:        KafkaSecurity{Protocol: "sasl_plaintext", SASLMechanism: "PLAIN", SASLUsername: "user", SASLPassword: "pass"},
+		MaxRetries:      3,
+		RetryBackoff:    time.Millisecond,
+		MaxRetryBackoff: time.Second,
+		MaxPayload:      1000,
+		DeliveryTimeout: time.Minute,
+	}
+	kc := c.kafkaConfig()
+	assert.Equal(t, "localhost:9092", kc.Address)
+	assert.Equal(t, "kafka-go", kc.Driver)
+	assert.Equal(t, "PLAIN", kc.Security.SASLMechanism)
+	assert.Equal(t, "pass", kc.Security.SASLPassword)
+	assert.Equal(t, 3, kc.Retry.MaxRetries)
+	assert.Equal(t, time.Second, kc.Retry.MaxBackoff)
+	assert.Equal(t, 1000, kc.MaxPayload)
+	assert.Equal(t, time.Minute, kc.DeliveryTimeout)
+
+	s, err := NewKafkaSink(context.Background(), c)
+	require.NoError(t, err)
+	assert.NoError(t, s.Close())
+
+	_, err = NewKafkaSink(context.Background(), KafkaConfig{Address: "localhost:9092", Driver: "abc"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package feeddo
+
+import (
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+)
+
+// Product is item of feed delivered by sinks. It is converted from internal model of app,
+// so changes of the model do not change API of library.
+type Product struct {
+	ID                   string          `json:"id"`
+	Name                 string          `json:"name"`
+	Title                string          `json:"product"`
+	Description          string          `json:"description"`
+	URL                  string          `json:"url"`
+	ImageURL             string          `json:"imageUrl"`
+	AlternativeImageURLs []string        `json:"imageUrlsAlternate"`
+	VideoURL             string          `json:"videoUrl"`
+	PriceVAT             decimal.Decimal `json:"priceWithVat"`
+	VAT                  string          `json:"vat"`
+	Type                 string          `json:"type"`
+	CPC                  decimal.Decimal `json:"cpc"`
+	Manufacturer         string          `json:"manufacterer"`
+	Category             string          `json:"category"`
+	EAN                  string          `json:"ean"`
+	ISBN                 string          `json:"isbn"`
+	Parameters           []Parameter     `json:"parameters"`
+	DeliveryDate         string          `json:"deliveryDay"`
+	Deliveries           []Delivery      `json:"deliveries"`
+	GroupID              string          `json:"groupId"`
+	Accessories          []string        `json:"accessories"`
+	Dues                 decimal.Decimal `json:"dues"`
+	Gifts                []Gift          `json:"gifts"`
+}
+
+// Parameter is parameter of product, e.g. color
+type Parameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Delivery is delivery option of product
+type Delivery struct {
+	ID       string          `json:"id"`
+	Price    decimal.Decimal `json:"price"`
+	PriceCOD decimal.Decimal `json:"priceCod"`
+}
+
+// Gift is item which is added to the order for free
+type Gift struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// fromProduct converts internal model into product of library
+func fromProduct(p product.Product) Product {
+	r := Product{
+		ID:                   p.ID,
+		Name:                 p.Name,
+		Title:                p.Title,
+		Description:          p.Description,
+		URL:                  p.URL,
+		ImageURL:             p.ImageURL,
+		AlternativeImageURLs: p.AlternativeImageURLs,
+		VideoURL:             p.VideoURL,
+		PriceVAT:             p.PriceVAT,
+		VAT:                  p.VAT,
+		Type:                 p.Type,
+		CPC:                  p.CPC,
+		Manufacturer:         p.Manufacturer,
+		Category:             p.Category,
+		EAN:                  p.EAN,
+		ISBN:                 p.ISBN,
+		DeliveryDate:         p.DeliveryDate,
+		GroupID:              p.GroupID,
+		Accessories:          p.Accessories,
+		Dues:                 p.Dues,
+	}
+	if p.Parameters != nil {
+		r.Parameters = make([]Parameter, len(p.Parameters))
+		for i, param := range p.Parameters {
+			r.Parameters[i] = Parameter{Name: param.Name, Value: param.Value}
+		}
+	}
+	if p.Deliveries != nil {
+		r.Deliveries = make([]Delivery, len(p.Deliveries))
+		for i, d := range p.Deliveries {
+			r.Deliveries[i] = Delivery{ID: d.ID, Price: d.Price, PriceCOD: d.PriceCOD}
+		}
+	}
+	if p.Gifts != nil {
+		r.Gifts = make([]Gift, len(p.Gifts))
+		for i, g := range p.Gifts {
+			r.Gifts[i] = Gift{ID: g.ID, Name: g.Name}
+		}
+	}
+	return r
+}
+
+// product converts product of library into internal model, e.g. for routing or encoding of payload
+func (p Product) product() product.Product {
+	r := product.Product{
+		ID:                   p.ID,
+		Name:                 p.Name,
+		Title:                p.Title,
+		Description:          p.Description,
+		URL:                  p.URL,
+		ImageURL:             p.ImageURL,
+		AlternativeImageURLs: p.AlternativeImageURLs,
+		VideoURL:             p.VideoURL,
+		PriceVAT:             p.PriceVAT,
+		VAT:                  p.VAT,
+		Type:                 p.Type,
+		CPC:                  p.CPC,
+		Manufacturer:         p.Manufacturer,
+		Category:             p.Category,
+		EAN:                  p.EAN,
+		ISBN:                 p.ISBN,
+		DeliveryDate:         p.DeliveryDate,
+		GroupID:              p.GroupID,
+		Accessories:          p.Accessories,
+		Dues:                 p.Dues,
+	}
+	if p.Parameters != nil {
+		r.Parameters = make([]product.Parameter, len(p.Parameters))
+		for i, param := range p.Parameters {
+			r.Parameters[i] = product.Parameter{Name: param.Name, Value: param.Value}
+		}
+	}
+	if p.Deliveries != nil {
+		r.Deliveries = make([]product.Delivery, len(p.Deliveries))
+		for i, d := range p.Deliveries {
+			r.Deliveries[i] = product.Delivery{ID: d.ID, Price: d.Price, PriceCOD: d.PriceCOD}
+		}
+	}
+	if p.Gifts != nil {
+		r.Gifts = make([]product.Gift, len(p.Gifts))
+		for i, g := range p.Gifts {
+			r.Gifts[i] = product.Gift{ID: g.ID, Name: g.Name}
+		}
+	}
+	return r
+}
@@ -0,0 +1,52 @@
+package feeddo
+
+import (
+	"testing"
+
+	"github.com/grubastik/feeddo/internal/pkg/product"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductConversion(t *testing.T) {
+	tests := []struct {
+		name    string
+		product product.Product
+	}{
+		{"empty", product.Product{}},
+		{"all fields", product.Product{
+			ID:                   "34644",
+			Name:                 "Epson T061240",
+			Title:                "Epson T061240, azurová",
+			Description:          "Cartridge",
+			URL:                  "http://www.czc.cz/34644/produkt",
+			ImageURL:             "https://iczc.cz/obrazek",
+			AlternativeImageURLs: []string{"https://iczc.cz/1/obrazek"},
+			VideoURL:             "https://www.youtube.com/watch?v=1",
+			PriceVAT:             decimal.New(26990, -2),
+			VAT:                  "21%",
+			Type:                 "bazar",
+			CPC:                  decimal.New(15, -1),
+			Manufacturer:         "Epson",
+			Category:             "Heureka.cz | Elektronika",
+			EAN:                  "8715946360188",
+			ISBN:                 "9788071391601",
+			Parameters:           []product.Parameter{{Name: "Objem", Value: "8 ml"}},
+			DeliveryDate:         "0",
+			Deliveries:           []product.Delivery{{ID: "PPL", Price: decimal.New(99, 0), PriceCOD: decimal.New(129, 0)}},
+			GroupID:              "T0612",
+			Accessories:          []string{"34645"},
+			Dues:                 decimal.New(5, 0),
+			Gifts:                []product.Gift{{ID: "1", Name: "Papír"}},
+		}},
+		{"empty lists", product.Product{Parameters: []product.Parameter{}, Deliveries: []product.Delivery{}, Gifts: []product.Gift{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := fromProduct(tt.product)
+			assert.Equal(t, tt.product.ID, p.ID)
+			assert.Equal(t, len(tt.product.Parameters), len(p.Parameters))
+			assert.Equal(t, tt.product, p.product())
+		})
+	}
+}